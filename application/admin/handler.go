@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"stream/middleware"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadFunc re-reads the configuration and applies it to the running
+// services. On error the previous configuration must stay in effect.
+type ReloadFunc func() error
+
+// Handler serves administrative endpoints guarded by a shared token
+type Handler struct {
+	token  string
+	reload ReloadFunc
+}
+
+// NewHandler creates a new Handler. Requests must send the token as
+// "Authorization: Bearer <token>"; an empty token rejects every request.
+func NewHandler(token string, reload ReloadFunc) *Handler {
+	return &Handler{token: token, reload: reload}
+}
+
+// RegisterRoutes registers the admin routes
+func (h *Handler) RegisterRoutes(r gin.IRouter) {
+	admin := r.Group("/admin", h.requireToken)
+	{
+		admin.POST("/reload", h.Reload)
+	}
+}
+
+// requireToken aborts requests without the admin token
+func (h *Handler) requireToken(c *gin.Context) {
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if h.token == "" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		send := c.MustGet("send").(func(middleware.Response))
+		send(middleware.Response{
+			Code:    http.StatusUnauthorized,
+			Message: "Invalid admin token",
+			Error:   errors.New("invalid admin token"),
+		})
+		return
+	}
+	c.Next()
+}
+
+// Reload handles the POST /admin/reload endpoint: it re-reads operator
+// dictionaries and config. Streams already running keep their snapshot.
+func (h *Handler) Reload(c *gin.Context) {
+	send := c.MustGet("send").(func(middleware.Response))
+
+	if err := h.reload(); err != nil {
+		send(middleware.Response{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "Reload failed, previous configuration kept: " + err.Error(),
+			Error:   err,
+		})
+		return
+	}
+
+	send(middleware.Response{
+		Code:    http.StatusOK,
+		Message: "Configuration reloaded",
+	})
+}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"stream/middleware"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupTestRouter(token string, reload ReloadFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+	NewHandler(token, reload).RegisterRoutes(r)
+	return r
+}
+
+func performReload(r *gin.Engine, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_Reload(t *testing.T) {
+	reloads := 0
+	r := setupTestRouter("secret", func() error {
+		reloads++
+		return nil
+	})
+
+	t.Run("valid token reloads", func(t *testing.T) {
+		w := performReload(r, "Bearer secret")
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if reloads != 1 {
+			t.Errorf("Expected 1 reload, got %d", reloads)
+		}
+	})
+
+	for name, authorization := range map[string]string{
+		"missing token":   "",
+		"wrong token":     "Bearer wrong",
+		"token as scheme": "secret",
+	} {
+		t.Run(name+" is rejected", func(t *testing.T) {
+			before := reloads
+			w := performReload(r, authorization)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d: %s", w.Code, w.Body.String())
+			}
+			if reloads != before {
+				t.Error("Expected no reload without a valid token")
+			}
+		})
+	}
+
+	t.Run("empty configured token rejects everything", func(t *testing.T) {
+		r := setupTestRouter("", func() error { return nil })
+
+		if w := performReload(r, "Bearer "); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("failed reload is reported", func(t *testing.T) {
+		r := setupTestRouter("secret", func() error { return errors.New("dictionary status.yaml: malformed") })
+
+		w := performReload(r, "Bearer secret")
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected status 422, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
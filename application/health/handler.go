@@ -1,6 +1,7 @@
 package health
 
 import (
+	"errors"
 	"net/http"
 	"stream/middleware"
 
@@ -21,6 +22,7 @@ func (h *Handler) RegisterRoutes(api *gin.RouterGroup) {
 		health.GET("", h.HealthCheck)
 		health.GET("/stream", h.HealthCheckStream)
 	}
+	api.GET("/readyz", h.Readiness)
 }
 
 func (h *Handler) HealthCheck(c *gin.Context) {
@@ -43,6 +45,29 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 	})
 }
 
+// Readiness handles GET /readyz: 200 when the service can stream, 503 while
+// a database is down. The body holds the status of each database.
+func (h *Handler) Readiness(c *gin.Context) {
+	send := c.MustGet("send").(func(middleware.Response))
+
+	response, ready := h.svc.CheckReadiness()
+	if !ready {
+		send(middleware.Response{
+			Code:    http.StatusServiceUnavailable,
+			Message: "Not ready",
+			Data:    response,
+			Error:   errors.New("database unavailable"),
+		})
+		return
+	}
+
+	send(middleware.Response{
+		Code:    http.StatusOK,
+		Message: "Ready",
+		Data:    response,
+	})
+}
+
 func (h *Handler) HealthCheckStream(c *gin.Context) {
 	sendStream := c.MustGet("sendStream").(func(middleware.StreamResponse))
 
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Pinger is a database the Monitor checks and resets
+type Pinger interface {
+	Ping() error
+	Reset() error
+}
+
+// MonitorConfig configures the background health check of a database.
+type MonitorConfig struct {
+	// Interval is the time between two pings.
+	//
+	// Default: 10 seconds
+	Interval time.Duration
+
+	// FailureThreshold is the number of consecutive failed pings after which
+	// the database is reported not ready and the pool is reset.
+	//
+	// Default: 3
+	FailureThreshold int
+
+	// MaxBackoff caps the time between two pool resets, which starts at
+	// Interval and doubles while the pings keep failing.
+	//
+	// Default: 5 minutes
+	MaxBackoff time.Duration
+}
+
+// MonitorStatus is the state of a monitored database, served by /readyz
+type MonitorStatus struct {
+	Ready               bool      `json:"ready"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastCheck           time.Time `json:"lastCheck"`
+	LastError           string    `json:"lastError,omitempty"`
+	Resets              int       `json:"resets"`
+}
+
+// Monitor periodically pings a database and resets its connection pool when
+// the pings keep failing, so a pool gone stale after a database restart
+// recovers without restarting the process.
+//
+// Implementation Notes:
+//   - The database is ready until FailureThreshold consecutive pings fail,
+//     and ready again after the first successful ping
+//   - Resets are retried with exponential backoff (Interval doubling up to
+//     MaxBackoff) and the backoff starts over once a ping succeeds
+//   - Start and Stop are idempotent; Stop waits for a running check and is a
+//     no-op for a monitor that was never started
+type Monitor struct {
+	pinger Pinger
+	config MonitorConfig
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	status    MonitorStatus
+	backoff   time.Duration
+	nextReset time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewMonitor creates a Monitor of pinger from config. The database is
+// reported ready until the checks prove otherwise.
+func NewMonitor(pinger Pinger, config MonitorConfig, logger *zap.Logger) *Monitor {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Minute
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Monitor{
+		pinger:  pinger,
+		config:  config,
+		logger:  logger,
+		now:     time.Now,
+		status:  MonitorStatus{Ready: true},
+		backoff: config.Interval,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start runs a check immediately and then every Interval in a background goroutine
+func (m *Monitor) Start() {
+	m.startOnce.Do(func() {
+		go m.run()
+	})
+}
+
+func (m *Monitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.Check()
+
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the background goroutine and waits for it to exit
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+
+	// Never started: nothing to wait for, and Start becomes a no-op
+	m.startOnce.Do(func() {
+		close(m.done)
+	})
+	<-m.done
+}
+
+// Check pings the database once, updates the status and resets the pool
+// when the failure threshold is reached and the backoff has elapsed
+func (m *Monitor) Check() {
+	err := m.pinger.Ping()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.status.LastCheck = now
+
+	if err == nil {
+		if !m.status.Ready {
+			m.logger.Info("Database recovered", zap.Int("failed_pings", m.status.ConsecutiveFailures))
+		}
+		m.status.Ready = true
+		m.status.ConsecutiveFailures = 0
+		m.status.LastError = ""
+		m.backoff = m.config.Interval
+		m.nextReset = time.Time{}
+		return
+	}
+
+	m.status.ConsecutiveFailures++
+	m.status.LastError = err.Error()
+	if m.status.ConsecutiveFailures < m.config.FailureThreshold {
+		return
+	}
+
+	if m.status.Ready {
+		m.logger.Warn("Database not ready", zap.Int("failed_pings", m.status.ConsecutiveFailures), zap.Error(err))
+	}
+	m.status.Ready = false
+
+	if now.Before(m.nextReset) {
+		return
+	}
+	m.status.Resets++
+	if err := m.pinger.Reset(); err != nil {
+		m.logger.Warn("Connection pool reset failed", zap.Error(err))
+	}
+	m.nextReset = now.Add(m.backoff)
+	m.backoff = min(m.backoff*2, m.config.MaxBackoff)
+}
+
+// Ready reports whether the database answered the recent pings
+func (m *Monitor) Ready() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status.Ready
+}
+
+// Status returns the current state of the database
+func (m *Monitor) Status() MonitorStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
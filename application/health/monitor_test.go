@@ -0,0 +1,179 @@
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"stream/middleware"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakePinger fails its pings while down is set and counts the resets
+type fakePinger struct {
+	down   atomic.Bool
+	resets atomic.Int32
+}
+
+func (p *fakePinger) Ping() error {
+	if p.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (p *fakePinger) Reset() error {
+	p.resets.Add(1)
+	return nil
+}
+
+func TestMonitor(t *testing.T) {
+	t.Run("readiness flips after failures and recovers", func(t *testing.T) {
+		pinger := &fakePinger{}
+		monitor := NewMonitor(pinger, MonitorConfig{Interval: time.Second, FailureThreshold: 2, MaxBackoff: 4 * time.Second}, nil)
+		now := time.Now()
+		monitor.now = func() time.Time { return now }
+
+		// check advances the clock by d and checks once
+		check := func(d time.Duration) MonitorStatus {
+			now = now.Add(d)
+			monitor.Check()
+			return monitor.Status()
+		}
+
+		if status := check(0); !status.Ready {
+			t.Fatalf("status = %+v, want ready", status)
+		}
+
+		pinger.down.Store(true)
+		if status := check(time.Second); !status.Ready || status.ConsecutiveFailures != 1 {
+			t.Errorf("after 1 failure: status = %+v, want ready below the threshold", status)
+		}
+		status := check(time.Second)
+		if status.Ready || status.LastError != "connection refused" {
+			t.Errorf("after 2 failures: status = %+v, want not ready", status)
+		}
+		if pinger.resets.Load() != 1 {
+			t.Errorf("resets = %d, want 1 once the threshold is reached", pinger.resets.Load())
+		}
+
+		// Resets back off: 1s after the first one, then 2s, then 4s
+		wantResets := []int32{1, 2, 2, 2, 2, 3, 3, 3}
+		for i, want := range wantResets {
+			check(500 * time.Millisecond)
+			if got := pinger.resets.Load(); got != want {
+				t.Errorf("after %v: resets = %d, want %d", time.Duration(i+1)*500*time.Millisecond, got, want)
+			}
+		}
+		if monitor.Ready() {
+			t.Error("monitor should stay not ready while the pings fail")
+		}
+
+		pinger.down.Store(false)
+		if status := check(time.Second); !status.Ready || status.ConsecutiveFailures != 0 || status.LastError != "" {
+			t.Errorf("after recovery: status = %+v, want ready", status)
+		}
+
+		// The backoff starts over after a recovery
+		pinger.down.Store(true)
+		check(time.Second)
+		check(time.Second)
+		check(time.Second)
+		if got := pinger.resets.Load(); got != 5 {
+			t.Errorf("resets = %d, want 5 with the backoff restarted at 1s", got)
+		}
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		pinger := &fakePinger{}
+		pinger.down.Store(true)
+		monitor := NewMonitor(pinger, MonitorConfig{Interval: 5 * time.Millisecond, FailureThreshold: 1}, nil)
+
+		monitor.Start()
+		deadline := time.Now().Add(time.Second)
+		for monitor.Ready() {
+			if time.Now().After(deadline) {
+				t.Fatal("failing pings did not make the monitor not ready")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		pinger.down.Store(false)
+		for !monitor.Ready() {
+			if time.Now().After(deadline) {
+				t.Fatal("monitor did not recover")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		monitor.Stop()
+		monitor.Stop()
+
+		NewMonitor(pinger, MonitorConfig{}, nil).Stop()
+	})
+}
+
+func TestHandler_Readiness(t *testing.T) {
+	openDB := func(t *testing.T) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatalf("Failed to connect database: %v", err)
+		}
+		return db
+	}
+
+	router := func(svc *Service) *gin.Engine {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.RequestInit())
+		r.Use(middleware.ResponseInit())
+		NewHandler(svc).RegisterRoutes(r.Group(""))
+		return r
+	}
+	readyz := func(r *gin.Engine) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w
+	}
+
+	t.Run("follows the real database monitor", func(t *testing.T) {
+		pinger := &fakePinger{}
+		monitor := NewMonitor(pinger, MonitorConfig{FailureThreshold: 2}, nil)
+		svc := NewService(NewRepository(openDB(t)), NewRepository(openDB(t)))
+		svc.SetRealMonitor(monitor)
+		r := router(svc)
+
+		if w := readyz(r); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+
+		pinger.down.Store(true)
+		monitor.Check()
+		monitor.Check()
+		w := readyz(r)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %d, want 503: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"consecutiveFailures":2`) {
+			t.Errorf("body should hold the monitor status: %s", w.Body.String())
+		}
+
+		pinger.down.Store(false)
+		monitor.Check()
+		if w := readyz(r); w.Code != http.StatusOK {
+			t.Errorf("status after recovery = %d, want 200: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("real database not configured is ready", func(t *testing.T) {
+		w := readyz(router(NewService(NewRepository(openDB(t)), NewRepository(nil))))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"real_database":"not_configured"`) {
+			t.Errorf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -1,19 +1,53 @@
 package health
 
-import "gorm.io/gorm"
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotConfigured is returned by Ping for a database that is not configured
+var ErrNotConfigured = errors.New("database not configured")
 
 type Repository struct {
-	db *gorm.DB
+	db           *gorm.DB
+	maxIdleConns int
 }
 
+// NewRepository returns a repository pinging db; db may be nil when the
+// database is not configured
 func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{db: db, maxIdleConns: 2} // database/sql's default
+}
+
+// SetMaxIdleConns sets the idle pool size Reset restores, i.e. the one the
+// database was opened with
+func (r *Repository) SetMaxIdleConns(n int) {
+	r.maxIdleConns = n
 }
 
 func (r *Repository) Ping() error {
+	if r.db == nil {
+		return ErrNotConfigured
+	}
 	sqlDB, err := r.db.DB()
 	if err != nil {
 		return err
 	}
 	return sqlDB.Ping()
 }
+
+// Reset closes the idle connections of the pool, which may all be stale
+// after a database restart, so the next queries open fresh ones
+func (r *Repository) Reset() error {
+	if r.db == nil {
+		return ErrNotConfigured
+	}
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxIdleConns(0)
+	sqlDB.SetMaxIdleConns(r.maxIdleConns)
+	return nil
+}
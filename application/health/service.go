@@ -1,14 +1,16 @@
 package health
 
 import (
+	"errors"
 	"stream/middleware"
 
 	json "github.com/json-iterator/go"
 )
 
 type Service struct {
-	dummyRepo *Repository
-	realRepo  *Repository
+	dummyRepo   *Repository
+	realRepo    *Repository
+	realMonitor *Monitor
 }
 
 func NewService(dummyRepo *Repository, realRepo *Repository) *Service {
@@ -18,6 +20,43 @@ func NewService(dummyRepo *Repository, realRepo *Repository) *Service {
 	}
 }
 
+// SetRealMonitor makes readiness follow the background checks of the real
+// database instead of pinging it per request
+func (s *Service) SetRealMonitor(monitor *Monitor) {
+	s.realMonitor = monitor
+}
+
+// CheckReadiness reports whether the service can stream: the dummy database
+// answers and the real database is ready or not configured. The result holds
+// the status of each database.
+func (s *Service) CheckReadiness() (map[string]interface{}, bool) {
+	result := make(map[string]interface{})
+	ready := true
+
+	if err := s.dummyRepo.Ping(); err != nil {
+		result["dummy_database"] = "error"
+		ready = false
+	} else {
+		result["dummy_database"] = "ok"
+	}
+
+	switch {
+	case s.realRepo.db == nil:
+		result["real_database"] = "not_configured"
+	case s.realMonitor != nil:
+		status := s.realMonitor.Status()
+		result["real_database"] = status
+		ready = ready && status.Ready
+	case s.realRepo.Ping() != nil:
+		result["real_database"] = "error"
+		ready = false
+	default:
+		result["real_database"] = "ok"
+	}
+
+	return result, ready
+}
+
 func (s *Service) CheckHealth() (map[string]string, error) {
 	result := make(map[string]string)
 
@@ -31,7 +70,9 @@ func (s *Service) CheckHealth() (map[string]string, error) {
 
 	// Check real database
 	err = s.realRepo.Ping()
-	if err != nil {
+	if errors.Is(err, ErrNotConfigured) {
+		result["real_database"] = "not_configured"
+	} else if err != nil {
 		result["real_database"] = "error"
 	} else {
 		result["real_database"] = "ok"
@@ -57,7 +98,9 @@ func (s *Service) CheckHealthStream() <-chan middleware.StreamChunk {
 
 		// Check real database
 		err = s.realRepo.Ping()
-		if err != nil {
+		if errors.Is(err, ErrNotConfigured) {
+			result["real_database"] = "not_configured"
+		} else if err != nil {
 			result["real_database"] = "error"
 		} else {
 			result["real_database"] = "ok"
@@ -0,0 +1,178 @@
+package tickets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BusinessHours defines the working time counted by businessDuration, e.g.
+// Monday to Friday from 09:00 to 17:00 in the tenant timezone.
+type BusinessHours struct {
+	// Start and End are the opening and closing wall-clock times as offsets
+	// from midnight (e.g. 9*time.Hour and 17*time.Hour). End must be after
+	// Start; business hours spanning midnight are not supported.
+	Start time.Duration
+	End   time.Duration
+
+	// Days are the working weekdays
+	Days []time.Weekday
+
+	// Location is the timezone of Start and End (nil: OperatorConfig.Location,
+	// then UTC)
+	Location *time.Location
+}
+
+// DefaultBusinessHours returns Monday to Friday, 09:00 to 17:00
+func DefaultBusinessHours() BusinessHours {
+	return BusinessHours{
+		Start: 9 * time.Hour,
+		End:   17 * time.Hour,
+		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	}
+}
+
+// weekdayNames maps the accepted day names to weekdays
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseBusinessHours parses business hours from their text form: hours as
+// "HH:MM-HH:MM" (e.g. "08:30-17:00") and days as a comma-separated list of
+// day names or ranges (e.g. "mon-fri" or "mon,wed,sat"). An empty hours or
+// days value keeps the DefaultBusinessHours value.
+func ParseBusinessHours(hours, days string) (BusinessHours, error) {
+	result := DefaultBusinessHours()
+
+	if hours = strings.TrimSpace(hours); hours != "" {
+		from, to, found := strings.Cut(hours, "-")
+		if !found {
+			return BusinessHours{}, fmt.Errorf("business hours %q must be HH:MM-HH:MM", hours)
+		}
+
+		start, err := parseClock(from)
+		if err != nil {
+			return BusinessHours{}, err
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return BusinessHours{}, err
+		}
+		if end <= start {
+			return BusinessHours{}, fmt.Errorf("business hours %q must end after they start", hours)
+		}
+		result.Start, result.End = start, end
+	}
+
+	if days = strings.TrimSpace(days); days != "" {
+		result.Days = nil
+		for _, part := range strings.Split(days, ",") {
+			from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(part)), "-")
+			first, ok := weekdayNames[from]
+			last := first
+			if isRange {
+				var lastOK bool
+				last, lastOK = weekdayNames[to]
+				ok = ok && lastOK
+			}
+			if !ok {
+				return BusinessHours{}, fmt.Errorf("invalid business day %q (use sun, mon, ..., sat or ranges such as mon-fri)", part)
+			}
+
+			for day := first; ; day = (day + 1) % 7 {
+				result.Days = append(result.Days, day)
+				if day == last {
+					break
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// parseClock parses "HH:MM" into an offset from midnight
+func parseClock(text string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", text)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// withDefaults fills missing or invalid hours and days from DefaultBusinessHours
+func (h BusinessHours) withDefaults() BusinessHours {
+	defaults := DefaultBusinessHours()
+	if h.End <= h.Start {
+		h.Start, h.End = defaults.Start, defaults.End
+	}
+	if len(h.Days) == 0 {
+		h.Days = defaults.Days
+	}
+	return h
+}
+
+// between returns the business time elapsed from from to to (from <= to),
+// evaluating the opening hours in loc
+func (h BusinessHours) between(from, to time.Time, loc *time.Location) time.Duration {
+	var working [7]bool
+	for _, day := range h.Days {
+		working[day%7] = true
+	}
+
+	from, to = from.In(loc), to.In(loc)
+	firstDay := calendarDays(from)
+	lastDay := calendarDays(to)
+
+	// open returns the business window of the calendar day of t
+	open := func(t time.Time) (time.Time, time.Time) {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, int(h.Start.Seconds()), 0, loc),
+			time.Date(year, month, day, 0, 0, int(h.End.Seconds()), 0, loc)
+	}
+
+	// overlap returns the part of [from, to] inside the business window of t's day
+	overlap := func(t time.Time) time.Duration {
+		if !working[t.Weekday()] {
+			return 0
+		}
+		windowStart, windowEnd := open(t)
+		if from.After(windowStart) {
+			windowStart = from
+		}
+		if to.Before(windowEnd) {
+			windowEnd = to
+		}
+		if windowEnd.Before(windowStart) {
+			return 0
+		}
+		return windowEnd.Sub(windowStart)
+	}
+
+	if firstDay == lastDay {
+		return overlap(from)
+	}
+
+	total := overlap(from) + overlap(to)
+
+	// Days strictly between the first and last day are fully covered, so
+	// they are counted per week instead of iterating over long spans
+	interior := lastDay - firstDay - 1
+	workingPerWeek := 0
+	for _, isWorking := range working {
+		if isWorking {
+			workingPerWeek++
+		}
+	}
+	workingDays := interior / 7 * workingPerWeek
+	weekday := (from.Weekday() + 1) % 7
+	for i := 0; i < interior%7; i++ {
+		if working[weekday] {
+			workingDays++
+		}
+		weekday = (weekday + 1) % 7
+	}
+
+	return total + time.Duration(workingDays)*(h.End-h.Start)
+}
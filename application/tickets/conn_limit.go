@@ -0,0 +1,101 @@
+package tickets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// DefaultMaxConnsPerRequest is the number of pooled connections one request
+// may hold at once. A v1 stream runs its COUNT and SELECT one after the other;
+// the cap bounds the queries it runs at once: the reference tables loaded for
+// dbEnum and the chunks of a long IN list merged in order.
+const DefaultMaxConnsPerRequest = 4
+
+// connLimiter is the request-scoped semaphore bounding the connections a
+// request holds. A SELECT holds its slot until its rows are closed.
+type connLimiter struct {
+	slots chan struct{}
+
+	mu   sync.Mutex
+	rows map[*sql.Rows]struct{} // open SELECTs holding a slot
+	peak int                    // most slots held at once
+}
+
+type connLimiterKey struct{}
+
+// WithConnLimit returns a context under which this repository's queries hold
+// at most the configured number of connections at once, so one export cannot
+// monopolize the shared pool. Call it once per request; a context that
+// already carries a limit is returned unchanged.
+func (r *Repository) WithConnLimit(ctx context.Context) context.Context {
+	if r.maxConnsPerRequest <= 0 || connLimiterFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, connLimiterKey{}, &connLimiter{
+		slots: make(chan struct{}, r.maxConnsPerRequest),
+		rows:  make(map[*sql.Rows]struct{}),
+	})
+}
+
+// connLimiterFrom returns the limiter of ctx, nil when the request is unlimited
+func connLimiterFrom(ctx context.Context) *connLimiter {
+	limiter, _ := ctx.Value(connLimiterKey{}).(*connLimiter)
+	return limiter
+}
+
+// acquire waits for a free slot; it fails when ctx is done first
+func (l *connLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for a database connection slot: %w", ctx.Err())
+	}
+
+	l.mu.Lock()
+	if held := len(l.slots); held > l.peak {
+		l.peak = held
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// release frees a slot taken by acquire
+func (l *connLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// hold keeps the slot taken for a SELECT until closeRows is called for rows
+func (l *connLimiter) hold(rows *sql.Rows) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.rows[rows] = struct{}{}
+	l.mu.Unlock()
+}
+
+// closeRows closes rows and frees the slot held for them, if any
+func closeRows(ctx context.Context, rows *sql.Rows) error {
+	err := rows.Close()
+
+	if limiter := connLimiterFrom(ctx); limiter != nil {
+		limiter.mu.Lock()
+		_, held := limiter.rows[rows]
+		delete(limiter.rows, rows)
+		limiter.mu.Unlock()
+
+		if held {
+			limiter.release()
+		}
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package tickets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	json "github.com/json-iterator/go"
+)
+
+// Dictionaries holds named key→value lookup tables used by the translate
+// operator, e.g. {"country": {"ID": "Indonesia"}, "status": {"1": "Open"}}.
+// They are loaded once at startup and injected through OperatorConfig.
+type Dictionaries map[string]map[string]string
+
+// LoadDictionaries loads one dictionary per file. The dictionary name is the
+// file name without extension ("dict/country.yaml" → "country").
+//
+// Supported formats (by extension):
+//   - .json: a flat object {"ID": "Indonesia", "MY": "Malaysia"}
+//   - .yaml / .yml: a flat mapping "ID: Indonesia"
+//
+// Keys and values are converted to strings, so numeric keys such as status
+// codes (1: Open) match column values of any numeric type.
+// Returns an error if a file is missing, malformed, nested or defines a name
+// twice.
+func LoadDictionaries(paths ...string) (Dictionaries, error) {
+	dictionaries := make(Dictionaries, len(paths))
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if _, exists := dictionaries[name]; exists {
+			return nil, fmt.Errorf("dictionary %q is defined twice (%s)", name, path)
+		}
+
+		dictionary, err := loadDictionary(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dictionary %q: %w", name, err)
+		}
+		dictionaries[name] = dictionary
+	}
+
+	return dictionaries, nil
+}
+
+// loadDictionary reads and decodes a single dictionary file
+func loadDictionary(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode with untyped keys so YAML numeric keys keep their text form
+	var raw map[interface{}]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var object map[string]interface{}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+		raw = make(map[interface{}]interface{}, len(object))
+		for key, value := range object {
+			raw[key] = value
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dictionary format %q (use .json, .yaml or .yml)", filepath.Ext(path))
+	}
+
+	dictionary := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch value.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("value of key %v must be a scalar", key)
+		}
+		dictionary[toString(key)] = toString(value)
+	}
+
+	return dictionary, nil
+}
@@ -0,0 +1,191 @@
+package tickets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"stream/internal/stream"
+	"stream/middleware"
+	"strings"
+	"testing"
+
+	"github.com/guregu/null/v5"
+	json "github.com/json-iterator/go"
+)
+
+// writeDictionary writes a dictionary file into dir and returns its path
+func writeDictionary(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDictionaries(t *testing.T) {
+	dir := t.TempDir()
+	country := writeDictionary(t, dir, "country.json", `{"ID": "Indonesia", "MY": "Malaysia"}`)
+	status := writeDictionary(t, dir, "status.yaml", "1: Open\n2: Closed\nvip: true\n")
+
+	t.Run("loads JSON and YAML files by name", func(t *testing.T) {
+		dictionaries, err := LoadDictionaries(country, status)
+		if err != nil {
+			t.Fatalf("LoadDictionaries() error = %v", err)
+		}
+
+		want := Dictionaries{
+			"country": {"ID": "Indonesia", "MY": "Malaysia"},
+			"status":  {"1": "Open", "2": "Closed", "vip": "true"},
+		}
+		for name, entries := range want {
+			for key, value := range entries {
+				if got := dictionaries[name][key]; got != value {
+					t.Errorf("%s[%s] = %q, want %q", name, key, got, value)
+				}
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		nested := writeDictionary(t, dir, "nested.json", `{"ID": {"name": "Indonesia"}}`)
+		malformed := writeDictionary(t, dir, "malformed.json", `{"ID": `)
+		text := writeDictionary(t, dir, "codes.txt", "ID=Indonesia")
+		duplicate := writeDictionary(t, t.TempDir(), "country.yml", "ID: Indonesia\n")
+
+		tests := map[string][]string{
+			"missing file":     {filepath.Join(dir, "missing.json")},
+			"nested value":     {nested},
+			"malformed JSON":   {malformed},
+			"unsupported type": {text},
+			"duplicate name":   {country, duplicate},
+		}
+		for name, paths := range tests {
+			if _, err := LoadDictionaries(paths...); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
+
+func TestTranslate(t *testing.T) {
+	dir := t.TempDir()
+	dictionaries, err := LoadDictionaries(
+		writeDictionary(t, dir, "country.json", `{"ID": "Indonesia", "MY": "Malaysia"}`),
+		writeDictionary(t, dir, "status.yaml", "1: Open\n2: Closed\n"),
+	)
+	if err != nil {
+		t.Fatalf("LoadDictionaries() error = %v", err)
+	}
+	translate := NewOperatorRegistry(OperatorConfig{Dictionaries: dictionaries})["translate"]
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "hit", params: []interface{}{"ID", "country"}, want: "Indonesia"},
+		{name: "hit from bytes with whitespace", params: []interface{}{[]uint8(" MY "), "country"}, want: "Malaysia"},
+		{name: "numeric key", params: []interface{}{int64(2), "status"}, want: "Closed"},
+		{name: "miss", params: []interface{}{"XX", "country"}, want: null.String{}},
+		{name: "miss with default", params: []interface{}{"XX", "country", "Unknown"}, want: "Unknown"},
+		{name: "nil key", params: []interface{}{nil, "country"}, want: null.String{}},
+		{name: "nil key with default", params: []interface{}{nil, "country", "Unknown"}, want: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := translate(tt.params)
+			if err != nil {
+				t.Fatalf("translate() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("translate() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown dictionary", func(t *testing.T) {
+		if _, err := translate([]interface{}{"ID", "currency"}); err == nil {
+			t.Error("expected error for an unconfigured dictionary")
+		}
+	})
+}
+
+func TestService_ReloadDictionaries(t *testing.T) {
+	dir := t.TempDir()
+	path := writeDictionary(t, dir, "status.yaml", "open: Open\nclosed: Closed\n")
+
+	loadConfig := func() OperatorConfig {
+		dictionaries, err := LoadDictionaries(path)
+		if err != nil {
+			t.Fatalf("LoadDictionaries() error = %v", err)
+		}
+		return OperatorConfig{Dictionaries: dictionaries}
+	}
+
+	svc := NewService(NewRepository(openTestDB(t, filepath.Join(dir, "tickets.db"))))
+	svc.SetOperatorConfig(loadConfig())
+	// One row per chunk, so the first stream is still running during the reload
+	svc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 1, BatchSize: 1})
+
+	payload := func() *QueryPayload {
+		return &QueryPayload{
+			TableName:      "tickets",
+			IsDisableCount: true,
+			Formulas: []Formula{
+				{Params: []string{"status", "'status' AS dictionary"}, Field: "status", Operator: "translate", Position: 1},
+			},
+		}
+	}
+	// readStatuses drains the stream and returns the translated statuses;
+	// chunks are joined with commas like sendStream does
+	readStatuses := func(chunkChan <-chan middleware.StreamChunk, body []byte) []string {
+		for chunk := range chunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Stream chunk error: %v", chunk.Error)
+			}
+			if next := *chunk.JSONBuf; len(body) > 0 && len(next) > 0 && next[0] != ',' && next[0] != ']' {
+				body = append(body, ',')
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+
+		var rows []struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(body, &rows); err != nil {
+			t.Fatalf("Invalid JSON body %q: %v", body, err)
+		}
+		statuses := make([]string, len(rows))
+		for i, row := range rows {
+			statuses[i] = row.Status
+		}
+		return statuses
+	}
+
+	inFlight := svc.StreamTickets(context.Background(), payload())
+	if inFlight.Error != nil {
+		t.Fatalf("StreamTickets() error = %v", inFlight.Error)
+	}
+	first := <-inFlight.ChunkChan
+	if first.Error != nil {
+		t.Fatalf("Stream chunk error: %v", first.Error)
+	}
+
+	// Change the dictionary on disk and reload while the first stream runs
+	writeDictionary(t, dir, "status.yaml", "open: Buka\nclosed: Tutup\n")
+	svc.SetOperatorConfig(loadConfig())
+
+	fresh := svc.StreamTickets(context.Background(), payload())
+	if fresh.Error != nil {
+		t.Fatalf("StreamTickets() error = %v", fresh.Error)
+	}
+	if got := readStatuses(fresh.ChunkChan, nil); strings.Join(got, ",") != "Buka,Buka,Tutup" {
+		t.Errorf("New stream statuses = %v, want reloaded translations", got)
+	}
+
+	if got := readStatuses(inFlight.ChunkChan, *first.JSONBuf); strings.Join(got, ",") != "Open,Open,Closed" {
+		t.Errorf("In-flight stream statuses = %v, want translations from its start", got)
+	}
+}
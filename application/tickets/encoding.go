@@ -0,0 +1,465 @@
+package tickets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+)
+
+// Format is the encoding of a streamed response body
+type Format string
+
+const (
+	// FormatJSON streams a JSON array of row objects (default)
+	FormatJSON Format = "json"
+	// FormatNDJSON streams one JSON row object per line
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV streams a header line with the field names, then one line per row
+	FormatCSV Format = "csv"
+	// FormatXML streams <rows><row><field name="...">value</field></row></rows>
+	FormatXML Format = "xml"
+	// FormatEnvelope streams {"data":[rows],"meta":{...}}, the meta object
+	// following the last row
+	FormatEnvelope Format = "envelope"
+	// FormatSSE streams one server-sent event per row, its id the cursor
+	// after the row
+	FormatSSE Format = "sse"
+)
+
+// MIME types of the response formats
+const (
+	MIMEJSON   = "application/json"
+	MIMENDJSON = "application/x-ndjson"
+	MIMECSV    = "text/csv"
+	MIMEXML    = "application/xml"
+	MIMEXML2   = "text/xml"
+	MIMESSE    = "text/event-stream"
+)
+
+// ParseFormat returns the Format named name ("json", "ndjson", "csv", "xml",
+// "envelope" or "sse", case-insensitive); ok is false for other names
+func ParseFormat(name string) (Format, bool) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatJSON, FormatNDJSON, FormatCSV, FormatXML, FormatEnvelope, FormatSSE:
+		return format, true
+	default:
+		return "", false
+	}
+}
+
+// FormatForMIME returns the Format served for a MIME type; ok is false for
+// unsupported types
+func FormatForMIME(mime string) (Format, bool) {
+	switch mime {
+	case MIMEJSON:
+		return FormatJSON, true
+	case MIMENDJSON:
+		return FormatNDJSON, true
+	case MIMECSV:
+		return FormatCSV, true
+	case MIMEXML, MIMEXML2:
+		return FormatXML, true
+	case MIMESSE:
+		return FormatSSE, true
+	default:
+		return "", false
+	}
+}
+
+// ContentType returns the Content-Type header value of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return MIMENDJSON
+	case FormatCSV:
+		return MIMECSV + "; charset=utf-8"
+	case FormatXML:
+		return MIMEXML + "; charset=utf-8"
+	case FormatSSE:
+		return MIMESSE
+	default:
+		return MIMEJSON
+	}
+}
+
+// rowEncoder appends the rows of one stream to chunk buffers. Encoders are
+// stateful (e.g. the CSV header), so each stream needs its own.
+type rowEncoder interface {
+	// open appends the bytes starting the body
+	open(buf []byte) []byte
+	// appendRow appends one row; buf is empty at the start of a new chunk
+	appendRow(buf []byte, row TransformedRow) ([]byte, error)
+	// close appends the bytes ending the body
+	close(buf []byte) []byte
+	// errorMarker returns the bytes ending a body whose stream failed after
+	// rows were sent (see Service.SetErrorMarker)
+	errorMarker(err error) []byte
+}
+
+// newRowEncoder returns a fresh encoder for format (JSON for unknown formats)
+func newRowEncoder(format Format) rowEncoder {
+	switch format {
+	case FormatNDJSON:
+		return ndjsonEncoder{}
+	case FormatCSV:
+		return &csvEncoder{}
+	case FormatXML:
+		return xmlEncoder{}
+	case FormatEnvelope:
+		return &envelopeEncoder{}
+	case FormatSSE:
+		return &sseEncoder{}
+	default:
+		return jsonEncoder{}
+	}
+}
+
+// jsonEncoder writes a JSON array. Rows are separated within a chunk only;
+// sendStream adds the separator between chunks.
+type jsonEncoder struct{}
+
+func (jsonEncoder) open(buf []byte) []byte { return append(buf, '[') }
+
+func (jsonEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return buf, err
+	}
+	// Add comma separator if not first row (length > 1 because of '[')
+	if len(buf) > 1 {
+		buf = append(buf, ',')
+	}
+	return append(buf, data...), nil
+}
+
+func (jsonEncoder) close(buf []byte) []byte { return append(buf, ']') }
+
+// errorMarker closes the array with an {"_error":"..."} element. Every chunk
+// of the array holds a row, so the marker always follows one.
+func (jsonEncoder) errorMarker(err error) []byte {
+	return append(append([]byte{','}, errorObject(err)...), ']')
+}
+
+// ndjsonEncoder writes one JSON object per line
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) open(buf []byte) []byte { return buf }
+
+func (ndjsonEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return buf, err
+	}
+	buf = append(buf, data...)
+	return append(buf, '\n'), nil
+}
+
+func (ndjsonEncoder) close(buf []byte) []byte { return buf }
+
+// errorMarker is a final {"_error":"..."} line
+func (ndjsonEncoder) errorMarker(err error) []byte {
+	return append(errorObject(err), '\n')
+}
+
+// csvEncoder writes RFC 4180 CSV. The header is taken from the first row, so
+// an empty result has an empty body.
+type csvEncoder struct {
+	wroteHeader bool
+	line        bytes.Buffer
+	record      []string
+}
+
+func (e *csvEncoder) open(buf []byte) []byte { return buf }
+
+func (e *csvEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	e.line.Reset()
+	writer := csv.NewWriter(&e.line)
+
+	if !e.wroteHeader {
+		e.record = e.record[:0]
+		for _, field := range row.fields {
+			e.record = append(e.record, field.Key)
+		}
+		if err := writer.Write(e.record); err != nil {
+			return buf, err
+		}
+		e.wroteHeader = true
+	}
+
+	e.record = e.record[:0]
+	for _, field := range row.fields {
+		text, _, err := cellText(field.Value)
+		if err != nil {
+			return buf, fmt.Errorf("field %q: %w", field.Key, err)
+		}
+		e.record = append(e.record, text)
+	}
+	if err := writer.Write(e.record); err != nil {
+		return buf, err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return buf, err
+	}
+	return append(buf, e.line.Bytes()...), nil
+}
+
+func (e *csvEncoder) close(buf []byte) []byte { return buf }
+
+// errorMarker is a final "_error,<message>" record
+func (e *csvEncoder) errorMarker(err error) []byte {
+	var line bytes.Buffer
+	writer := csv.NewWriter(&line)
+	writer.Write([]string{errorMarkerKey, err.Error()})
+	writer.Flush()
+	return line.Bytes()
+}
+
+// xmlEncoder writes <rows><row><field name="key">value</field></row></rows>.
+// Field names go in an attribute, so any key yields well-formed XML; null
+// values are written as <field name="key" null="true"/>.
+type xmlEncoder struct{}
+
+func (xmlEncoder) open(buf []byte) []byte {
+	return append(buf, xml.Header+"<rows>"...)
+}
+
+func (xmlEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("<row>")
+	for _, field := range row.fields {
+		text, isNull, err := cellText(field.Value)
+		if err != nil {
+			return buf, fmt.Errorf("field %q: %w", field.Key, err)
+		}
+
+		out.WriteString(`<field name="`)
+		if err := xml.EscapeText(&out, []byte(field.Key)); err != nil {
+			return buf, err
+		}
+		if isNull {
+			out.WriteString(`" null="true"/>`)
+			continue
+		}
+		out.WriteString(`">`)
+		if err := xml.EscapeText(&out, []byte(text)); err != nil {
+			return buf, err
+		}
+		out.WriteString("</field>")
+	}
+	out.WriteString("</row>")
+	return append(buf, out.Bytes()...), nil
+}
+
+func (xmlEncoder) close(buf []byte) []byte { return append(buf, "</rows>"...) }
+
+// errorMarker closes the document with an <error>message</error> element
+func (xmlEncoder) errorMarker(err error) []byte {
+	var out bytes.Buffer
+	out.WriteString("<error>")
+	xml.EscapeText(&out, []byte(err.Error()))
+	out.WriteString("</error></rows>")
+	return out.Bytes()
+}
+
+// EnvelopeMeta is the "meta" object closing an envelope response
+type EnvelopeMeta struct {
+	Count         *int64 `json:"count"`          // Total matching rows; null when the count is disabled
+	StreamedCount int64  `json:"streamed_count"` // Rows in "data", without a totals row
+	HasMore       bool   `json:"hasMore"`        // Rows remain past this page
+	Cursor        *int64 `json:"cursor"`         // Offset of the next page; null without more rows
+}
+
+// envelopeEncoder writes {"data":[...],"meta":{...}}. The body is not a bare
+// array, so unlike jsonEncoder it separates rows across chunks itself. meta
+// is called once, after the last row; without it only the rows are counted.
+type envelopeEncoder struct {
+	rows int64
+	meta func() EnvelopeMeta
+}
+
+func (e *envelopeEncoder) open(buf []byte) []byte { return append(buf, `{"data":[`...) }
+
+func (e *envelopeEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return buf, err
+	}
+	if e.rows > 0 {
+		buf = append(buf, ',')
+	}
+	e.rows++
+	return append(buf, data...), nil
+}
+
+func (e *envelopeEncoder) close(buf []byte) []byte {
+	meta := EnvelopeMeta{StreamedCount: e.rows}
+	if e.meta != nil {
+		meta = e.meta()
+	}
+	// Numbers and booleans only, so encoding cannot fail
+	data, _ := json.Marshal(meta)
+	buf = append(buf, `],"meta":`...)
+	buf = append(buf, data...)
+	return append(buf, '}')
+}
+
+// errorMarker closes "data" and the envelope with an "_error" member in
+// place of "meta"
+func (e *envelopeEncoder) errorMarker(err error) []byte {
+	marker := append([]byte(`],"`+errorMarkerKey+`":`), errorMessage(err)...)
+	return append(marker, '}')
+}
+
+// sseEncoder writes one "id: <cursor>\ndata: <row>\n\n" event per row. The id
+// is the offset of the row after it (the cursor of the next page, counted
+// from cursor, the offset of the first row), so a client reconnecting with
+// that Last-Event-ID resumes past the last row it received.
+type sseEncoder struct {
+	cursor int64
+}
+
+func (e *sseEncoder) open(buf []byte) []byte { return buf }
+
+func (e *sseEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return buf, err
+	}
+	// Encoded JSON has no raw newlines, so the row fits one data line
+	e.cursor++
+	buf = append(buf, "id: "...)
+	buf = strconv.AppendInt(buf, e.cursor, 10)
+	buf = append(buf, "\ndata: "...)
+	buf = append(buf, data...)
+	return append(buf, "\n\n"...), nil
+}
+
+func (e *sseEncoder) close(buf []byte) []byte { return buf }
+
+// errorMarker is a final "error" event with an {"_error":"..."} object and
+// no id, so it does not move the client's Last-Event-ID
+func (e *sseEncoder) errorMarker(err error) []byte {
+	marker := append([]byte("event: error\ndata: "), errorObject(err)...)
+	return append(marker, "\n\n"...)
+}
+
+// errorMarkerKey names the error member or column of an error marker
+const errorMarkerKey = "_error"
+
+// errorObject returns {"_error":"<message>"}
+func errorObject(err error) []byte {
+	object := append([]byte(`{"`+errorMarkerKey+`":`), errorMessage(err)...)
+	return append(object, '}')
+}
+
+// errorMessage returns the message of err as a JSON string
+func errorMessage(err error) []byte {
+	// A string always encodes
+	data, _ := json.Marshal(err.Error())
+	return data
+}
+
+// cellText returns the text of a value for the CSV and XML formats. Text is
+// written as-is; other values use their JSON form (unquoted for JSON strings
+// such as dates), so numbers and dates match the JSON format.
+func cellText(v interface{}) (string, bool, error) {
+	if isNullValue(v) {
+		return "", true, nil
+	}
+
+	switch val := normalizeBytes(v).(type) {
+	case string:
+		return val, false, nil
+	case []uint8:
+		return string(val), false, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false, err
+	}
+	if string(data) == "null" {
+		return "", true, nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return "", false, err
+		}
+		return text, false, nil
+	}
+	return string(data), false, nil
+}
+
+// KeyCase is the naming convention applied to every output key
+type KeyCase string
+
+const (
+	// KeyCaseNone keeps the formula field names (default)
+	KeyCaseNone KeyCase = ""
+	// KeyCaseSnake writes keys as snake_case ("ticketNo" -> "ticket_no")
+	KeyCaseSnake KeyCase = "snake"
+	// KeyCaseCamel writes keys as camelCase ("ticket_no" -> "ticketNo")
+	KeyCaseCamel KeyCase = "camel"
+)
+
+// ParseKeyCase returns the KeyCase named name ("none", "snake" or "camel",
+// case-insensitive; "" is none); ok is false for other names
+func ParseKeyCase(name string) (KeyCase, bool) {
+	switch keyCase := KeyCase(strings.ToLower(strings.TrimSpace(name))); keyCase {
+	case "none", KeyCaseNone:
+		return KeyCaseNone, true
+	case KeyCaseSnake, KeyCaseCamel:
+		return keyCase, true
+	default:
+		return "", false
+	}
+}
+
+// convert returns key in the convention; keys without letters or digits
+// are kept as they are
+func (k KeyCase) convert(key string) string {
+	if k == KeyCaseNone {
+		return key
+	}
+	if converted, ok := toCase(key, string(k)); ok && converted != "" {
+		return converted
+	}
+	return key
+}
+
+// keyCaseEncoder renames the keys of every row in place before the wrapped
+// encoder writes it, so field order and all formats are preserved
+type keyCaseEncoder struct {
+	rowEncoder
+	keyCase KeyCase
+	keys    map[string]string // converted keys; rows of a stream share them
+}
+
+// withKeyCase wraps encoder to apply keyCase (encoder itself for KeyCaseNone)
+func withKeyCase(encoder rowEncoder, keyCase KeyCase) rowEncoder {
+	if keyCase == KeyCaseNone {
+		return encoder
+	}
+	return &keyCaseEncoder{rowEncoder: encoder, keyCase: keyCase, keys: make(map[string]string)}
+}
+
+func (e *keyCaseEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	for i := range row.fields {
+		key := row.fields[i].Key
+		converted, ok := e.keys[key]
+		if !ok {
+			converted = e.keyCase.convert(key)
+			e.keys[key] = converted
+		}
+		row.fields[i].Key = converted
+	}
+	return e.rowEncoder.appendRow(buf, row)
+}
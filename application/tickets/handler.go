@@ -1,11 +1,17 @@
 package tickets
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"stream/common"
 	"stream/middleware"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
 )
 
 // Handler handles HTTP requests for tickets
@@ -20,46 +26,212 @@ func NewHandler(service *Service) *Handler {
 
 // RegisterRoutes registers the handler routes
 func (h *Handler) RegisterRoutes(api *gin.RouterGroup) {
-	tickets := api.Group("/v1/tickets")
-	{
-		tickets.POST("/stream", h.StreamTickets)
-	}
+	h.RegisterRoutesWithPrefix(api.Group("/v1/tickets"))
 }
 
 // RegisterRoutesWithPrefix registers the handler routes with a custom prefix
 // This is used to create separate endpoints for different databases
 func (h *Handler) RegisterRoutesWithPrefix(group *gin.RouterGroup) {
-	group.POST("/stream", h.StreamTickets)
+	RegisterRoutesWithSelector(group, func(*gin.Context) *Handler { return h })
+}
+
+// RegisterRoutesWithSelector registers the handler routes on group, serving
+// every request with the handler selectHandler returns for it (e.g. the
+// handler of the database named by a header). A nil handler means
+// selectHandler has already answered the request. The other Register
+// methods use it, so the routes are listed here only.
+func RegisterRoutesWithSelector(group *gin.RouterGroup, selectHandler func(c *gin.Context) *Handler) {
+	group.POST("/stream", func(c *gin.Context) {
+		if h := selectHandler(c); h != nil {
+			h.StreamTickets(c)
+		}
+	})
 }
 
-// StreamTickets handles the POST /v1/tickets/stream endpoint
+// StreamTickets handles the POST /v1/tickets/stream endpoint. The body format
+// is negotiated from the Accept header (JSON, NDJSON, CSV, XML or SSE); a
+// ?format= query parameter overrides it and also selects the envelope.
+// An SSE stream resumes after the row named by a Last-Event-ID header.
+// ?preview=N returns only the first N rows (at most MaxPreviewRows) with the
+// field names in X-Fields, and ?fields=a,b streams only those output fields.
 func (h *Handler) StreamTickets(c *gin.Context) {
 	sendStream := c.MustGet("sendStream").(func(middleware.StreamResponse))
 	requestID := c.GetString("requestId")
 	startTime := time.Now()
 
+	// Select the response format before doing any work
+	format, ok := negotiateFormat(c)
+	if !ok {
+		send := c.MustGet("send").(func(middleware.Response))
+		send(middleware.Response{
+			Code:    http.StatusNotAcceptable,
+			Message: "Not acceptable: supported formats are json, ndjson, csv, xml, envelope and sse",
+			Error:   errors.New("unsupported response format"),
+		})
+		return
+	}
+
 	// Parse and bind payload
 	var payload QueryPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := middleware.BindStrictJSON(c, &payload); err != nil {
 		send := c.MustGet("send").(func(middleware.Response))
 		send(middleware.Response{
-			Code:    http.StatusBadRequest,
-			Message: "Invalid JSON payload",
+			Code:    common.HTTPStatus(err),
+			Message: middleware.InvalidPayloadMessage(err),
 			Error:   err,
 		})
 		return
 	}
 
+	if format == FormatSSE {
+		done, err := resumeFromLastEventID(c, &payload)
+		if err != nil {
+			send := c.MustGet("send").(func(middleware.Response))
+			send(middleware.Response{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid Last-Event-ID: must be an event id of this stream",
+				Error:   err,
+			})
+			return
+		}
+		// Every requested row was received: 204 stops the client reconnecting
+		if done {
+			send := c.MustGet("send").(func(middleware.Response))
+			send(middleware.Response{
+				Code:    http.StatusNoContent,
+				Message: "Stream complete",
+			})
+			return
+		}
+	}
+
+	if value := c.Query("preview"); value != "" {
+		preview, err := strconv.Atoi(value)
+		if err != nil || preview < 1 {
+			send := c.MustGet("send").(func(middleware.Response))
+			send(middleware.Response{
+				Code:    http.StatusBadRequest,
+				Message: "Invalid preview: must be a positive number of rows",
+				Error:   common.NewValidationError(fmt.Errorf("invalid preview %q", value)),
+			})
+			return
+		}
+		payload.Preview = preview
+	}
+
+	if value, ok := c.GetQuery("fields"); ok {
+		payload.Fields = splitFields(value)
+	}
+
 	// Log request start
 	h.svc.LogRequest(requestID, &payload, 0, nil)
 
 	// Stream processing
-	response := h.svc.StreamTickets(c.Request.Context(), &payload)
+	ctx := WithRequestID(c.Request.Context(), requestID)
+	response := h.svc.StreamTicketsAs(ctx, &payload, format)
 
 	// Log request completion
 	duration := time.Since(startTime)
 	h.svc.LogRequest(requestID, &payload, duration, response.Error)
 
+	// Map typed errors (validation, query, cancellation) to HTTP status codes
+	if response.Error != nil {
+		response.Code = common.HTTPStatus(response.Error)
+	}
+
+	// List every payload problem, so they can be fixed in one go
+	if errors.Is(response.Error, common.ErrValidation) {
+		send := c.MustGet("send").(func(middleware.Response))
+		send(middleware.Response{
+			Code:    response.Code,
+			Message: "Invalid payload",
+			Data:    gin.H{"errors": ValidationProblems(response.Error)},
+			Error:   response.Error,
+		})
+		return
+	}
+
+	// Expose total count and preview fields before the body starts streaming
+	setTotalCountHeader(c, response)
+	setFieldsHeader(c, response)
+
 	// Send streaming response
 	sendStream(response)
 }
+
+// resumeFromLastEventID continues an SSE stream after the last row a
+// reconnecting client received. Event ids are the offset of the next row, so
+// Last-Event-ID replaces the payload offset and the limit shrinks by the rows
+// already received: the resumed stream ends where the first one would have.
+// Ids outside the requested rows are rejected; done is true when the client
+// already received all of them.
+func resumeFromLastEventID(c *gin.Context, payload *QueryPayload) (done bool, err error) {
+	value := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if value == "" {
+		return false, nil
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return false, common.NewValidationError(fmt.Errorf("invalid Last-Event-ID %q", value))
+	}
+
+	// The window of the first stream, with the limit validation applies
+	clampLimit(payload)
+	start, limit := payload.GetOffset(), payload.GetLimit()
+	if offset < start || (limit > 0 && offset > start+limit) {
+		return false, common.NewValidationError(fmt.Errorf("invalid Last-Event-ID %d: outside the requested rows (offset %d, limit %d)", offset, start, limit))
+	}
+
+	payload.Offset = offset
+	if limit > 0 {
+		remaining := limit - (offset - start)
+		if remaining == 0 {
+			return true, nil
+		}
+		payload.Limit = &remaining
+	}
+	return false, nil
+}
+
+// splitFields parses the comma-separated ?fields= list, dropping empty names
+func splitFields(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// setTotalCountHeader exposes the total count as X-Total-Count before the body
+// is streamed. The header is omitted when the count was disabled (-1) or failed.
+func setTotalCountHeader(c *gin.Context, response middleware.StreamResponse) {
+	if response.Error != nil || response.TotalCount < 0 {
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(response.TotalCount, 10))
+}
+
+// setFieldsHeader exposes the output field names as X-Fields, a JSON array,
+// when the response knows them up front (preview mode)
+func setFieldsHeader(c *gin.Context, response middleware.StreamResponse) {
+	if response.Error != nil || response.Fields == nil {
+		return
+	}
+	fields, err := json.Marshal(response.Fields)
+	if err != nil {
+		return
+	}
+	c.Header("X-Fields", string(fields))
+}
+
+// negotiateFormat selects the response format from the ?format= query
+// parameter or else the Accept header. A missing Accept header or */* selects
+// JSON; ok is false when no supported format is acceptable.
+func negotiateFormat(c *gin.Context) (Format, bool) {
+	if name := c.Query("format"); name != "" {
+		return ParseFormat(name)
+	}
+	return FormatForMIME(c.NegotiateFormat(MIMEJSON, MIMENDJSON, MIMECSV, MIMEXML, MIMEXML2, MIMESSE))
+}
@@ -0,0 +1,1372 @@
+package tickets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"stream/common"
+	"stream/internal/stream"
+	"stream/middleware"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guregu/null/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+)
+
+func setupTestRouter(t *testing.T, db *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+
+	handler := NewHandler(NewService(NewRepository(db)))
+	handler.RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+	return r
+}
+
+func performStreamRequest(r *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_ErrorStatusMapping(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+
+	t.Run("validation failure returns 400", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "invalid_table"}`)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("validation failure lists every problem", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "orderBy": ["id", "sideways"], "formulas": [
+			{"params": ["id"], "field": "id", "operator": "noSuchOperator", "position": 1},
+			{"params": ["subject"], "field": "subject", "position": 2},
+			{"params": ["ticket_no"], "field": "subject", "position": 3}
+		]}`)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+		var response struct {
+			Message string `json:"message"`
+			Data    struct {
+				Errors []string `json:"errors"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("invalid JSON: %v: %s", err, w.Body.String())
+		}
+		if response.Message != "Invalid payload" || len(response.Data.Errors) != 3 {
+			t.Fatalf("response = %+v, want 3 errors", response)
+		}
+		for i, want := range []string{"invalid orderBy", "invalid formula at index 0", "duplicate formula field name: subject"} {
+			if !strings.HasPrefix(response.Data.Errors[i], want) {
+				t.Errorf("errors[%d] = %q, want prefix %q", i, response.Data.Errors[i], want)
+			}
+		}
+	})
+
+	t.Run("malformed JSON returns 400", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": `)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("database failure returns 500", func(t *testing.T) {
+		// report_ticket is whitelisted but does not exist in the test database
+		w := performStreamRequest(r, `{"tableName": "report_ticket"}`)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status 500, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("successful request returns 200", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "formulas": [{"params": ["id"], "field": "id", "position": 1}]}`)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.HasPrefix(w.Body.String(), "[") {
+			t.Errorf("Expected JSON array body, got %s", w.Body.String())
+		}
+	})
+}
+
+func TestHandler_TotalCountHeader(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+
+	t.Run("header present when count is enabled", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "where": [{"field": "status", "op": "=", "value": "open"}]}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Total-Count"); got != "2" {
+			t.Errorf("Expected X-Total-Count 2, got %q", got)
+		}
+	})
+
+	t.Run("header absent when count is disabled", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "isDisableCount": true}`)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if _, exists := w.Header()["X-Total-Count"]; exists {
+			t.Errorf("Expected no X-Total-Count header, got %q", w.Header().Get("X-Total-Count"))
+		}
+	})
+
+	t.Run("header absent on validation error", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "invalid_table"}`)
+
+		if _, exists := w.Header()["X-Total-Count"]; exists {
+			t.Errorf("Expected no X-Total-Count header, got %q", w.Header().Get("X-Total-Count"))
+		}
+	})
+}
+
+func TestHandler_HasMoreTrailer(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantRows int
+		want     string
+	}{
+		{
+			name:     "limit below row count has more",
+			body:     `{"tableName": "tickets", "limit": 2, "isDisableCount": true, "detectHasMore": true}`,
+			wantRows: 2,
+			want:     "true",
+		},
+		{
+			name:     "limit equal to row count has no more",
+			body:     `{"tableName": "tickets", "limit": 3, "isDisableCount": true, "detectHasMore": true}`,
+			wantRows: 3,
+			want:     "false",
+		},
+		{
+			name:     "no limit has no more",
+			body:     `{"tableName": "tickets", "isDisableCount": true, "detectHasMore": true}`,
+			wantRows: 3,
+			want:     "false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performStreamRequest(r, tt.body)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+				t.Fatalf("Invalid JSON body: %v", err)
+			}
+			if len(rows) != tt.wantRows {
+				t.Errorf("Expected %d rows, got %d", tt.wantRows, len(rows))
+			}
+
+			if got := w.Result().Trailer.Get("X-Has-More"); got != tt.want {
+				t.Errorf("Expected X-Has-More trailer %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("trailer absent when detection is off", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "limit": 2}`)
+
+		if _, exists := w.Result().Trailer["X-Has-More"]; exists {
+			t.Errorf("Expected no X-Has-More trailer, got %q", w.Result().Trailer.Get("X-Has-More"))
+		}
+	})
+}
+
+func TestHandler_MidStreamError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// streamChunks serves the given chunks through sendStream, as StreamTickets does
+	streamChunks := func(chunks ...middleware.StreamChunk) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(middleware.RequestInit())
+		r.Use(middleware.ResponseInit())
+		r.GET("/stream", func(c *gin.Context) {
+			chunkChan := make(chan middleware.StreamChunk, len(chunks))
+			for _, chunk := range chunks {
+				chunkChan <- chunk
+			}
+			close(chunkChan)
+
+			sendStream := c.MustGet("sendStream").(func(middleware.StreamResponse))
+			sendStream(middleware.StreamResponse{TotalCount: -1, ChunkChan: chunkChan})
+		})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		return w
+	}
+	jsonChunk := func(s string) middleware.StreamChunk {
+		buf := []byte(s)
+		return middleware.StreamChunk{JSONBuf: &buf}
+	}
+
+	t.Run("error after first chunk leaves incomplete JSON and sets trailer", func(t *testing.T) {
+		w := streamChunks(
+			jsonChunk(`[{"id":1},{"id":2}`),
+			middleware.StreamChunk{Error: common.NewStreamError(errors.New("connection reset"))},
+			jsonChunk(`]`),
+		)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 (already sent), got %d", w.Code)
+		}
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err == nil {
+			t.Errorf("Expected incomplete JSON body, got valid %q", w.Body.String())
+		}
+
+		got := w.Result().Trailer.Get(middleware.StreamErrorTrailer)
+		if !strings.Contains(got, "connection reset") {
+			t.Errorf("Expected %s trailer with the error, got %q", middleware.StreamErrorTrailer, got)
+		}
+	})
+
+	t.Run("complete stream has no error trailer", func(t *testing.T) {
+		w := streamChunks(jsonChunk(`[{"id":1}`), jsonChunk(`,{"id":2}]`))
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("Invalid JSON body: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(rows))
+		}
+
+		if declared := w.Header().Get("Trailer"); !strings.Contains(declared, middleware.StreamErrorTrailer) {
+			t.Errorf("Expected %s to be declared as a trailer, got %q", middleware.StreamErrorTrailer, declared)
+		}
+		if got := w.Result().Trailer.Get(middleware.StreamErrorTrailer); got != "" {
+			t.Errorf("Expected empty %s trailer, got %q", middleware.StreamErrorTrailer, got)
+		}
+	})
+}
+
+func TestHandler_ErrorMarker(t *testing.T) {
+	// A file database: a stream of one subtest may still hold a connection
+	// when the next one opens another, which in memory would be empty
+	db := openTestDB(t, filepath.Join(t.TempDir(), "tickets.db"))
+
+	// The fourth row is too large, so the stream fails after three rows
+	if err := db.Create(&common.Ticket{ID: 4, TicketNo: "TKT-000004", CustomerID: 1, Subject: strings.Repeat("x", 2000), Status: "open"}).Error; err != nil {
+		t.Fatalf("Failed to seed data: %v", err)
+	}
+
+	// router streams one row per chunk, with or without error markers
+	router := func(errorMarker bool) *gin.Engine {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.RequestInit())
+		r.Use(middleware.ResponseInit())
+
+		svc := NewService(NewRepository(db))
+		svc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 1, BatchSize: 1, MaxRowBytes: 1000})
+		svc.SetErrorMarker(errorMarker)
+		NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+		return r
+	}
+	perform := func(r *gin.Engine, format Format) *httptest.ResponseRecorder {
+		body := `{"tableName": "tickets", "orderBy": ["id", "asc"], "formulas": [
+			{"params": ["id"], "field": "id", "operator": "", "position": 1},
+			{"params": ["subject"], "field": "subject", "operator": "", "position": 2}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream?format="+string(format), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// markers decode the rows and the error marker of each format
+	markers := map[Format]func(t *testing.T, body []byte) (int, string){
+		FormatJSON: func(t *testing.T, body []byte) (int, string) {
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(body, &rows); err != nil {
+				t.Fatalf("invalid JSON: %v: %s", err, body)
+			}
+			last, _ := rows[len(rows)-1]["_error"].(string)
+			return len(rows) - 1, last
+		},
+		FormatNDJSON: func(t *testing.T, body []byte) (int, string) {
+			lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+			var marker map[string]string
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &marker); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", lines[len(lines)-1], err)
+			}
+			return len(lines) - 1, marker["_error"]
+		},
+		FormatCSV: func(t *testing.T, body []byte) (int, string) {
+			reader := csv.NewReader(bytes.NewReader(body))
+			records, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("invalid CSV: %v: %s", err, body)
+			}
+			last := records[len(records)-1]
+			if last[0] != "_error" {
+				return len(records) - 1, ""
+			}
+			return len(records) - 2, last[1]
+		},
+		FormatXML: func(t *testing.T, body []byte) (int, string) {
+			var doc struct {
+				Rows  []struct{} `xml:"row"`
+				Error string     `xml:"error"`
+			}
+			if err := xml.Unmarshal(body, &doc); err != nil {
+				t.Fatalf("invalid XML: %v: %s", err, body)
+			}
+			return len(doc.Rows), doc.Error
+		},
+		FormatEnvelope: func(t *testing.T, body []byte) (int, string) {
+			var envelope struct {
+				Data  []map[string]interface{} `json:"data"`
+				Meta  json.RawMessage          `json:"meta"`
+				Error string                   `json:"_error"`
+			}
+			if err := json.Unmarshal(body, &envelope); err != nil {
+				t.Fatalf("invalid envelope: %v: %s", err, body)
+			}
+			if envelope.Meta != nil {
+				t.Errorf("meta = %s, want none on a failed stream", envelope.Meta)
+			}
+			return len(envelope.Data), envelope.Error
+		},
+	}
+
+	r := router(true)
+	for format, decode := range markers {
+		t.Run(string(format), func(t *testing.T) {
+			w := perform(r, format)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200 (already sent), got %d: %s", w.Code, w.Body.String())
+			}
+
+			trailer := w.Result().Trailer.Get(middleware.StreamErrorTrailer)
+			if !strings.Contains(trailer, "row exceeds maximum size") {
+				t.Fatalf("Expected %s trailer with the error, got %q", middleware.StreamErrorTrailer, trailer)
+			}
+
+			rows, marker := decode(t, w.Body.Bytes())
+			if rows != 3 {
+				t.Errorf("Expected 3 rows before the marker, got %d: %s", rows, w.Body.String())
+			}
+			if marker != trailer {
+				t.Errorf("error marker = %q, want the trailer message %q", marker, trailer)
+			}
+		})
+	}
+
+	t.Run("disabled leaves the body incomplete", func(t *testing.T) {
+		w := perform(router(false), FormatJSON)
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err == nil {
+			t.Errorf("Expected incomplete JSON body, got valid %q", w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "_error") {
+			t.Errorf("Expected no error marker, got %s", w.Body.String())
+		}
+	})
+}
+
+func TestHandler_UnknownFields(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{name: "misspelled top-level field", body: `{"tableName": "tickets", "formula": []}`, field: "formula"},
+		{name: "differently cased field", body: `{"tableName": "tickets", "orderby": ["id"]}`, field: "orderby"},
+		{name: "unknown nested field", body: `{"tableName": "tickets", "where": [{"field": "id", "operator": "=", "value": 1}]}`, field: "operator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performStreamRequest(r, tt.body)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response middleware.ResponseAPI
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Invalid JSON body: %v", err)
+			}
+			if want := `unknown field "` + tt.field + `"`; !strings.Contains(response.Message, want) {
+				t.Errorf("Expected message naming %s, got %q", want, response.Message)
+			}
+		})
+	}
+}
+
+func TestBindStrictJSON_KnownFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := `{
+		"tableName": "tickets",
+		"orderBy": ["id DESC"],
+		"limit": 10,
+		"offset": 5,
+		"where": [{"field": "status", "op": "=", "value": "$status"}],
+		"formulas": [{"params": ["id"], "field": "id", "operator": "", "position": 1}],
+		"isFormatDate": true,
+		"isDisableCount": true,
+		"detectHasMore": true,
+		"params": {"status": "open"},
+		"union": {"tableName": "tickets_archive", "where": [{"field": "id", "op": ">", "value": 1}], "all": true}
+	}`
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/tickets/stream", bytes.NewBufferString(body))
+
+	var payload QueryPayload
+	if err := middleware.BindStrictJSON(c, &payload); err != nil {
+		t.Fatalf("BindStrictJSON() error = %v", err)
+	}
+
+	if payload.TableName != "tickets" || len(payload.OrderBy) != 1 || payload.GetLimit() != 10 || payload.Offset != 5 {
+		t.Errorf("Unexpected query fields: %+v", payload)
+	}
+	if len(payload.Where) != 1 || payload.Where[0].Operator != "=" || len(payload.Formulas) != 1 || payload.Formulas[0].Position != 1 {
+		t.Errorf("Unexpected where/formulas: %+v", payload)
+	}
+	if !payload.IsFormatDate || !payload.IsDisableCount || !payload.DetectHasMore {
+		t.Errorf("Expected all flags to be true: %+v", payload)
+	}
+	if payload.Params["status"] != "open" || payload.Union == nil || !payload.Union.All || payload.Union.TableName != "tickets_archive" {
+		t.Errorf("Unexpected params/union: %+v", payload)
+	}
+
+	t.Run("binding tags are still validated", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/tickets/stream", bytes.NewBufferString(`{"limit": 0}`))
+
+		var payload QueryPayload
+		err := middleware.BindStrictJSON(c, &payload)
+		if !errors.Is(err, common.ErrValidation) {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+	})
+}
+
+func TestHandler_ContentNegotiation(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+	body := `{"tableName": "tickets", "where": [{"field": "status", "op": "=", "value": "open"}], "formulas": [
+		{"params": ["id"], "field": "id", "operator": "", "position": 1},
+		{"params": ["subject"], "field": "subject", "operator": "", "position": 2}
+	]}`
+
+	perform := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	wantSubjects := []string{"Test ticket 1", "Test ticket 2"}
+
+	// subjects decodes the subjects of the rows in each format
+	subjects := map[Format]func(t *testing.T, body []byte) []string{
+		FormatJSON: func(t *testing.T, body []byte) []string {
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(body, &rows); err != nil {
+				t.Fatalf("invalid JSON: %v: %s", err, body)
+			}
+			var out []string
+			for _, row := range rows {
+				out = append(out, row["subject"].(string))
+			}
+			return out
+		},
+		FormatNDJSON: func(t *testing.T, body []byte) []string {
+			var out []string
+			for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+				var row map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &row); err != nil {
+					t.Fatalf("invalid NDJSON line %q: %v", line, err)
+				}
+				out = append(out, row["subject"].(string))
+			}
+			return out
+		},
+		FormatCSV: func(t *testing.T, body []byte) []string {
+			records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+			if err != nil {
+				t.Fatalf("invalid CSV: %v: %s", err, body)
+			}
+			if len(records) == 0 || strings.Join(records[0], ",") != "id,subject" {
+				t.Fatalf("expected header id,subject, got %v", records)
+			}
+			var out []string
+			for _, record := range records[1:] {
+				out = append(out, record[1])
+			}
+			return out
+		},
+		FormatXML: func(t *testing.T, body []byte) []string {
+			var doc struct {
+				Rows []struct {
+					Fields []struct {
+						Name  string `xml:"name,attr"`
+						Value string `xml:",chardata"`
+					} `xml:"field"`
+				} `xml:"row"`
+			}
+			if err := xml.Unmarshal(body, &doc); err != nil {
+				t.Fatalf("invalid XML: %v: %s", err, body)
+			}
+			var out []string
+			for _, row := range doc.Rows {
+				out = append(out, row.Fields[1].Value)
+			}
+			return out
+		},
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		format      Format
+		contentType string
+	}{
+		{name: "no Accept header", target: "/v1/tickets/stream", format: FormatJSON, contentType: "application/json"},
+		{name: "any type", target: "/v1/tickets/stream", accept: "*/*", format: FormatJSON, contentType: "application/json"},
+		{name: "json", target: "/v1/tickets/stream", accept: "application/json", format: FormatJSON, contentType: "application/json"},
+		{name: "ndjson", target: "/v1/tickets/stream", accept: "application/x-ndjson", format: FormatNDJSON, contentType: "application/x-ndjson"},
+		{name: "csv", target: "/v1/tickets/stream", accept: "text/csv", format: FormatCSV, contentType: "text/csv; charset=utf-8"},
+		{name: "xml", target: "/v1/tickets/stream", accept: "application/xml", format: FormatXML, contentType: "application/xml; charset=utf-8"},
+		{name: "text xml", target: "/v1/tickets/stream", accept: "text/xml", format: FormatXML, contentType: "application/xml; charset=utf-8"},
+		{name: "first supported type in a list", target: "/v1/tickets/stream", accept: "text/html, text/csv;q=0.9", format: FormatCSV, contentType: "text/csv; charset=utf-8"},
+		{name: "query parameter overrides Accept", target: "/v1/tickets/stream?format=ndjson", accept: "application/json", format: FormatNDJSON, contentType: "application/x-ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := perform(tt.target, tt.accept)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.contentType, got)
+			}
+			got := subjects[tt.format](t, w.Body.Bytes())
+			if strings.Join(got, "|") != strings.Join(wantSubjects, "|") {
+				t.Errorf("Expected subjects %v, got %v", wantSubjects, got)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ name, target, accept string }{
+		{name: "unsupported Accept", target: "/v1/tickets/stream", accept: "text/html"},
+		{name: "unsupported format parameter", target: "/v1/tickets/stream?format=yaml", accept: ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := perform(tt.target, tt.accept)
+			if w.Code != http.StatusNotAcceptable {
+				t.Errorf("Expected status 406, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Envelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+
+	// One row per chunk, so rows are separated across chunks
+	svc := NewService(NewRepository(setupTestDB(t)))
+	svc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 1, BatchSize: 1})
+	NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+	tests := []struct {
+		name     string
+		paging   string
+		wantIDs  string
+		wantMeta string
+	}{
+		{"first page", `"limit": 2`, "[1 2]", `{"count":3,"streamed_count":2,"hasMore":true,"cursor":2}`},
+		{"last page", `"limit": 2, "offset": 2`, "[3]", `{"count":3,"streamed_count":1,"hasMore":false,"cursor":null}`},
+		{"detected next page without count", `"limit": 2, "isDisableCount": true, "detectHasMore": true`, "[1 2]", `{"count":null,"streamed_count":2,"hasMore":true,"cursor":2}`},
+		{"no count", `"isDisableCount": true`, "[1 2 3]", `{"count":null,"streamed_count":3,"hasMore":false,"cursor":null}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"tableName": "tickets", "orderBy": ["id", "asc"], ` + tt.paging + `, "formulas": [
+				{"params": ["id"], "field": "id", "operator": "", "position": 1}
+			]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream?format=envelope", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+
+			var envelope struct {
+				Data []struct {
+					ID int `json:"id"`
+				} `json:"data"`
+				Meta json.RawMessage `json:"meta"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("invalid envelope: %v: %s", err, w.Body.String())
+			}
+
+			var ids []int
+			for _, row := range envelope.Data {
+				ids = append(ids, row.ID)
+			}
+			if got := fmt.Sprint(ids); got != tt.wantIDs {
+				t.Errorf("data ids = %s, want %s", got, tt.wantIDs)
+			}
+			if got := string(envelope.Meta); got != tt.wantMeta {
+				t.Errorf("meta = %s, want %s", got, tt.wantMeta)
+			}
+		})
+	}
+}
+
+func TestHandler_SSEResume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+
+	// One row per chunk, so every event is flushed on its own
+	svc := NewService(NewRepository(setupTestDB(t)))
+	svc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 1, BatchSize: 1})
+	NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+	tests := []struct {
+		name        string
+		paging      string
+		lastEventID string
+		want        string
+	}{
+		{"from the start", "", "", "id: 1\ndata: {\"id\":1}\n\nid: 2\ndata: {\"id\":2}\n\nid: 3\ndata: {\"id\":3}\n\n"},
+		{"resumed after the first row", "", "1", "id: 2\ndata: {\"id\":2}\n\nid: 3\ndata: {\"id\":3}\n\n"},
+		{"resumed with a limit", `, "limit": 2`, "1", "id: 2\ndata: {\"id\":2}\n\n"},
+		{"resumed with an offset and a limit", `, "offset": 1, "limit": 2`, "2", "id: 3\ndata: {\"id\":3}\n\n"},
+		{"resumed past the last row", "", "3", ""},
+		{"offset without a header", `, "offset": 2`, "", "id: 3\ndata: {\"id\":3}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"tableName": "tickets", "orderBy": ["id", "asc"]` + tt.paging + `, "formulas": [
+				{"params": ["id"], "field": "id", "operator": "", "position": 1}
+			]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "text/event-stream")
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("Content-Type = %q, want text/event-stream", got)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("reconnect with a limit stays in its window", func(t *testing.T) {
+		for _, tt := range []struct {
+			paging      string
+			lastEventID string
+			want        int
+		}{
+			{`"limit": 2`, "2", http.StatusNoContent},     // every requested row received
+			{`"limit": 1`, "3", http.StatusBadRequest},    // past the requested rows
+			{`"offset": 2`, "1", http.StatusBadRequest},   // before the requested rows
+			{`"limit": 100`, "-1", http.StatusBadRequest}, // not an event id
+		} {
+			body := `{"tableName": "tickets", ` + tt.paging + `, "formulas": [{"params": ["id"], "field": "id", "operator": "", "position": 1}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream?format=sse", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Last-Event-ID", tt.lastEventID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("%s, Last-Event-ID %s: status = %d, want %d: %s", tt.paging, tt.lastEventID, w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusNoContent && w.Body.Len() != 0 {
+				t.Errorf("%s, Last-Event-ID %s: body = %q, want none", tt.paging, tt.lastEventID, w.Body.String())
+			}
+		}
+	})
+}
+
+func TestRowEncoders(t *testing.T) {
+	rows := []TransformedRow{
+		{fields: []TransformedField{{Key: "id", Value: int64(1)}, {Key: "note", Value: `a, "quoted" <b>`}}},
+		{fields: []TransformedField{{Key: "id", Value: int64(2)}, {Key: "note", Value: null.String{}}}},
+	}
+
+	encode := func(format Format) string {
+		encoder := newRowEncoder(format)
+		buf := encoder.open(nil)
+		for _, row := range rows {
+			var err error
+			if buf, err = encoder.appendRow(buf, row); err != nil {
+				t.Fatalf("appendRow(%s) error = %v", format, err)
+			}
+		}
+		return string(encoder.close(buf))
+	}
+
+	tests := map[Format]string{
+		FormatJSON:   `[{"id":1,"note":"a, \"quoted\" \u003cb\u003e"},{"id":2,"note":null}]`,
+		FormatNDJSON: "{\"id\":1,\"note\":\"a, \\\"quoted\\\" \\u003cb\\u003e\"}\n{\"id\":2,\"note\":null}\n",
+		FormatCSV:    "id,note\n1,\"a, \"\"quoted\"\" <b>\"\n2,\n",
+		FormatXML: xml.Header + `<rows><row><field name="id">1</field><field name="note">a, &#34;quoted&#34; &lt;b&gt;</field></row>` +
+			`<row><field name="id">2</field><field name="note" null="true"/></row></rows>`,
+		FormatEnvelope: `{"data":[{"id":1,"note":"a, \"quoted\" \u003cb\u003e"},{"id":2,"note":null}],` +
+			`"meta":{"count":null,"streamed_count":2,"hasMore":false,"cursor":null}}`,
+		FormatSSE: "id: 1\ndata: {\"id\":1,\"note\":\"a, \\\"quoted\\\" \\u003cb\\u003e\"}\n\n" +
+			"id: 2\ndata: {\"id\":2,\"note\":null}\n\n",
+	}
+
+	for format, want := range tests {
+		if got := encode(format); got != want {
+			t.Errorf("%s encoding = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestHandler_Preview(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Grow the table well past the preview size
+	extra := make([]common.Ticket, 50)
+	for i := range extra {
+		extra[i] = common.Ticket{
+			ID:         uint(100 + i),
+			TicketNo:   fmt.Sprintf("TKT-%06d", 100+i),
+			CustomerID: 1,
+			Subject:    fmt.Sprintf("Bulk ticket %d", i),
+			Status:     "open",
+			Priority:   "low",
+		}
+	}
+	if err := db.Create(&extra).Error; err != nil {
+		t.Fatalf("Failed to seed data: %v", err)
+	}
+
+	r := setupTestRouter(t, db)
+	perform := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// decode returns the rows of a JSON response and its X-Fields header
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) ([]map[string]interface{}, []string) {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("invalid JSON: %v: %s", err, w.Body.String())
+		}
+		var fields []string
+		if err := json.Unmarshal([]byte(w.Header().Get("X-Fields")), &fields); err != nil {
+			t.Fatalf("invalid X-Fields header %q: %v", w.Header().Get("X-Fields"), err)
+		}
+		return rows, fields
+	}
+
+	t.Run("returns the first N rows and the field names", func(t *testing.T) {
+		w := perform("/v1/tickets/stream?preview=5", `{"tableName": "tickets", "formulas": [
+			{"params": ["id"], "field": "id", "operator": "", "position": 1},
+			{"params": ["ticket_no"], "field": "ticket", "operator": "ticketIdMasking", "position": 2},
+			{"params": ["subject"], "field": "subject", "operator": "", "position": 3}
+		]}`)
+
+		rows, fields := decode(t, w)
+		if len(rows) != 5 {
+			t.Errorf("Expected 5 rows, got %d", len(rows))
+		}
+		if strings.Join(fields, ",") != "id,ticket,subject" {
+			t.Errorf("X-Fields = %v, want [id ticket subject]", fields)
+		}
+		if got := w.Header().Get("X-Total-Count"); got != "" {
+			t.Errorf("Expected no COUNT(*) in preview mode, got X-Total-Count %q", got)
+		}
+	})
+
+	t.Run("empty formulas report the table columns", func(t *testing.T) {
+		rows, fields := decode(t, perform("/v1/tickets/stream?preview=5", `{"tableName": "tickets"}`))
+		if len(rows) != 5 {
+			t.Errorf("Expected 5 rows, got %d", len(rows))
+		}
+		if len(fields) == 0 || fields[0] != "id" || len(fields) != len(rows[0]) {
+			t.Errorf("X-Fields = %v, want the %d table columns starting with id", fields, len(rows[0]))
+		}
+	})
+
+	t.Run("a smaller payload limit wins", func(t *testing.T) {
+		rows, _ := decode(t, perform("/v1/tickets/stream?preview=5", `{"tableName": "tickets", "limit": 2}`))
+		if len(rows) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(rows))
+		}
+	})
+
+	t.Run("invalid preview returns 400", func(t *testing.T) {
+		for _, preview := range []string{"0", "-1", "five"} {
+			w := perform("/v1/tickets/stream?preview="+preview, `{"tableName": "tickets"}`)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("preview=%s: expected status 400, got %d", preview, w.Code)
+			}
+		}
+	})
+
+	t.Run("preview is capped", func(t *testing.T) {
+		payload := &QueryPayload{Preview: MaxPreviewRows * 10}
+		applyPreview(payload)
+		if payload.GetLimit() != MaxPreviewRows {
+			t.Errorf("limit = %d, want %d", payload.GetLimit(), MaxPreviewRows)
+		}
+	})
+}
+
+func TestHandler_SummaryRow(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+	body := `{"tableName": "tickets", "orderBy": ["id", "asc"], "formulas": [
+		{"params": ["id"], "field": "id", "operator": "", "position": 1},
+		{"params": ["customer_id"], "field": "amount", "operator": "", "position": 2},
+		{"params": ["status"], "field": "status", "operator": "", "position": 3}
+	], "summary": [
+		{"field": "id", "function": "count"},
+		{"field": "amount", "function": "sum"}
+	]}`
+
+	t.Run("totals row follows the detail rows", func(t *testing.T) {
+		w := performStreamRequest(r, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("invalid JSON: %v: %s", err, w.Body.String())
+		}
+		if len(rows) != 4 {
+			t.Fatalf("Expected 3 detail rows and a totals row, got %d: %s", len(rows), w.Body.String())
+		}
+		for i, row := range rows[:3] {
+			if row["id"] != float64(i+1) {
+				t.Errorf("detail row %d = %v", i, row)
+			}
+		}
+
+		totals := rows[3]
+		if totals["id"] != float64(3) {
+			t.Errorf("count = %v, want 3", totals["id"])
+		}
+		if totals["amount"] != float64(6) {
+			t.Errorf("sum = %v, want 6", totals["amount"])
+		}
+		if totals["status"] != nil {
+			t.Errorf("unsummarized field = %v, want null", totals["status"])
+		}
+	})
+
+	t.Run("totals row keeps the CSV columns", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream?format=csv", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		records, err := csv.NewReader(bytes.NewReader(w.Body.Bytes())).ReadAll()
+		if err != nil {
+			t.Fatalf("invalid CSV: %v: %s", err, w.Body.String())
+		}
+		if len(records) != 5 || strings.Join(records[4], ",") != "3,6," {
+			t.Errorf("CSV = %v, want header, 3 rows and totals 3,6,", records)
+		}
+	})
+
+	t.Run("invalid summary returns 400", func(t *testing.T) {
+		for _, summary := range []string{
+			`[{"field": "amount", "function": "median"}]`,
+			`[{"field": "unknown", "function": "sum"}]`,
+			`[{"field": "amount", "function": "sum"}, {"field": "amount", "function": "avg"}]`,
+		} {
+			w := performStreamRequest(r, `{"tableName": "tickets", "formulas": [
+				{"params": ["customer_id"], "field": "amount", "operator": "", "position": 1}
+			], "summary": `+summary+`}`)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("summary %s: expected status 400, got %d", summary, w.Code)
+			}
+		}
+
+		w := performStreamRequest(r, `{"tableName": "tickets", "summary": [{"field": "amount", "function": "sum"}]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("unknown column without formulas: expected status 400, got %d", w.Code)
+		}
+	})
+}
+
+func TestSummaryAccumulator(t *testing.T) {
+	formulas := []Formula{{Field: "n"}, {Field: "label"}}
+	acc, err := newSummaryAccumulator([]SummaryColumn{
+		{Field: "n", Function: "avg"},
+		{Field: "label", Function: "count"},
+	}, formulas)
+	if err != nil {
+		t.Fatalf("newSummaryAccumulator() error = %v", err)
+	}
+
+	for _, values := range [][2]interface{}{
+		{int64(1), "a"},
+		{2.5, nil},
+		{[]uint8("0.5"), "c"},
+		{"n/a", null.String{}},
+		{nil, "e"},
+	} {
+		acc.add(TransformedRow{fields: []TransformedField{{Key: "n", Value: values[0]}, {Key: "label", Value: values[1]}}})
+	}
+
+	row := acc.row()
+	if got, _ := row.Get("n"); got != float64(4)/3 {
+		t.Errorf("avg = %v, want %v", got, float64(4)/3)
+	}
+	if got, _ := row.Get("label"); got != int64(3) {
+		t.Errorf("count = %v, want 3", got)
+	}
+
+	for function, want := range map[string]interface{}{"sum": int64(4), "min": 0.5, "max": 2.5} {
+		acc, _ := newSummaryAccumulator([]SummaryColumn{{Field: "n", Function: function}}, formulas)
+		for _, v := range []interface{}{int64(1), 2.5, "0.5", nil} {
+			acc.add(TransformedRow{fields: []TransformedField{{Key: "n", Value: v}}})
+		}
+		if got, _ := acc.row().Get("n"); got != want {
+			t.Errorf("%s = %v (%T), want %v", function, got, got, want)
+		}
+	}
+}
+
+func TestHandler_KeyCase(t *testing.T) {
+	db := setupTestDB(t)
+	body := `{"tableName": "tickets", "orderBy": ["id", "asc"], "limit": 1, "formulas": [
+		{"params": ["id"], "field": "ticketId", "operator": "", "position": 1},
+		{"params": ["ticket_no"], "field": "TicketNumber", "operator": "", "position": 2},
+		{"params": ["customer_id"], "field": "customerID", "operator": "", "position": 3},
+		{"params": ["status"], "field": "status", "operator": "", "position": 4}
+	]}`
+
+	// router serves the stream with the given key case
+	router := func(keyCase KeyCase) *gin.Engine {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.RequestInit())
+		r.Use(middleware.ResponseInit())
+
+		svc := NewService(NewRepository(db))
+		svc.SetKeyCase(keyCase)
+		NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+		return r
+	}
+
+	tests := []struct {
+		keyCase KeyCase
+		want    string
+	}{
+		{KeyCaseNone, `[{"ticketId":1,"TicketNumber":"TKT-000001","customerID":1,"status":"open"}]`},
+		{KeyCaseSnake, `[{"ticket_id":1,"ticket_number":"TKT-000001","customer_id":1,"status":"open"}]`},
+		{KeyCaseCamel, `[{"ticketId":1,"ticketNumber":"TKT-000001","customerId":1,"status":"open"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.keyCase), func(t *testing.T) {
+			w := performStreamRequest(router(tt.keyCase), body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			// Compared as text: the key order must be kept
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("keys colliding after renaming return 400", func(t *testing.T) {
+		w := performStreamRequest(router(KeyCaseSnake), `{"tableName": "tickets", "formulas": [
+			{"params": ["id"], "field": "ticketId", "operator": "", "position": 1},
+			{"params": ["id"], "field": "ticket_id", "operator": "", "position": 2}
+		]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestParseKeyCase(t *testing.T) {
+	for name, want := range map[string]KeyCase{"": KeyCaseNone, "none": KeyCaseNone, "Snake": KeyCaseSnake, " camel ": KeyCaseCamel} {
+		if got, ok := ParseKeyCase(name); !ok || got != want {
+			t.Errorf("ParseKeyCase(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := ParseKeyCase("kebab"); ok {
+		t.Error("ParseKeyCase(\"kebab\") should fail")
+	}
+}
+
+func TestHandler_FieldProjection(t *testing.T) {
+	r := setupTestRouter(t, setupTestDB(t))
+	formulas := `"formulas": [
+		{"params": ["id"], "field": "id", "operator": "", "position": 1},
+		{"params": ["ticket_no"], "field": "ticketNo", "operator": "", "position": 2},
+		{"params": ["status"], "field": "status", "operator": "upper", "position": 3},
+		{"params": ["customer_id"], "field": "customerId", "operator": "", "position": 4}
+	]`
+
+	t.Run("two of four fields stream in row order", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "orderBy": ["id", "asc"], "limit": 2, `+formulas+`,
+			"fields": ["status", "ticketNo"]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		// Compared as text: the key order must be kept
+		want := `[{"ticketNo":"TKT-000001","status":"OPEN"},{"ticketNo":"TKT-000002","status":"OPEN"}]`
+		if got := w.Body.String(); got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("query parameter overrides the payload", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/tickets/stream?fields=id,+customerId", bytes.NewBufferString(
+			`{"tableName": "tickets", "orderBy": ["id", "asc"], "limit": 1, `+formulas+`, "fields": ["status"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if want := `[{"id":1,"customerId":1}]`; w.Body.String() != want {
+			t.Errorf("body = %s, want %s", w.Body.String(), want)
+		}
+	})
+
+	t.Run("unknown fields return 400", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", `+formulas+`, "fields": ["id", "subject"]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestHandler_StreamSummaryLog(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+	svc := NewService(NewRepository(setupTestDB(t)))
+	svc.SetLogger(zap.New(core))
+	NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+	t.Run("completed stream logs its counts at info level", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "formulas": [
+			{"params": ["id"], "field": "id", "operator": "", "position": 1},
+			{"params": ["status"], "field": "status", "operator": "upper", "position": 2}
+		]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("Expected one summary line, got %d: %v", len(entries), entries)
+		}
+		entry := entries[0]
+		if entry.Level != zapcore.InfoLevel || entry.Message != "Stream completed" {
+			t.Errorf("entry = %s %q, want info \"Stream completed\"", entry.Level, entry.Message)
+		}
+
+		fields := entry.ContextMap()
+		want := map[string]interface{}{
+			"table":  "tickets",
+			"format": "json",
+			"rows":   int64(3),
+			"bytes":  int64(w.Body.Len()), // a single chunk
+		}
+		for key, value := range want {
+			if fields[key] != value {
+				t.Errorf("%s = %v (%T), want %v", key, fields[key], fields[key], value)
+			}
+		}
+		if _, ok := fields["duration"]; !ok {
+			t.Error("duration field is missing")
+		}
+		if _, ok := fields["error"]; ok {
+			t.Errorf("completed stream should not log an error: %v", fields["error"])
+		}
+	})
+
+	t.Run("failed stream logs the error at error level", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "unknown_table"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+		}
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("Expected one summary line, got %d: %v", len(entries), entries)
+		}
+		entry := entries[0]
+		if entry.Level != zapcore.ErrorLevel || entry.Message != "Stream failed" {
+			t.Errorf("entry = %s %q, want error \"Stream failed\"", entry.Level, entry.Message)
+		}
+		if fields := entry.ContextMap(); fields["table"] != "unknown_table" || fields["error"] == nil {
+			t.Errorf("fields = %v, want table and error", fields)
+		}
+	})
+}
+
+func TestHandler_QueryDebugLog(t *testing.T) {
+	db := setupTestDB(t)
+	body := `{"tableName": "tickets", "where": [{"field": "status", "op": "=", "value": "open"}], "formulas": [
+		{"params": ["id"], "field": "id", "operator": "", "position": 1}
+	]}`
+
+	// perform streams body with the queries logged in mode
+	perform := func(t *testing.T, mode QueryLogMode) []observer.LoggedEntry {
+		core, logs := observer.New(zapcore.DebugLevel)
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(middleware.RequestInit())
+		r.Use(func(c *gin.Context) { c.Set("requestId", "req-42") })
+		r.Use(middleware.ResponseInit())
+
+		repo := NewRepository(db)
+		repo.SetQueryLog(zap.New(core), mode)
+		NewHandler(NewService(repo)).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+		if w := performStreamRequest(r, body); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		return logs.TakeAll()
+	}
+
+	// queryEntries returns the logged queries by kind, checking their common fields
+	queryEntries := func(t *testing.T, entries []observer.LoggedEntry) map[string]map[string]interface{} {
+		byKind := make(map[string]map[string]interface{})
+		for _, entry := range entries {
+			if entry.Level != zapcore.DebugLevel || entry.Message != "Query" {
+				t.Errorf("entry = %s %q, want debug \"Query\"", entry.Level, entry.Message)
+			}
+			fields := entry.ContextMap()
+			if fields["request_id"] != "req-42" {
+				t.Errorf("request_id = %v, want req-42", fields["request_id"])
+			}
+			byKind[fields["kind"].(string)] = fields
+		}
+		if len(byKind) != 2 || byKind["select"] == nil || byKind["count"] == nil {
+			t.Fatalf("Expected a select and a count query, got %v", entries)
+		}
+		return byKind
+	}
+
+	t.Run("args", func(t *testing.T) {
+		for kind, fields := range queryEntries(t, perform(t, QueryLogArgs)) {
+			if query, _ := fields["query"].(string); !strings.Contains(query, "WHERE `status` = ?") {
+				t.Errorf("%s query = %q, want the executed SQL", kind, query)
+			}
+			if args := fmt.Sprint(fields["args"]); !strings.Contains(args, "open") {
+				t.Errorf("%s args = %s, want the bound value", kind, args)
+			}
+		}
+	})
+
+	t.Run("redacted", func(t *testing.T) {
+		for kind, fields := range queryEntries(t, perform(t, QueryLogRedacted)) {
+			if query, _ := fields["query"].(string); !strings.Contains(query, "WHERE `status` = ?") {
+				t.Errorf("%s query = %q, want the executed SQL", kind, query)
+			}
+			args := fmt.Sprint(fields["args"])
+			if strings.Contains(args, "open") || !strings.Contains(args, "string") {
+				t.Errorf("%s args = %s, want the arg types only", kind, args)
+			}
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		if entries := perform(t, QueryLogOff); len(entries) != 0 {
+			t.Errorf("Expected no query log, got %v", entries)
+		}
+	})
+}
+
+func TestHandler_LargeInList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestInit())
+	r.Use(middleware.ResponseInit())
+	repo := NewRepository(openTestDB(t, filepath.Join(t.TempDir(), "tickets.db")))
+	svc := NewService(repo)
+	svc.SetMaxInListSize(2)
+	NewHandler(svc).RegisterRoutesWithPrefix(r.Group("/v1/tickets"))
+
+	// 1 and 3 are repeated across what would be different chunks; 999 and
+	// 1000 match nothing
+	const ids = `[1, 999, 3, 1, 2, 3, 1000]`
+	formulas := `"formulas": [{"params": ["id"], "field": "id", "operator": "", "position": 1}]`
+
+	t.Run("union of the chunks exactly once", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "where": [{"field": "id", "op": "IN", "value": `+ids+`}], `+formulas+`}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		// Merged in the default id order
+		if got := w.Body.String(); got != `[{"id":1},{"id":2},{"id":3}]` {
+			t.Errorf("body = %s, want ids 1, 2 and 3 once each, in order", got)
+		}
+		if count := w.Header().Get("X-Total-Count"); count != "3" {
+			t.Errorf("X-Total-Count = %q, want 3", count)
+		}
+	})
+
+	t.Run("limit applies to the whole response", func(t *testing.T) {
+		w := performStreamRequest(r, `{"tableName": "tickets", "limit": 2, "where": [{"field": "id", "op": "IN", "value": `+ids+`}], `+formulas+`}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		// Chunks [1, 999], [3, 2] and [1000], merged by id
+		if got := w.Body.String(); got != `[{"id":1},{"id":2}]` {
+			t.Errorf("body = %s, want the first 2 rows", got)
+		}
+	})
+
+	t.Run("first rows in the second chunk", func(t *testing.T) {
+		// Chunks [3, 999] and [1, 2]: the rows of the second come first
+		where := `"where": [{"field": "id", "op": "IN", "value": [3, 999, 1, 2]}]`
+		for body, want := range map[string]string{
+			`{"tableName": "tickets", ` + where + `, ` + formulas + `}`:             `[{"id":1},{"id":2},{"id":3}]`,
+			`{"tableName": "tickets", "limit": 2, ` + where + `, ` + formulas + `}`: `[{"id":1},{"id":2}]`,
+		} {
+			w := performStreamRequest(r, body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+			if got := w.Body.String(); got != want {
+				t.Errorf("%s: body = %s, want %s", body, got, want)
+			}
+		}
+	})
+
+	t.Run("more chunks than connections to merge them", func(t *testing.T) {
+		repo.SetMaxConnsPerRequest(2)
+		defer repo.SetMaxConnsPerRequest(DefaultMaxConnsPerRequest)
+
+		w := performStreamRequest(r, `{"tableName": "tickets", "where": [{"field": "id", "op": "IN", "value": `+ids+`}], `+formulas+`}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400: %s", w.Code, w.Body.String())
+		}
+
+		// Unordered chunks run one after the other
+		w = performStreamRequest(r, `{"tableName": "tickets", "disableDefaultOrder": true, "where": [{"field": "id", "op": "IN", "value": `+ids+`}], `+formulas+`}`)
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rows); w.Code != http.StatusOK || err != nil || len(rows) != 3 {
+			t.Errorf("disableDefaultOrder: status = %d, body = %s, want 3 rows", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("offset, orderBy and a second long list are rejected", func(t *testing.T) {
+		for _, where := range []string{
+			`"offset": 1, "where": [{"field": "id", "op": "IN", "value": ` + ids + `}]`,
+			`"orderBy": ["created_at", "desc"], "where": [{"field": "id", "op": "IN", "value": ` + ids + `}]`,
+			`"where": [{"field": "id", "op": "IN", "value": ` + ids + `}, {"field": "customer_id", "op": "IN", "value": [1, 2, 3]}]`,
+		} {
+			w := performStreamRequest(r, `{"tableName": "tickets", `+where+`, `+formulas+`}`)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: status = %d, want 400: %s", where, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("short lists and NOT IN are not chunked", func(t *testing.T) {
+		for _, where := range []WhereClause{
+			{Field: "id", Operator: "IN", Value: []interface{}{1, 2}},
+			{Field: "id", Operator: "NOT IN", Value: []interface{}{1, 2, 3}},
+		} {
+			if split, err := splitInList(&QueryPayload{Where: []WhereClause{where}}, 2); split != nil || err != nil {
+				t.Errorf("splitInList(%v) = %v, %v, want nil", where, split, err)
+			}
+		}
+	})
+}
+
+func TestParseQueryLogMode(t *testing.T) {
+	for name, want := range map[string]QueryLogMode{"": QueryLogOff, "off": QueryLogOff, "false": QueryLogOff, "true": QueryLogArgs, " Args ": QueryLogArgs, "redacted": QueryLogRedacted} {
+		if got, ok := ParseQueryLogMode(name); !ok || got != want {
+			t.Errorf("ParseQueryLogMode(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := ParseQueryLogMode("verbose"); ok {
+		t.Error("ParseQueryLogMode(\"verbose\") should fail")
+	}
+}
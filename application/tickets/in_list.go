@@ -0,0 +1,341 @@
+package tickets
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	stdjson "encoding/json"
+	"fmt"
+	"stream/internal/stream"
+	"strings"
+	"time"
+)
+
+// DefaultMaxInListSize is the largest IN list bound in a single query. A
+// payload with a longer list is streamed as one query per chunk of values,
+// keeping every statement below MySQL's placeholder and packet limits.
+const DefaultMaxInListSize = 1000
+
+// inListChunks is a WHERE ... IN clause whose values are split into chunks,
+// each queried on its own
+type inListChunks struct {
+	where  []WhereClause // the payload conditions
+	index  int           // of the IN clause in where
+	chunks [][]interface{}
+}
+
+// splitInList splits the values of the IN clause of payload holding more than
+// size values into chunks of at most size. Returns nil when no list is that
+// long or size <= 0.
+//
+// Implementation Notes:
+//   - Values are de-duplicated first, so the chunks are disjoint and every
+//     matching row is returned by exactly one chunk query
+//   - Only one list may be chunked; NOT IN lists are never chunked (the
+//     union of the chunk queries would not exclude the other chunks)
+//   - The chunks are queried at once and their rows merged in the default
+//     order (see mergeRowsStreaming), or one after the other when it is
+//     disabled; LIMIT applies to the merged rows. An explicit ORDER BY, a
+//     global OFFSET or UNION cannot be honored across chunks and is rejected.
+func splitInList(payload *QueryPayload, size int) (*inListChunks, error) {
+	if size <= 0 {
+		return nil, nil
+	}
+
+	var split *inListChunks
+	for i, where := range payload.Where {
+		values, ok := where.Value.([]interface{})
+		if !ok || len(values) <= size || !strings.EqualFold(where.Operator, "IN") {
+			continue
+		}
+		if split != nil {
+			return nil, fmt.Errorf("where clauses at index %d and %d: only one IN list may hold more than %d values", split.index, i, size)
+		}
+		split = &inListChunks{where: payload.Where, index: i, chunks: chunkValues(uniqueValues(values), size)}
+	}
+	if split == nil {
+		return nil, nil
+	}
+
+	if payload.GetOffset() > 0 {
+		return nil, fmt.Errorf("offset is not supported with an IN list of more than %d values", size)
+	}
+	if payload.Union != nil {
+		return nil, fmt.Errorf("union is not supported with an IN list of more than %d values", size)
+	}
+	if len(payload.OrderBy) > 0 {
+		return nil, fmt.Errorf("orderBy is not supported with an IN list of more than %d values", size)
+	}
+
+	return split, nil
+}
+
+// uniqueValues returns values without repeats, in first-seen order. Values
+// of different types never repeat each other (1 and "1" are both kept).
+func uniqueValues(values []interface{}) []interface{} {
+	seen := make(map[string]bool, len(values))
+	unique := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		key := fmt.Sprintf("%T:%v", value, value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, value)
+	}
+	return unique
+}
+
+// chunkValues splits values into consecutive chunks of at most size values
+func chunkValues(values []interface{}, size int) [][]interface{} {
+	chunks := make([][]interface{}, 0, (len(values)+size-1)/size)
+	for start := 0; start < len(values); start += size {
+		chunks = append(chunks, values[start:min(start+size, len(values))])
+	}
+	return chunks
+}
+
+// each calls fn with qb restricted to each chunk in turn; a nil c calls fn
+// once with qb unchanged
+func (c *inListChunks) each(qb *QueryBuilder, fn func() error) error {
+	if c == nil {
+		return fn()
+	}
+	defer qb.SetWhere(c.where)
+
+	for _, chunk := range c.chunks {
+		where := make([]WhereClause, len(c.where))
+		copy(where, c.where)
+		where[c.index].Value = chunk
+
+		qb.SetWhere(where)
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectQuery is a SELECT statement with its bound arguments
+type selectQuery struct {
+	query string
+	args  []interface{}
+}
+
+// checkMerge returns an error when the chunks cannot be merged with at most
+// maxConns connections (0: unlimited): each chunk query holds one until the
+// rows of every chunk are streamed
+func (c *inListChunks) checkMerge(maxConns int) error {
+	if maxConns > 0 && len(c.chunks) > maxConns {
+		return fmt.Errorf("an IN list of %d chunks is merged in order over one connection per chunk, at most %d; shorten the list or set disableDefaultOrder", len(c.chunks), maxConns)
+	}
+	return nil
+}
+
+// fetchRowsStreaming streams the rows of rows and then of each query in next
+// (the other chunks of a long IN list) as a single sequence of batches. With
+// an order the queries run at once and their rows are merged by it;
+// otherwise they run one after the other. Once limit rows (when > 0) were
+// sent, the remaining rows are skipped.
+func (s *Service) fetchRowsStreaming(ctx context.Context, rows *sql.Rows, next []selectQuery, batchSize, limit int, order []string) (<-chan []RowData, <-chan error) {
+	if len(next) == 0 {
+		return s.repo.FetchRowsStreaming(ctx, rows, batchSize)
+	}
+	if order != nil {
+		return s.mergeRowsStreaming(ctx, rows, next, batchSize, limit, order)
+	}
+
+	rowsChan := make(chan []RowData, 2)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(rowsChan)
+		defer close(errChan)
+
+		sent := 0
+		for i := 0; ; i++ {
+			batches, errs := s.repo.FetchRowsStreaming(ctx, rows, batchSize)
+			for batch := range batches {
+				select {
+				case rowsChan <- batch:
+					sent += len(batch)
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := <-errs; err != nil {
+				errChan <- err
+				return
+			}
+
+			if ctx.Err() != nil || i == len(next) || (limit > 0 && sent >= limit) {
+				return
+			}
+
+			var err error
+			rows, err = s.repo.ExecuteQuery(ctx, next[i].query, next[i].args)
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	return rowsChan, errChan
+}
+
+// mergeRowsStreaming streams the rows of rows and of each query in next, all
+// open at once, merged by the [field, direction] pair order. Every query
+// returns its rows sorted by order, so holding the next row of each query is
+// enough to emit them in order: the response is ordered like a single query
+// over the whole IN list, and its first limit rows (when > 0) are the first
+// rows of that query even though each chunk is limited on its own.
+func (s *Service) mergeRowsStreaming(ctx context.Context, rows *sql.Rows, next []selectQuery, batchSize, limit int, order []string) (<-chan []RowData, <-chan error) {
+	rowsChan := make(chan []RowData, 2)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(rowsChan)
+		defer close(errChan)
+
+		cursors := []*sql.Rows{rows}
+		defer func() {
+			for _, cursor := range cursors {
+				closeRows(ctx, cursor)
+			}
+		}()
+		for _, q := range next {
+			chunk, err := s.repo.ExecuteQuery(ctx, q.query, q.args)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			cursors = append(cursors, chunk)
+		}
+
+		// heads holds the next row of each query, nil once it is drained;
+		// each query has its own scanner, which caches its column types
+		heads := make([]RowData, len(cursors))
+		columns := make([][]string, len(cursors))
+		scanners := make([]stream.SQLRowScanner[map[string]interface{}], len(cursors))
+		advance := func(i int) error {
+			heads[i] = nil
+			if !cursors[i].Next() {
+				if err := cursors[i].Err(); err != nil {
+					return fmt.Errorf("error iterating rows: %w", err)
+				}
+				return nil
+			}
+			row, err := scanners[i](cursors[i], columns[i])
+			if err != nil {
+				return err
+			}
+			heads[i] = row
+			return nil
+		}
+		for i, cursor := range cursors {
+			var err error
+			if columns[i], err = cursor.Columns(); err != nil {
+				errChan <- fmt.Errorf("failed to get columns: %w", err)
+				return
+			}
+			scanners[i] = stream.TypedRowScanner()
+			if err := advance(i); err != nil {
+				errChan <- err
+				return
+			}
+		}
+
+		field, descending := order[0], strings.EqualFold(order[1], "desc")
+		batch := make([]RowData, 0, batchSize)
+		for merged := 0; limit <= 0 || merged < limit; merged++ {
+			// Take the first row in order; ties go to the earlier chunk
+			first := -1
+			for i, head := range heads {
+				if head == nil {
+					continue
+				}
+				if first < 0 {
+					first = i
+					continue
+				}
+				c := compareOrderValues(head[field], heads[first][field])
+				if descending {
+					c = -c
+				}
+				if c < 0 {
+					first = i
+				}
+			}
+			if first < 0 {
+				break
+			}
+
+			batch = append(batch, heads[first])
+			if len(batch) >= batchSize {
+				select {
+				case rowsChan <- batch:
+				case <-ctx.Done():
+					return
+				}
+				batch = make([]RowData, 0, batchSize)
+			}
+
+			if err := advance(first); err != nil {
+				errChan <- err
+				return
+			}
+		}
+
+		if len(batch) > 0 {
+			select {
+			case rowsChan <- batch:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return rowsChan, errChan
+}
+
+// compareOrderValues compares two values of an ORDER BY column like MySQL
+// does for the usual key types: NULL first, then numbers, times or text
+// (byte-wise, so case-insensitive collations may differ on text keys).
+// Returns -1, 0 or +1.
+func compareOrderValues(a, b interface{}) int {
+	a, b = normalizeBytes(a), normalizeBytes(b)
+	switch aNull, bNull := isNullValue(a), isNullValue(b); {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return -1
+	case bNull:
+		return 1
+	}
+
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	if x, ok := orderNumber(a); ok {
+		if y, ok := orderNumber(b); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+// orderNumber returns v as a float64 when it is a number, not numeric text
+func orderNumber(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, stdjson.Number:
+		return toFloat64(v)
+	}
+	return 0, false
+}
@@ -2,8 +2,10 @@ package tickets
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"stream/common"
+	"stream/internal/stream"
 	"testing"
 	"time"
 
@@ -14,7 +16,13 @@ import (
 )
 
 func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+	return openTestDB(t, ":memory:")
+}
+
+// openTestDB opens and seeds the test database at dsn. Tests running several
+// queries at once need a file: every ":memory:" connection is a separate database.
+func openTestDB(t *testing.T, dsn string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
@@ -916,6 +924,93 @@ func TestQueryBuilder_NoOrderBy(t *testing.T) {
 	}
 }
 
+// TestService_DefaultOrderBy tests that an omitted OrderBy falls back to the
+// primary key and that disableDefaultOrder keeps the query without ORDER BY
+func TestService_DefaultOrderBy(t *testing.T) {
+	svc := NewService(NewRepository(setupTestDB(t)))
+	selectCols := []string{"id", "status"}
+
+	t.Run("omitted orderBy uses primary key", func(t *testing.T) {
+		query, _ := svc.newQueryBuilder(&QueryPayload{TableName: "tickets"}, selectCols).BuildSelectQuery()
+
+		expected := "SELECT `id`, `status` FROM `tickets` ORDER BY `id` ASC"
+		if query != expected {
+			t.Errorf("Expected query: %s\nGot: %s", expected, query)
+		}
+	})
+
+	t.Run("explicit orderBy wins", func(t *testing.T) {
+		payload := &QueryPayload{TableName: "tickets", OrderBy: []string{"status", "desc"}}
+		query, _ := svc.newQueryBuilder(payload, selectCols).BuildSelectQuery()
+
+		expected := "SELECT `id`, `status` FROM `tickets` ORDER BY `status` DESC"
+		if query != expected {
+			t.Errorf("Expected query: %s\nGot: %s", expected, query)
+		}
+	})
+
+	t.Run("opt-out keeps query without ORDER BY", func(t *testing.T) {
+		payload := &QueryPayload{TableName: "tickets", OrderBy: []string{}, DisableDefaultOrder: true}
+		query, _ := svc.newQueryBuilder(payload, selectCols).BuildSelectQuery()
+
+		expected := "SELECT `id`, `status` FROM `tickets`"
+		if query != expected {
+			t.Errorf("Expected query: %s\nGot: %s", expected, query)
+		}
+	})
+
+	t.Run("configured default orders streamed rows", func(t *testing.T) {
+		svc := NewService(NewRepository(setupTestDB(t)))
+		if err := svc.SetDefaultOrderBy([]string{"id", "desc"}); err != nil {
+			t.Fatalf("SetDefaultOrderBy() error = %v", err)
+		}
+
+		streamIDs := func(payload *QueryPayload) []int {
+			response := svc.StreamTickets(context.Background(), payload)
+			if response.Error != nil {
+				t.Fatalf("StreamTickets() error = %v", response.Error)
+			}
+
+			var body []byte
+			for chunk := range response.ChunkChan {
+				if chunk.Error != nil {
+					t.Fatalf("Stream chunk error: %v", chunk.Error)
+				}
+				body = append(body, *chunk.JSONBuf...)
+			}
+
+			var rows []struct {
+				ID int `json:"id"`
+			}
+			if err := json.Unmarshal(body, &rows); err != nil {
+				t.Fatalf("Invalid JSON body: %v", err)
+			}
+			ids := make([]int, len(rows))
+			for i, row := range rows {
+				ids[i] = row.ID
+			}
+			return ids
+		}
+		formulas := []Formula{{Params: []string{"id"}, Field: "id", Position: 1}}
+
+		ids := streamIDs(&QueryPayload{TableName: "tickets", Formulas: formulas, IsDisableCount: true})
+		if fmt.Sprint(ids) != "[3 2 1]" {
+			t.Errorf("Expected ids in default order [3 2 1], got %v", ids)
+		}
+
+		ids = streamIDs(&QueryPayload{TableName: "tickets", Formulas: formulas, IsDisableCount: true, DisableDefaultOrder: true})
+		if fmt.Sprint(ids) != "[1 2 3]" {
+			t.Errorf("Expected ids in storage order [1 2 3], got %v", ids)
+		}
+	})
+
+	t.Run("invalid default is rejected", func(t *testing.T) {
+		if err := svc.SetDefaultOrderBy([]string{"id"}); err == nil {
+			t.Error("Expected error for a default orderBy without direction")
+		}
+	})
+}
+
 // TestValidator_NoOrderByValidation tests that validation passes without OrderBy
 func TestValidator_NoOrderByValidation(t *testing.T) {
 	tests := []struct {
@@ -1440,6 +1535,138 @@ func TestIntegration_AutoFillFieldFromOperator(t *testing.T) {
 	})
 }
 
+// TestIntegration_Union tests streaming two filtered selects as one result set
+func TestIntegration_Union(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewService(NewRepository(db))
+
+	streamIDs := func(payload *QueryPayload) ([]float64, int64) {
+		response := svc.StreamTickets(context.Background(), payload)
+		if response.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", response.Error)
+		}
+
+		var body []byte
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Stream chunk error: %v", chunk.Error)
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(body, &rows); err != nil {
+			t.Fatalf("Invalid JSON: %v (%s)", err, body)
+		}
+
+		ids := make([]float64, len(rows))
+		for i, row := range rows {
+			ids[i], _ = row["ticket_id"].(float64)
+		}
+		return ids, response.TotalCount
+	}
+
+	// open tickets (1, 2) combined with high priority tickets (1)
+	newPayload := func(all bool) *QueryPayload {
+		return &QueryPayload{
+			TableName: "tickets",
+			OrderBy:   []string{"id", "asc"},
+			Where:     []WhereClause{{Field: "status", Operator: "=", Value: "open"}},
+			Formulas: []Formula{
+				{Params: []string{"id"}, Field: "ticket_id", Position: 1},
+				{Params: []string{"priority"}, Field: "priority", Position: 2},
+			},
+			Union: &UnionClause{
+				TableName: "tickets",
+				Where:     []WhereClause{{Field: "priority", Operator: "=", Value: "$priority"}},
+				All:       all,
+			},
+			Params: map[string]interface{}{"priority": "high"},
+		}
+	}
+
+	t.Run("UNION removes duplicates", func(t *testing.T) {
+		ids, count := streamIDs(newPayload(false))
+
+		if fmt.Sprint(ids) != "[1 2]" {
+			t.Errorf("Expected ids [1 2], got %v", ids)
+		}
+		if count != 2 {
+			t.Errorf("Expected TotalCount 2, got %d", count)
+		}
+	})
+
+	t.Run("UNION ALL keeps duplicates", func(t *testing.T) {
+		ids, count := streamIDs(newPayload(true))
+
+		if fmt.Sprint(ids) != "[1 1 2]" {
+			t.Errorf("Expected ids [1 1 2], got %v", ids)
+		}
+		if count != 3 {
+			t.Errorf("Expected TotalCount 3, got %d", count)
+		}
+	})
+
+	t.Run("SELECT * union over different tables is rejected", func(t *testing.T) {
+		response := svc.StreamTickets(context.Background(), &QueryPayload{
+			TableName: "tickets",
+			Union:     &UnionClause{TableName: "report_ticket"},
+		})
+		if !errors.Is(response.Error, common.ErrValidation) {
+			t.Errorf("Expected validation error, got %v", response.Error)
+		}
+	})
+
+	t.Run("union table must be allowed", func(t *testing.T) {
+		payload := newPayload(false)
+		payload.Union.TableName = "users"
+
+		response := svc.StreamTickets(context.Background(), payload)
+		if !errors.Is(response.Error, common.ErrValidation) {
+			t.Errorf("Expected validation error, got %v", response.Error)
+		}
+	})
+}
+
+// TestIntegration_ChunkConfig tests that services with different chunk
+// configurations flush chunks at their own thresholds
+func TestIntegration_ChunkConfig(t *testing.T) {
+	db := setupTestDB(t)
+
+	countChunks := func(svc *Service) int {
+		payload := &QueryPayload{TableName: "tickets", IsDisableCount: true}
+
+		response := svc.StreamTickets(context.Background(), payload)
+		if response.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", response.Error)
+		}
+
+		chunks := 0
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Stream chunk error: %v", chunk.Error)
+			}
+			chunks++
+		}
+		return chunks
+	}
+
+	// Every seeded row is larger than 1 byte, so each row fills a chunk
+	smallSvc := NewService(NewRepository(db))
+	smallSvc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 1, BatchSize: 1})
+
+	largeSvc := NewService(NewRepository(db))
+	largeSvc.SetChunkConfig(stream.ChunkConfig{ChunkThreshold: 64 * 1024, BatchSize: 2000})
+
+	// 3 row chunks plus the closing bracket
+	if got := countChunks(smallSvc); got != 4 {
+		t.Errorf("Expected 4 chunks with a 1 byte threshold, got %d", got)
+	}
+	if got := countChunks(largeSvc); got != 1 {
+		t.Errorf("Expected 1 chunk with a 64KB threshold, got %d", got)
+	}
+}
+
 func BenchmarkStreamTickets(b *testing.B) {
 	db := setupBenchmarkDB(b)
 	repo := NewRepository(db)
@@ -0,0 +1,85 @@
+package tickets
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LookupOperators are the operators that look values up in a data source
+// (dictionaries, reference tables). OperatorConfig.Lookups bounds how many
+// of their calls run at once.
+var LookupOperators = map[string]bool{
+	"translate": true,
+	"dbEnum":    true,
+}
+
+// LookupLimiter is a semaphore shared by the lookup operators of every
+// registry built with it, so parallel transforms (e.g. TRANSFORM_WORKERS)
+// cannot overwhelm the data sources behind them. Set it as
+// OperatorConfig.Lookups; keep one across config reloads, like
+// OperatorMetrics, so the limit holds for in-flight streams too.
+type LookupLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// DefaultLookupWait is how long a lookup waits for a free slot before it fails
+const DefaultLookupWait = 30 * time.Second
+
+// ErrLookupBusy is the error of a lookup that found no free slot in time; it
+// fails the stream like any other operator error
+var ErrLookupBusy = errors.New("too many concurrent lookups")
+
+// NewLookupLimiter returns a limiter allowing max concurrent lookups, or nil
+// (no limit) when max <= 0. A lookup waits at most DefaultLookupWait for a
+// slot.
+func NewLookupLimiter(max int) *LookupLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &LookupLimiter{slots: make(chan struct{}, max), wait: DefaultLookupWait}
+}
+
+// SetWait sets how long a lookup waits for a free slot before failing with
+// ErrLookupBusy. Call it before the limiter is used.
+func (l *LookupLimiter) SetWait(wait time.Duration) {
+	l.wait = wait
+}
+
+// Limit returns the most lookups that run at once
+func (l *LookupLimiter) Limit() int {
+	return cap(l.slots)
+}
+
+// acquire takes a slot, waiting for one at most l.wait when all are taken.
+// Operators get no request context, so the wait is bounded by time: a
+// stuck lookup cannot hold every other stream forever.
+func (l *LookupLimiter) acquire() error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%w: no free slot within %v", ErrLookupBusy, l.wait)
+	}
+}
+
+// withLookupLimit wraps a lookup operator so every call holds a slot of
+// limiter while it runs, waiting for one when all are taken
+func withLookupLimit(fn OperatorFunc, limiter *LookupLimiter) OperatorFunc {
+	return func(params []interface{}) (interface{}, error) {
+		if err := limiter.acquire(); err != nil {
+			return nil, err
+		}
+		defer func() { <-limiter.slots }()
+		return fn(params)
+	}
+}
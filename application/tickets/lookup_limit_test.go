@@ -0,0 +1,135 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stream/internal/stream"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLookupLimiter(t *testing.T) {
+	t.Run("parallel transform stays within the limit", func(t *testing.T) {
+		const limit = 2
+		config := OperatorConfig{Lookups: NewLookupLimiter(limit)}.withDefaults()
+
+		// lookup records how many of its calls overlap
+		var active, peak atomic.Int64
+		lookup := func(params []interface{}) (interface{}, error) {
+			running := active.Add(1)
+			defer active.Add(-1)
+			for {
+				seen := peak.Load()
+				if running <= seen || peak.CompareAndSwap(seen, running) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return params[0], nil
+		}
+
+		operators := NewOperatorRegistry(config)
+		operators["dbEnum"] = wrapOperator("dbEnum", lookup, config)
+		formulas := []Formula{
+			{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+			{Params: []string{"status"}, Field: "status", Operator: "dbEnum", Position: 2},
+		}
+
+		rows := make([]RowData, 64)
+		for i := range rows {
+			rows[i] = RowData{"id": int64(i), "status": fmt.Sprintf("status-%d", i)}
+		}
+
+		transform := newRowTransform(formulas, operators, false)
+		parallel := stream.BatchTransformParallel(context.Background(), 8, func(row RowData) (interface{}, error) {
+			return transform(row)
+		})
+		results, err := parallel(rows)
+		if err != nil {
+			t.Fatalf("parallel transform error = %v", err)
+		}
+		if len(results) != len(rows) {
+			t.Fatalf("transformed %d rows, want %d", len(results), len(rows))
+		}
+
+		if got := peak.Load(); got > limit {
+			t.Errorf("peak concurrent lookups = %d, want at most %d", got, limit)
+		}
+	})
+
+	t.Run("other operators are not limited", func(t *testing.T) {
+		config := OperatorConfig{Lookups: NewLookupLimiter(1)}.withDefaults()
+
+		// Two calls that only return once both run: a limit would deadlock
+		started := make(chan struct{}, 2)
+		both := func(params []interface{}) (interface{}, error) {
+			started <- struct{}{}
+			for len(started) < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			return params[0], nil
+		}
+		upper := wrapOperator("upper", both, config)
+
+		done := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				_, err := upper([]interface{}{"x"})
+				done <- err
+			}()
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("upper() error = %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("upper() calls did not run concurrently")
+			}
+		}
+	})
+
+	t.Run("a lookup without a free slot in time fails", func(t *testing.T) {
+		limiter := NewLookupLimiter(1)
+		limiter.SetWait(10 * time.Millisecond)
+		config := OperatorConfig{Lookups: limiter}.withDefaults()
+
+		// The first call holds the only slot until released
+		release := make(chan struct{})
+		held := make(chan struct{})
+		blocking := func(params []interface{}) (interface{}, error) {
+			close(held)
+			<-release
+			return params[0], nil
+		}
+		go wrapOperator("translate", blocking, config)([]interface{}{"x"})
+		<-held
+
+		_, err := wrapOperator("translate", passThrough, config)([]interface{}{"y"})
+		if !errors.Is(err, ErrLookupBusy) {
+			t.Errorf("translate() error = %v, want ErrLookupBusy", err)
+		}
+
+		// Once the slot is free again, lookups run
+		close(release)
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, err = wrapOperator("translate", passThrough, config)([]interface{}{"y"}); err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+		if err != nil {
+			t.Errorf("translate() after release error = %v", err)
+		}
+	})
+
+	if limiter := NewLookupLimiter(0); limiter != nil {
+		t.Errorf("NewLookupLimiter(0) = %v, want nil (no limit)", limiter)
+	}
+	if got := NewLookupLimiter(3).Limit(); got != 3 {
+		t.Errorf("Limit() = %d, want 3", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/guregu/null/v5"
 )
 
 // ScanRowGeneric scans a single row into a RowData map using column metadata
@@ -84,11 +86,17 @@ func extractAliasFromParam(param string) string {
 
 // TransformRow applies formulas to a RowData to produce TransformedRow
 // Formulas MUST be sorted by position before calling this function
+//
+// Only formula outputs are written: a selected column reaches the output
+// through a formula, never on its own. A formula whose Field is a column name
+// (e.g. upper of "status" as "status") therefore replaces the raw column: the
+// key appears once, with the transformed value, at the formula's Position.
 func TransformRow(row RowData, formulas []Formula, operators map[string]OperatorFunc) (TransformedRow, error) {
-	// Pre-allocate slice with exact size (formulas already sorted by position)
-	fields := make([]TransformedField, len(formulas))
+	// Pre-allocate one field per formula (formulas already sorted by position);
+	// formulas with OutputFields may add more
+	fields := make([]TransformedField, 0, len(formulas))
 
-	for i, formula := range formulas {
+	for _, formula := range formulas {
 		// Extract parameter values from the row
 		paramValues := make([]interface{}, len(formula.Params))
 		for j, paramName := range formula.Params {
@@ -105,6 +113,11 @@ func TransformRow(row RowData, formulas []Formula, operators map[string]Operator
 			paramValues[j] = val
 		}
 
+		// Operators such as substituteTemplate also read the whole row
+		if RowContextOperators[formula.Operator] {
+			paramValues = append(paramValues, map[string]interface{}(row))
+		}
+
 		// Get the operator function
 		operatorFunc, exists := operators[formula.Operator]
 		if !exists {
@@ -117,35 +130,177 @@ func TransformRow(row RowData, formulas []Formula, operators map[string]Operator
 			return TransformedRow{}, fmt.Errorf("failed to execute operator '%s': %w", formula.Operator, err)
 		}
 
+		// Spread a list or object result over the declared output fields
+		if len(formula.OutputFields) > 0 {
+			values, ok := toOutputValues(transformedValue, formula.OutputFields)
+			if !ok {
+				return TransformedRow{}, fmt.Errorf("operator '%s' must return a list or an object to fill outputFields, got %T", formula.Operator, transformedValue)
+			}
+			for k, name := range formula.OutputFields {
+				var value interface{} = null.String{}
+				if k < len(values) {
+					value = values[k]
+				}
+				fields = append(fields, TransformedField{Key: name, Value: value})
+			}
+			continue
+		}
+
 		// Store in ordered slice (maintains position order)
-		fields[i] = TransformedField{
+		fields = append(fields, TransformedField{
 			Key:   formula.Field,
 			Value: transformedValue,
-		}
+		})
 	}
 
 	return TransformedRow{fields: fields}, nil
 }
 
+// toOutputValues returns the elements of an operator result spread over
+// OutputFields: list elements by position, object values by field name (a
+// missing key is null). A null result has no elements; ok is false for other
+// values.
+func toOutputValues(value interface{}, names []string) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			if field, ok := v[name]; ok {
+				values[i] = field
+			} else {
+				values[i] = null.String{}
+			}
+		}
+		return values, true
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values, true
+	case null.String:
+		return nil, !v.Valid
+	default:
+		return nil, false
+	}
+}
+
 // BatchTransformRows transforms multiple rows in batch
+// Batches whose formulas are all pass-through (SELECT *-style exports) take a
+// fast path that copies values directly; see passThroughPlan
 func BatchTransformRows(rows []RowData, formulas []Formula, operators map[string]OperatorFunc, isFormatDate bool) ([]TransformedRow, error) {
 	results := make([]TransformedRow, len(rows))
+	transform := newRowTransform(formulas, operators, isFormatDate)
 
 	for i, row := range rows {
-		transformed, err := TransformRow(row, formulas, operators)
+		transformed, err := transform(row)
 		if err != nil {
 			return nil, fmt.Errorf("failed to transform row %d: %w", i, err)
 		}
+		results[i] = transformed
+	}
+
+	return results, nil
+}
 
-		// Post-process: format date* fields if requested
-		if isFormatDate {
-			transformed = formatDateFields(transformed)
+// newRowTransform returns the per-row transform of BatchTransformRows. It is
+// safe for concurrent use (e.g. by stream.BatchTransformParallel workers):
+// every call allocates its own fields and params, and formulas, operators and
+// the pass-through plan are only read.
+func newRowTransform(formulas []Formula, operators map[string]OperatorFunc, isFormatDate bool) func(RowData) (TransformedRow, error) {
+	transform := func(row RowData) (TransformedRow, error) {
+		return TransformRow(row, formulas, operators)
+	}
+	if plan, ok := newPassThroughPlan(formulas, operators); ok {
+		transform = plan.transform
+	}
+	if !isFormatDate {
+		return transform
+	}
+
+	// Post-process: format date* fields
+	return func(row RowData) (TransformedRow, error) {
+		transformed, err := transform(row)
+		if err != nil {
+			return TransformedRow{}, err
 		}
+		return formatDateFields(transformed), nil
+	}
+}
 
-		results[i] = transformed
+// passThroughPlan copies row values into output fields for formula sets
+// made only of pass-through formulas. Lookup keys are resolved once per
+// batch instead of once per field, and the pass-through operator is called
+// with one params slice per row (it still applies the registry's UTF-8
+// policy). A plan is not modified after creation, so goroutines may share it.
+type passThroughPlan struct {
+	formulas []Formula
+	keys     [][]string // lookup key of every param, per formula
+	operator OperatorFunc
+}
+
+// newPassThroughPlan returns a plan when every formula uses the empty
+// (pass-through) operator without OutputFields. ok is false otherwise, or
+// when the registry has no pass-through operator, so TransformRow reports it.
+func newPassThroughPlan(formulas []Formula, operators map[string]OperatorFunc) (*passThroughPlan, bool) {
+	if len(formulas) == 0 || operators[""] == nil {
+		return nil, false
 	}
 
-	return results, nil
+	keys := make([][]string, len(formulas))
+	for i, formula := range formulas {
+		if formula.Operator != "" || len(formula.OutputFields) > 0 || len(formula.Params) == 0 {
+			return nil, false
+		}
+
+		keys[i] = make([]string, len(formula.Params))
+		for j, paramName := range formula.Params {
+			keys[i][j] = paramName
+			if alias := extractAliasFromParam(paramName); alias != "" {
+				keys[i][j] = alias
+			}
+		}
+	}
+
+	return &passThroughPlan{
+		formulas: formulas,
+		keys:     keys,
+		operator: operators[""],
+	}, true
+}
+
+// transform produces the same row as TransformRow with passThrough: the value
+// of the first param, after checking that every param exists
+func (p *passThroughPlan) transform(row RowData) (TransformedRow, error) {
+	fields := make([]TransformedField, len(p.formulas))
+	params := make([]interface{}, 1)
+
+	for i, formula := range p.formulas {
+		var value interface{}
+		for j, key := range p.keys[i] {
+			val, exists := row[key]
+			if !exists {
+				return TransformedRow{}, fmt.Errorf("parameter '%s' (lookup key: '%s') not found in row data", formula.Params[j], key)
+			}
+			if j == 0 {
+				value = val
+			}
+		}
+
+		params[0] = value
+		output, err := p.operator(params)
+		if err != nil {
+			return TransformedRow{}, fmt.Errorf("failed to execute operator '%s': %w", formula.Operator, err)
+		}
+
+		fields[i] = TransformedField{Key: formula.Field, Value: output}
+	}
+
+	return TransformedRow{fields: fields}, nil
 }
 
 // formatDateFields formats all fields with "date" prefix to ISO 8601 GMT+7
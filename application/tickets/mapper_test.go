@@ -1,8 +1,17 @@
 package tickets
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"stream/internal/stream"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/guregu/null/v5"
 )
 
 func TestBatchTransformRows_WithDateFormatting(t *testing.T) {
@@ -431,3 +440,367 @@ func TestBatchTransformRows_EdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestTransformRow_OutputFields(t *testing.T) {
+	operators := GetOperatorRegistry()
+	formulas := []Formula{
+		{Params: []string{"id"}, Field: "id", Position: 1},
+		{Params: []string{"customer", "'|' AS delimiter"}, Field: "customer", Operator: "splitToColumns", Position: 2, OutputFields: []string{"first", "last", "tier"}},
+		{Params: []string{"status"}, Field: "status", Position: 3},
+	}
+	row := RowData{"id": 7, "customer": []uint8("John|Doe"), "delimiter": "|", "status": "open"}
+
+	transformed, err := TransformRow(row, formulas, operators)
+	if err != nil {
+		t.Fatalf("TransformRow() error = %v", err)
+	}
+
+	jsonData, err := transformed.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	want := `{"id":7,"first":"John","last":"Doe","tier":null,"status":"open"}`
+	if string(jsonData) != want {
+		t.Errorf("TransformRow() = %s, want %s", jsonData, want)
+	}
+
+	t.Run("null source fills all output fields with null", func(t *testing.T) {
+		row := RowData{"id": 7, "customer": nil, "delimiter": "|", "status": "open"}
+
+		transformed, err := TransformRow(row, formulas, operators)
+		if err != nil {
+			t.Fatalf("TransformRow() error = %v", err)
+		}
+		for _, name := range []string{"first", "last", "tier"} {
+			if value, _ := transformed.Get(name); value != (null.String{}) {
+				t.Errorf("Expected %s to be null, got %v", name, value)
+			}
+		}
+	})
+
+	t.Run("non-list result is an error", func(t *testing.T) {
+		formulas := []Formula{{Params: []string{"customer"}, Field: "customer", Operator: "upper", Position: 1, OutputFields: []string{"a", "b"}}}
+
+		if _, err := TransformRow(row, formulas, operators); err == nil {
+			t.Error("Expected error for outputFields on a non-list operator result")
+		}
+	})
+}
+
+// passThroughRows builds rows and pass-through formulas like a SELECT * export
+func passThroughRows(count int) ([]RowData, []Formula) {
+	columns := []string{"id", "ticket_no", "subject", "status", "priority", "created_at"}
+	formulas := make([]Formula, len(columns))
+	for i, column := range columns {
+		formulas[i] = Formula{Params: []string{column}, Field: column, Position: i + 1}
+	}
+	formulas = append(formulas, Formula{Params: []string{"COALESCE(agent, 'none') AS agent"}, Field: "agent", Position: len(columns) + 1})
+
+	rows := make([]RowData, count)
+	for i := range rows {
+		rows[i] = RowData{
+			"id":         int64(i + 1),
+			"ticket_no":  fmt.Sprintf("TKT-%06d", i+1),
+			"subject":    []uint8("Subject"),
+			"status":     "open",
+			"priority":   null.String{},
+			"created_at": int64(1700000000 + i),
+			"agent":      "none",
+		}
+	}
+	return rows, formulas
+}
+
+// transformRowsGeneric transforms rows through the operator registry only
+func transformRowsGeneric(rows []RowData, formulas []Formula, operators map[string]OperatorFunc) ([]TransformedRow, error) {
+	results := make([]TransformedRow, len(rows))
+	for i, row := range rows {
+		transformed, err := TransformRow(row, formulas, operators)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = transformed
+	}
+	return results, nil
+}
+
+func TestBatchTransformRows_PassThroughFastPath(t *testing.T) {
+	operators := GetOperatorRegistry()
+	rows, formulas := passThroughRows(1000)
+
+	if _, ok := newPassThroughPlan(formulas, operators); !ok {
+		t.Fatal("Expected pass-through formulas to take the fast path")
+	}
+
+	fast, err := BatchTransformRows(rows, formulas, operators, false)
+	if err != nil {
+		t.Fatalf("BatchTransformRows() error = %v", err)
+	}
+	generic, err := transformRowsGeneric(rows, formulas, operators)
+	if err != nil {
+		t.Fatalf("TransformRow() error = %v", err)
+	}
+	if !reflect.DeepEqual(fast, generic) {
+		t.Error("Fast path output differs from the generic path")
+	}
+
+	t.Run("operator formula takes generic path", func(t *testing.T) {
+		mixed := append([]Formula{{Params: []string{"status"}, Field: "upper_status", Operator: "upper", Position: 0}}, formulas...)
+		if _, ok := newPassThroughPlan(mixed, operators); ok {
+			t.Error("Expected formulas with an operator to take the generic path")
+		}
+	})
+
+	t.Run("missing param reports same error", func(t *testing.T) {
+		broken := append([]Formula{}, formulas...)
+		broken[1] = Formula{Params: []string{"ticket_no", "missing"}, Field: "ticket_no", Position: 2}
+
+		_, fastErr := BatchTransformRows(rows[:1], broken, operators, false)
+		_, genericErr := TransformRow(rows[0], broken, operators)
+		if fastErr == nil || genericErr == nil {
+			t.Fatalf("Expected errors, got fast=%v generic=%v", fastErr, genericErr)
+		}
+		if want := "failed to transform row 0: " + genericErr.Error(); fastErr.Error() != want {
+			t.Errorf("Fast path error = %q, want %q", fastErr, want)
+		}
+	})
+}
+
+func BenchmarkBatchTransformRows_PassThrough_FastPath(b *testing.B) {
+	operators := GetOperatorRegistry()
+	rows, formulas := passThroughRows(1000)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := BatchTransformRows(rows, formulas, operators, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBatchTransformRows_PassThrough_Generic(b *testing.B) {
+	operators := GetOperatorRegistry()
+	rows, formulas := passThroughRows(1000)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := transformRowsGeneric(rows, formulas, operators); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTransformRow_RowContext(t *testing.T) {
+	formulas := []Formula{
+		{Params: []string{"id"}, Field: "id", Position: 1},
+		{
+			Params:   []string{"'Ticket {{.ticket_no}} for {{.customer_name}} ({{.status}}){{.missing}}' AS tpl", "ticket_no", "customer_name", "status"},
+			Field:    "label",
+			Operator: "substituteTemplate",
+			Position: 2,
+		},
+	}
+	row := RowData{
+		"id":            1,
+		"tpl":           "Ticket {{.ticket_no}} for {{.customer_name}} ({{.status}}){{.missing}}",
+		"ticket_no":     []uint8("TKT-000001"),
+		"customer_name": "Budi",
+		"status":        "open",
+	}
+
+	transformed, err := TransformRow(row, formulas, GetOperatorRegistry())
+	if err != nil {
+		t.Fatalf("TransformRow() error = %v", err)
+	}
+	if label, _ := transformed.Get("label"); label != "Ticket TKT-000001 for Budi (open)" {
+		t.Errorf("label = %v, want %q", label, "Ticket TKT-000001 for Budi (open)")
+	}
+}
+
+func TestTransformRow_FormulaOverridesColumn(t *testing.T) {
+	// "status" is both a selected column and the field of the formula
+	// transforming it; "priority" is selected by no formula
+	formulas := SortFormulas([]Formula{
+		{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 2},
+		{Params: []string{"id"}, Field: "id", Position: 1},
+		{Params: []string{"subject"}, Field: "subject", Position: 3},
+	})
+	rows := []RowData{{"id": 7, "status": "open", "subject": "Printer jam", "priority": "high"}}
+
+	transformed, err := BatchTransformRows(rows, formulas, GetOperatorRegistry(), false)
+	if err != nil {
+		t.Fatalf("BatchTransformRows() error = %v", err)
+	}
+
+	jsonData, err := transformed[0].MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	want := `{"id":7,"status":"OPEN","subject":"Printer jam"}`
+	if string(jsonData) != want {
+		t.Errorf("BatchTransformRows() = %s, want %s", jsonData, want)
+	}
+}
+
+func TestBatchTransformRows_InvalidUTF8(t *testing.T) {
+	rows := []RowData{
+		{"id": 1, "name": "caf\xe9", "raw": []uint8("ok\xff\xfe"), "valid": []uint8("Budi")},
+	}
+
+	tests := []struct {
+		name     string
+		policy   UTF8Policy
+		formulas []Formula
+		want     string
+	}{
+		{
+			name:   "pass-through replaces invalid sequences",
+			policy: UTF8Replace,
+			formulas: []Formula{
+				{Params: []string{"id"}, Field: "id", Position: 1},
+				{Params: []string{"name"}, Field: "name", Position: 2},
+				{Params: []string{"raw"}, Field: "raw", Position: 3},
+				{Params: []string{"valid"}, Field: "valid", Position: 4},
+			},
+			want: `{"id":1,"name":"caf�","raw":"ok�","valid":"Budi"}`,
+		},
+		{
+			name:   "pass-through nulls invalid fields",
+			policy: UTF8Null,
+			formulas: []Formula{
+				{Params: []string{"id"}, Field: "id", Position: 1},
+				{Params: []string{"name"}, Field: "name", Position: 2},
+				{Params: []string{"raw"}, Field: "raw", Position: 3},
+				{Params: []string{"valid"}, Field: "valid", Position: 4},
+			},
+			want: `{"id":1,"name":null,"raw":null,"valid":"Budi"}`,
+		},
+		{
+			name:   "operator results are checked too",
+			policy: UTF8Null,
+			formulas: []Formula{
+				{Params: []string{"id"}, Field: "id", Position: 1},
+				{Params: []string{"raw", "valid"}, Field: "joined", Operator: "concat", Position: 2},
+			},
+			want: `{"id":1,"joined":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operators := NewOperatorRegistry(OperatorConfig{InvalidUTF8: tt.policy})
+			results, err := BatchTransformRows(rows, tt.formulas, operators, false)
+			if err != nil {
+				t.Fatalf("BatchTransformRows() error = %v", err)
+			}
+
+			data, err := json.Marshal(results[0])
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if !utf8.Valid(data) || !json.Valid(data) {
+				t.Fatalf("output is not valid UTF-8 JSON: %q", data)
+			}
+
+			var got, want interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("bad want: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("output = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+// TestBatchTransformRows_Concurrent transforms the same rows with one formula
+// set and operator registry from many goroutines; run with -race to check that
+// the transforms share no mutable state
+func TestBatchTransformRows_Concurrent(t *testing.T) {
+	operators := GetOperatorRegistry()
+	rows, passThrough := passThroughRows(200)
+	for i, row := range rows {
+		row["payload"] = fmt.Sprintf(`{"items":[{"sku":"A%d"}]}`, i)
+		row["template"] = "Ticket {{.ticket_no}} ({{.status}})"
+		row["pattern"] = "^TKT-0+1"
+		row["path"] = "$.items[*].sku"
+		row["date_created"] = row["created_at"]
+	}
+
+	mixed := []Formula{
+		{Params: []string{"id"}, Field: "ticket", Operator: "ticketIdMasking", Position: 1},
+		{Params: []string{"ticket_no", "status"}, Field: "label", Operator: "concat", Position: 2},
+		{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 3},
+		{Params: []string{"ticket_no", "pattern"}, Field: "first", Operator: "matches", Position: 4},
+		{Params: []string{"payload", "'$.items[*].sku' AS path"}, Field: "sku", Operator: "jsonPath", Position: 5},
+		{Params: []string{"template"}, Field: "summary", Operator: "substituteTemplate", Position: 6},
+		{Params: []string{"date_created"}, Field: "date_created", Position: 7},
+	}
+	tests := []struct {
+		name     string
+		formulas []Formula
+	}{
+		{"pass-through", passThrough},
+		{"operators", mixed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := BatchTransformRows(rows, tt.formulas, operators, true)
+			if err != nil {
+				t.Fatalf("BatchTransformRows() error = %v", err)
+			}
+
+			const goroutines = 16
+			results := make([][]TransformedRow, goroutines)
+			errs := make([]error, goroutines)
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[g], errs[g] = BatchTransformRows(rows, tt.formulas, operators, true)
+				}()
+			}
+			wg.Wait()
+
+			for g := range results {
+				if errs[g] != nil {
+					t.Fatalf("goroutine %d: BatchTransformRows() error = %v", g, errs[g])
+				}
+				if !reflect.DeepEqual(results[g], want) {
+					t.Fatalf("goroutine %d: output differs from the sequential transform", g)
+				}
+			}
+		})
+
+		t.Run(tt.name+" with a shared row transform", func(t *testing.T) {
+			want, err := BatchTransformRows(rows, tt.formulas, operators, true)
+			if err != nil {
+				t.Fatalf("BatchTransformRows() error = %v", err)
+			}
+
+			transform := newRowTransform(tt.formulas, operators, true)
+			parallel := stream.BatchTransformParallel(context.Background(), 8, func(row RowData) (interface{}, error) {
+				return transform(row)
+			})
+			got, err := parallel(rows)
+			if err != nil {
+				t.Fatalf("parallel transform error = %v", err)
+			}
+
+			for i := range got {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Fatalf("row %d: got %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package tickets
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumberLocale names the thousands separator and decimal mark formatNumber
+// writes, e.g. "en-US" for 1,234,567.89 or "id-ID" for 1.234.567,89
+type NumberLocale string
+
+// DefaultNumberLocale is the locale of formulas that do not pass one
+const DefaultNumberLocale NumberLocale = "en-US"
+
+// numberSeparators are the thousands separator and decimal mark of a locale
+type numberSeparators struct {
+	group   string
+	decimal string
+}
+
+// numberLocales are the supported locales
+var numberLocales = map[NumberLocale]numberSeparators{
+	"en-US": {group: ",", decimal: "."},
+	"en-GB": {group: ",", decimal: "."},
+	"id-ID": {group: ".", decimal: ","},
+	"de-DE": {group: ".", decimal: ","},
+	"fr-FR": {group: "\u202f", decimal: ","}, // narrow no-break space
+}
+
+// numberLanguages are the locales selected by a bare language tag
+var numberLanguages = map[string]NumberLocale{
+	"en": "en-US",
+	"id": "id-ID",
+	"de": "de-DE",
+	"fr": "fr-FR",
+}
+
+// ParseNumberLocale returns the NumberLocale tagged name ("en-US", "en-GB",
+// "id-ID", "de-DE" or "fr-FR", case-insensitive, "_" for "-"); a bare
+// language such as "id" selects its locale above. ok is false for other names.
+func ParseNumberLocale(name string) (NumberLocale, bool) {
+	tag := strings.ReplaceAll(strings.TrimSpace(name), "_", "-")
+	for locale := range numberLocales {
+		if strings.EqualFold(tag, string(locale)) {
+			return locale, true
+		}
+	}
+	locale, ok := numberLanguages[strings.ToLower(tag)]
+	return locale, ok
+}
+
+// format writes value rounded to decimals digits with the separators of the
+// locale (DefaultNumberLocale for unknown locales); ok is false for NaN and
+// infinities
+func (l NumberLocale) format(value float64, decimals int) (string, bool) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", false
+	}
+	separators, known := numberLocales[l]
+	if !known {
+		separators = numberLocales[DefaultNumberLocale]
+	}
+
+	// Round halves away from zero like roundFloat (FormatFloat rounds 2.5
+	// to "2"), unless scaling overflows
+	if rounded := roundFloat(value, decimals); !math.IsInf(rounded, 0) && !math.IsNaN(rounded) {
+		value = rounded
+	}
+	text := strconv.FormatFloat(value, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		text = text[1:]
+		// Values rounding to zero lose their sign: -0.001 is "0.00"
+		if strings.Trim(text, "0.") != "" {
+			sign = "-"
+		}
+	}
+	integer, fraction, _ := strings.Cut(text, ".")
+
+	var out strings.Builder
+	out.WriteString(sign)
+	for i := 0; i < len(integer); i++ {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			out.WriteString(separators.group)
+		}
+		out.WriteByte(integer[i])
+	}
+	if fraction != "" {
+		out.WriteString(separators.decimal)
+		out.WriteString(fraction)
+	}
+	return out.String(), true
+}
@@ -0,0 +1,158 @@
+package tickets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the operator latency
+// histogram buckets, from 1µs (pass-through, case changes) to 100ms
+// (decrypt or template rendering of large values)
+func DefaultLatencyBuckets() []time.Duration {
+	return []time.Duration{
+		time.Microsecond, 5 * time.Microsecond, 10 * time.Microsecond, 50 * time.Microsecond,
+		100 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond,
+		10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	}
+}
+
+// OperatorMetrics accumulates a latency histogram per formula operator, to
+// find the operators that slow down the transform. Set it as
+// OperatorConfig.Metrics to enable timing; it is safe for concurrent use
+// and serves the histograms in the Prometheus text format.
+type OperatorMetrics struct {
+	buckets []time.Duration
+
+	mu         sync.Mutex
+	histograms map[string]*latencyHistogram
+}
+
+// latencyHistogram counts the calls of one operator per latency bucket
+type latencyHistogram struct {
+	buckets []time.Duration
+	counts  []atomic.Uint64 // per bucket, non-cumulative; last is +Inf
+	sum     atomic.Int64    // total nanoseconds
+}
+
+// NewOperatorMetrics returns empty metrics with DefaultLatencyBuckets
+func NewOperatorMetrics() *OperatorMetrics {
+	return &OperatorMetrics{
+		buckets:    DefaultLatencyBuckets(),
+		histograms: make(map[string]*latencyHistogram),
+	}
+}
+
+// histogram returns the histogram of operator name, creating it on first use.
+// Registries rebuilt on reload find the histograms of the previous ones.
+func (m *OperatorMetrics) histogram(name string) *latencyHistogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.histograms[name]
+	if !ok {
+		h = &latencyHistogram{buckets: m.buckets, counts: make([]atomic.Uint64, len(m.buckets)+1)}
+		m.histograms[name] = h
+	}
+	return h
+}
+
+// observe records one call taking d
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(h.buckets), func(i int) bool { return d <= h.buckets[i] })
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Latency returns how many calls of operator name were timed and their total
+// duration
+func (m *OperatorMetrics) Latency(name string) (count uint64, total time.Duration) {
+	m.mu.Lock()
+	h, ok := m.histograms[name]
+	m.mu.Unlock()
+	if !ok {
+		return 0, 0
+	}
+
+	for i := range h.counts {
+		count += h.counts[i].Load()
+	}
+	return count, time.Duration(h.sum.Load())
+}
+
+// withTiming wraps an operator so each call is recorded in h
+func withTiming(fn OperatorFunc, h *latencyHistogram) OperatorFunc {
+	return func(params []interface{}) (interface{}, error) {
+		start := time.Now()
+		value, err := fn(params)
+		h.observe(time.Since(start))
+		return value, err
+	}
+}
+
+// WritePrometheus writes the histograms of the operators called so far as
+// stream_operator_duration_seconds{operator="..."}, sorted by operator. The
+// pass-through operator is labelled "passThrough".
+func (m *OperatorMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.histograms))
+	for name := range m.histograms {
+		names = append(names, name)
+	}
+	histograms := make([]*latencyHistogram, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		histograms[i] = m.histograms[name]
+	}
+	m.mu.Unlock()
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "# HELP stream_operator_duration_seconds Time spent in formula operators.")
+	fmt.Fprintln(out, "# TYPE stream_operator_duration_seconds histogram")
+
+	for i, h := range histograms {
+		// Load the counts once, so the buckets and the total agree
+		counts := make([]uint64, len(h.counts))
+		var count uint64
+		for j := range h.counts {
+			counts[j] = h.counts[j].Load()
+			count += counts[j]
+		}
+		if count == 0 {
+			continue
+		}
+
+		label := names[i]
+		if label == "" {
+			label = "passThrough"
+		}
+		label = strconv.Quote(label)
+
+		var cumulative uint64
+		for j, bound := range h.buckets {
+			cumulative += counts[j]
+			fmt.Fprintf(out, "stream_operator_duration_seconds_bucket{operator=%s,le=%q} %d\n",
+				label, strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(out, "stream_operator_duration_seconds_bucket{operator=%s,le=\"+Inf\"} %d\n", label, count)
+		fmt.Fprintf(out, "stream_operator_duration_seconds_sum{operator=%s} %s\n",
+			label, strconv.FormatFloat(time.Duration(h.sum.Load()).Seconds(), 'g', -1, 64))
+		fmt.Fprintf(out, "stream_operator_duration_seconds_count{operator=%s} %d\n", label, count)
+	}
+
+	return out.Flush()
+}
+
+// ServeHTTP serves the histograms for a Prometheus scrape (GET /metrics)
+func (m *OperatorMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
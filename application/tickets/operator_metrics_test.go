@@ -0,0 +1,90 @@
+package tickets
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOperatorMetrics(t *testing.T) {
+	t.Run("latency is recorded per operator", func(t *testing.T) {
+		metrics := NewOperatorMetrics()
+		config := OperatorConfig{Metrics: metrics}.withDefaults()
+
+		slow := func(params []interface{}) (interface{}, error) {
+			time.Sleep(2 * time.Millisecond)
+			return params[0], nil
+		}
+		fast := func(params []interface{}) (interface{}, error) {
+			return params[0], nil
+		}
+		operators := map[string]OperatorFunc{
+			"slow": wrapOperator("slow", slow, config),
+			"fast": wrapOperator("fast", fast, config),
+		}
+		formulas := []Formula{
+			{Params: []string{"id"}, Field: "slow", Operator: "slow", Position: 1},
+			{Params: []string{"id"}, Field: "fast", Operator: "fast", Position: 2},
+		}
+
+		const rows = 5
+		for i := 0; i < rows; i++ {
+			if _, err := TransformRow(RowData{"id": i}, formulas, operators); err != nil {
+				t.Fatalf("TransformRow() error = %v", err)
+			}
+		}
+
+		slowCount, slowTotal := metrics.Latency("slow")
+		fastCount, fastTotal := metrics.Latency("fast")
+		if slowCount != rows || fastCount != rows {
+			t.Fatalf("call counts = slow %d, fast %d, want %d each", slowCount, fastCount, rows)
+		}
+		if slowTotal < rows*2*time.Millisecond {
+			t.Errorf("slow total = %v, want at least %v", slowTotal, rows*2*time.Millisecond)
+		}
+		if fastTotal >= slowTotal {
+			t.Errorf("fast total %v should be lower than slow total %v", fastTotal, slowTotal)
+		}
+
+		var body strings.Builder
+		if err := metrics.WritePrometheus(&body); err != nil {
+			t.Fatalf("WritePrometheus() error = %v", err)
+		}
+		for _, want := range []string{
+			"# TYPE stream_operator_duration_seconds histogram\n",
+			`stream_operator_duration_seconds_bucket{operator="slow",le="0.001"} 0` + "\n",
+			`stream_operator_duration_seconds_bucket{operator="slow",le="+Inf"} 5` + "\n",
+			`stream_operator_duration_seconds_count{operator="fast"} 5` + "\n",
+		} {
+			if !strings.Contains(body.String(), want) {
+				t.Errorf("metrics output missing %q:\n%s", want, body.String())
+			}
+		}
+	})
+
+	t.Run("registry operators are timed when enabled", func(t *testing.T) {
+		metrics := NewOperatorMetrics()
+		registry := NewOperatorRegistry(OperatorConfig{Metrics: metrics})
+		formulas := []Formula{
+			{Params: []string{"name"}, Field: "name", Position: 1},
+			{Params: []string{"name"}, Field: "upper", Operator: "upper", Position: 2},
+		}
+
+		if _, err := BatchTransformRows([]RowData{{"name": "a"}, {"name": "b"}}, formulas, registry, false); err != nil {
+			t.Fatalf("BatchTransformRows() error = %v", err)
+		}
+		if count, _ := metrics.Latency("upper"); count != 2 {
+			t.Errorf("upper calls = %d, want 2", count)
+		}
+		if count, _ := metrics.Latency("lower"); count != 0 {
+			t.Errorf("lower calls = %d, want 0", count)
+		}
+
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		if body := recorder.Body.String(); !strings.Contains(body, `operator="passThrough"`) || strings.Contains(body, `operator="lower"`) {
+			t.Errorf("metrics output should list called operators only:\n%s", body)
+		}
+	})
+}
@@ -1,38 +1,290 @@
 package tickets
 
 import (
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"encoding/xml"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	json "github.com/json-iterator/go"
 
 	"github.com/guregu/null/v5"
+	"github.com/ohler55/ojg/jp"
+)
+
+// OperatorConfig holds tenant-specific values used by the formula operators.
+// Zero values fall back to DefaultOperatorConfig, so a partial config is valid.
+type OperatorConfig struct {
+	// TicketPrefix is the prefix used by ticketIdMasking (default: "TICKET")
+	TicketPrefix string
+
+	// AdditionalDataPrefix is the default key prefix used by additionalData
+	// when the formula does not pass one (default: "additional")
+	AdditionalDataPrefix string
+
+	// SentimentLabels maps sentiment values to labels for sentimentMapping
+	SentimentLabels map[int]string
+
+	// SentimentScales are the named scales sentimentMapping selects with its
+	// second parameter (default: DefaultSentimentScales; configured scales
+	// are added to them, replacing those of the same name)
+	SentimentScales map[string]SentimentScale
+
+	// EscalatedLabels maps escalation flags to labels for escalatedMapping
+	EscalatedLabels map[int]string
+
+	// DecryptKey is the key used by decrypt and stripDecrypt
+	DecryptKey string
+
+	// Location converts ticketDate dates to the tenant timezone (nil keeps them as parsed)
+	Location *time.Location
+
+	// Now is the clock used by date operators such as ageInDays (default: time.Now)
+	Now func() time.Time
+
+	// Dictionaries are the named lookup tables used by translate (see LoadDictionaries)
+	Dictionaries Dictionaries
+
+	// BusinessHours is the working time counted by businessDuration
+	// (default: Monday to Friday, 09:00 to 17:00)
+	BusinessHours BusinessHours
+
+	// DateLayouts are the layouts parseDateFlexible tries in order
+	// (default: DefaultDateLayouts)
+	DateLayouts []string
+
+	// InvalidUTF8 decides how operator results with invalid UTF-8 are output
+	// (default: UTF8Replace)
+	InvalidUTF8 UTF8Policy
+
+	// NumberLocale sets the separators formatNumber writes when the formula
+	// does not pass a locale (default: "en-US")
+	NumberLocale NumberLocale
+
+	// FormatDateEpochs makes formatDate read numbers and numeric text as unix
+	// seconds; by default they are output as text
+	FormatDateEpochs bool
+
+	// Metrics records the latency of every operator call (nil disables
+	// timing, which then costs nothing)
+	Metrics *OperatorMetrics
+
+	// Lookups bounds the concurrent calls of LookupOperators across the
+	// streams of every registry sharing it (nil: no limit)
+	Lookups *LookupLimiter
+}
+
+// DefaultOperatorConfig returns the operator configuration used when no
+// tenant-specific values are provided.
+func DefaultOperatorConfig() OperatorConfig {
+	return OperatorConfig{
+		TicketPrefix:         "TICKET",
+		AdditionalDataPrefix: "additional",
+		SentimentLabels: map[int]string{
+			-1: "Negative",
+			0:  "Neutral",
+			1:  "Positive",
+		},
+		SentimentScales: DefaultSentimentScales(),
+		EscalatedLabels: map[int]string{
+			1: "escalated",
+			0: "not escalated",
+		},
+		Now:           time.Now,
+		BusinessHours: DefaultBusinessHours(),
+		DateLayouts:   DefaultDateLayouts(),
+		InvalidUTF8:   UTF8Replace,
+		NumberLocale:  DefaultNumberLocale,
+	}
+}
+
+// withDefaults fills empty fields from DefaultOperatorConfig
+func (c OperatorConfig) withDefaults() OperatorConfig {
+	defaults := DefaultOperatorConfig()
+	if c.TicketPrefix == "" {
+		c.TicketPrefix = defaults.TicketPrefix
+	}
+	if c.AdditionalDataPrefix == "" {
+		c.AdditionalDataPrefix = defaults.AdditionalDataPrefix
+	}
+	if len(c.SentimentLabels) == 0 {
+		c.SentimentLabels = defaults.SentimentLabels
+	}
+	scales := defaults.SentimentScales
+	maps.Copy(scales, c.SentimentScales)
+	c.SentimentScales = scales
+	if len(c.EscalatedLabels) == 0 {
+		c.EscalatedLabels = defaults.EscalatedLabels
+	}
+	if c.Now == nil {
+		c.Now = defaults.Now
+	}
+	c.BusinessHours = c.BusinessHours.withDefaults()
+	if len(c.DateLayouts) == 0 {
+		c.DateLayouts = defaults.DateLayouts
+	}
+	if _, ok := ParseUTF8Policy(string(c.InvalidUTF8)); !ok {
+		c.InvalidUTF8 = defaults.InvalidUTF8
+	}
+	if _, ok := numberLocales[c.NumberLocale]; !ok {
+		c.NumberLocale = defaults.NumberLocale
+	}
+	return c
+}
+
+// operatorSet binds the configurable operators to an OperatorConfig
+type operatorSet struct {
+	config OperatorConfig
+}
+
+// defaultOperators is the operator set for DefaultOperatorConfig.
+// The package-level names below are its bound methods.
+var defaultOperators = &operatorSet{config: DefaultOperatorConfig()}
+
+var (
+	ticketIdMasking   = defaultOperators.ticketIdMasking
+	sentimentMapping  = defaultOperators.sentimentMapping
+	escalatedMapping  = defaultOperators.escalatedMapping
+	ticketDate        = defaultOperators.ticketDate
+	additionalData    = defaultOperators.additionalData
+	decrypt           = defaultOperators.decrypt
+	stripDecrypt      = defaultOperators.stripDecrypt
+	ageInDays         = defaultOperators.ageInDays
+	translate         = defaultOperators.translate
+	businessDuration  = defaultOperators.businessDuration
+	parseDateFlexible = defaultOperators.parseDateFlexible
+	coalesceDate      = defaultOperators.coalesceDate
+	statusTimestamps  = defaultOperators.statusTimestamps
+	datePart          = defaultOperators.datePart
+	formatNumber      = defaultOperators.formatNumber
+	formatDate        = defaultOperators.formatDate
 )
 
 // GetOperatorRegistry returns a map of all available formula operators
+// using DefaultOperatorConfig
 func GetOperatorRegistry() map[string]OperatorFunc {
-	return map[string]OperatorFunc{
+	return NewOperatorRegistry(DefaultOperatorConfig())
+}
+
+// NewOperatorRegistry returns a map of all available formula operators with
+// tenant-specific values taken from config. Every operator's result goes
+// through the config.InvalidUTF8 policy, so one malformed value cannot
+// corrupt the output stream, and is timed when config.Metrics is set.
+func NewOperatorRegistry(config OperatorConfig) map[string]OperatorFunc {
+	ops := &operatorSet{config: config.withDefaults()}
+
+	registry := map[string]OperatorFunc{
 		"":                    passThrough,
-		"ticketIdMasking":     ticketIdMasking,
+		"ticketIdMasking":     ops.ticketIdMasking,
 		"difftime":            difftime,
-		"sentimentMapping":    sentimentMapping,
-		"escalatedMapping":    escalatedMapping,
+		"sentimentMapping":    ops.sentimentMapping,
+		"escalatedMapping":    ops.escalatedMapping,
 		"formatTime":          formatTime,
 		"stripHTML":           stripHTML,
 		"contacts":            contacts,
-		"ticketDate":          ticketDate,
-		"additionalData":      additionalData,
-		"decrypt":             decrypt,
-		"stripDecrypt":        stripDecrypt,
+		"ticketDate":          ops.ticketDate,
+		"additionalData":      ops.additionalData,
+		"decrypt":             ops.decrypt,
+		"stripDecrypt":        ops.stripDecrypt,
 		"transactionState":    transactionState,
 		"length":              length,
 		"processSurveyAnswer": processSurveyAnswer,
 		"concat":              concat,
 		"upper":               upper,
 		"lower":               lower,
-		"formatDate":          formatDate,
+		"formatDate":          ops.formatDate,
+		"percentOf":           percentOf,
+		"convertCase":         convertCase,
+		"scale":               scale,
+		"bucket":              bucket,
+		"jsonMerge":           jsonMerge,
+		"stripEmoji":          stripEmoji,
+		"matches":             matches,
+		"slugify":             slugify,
+		"changed":             changed,
+		"diffText":            diffText,
+		"ageInDays":           ops.ageInDays,
+		"translate":           ops.translate,
+		"countMatching":       countMatching,
+		"splitToColumns":      splitToColumns,
+		"jsonPath":            jsonPath,
+		"toJSON":              toJSON,
+		"redact":              redact,
+		"businessDuration":    ops.businessDuration,
+		"substituteTemplate":  substituteTemplate,
+		"sumFields":           sumFields,
+		"avgFields":           avgFields,
+		"parseDateFlexible":   ops.parseDateFlexible,
+		"coalesceDate":        ops.coalesceDate,
+		"formatBytes":         formatBytes,
+		"maskFormat":          maskFormat,
+		"dbEnum":              enumTables(nil).dbEnum, // bound per stream by the Service
+		"regexCapture":        regexCapture,
+		"parseKeyValue":       parseKeyValue,
+		"lengthInRange":       lengthInRange,
+		"hashBucket":          hashBucket,
+		"jsonSet":             jsonSet,
+		"statusTimestamps":    ops.statusTimestamps,
+		"padLeft":             padLeft,
+		"padRight":            padRight,
+		"xmlStrip":            xmlStrip,
+		"dedupeArray":         dedupeArray,
+		"coalesceTracked":     coalesceTracked,
+		"ordinal":             ordinal,
+		"numberToWords":       numberToWords,
+		"mostCommon":          mostCommon,
+		"datePart":            ops.datePart,
+		"validateNationalId":  validateNationalId,
+		"jsonArrayFilter":     jsonArrayFilter,
+		"formatNumber":        ops.formatNumber,
+		"hexEncode":           hexEncode,
+		"hexDecode":           hexDecode,
+		"base32Encode":        base32Encode,
+		"base32Decode":        base32Decode,
+	}
+
+	for name, fn := range registry {
+		registry[name] = wrapOperator(name, fn, ops.config)
+	}
+	return registry
+}
+
+// wrapOperator applies the registry-wide behaviour of config to operator
+// name: the UTF-8 policy and, when enabled, latency metrics and the lookup
+// concurrency limit
+func wrapOperator(name string, fn OperatorFunc, config OperatorConfig) OperatorFunc {
+	fn = withUTF8Output(fn, config.InvalidUTF8)
+	if config.Metrics != nil {
+		fn = withTiming(fn, config.Metrics.histogram(name))
 	}
+	// Outside the timing, so waiting for a slot is not counted as latency
+	if config.Lookups != nil && LookupOperators[name] {
+		fn = withLookupLimit(fn, config.Lookups)
+	}
+	return fn
+}
+
+// RowContextOperators are the operators that also receive the whole row: the
+// mappers append it as a map[string]interface{} after the formula params
+var RowContextOperators = map[string]bool{
+	"substituteTemplate": true,
 }
 
 // passThrough returns the first parameter as-is (no transformation)
@@ -51,7 +303,7 @@ func passThrough(params []interface{}) (interface{}, error) {
 //   - params[1]: (Optional) Date field (unix timestamp or time.Time) - used for date-based prefix
 //
 // Output:
-//   - Formatted string: "TICKET-0000012345" (default prefix)
+//   - Formatted string: "TICKET-0000012345" (prefix from OperatorConfig.TicketPrefix)
 //   - If date provided and prefix is "date", uses date format: "20250115-0000012345"
 //
 // Memory efficiency:
@@ -64,7 +316,7 @@ func passThrough(params []interface{}) (interface{}, error) {
 //	ticketIdMasking(12345, nil) -> "TICKET-0000012345"
 //	ticketIdMasking(12345, 1609459200) -> "TICKET-0000012345"
 //	ticketIdMasking(98765, time.Now()) -> "TICKET-0000098765"
-func ticketIdMasking(params []interface{}) (interface{}, error) {
+func (o *operatorSet) ticketIdMasking(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return nil, fmt.Errorf("ticketIdMasking requires at least 1 parameter (ticket_id)")
 	}
@@ -75,13 +327,8 @@ func ticketIdMasking(params []interface{}) (interface{}, error) {
 		return null.String{}, nil
 	}
 
-	// Default prefix
-	prefix := "TICKET"
-
-	// Optional: Use date for prefix if second param provided
-	// This matches the original implementation's settingPrefix logic
-	// For now, we use a simple TICKET prefix
-	// You can extend this to check a settings map if needed
+	// Tenant prefix from OperatorConfig (default "TICKET")
+	prefix := o.config.TicketPrefix
 
 	// Format: PREFIX-NNNNNNNNNN (10 digits, zero-padded)
 	// Stack-allocated string formatting - Go compiler optimizes this
@@ -135,27 +382,92 @@ func difftime(params []interface{}) (interface{}, error) {
 	return secondsToHHMMSS(diff), nil
 }
 
+// businessDuration calculates the business time elapsed between two
+// timestamps, excluding non-working days and hours outside the opening hours.
+// This operator measures SLA durations where difftime would count nights and
+// weekends.
+//
+// Parameters:
+//   - params[0]: First timestamp (unix seconds, time.Time or date text)
+//   - params[1]: Second timestamp (same formats)
+//
+// Output:
+//   - String: Business time in HH:MM:SS (hours may exceed 24)
+//   - null.String{} if either timestamp is missing or invalid (e.g. a ticket
+//     that is not resolved yet)
+//
+// Implementation Notes:
+//   - Working days and hours come from OperatorConfig.BusinessHours and are
+//     evaluated in its Location (default: OperatorConfig.Location, then UTC)
+//   - The order of the timestamps does not matter (absolute duration)
+//   - Date text without a zone is read as UTC, like ageInDays
+//   - Full weeks are counted arithmetically, so long spans stay cheap
+//
+// Examples (Monday to Friday, 09:00 to 17:00):
+//
+//	businessDuration("2024-01-05 16:00:00", "2024-01-08 10:00:00") -> "02:00:00" (Fri → Mon)
+//	businessDuration("2024-01-08 16:30:00", "2024-01-09 09:15:00") -> "00:45:00" (overnight)
+//	businessDuration("2024-01-06 10:00:00", "2024-01-07 18:00:00") -> "00:00:00" (weekend)
+//	businessDuration("2024-01-08 10:00:00", nil) -> null.String{}
+func (o *operatorSet) businessDuration(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return null.String{}, nil
+	}
+
+	from, ok := toTime(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+	to, ok := toTime(params[1])
+	if !ok {
+		return null.String{}, nil
+	}
+	if to.Before(from) {
+		from, to = to, from
+	}
+
+	hours := o.config.BusinessHours
+	location := hours.Location
+	if location == nil {
+		location = o.config.Location
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	return secondsToHHMMSS(int(hours.between(from, to, location).Seconds())), nil
+}
+
 // sentimentMapping maps numeric sentiment values to human-readable strings.
 // This operator converts sentiment analysis scores to descriptive labels.
 //
 // Parameters:
-//   - params[0]: Sentiment value (integer: -1, 0, or 1)
+//   - params[0]: Sentiment value (integer: -1, 0, or 1 without a scale)
+//   - params[1]: (Optional) Scale name, e.g. "'stars' AS scale" for 1-5 star
+//     ratings (see OperatorConfig.SentimentScales)
 //
-// Mapping:
+// Mapping without a scale (defaults, configurable via OperatorConfig.SentimentLabels):
 //   - -1 → "Negative"
 //   - 0 → "Neutral"
 //   - 1 → "Positive"
 //   - Other values → null (no output)
 //
+// Built-in scales (DefaultSentimentScales):
+//   - "five": -2 → "Very Negative", -1 → "Negative", 0 → "Neutral",
+//     1 → "Positive", 2 → "Very Positive"
+//   - "stars": 1-2 → "Negative", 3 → "Neutral", 4-5 → "Positive"
+//
 // Output:
-//   - String: "Negative", "Neutral", or "Positive"
-//   - null.String{} if the sentiment value is not in the expected range
+//   - String: the label of the value
+//   - null.String{} if the sentiment value is not in the expected range (or
+//     nil with a scale)
+//   - Error if the scale is not configured
 //
 // Memory efficiency:
-//   - Small map literal (3 entries) - compiler may stack-allocate
+//   - Labels map built once in OperatorConfig (no per-call allocation)
 //   - Single integer extraction (stack allocation)
 //   - No string allocations beyond map values (constants)
-//   - Map lookup is O(1)
+//   - Map lookup is O(1); a scale scans its few buckets
 //
 // Examples:
 //
@@ -163,24 +475,34 @@ func difftime(params []interface{}) (interface{}, error) {
 //	sentimentMapping(0) -> "Neutral"
 //	sentimentMapping(-1) -> "Negative"
 //	sentimentMapping(2) -> null.String{}
-func sentimentMapping(params []interface{}) (interface{}, error) {
+//	sentimentMapping(2, "five") -> "Very Positive"
+//	sentimentMapping(4, "stars") -> "Positive"
+//	sentimentMapping(6, "stars") -> null.String{}
+func (o *operatorSet) sentimentMapping(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return null.String{}, nil
 	}
 
+	if len(params) > 1 && toString(params[1]) != "" {
+		name := toString(params[1])
+		scale, ok := o.config.SentimentScales[name]
+		if !ok {
+			return nil, fmt.Errorf("sentimentMapping: unknown scale %q", name)
+		}
+		if isNullValue(params[0]) {
+			return null.String{}, nil
+		}
+		if label, ok := scale.label(toInt(params[0])); ok {
+			return label, nil
+		}
+		return null.String{}, nil
+	}
+
 	// Extract sentiment value - stack allocation
 	sentiment := toInt(params[0])
 
-	// Sentiment mapping - small constant map
-	// Go compiler may optimize this to a switch statement or stack allocation
-	sentimentMap := map[int]string{
-		-1: "Negative",
-		0:  "Neutral",
-		1:  "Positive",
-	}
-
-	// Map and return result
-	if mappedValue, ok := sentimentMap[sentiment]; ok {
+	// Map and return result using the configured labels
+	if mappedValue, ok := o.config.SentimentLabels[sentiment]; ok {
 		return mappedValue, nil
 	}
 
@@ -194,7 +516,7 @@ func sentimentMapping(params []interface{}) (interface{}, error) {
 // Parameters:
 //   - params[0]: Escalation flag (integer: 0 or 1)
 //
-// Mapping:
+// Mapping (defaults, configurable via OperatorConfig.EscalatedLabels):
 //   - 1 → "escalated"
 //   - 0 → "not escalated"
 //   - Other values → null (no output)
@@ -204,7 +526,7 @@ func sentimentMapping(params []interface{}) (interface{}, error) {
 //   - null.String{} if the value is not in the expected range
 //
 // Memory efficiency:
-//   - Small map literal (2 entries) - compiler may stack-allocate
+//   - Labels map built once in OperatorConfig (no per-call allocation)
 //   - Single integer extraction (stack allocation)
 //   - No string allocations beyond map values (constants)
 //   - Map lookup is O(1)
@@ -214,7 +536,7 @@ func sentimentMapping(params []interface{}) (interface{}, error) {
 //	escalatedMapping(1) -> "escalated"
 //	escalatedMapping(0) -> "not escalated"
 //	escalatedMapping(2) -> null.String{}
-func escalatedMapping(params []interface{}) (interface{}, error) {
+func (o *operatorSet) escalatedMapping(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return null.String{}, nil
 	}
@@ -222,15 +544,8 @@ func escalatedMapping(params []interface{}) (interface{}, error) {
 	// Extract escalation value - stack allocation
 	escalated := toInt(params[0])
 
-	// Escalation mapping - small constant map
-	// Go compiler may optimize this to a switch statement or stack allocation
-	escalatedMap := map[int]string{
-		1: "escalated",
-		0: "not escalated",
-	}
-
-	// Map and return result
-	if mappedValue, ok := escalatedMap[escalated]; ok {
+	// Map and return result using the configured labels
+	if mappedValue, ok := o.config.EscalatedLabels[escalated]; ok {
 		return mappedValue, nil
 	}
 
@@ -293,14 +608,16 @@ func formatTime(params []interface{}) (interface{}, error) {
 //   - No regex compilation (uses simple string iteration)
 //
 // Implementation:
-//   - Removes content between < and > tags
-//   - Handles nested tags
-//   - Preserves text content between tags
+//   - Removes real tags (<p>, </p>, <br/>, <!-- ... -->, <!DOCTYPE ...>)
+//   - Handles nested tags and quoted attribute values containing < or >
+//   - Preserves stray angle brackets in plain text ("5 < 10 and 20 > 15")
+//   - Preserves unterminated tags as text instead of swallowing the rest
 //
 // Examples:
 //
 //	stripHTML("<p>Hello</p>") -> "Hello"
 //	stripHTML("<b>Bold</b> text") -> "Bold text"
+//	stripHTML("a < b > c") -> "a < b > c"
 //	stripHTML("Plain text") -> "Plain text"
 //	stripHTML(nil) -> null.String{}
 func stripHTML(params []interface{}) (interface{}, error) {
@@ -323,27 +640,7 @@ func stripHTML(params []interface{}) (interface{}, error) {
 		return "", nil
 	}
 
-	// Strip HTML tags using simple iteration (memory efficient)
-	// Stack-allocated variables
-	var result strings.Builder
-	result.Grow(len(text)) // Preallocate capacity (avoid reallocation)
-
-	inTag := false
-	for _, char := range text {
-		if char == '<' {
-			inTag = true
-			continue
-		}
-		if char == '>' {
-			inTag = false
-			continue
-		}
-		if !inTag {
-			result.WriteRune(char)
-		}
-	}
-
-	return result.String(), nil
+	return stripHTMLTags(text), nil
 }
 
 // contacts processes contact data by decrypting contact values and structuring the output.
@@ -495,7 +792,7 @@ func contacts(params []interface{}) (interface{}, error) {
 //
 //	ticketDate('[{"status_id":2,"date_create":"2024-01-15"}]', "2006-01-02")
 //	  → {"status_dates": [{"status_id":2,"date_create":"2024-01-15"}]}
-func ticketDate(params []interface{}) (interface{}, error) {
+func (o *operatorSet) ticketDate(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return map[string]interface{}{}, nil
 	}
@@ -516,6 +813,11 @@ func ticketDate(params []interface{}) (interface{}, error) {
 	// Stack-allocated slice for status date data
 	var statusDateData []map[string]interface{}
 
+	// MySQL JSON columns arrive as bytes
+	if raw, ok := normalizeBytes(statusDateField).([]uint8); ok {
+		statusDateField = string(raw)
+	}
+
 	// Parse input based on type
 	switch v := statusDateField.(type) {
 	case string:
@@ -553,32 +855,12 @@ func ticketDate(params []interface{}) (interface{}, error) {
 	// Process and format dates
 	for i := range statusDateData {
 		if dateCreate, ok := statusDateData[i]["date_create"]; ok {
-			// Parse and format the date
+			// Parse and format the date; unparseable text is kept as-is
 			var formattedDate string
-
-			switch d := dateCreate.(type) {
-			case string:
-				// Try parsing common formats
-				if t, err := time.Parse("2006-01-02 15:04:05", d); err == nil {
-					formattedDate = t.Format(dateFormat)
-				} else if t, err := time.Parse(time.RFC3339, d); err == nil {
-					formattedDate = t.Format(dateFormat)
-				} else if t, err := time.Parse("2006-01-02", d); err == nil {
-					formattedDate = t.Format(dateFormat)
-				} else {
-					formattedDate = d // Keep original if can't parse
-				}
-
-			case time.Time:
-				formattedDate = d.Format(dateFormat)
-
-			case int64:
-				t := time.Unix(d, 0)
-				formattedDate = t.Format(dateFormat)
-
-			case float64:
-				t := time.Unix(int64(d), 0)
-				formattedDate = t.Format(dateFormat)
+			if t, ok := toTime(dateCreate); ok {
+				formattedDate = o.inLocation(t).Format(dateFormat)
+			} else if text, ok := normalizeBytes(dateCreate).(string); ok {
+				formattedDate = text
 			}
 
 			if formattedDate != "" {
@@ -590,644 +872,3331 @@ func ticketDate(params []interface{}) (interface{}, error) {
 	return statusDateData, nil
 }
 
-// additionalData processes additional data fields by parsing JSON and structuring the output.
-// This operator handles dynamic additional data that can contain arbitrary key-value pairs.
+// statusTimestamps pivots a status history into one timestamp per status.
+// This operator turns the ticketDate history into flat columns such as
+// first_open_at and first_resolved_at.
 //
 // Parameters:
-//   - params[0]: Additional data field (JSON string or map)
-//   - params[1]: (Optional) Prefix for output keys (default: "additional")
+//   - params[0]: Status history, as for ticketDate (JSON array string,
+//     []uint8 or decoded list of {"status_id", "date_create"} objects)
+//   - params[1]: Mapping of status_id to column name, as a JSON object
+//     string or map, e.g. '{"1":"first_open_at","3":"first_resolved_at"}'
+//   - params[2]: (Optional) "null" (or true) includes the mapped statuses
+//     missing from the history as null (default: omitted)
+//   - params[3]: (Optional) Date format (default: RFC3339)
 //
 // Output:
-//   - Map containing parsed additional data with optional prefix
-//   - Structure: {"additional_key1": "value1", "additional_key2": "value2"}
-//   - Keys are sanitized (spaces replaced with underscores)
-//   - Returns empty map if no valid additional data
+//   - map[string]interface{}: Formatted date of the first (earliest)
+//     occurrence of each mapped status, by column name
+//   - Empty map (or nulls, see params[2]) for a nil or invalid history
+//   - Error if the mapping is missing or not an object
 //
-// Memory efficiency:
-//   - Preallocates map with estimated capacity
-//   - Stack-allocated string operations
-//   - Minimal allocations during key transformation
-//   - No unnecessary intermediate structures
-//
-// Input format:
-//   - JSON string: '{"custom_field1":"value1","custom_field2":"value2"}'
-//   - Map with additional data
+// Implementation Notes:
+//   - Dates are parsed like ticketDate and converted to OperatorConfig.Location;
+//     entries with an unparseable date or an unmapped status are skipped
+//   - Status ids are compared as text, so 1, "1" and 1.0 match the key "1"
+//   - Combined with Formula.OutputFields naming the columns, each status
+//     becomes its own output field
 //
 // Examples:
 //
-//	additionalData('{"field1":"value1","field2":"value2"}')
-//	  → {"additional_field1":"value1","additional_field2":"value2"}
-//
-//	additionalData('{"field1":"value1"}', "custom")
-//	  → {"custom_field1":"value1"}
-//
-//	additionalData('{"Customer Name":"John Doe"}')
-//	  → {"additional_Customer_Name":"John Doe"}  // Spaces replaced with underscores
-func additionalData(params []interface{}) (interface{}, error) {
-	if len(params) < 1 {
-		return map[string]interface{}{}, nil
+//	statusTimestamps('[{"status_id":1,"date_create":"2024-01-15 10:30:00"},{"status_id":3,"date_create":"2024-01-16 09:00:00"}]',
+//	  '{"1":"first_open_at","3":"first_resolved_at"}')
+//	  -> {"first_open_at":"2024-01-15T10:30:00Z","first_resolved_at":"2024-01-16T09:00:00Z"}
+//	statusTimestamps('[]', '{"1":"first_open_at"}', "null") -> {"first_open_at":null}
+func (o *operatorSet) statusTimestamps(params []interface{}) (interface{}, error) {
+	if len(params) < 2 || isNullValue(params[1]) {
+		return nil, fmt.Errorf("statusTimestamps requires 2 parameters (history, mapping)")
 	}
 
-	additionalField := params[0]
-	if additionalField == nil {
-		return map[string]interface{}{}, nil
+	document, ok := toJSONDocument(params[1])
+	mapping, isObject := document.(map[string]interface{})
+	if !ok || !isObject {
+		return nil, fmt.Errorf("statusTimestamps: mapping must be a JSON object of status_id to column, got %v", params[1])
 	}
 
-	// Optional prefix (default to "additional")
-	prefix := "additional"
-	if len(params) > 1 {
-		if p, ok := params[1].(string); ok && p != "" {
-			prefix = p
-		}
+	includeMissing := false
+	if len(params) > 2 {
+		flag := strings.ToLower(strings.TrimSpace(toString(params[2])))
+		includeMissing = flag == "null" || flag == "true"
 	}
 
-	// Parse additional data
-	var additionalDataMap map[string]interface{}
+	dateFormat := time.RFC3339
+	if len(params) > 3 && toString(params[3]) != "" {
+		dateFormat = toString(params[3])
+	}
 
-	switch v := additionalField.(type) {
-	case string:
-		if strings.TrimSpace(v) == "" {
-			return map[string]interface{}{}, nil
+	// Earliest date per mapped status
+	first := make(map[string]time.Time, len(mapping))
+	history, _ := toJSONArray(params[0])
+	for _, item := range history {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status := toString(entry["status_id"])
+		if _, mapped := mapping[status]; !mapped {
+			continue
+		}
+		date, ok := toTime(entry["date_create"])
+		if !ok {
+			continue
 		}
+		if earliest, seen := first[status]; !seen || date.Before(earliest) {
+			first[status] = date
+		}
+	}
 
-		// Try parsing as JSON
-		if err := json.Unmarshal([]byte(v), &additionalDataMap); err != nil {
-			// If parsing fails, return empty map
-			return map[string]interface{}{}, nil
+	result := make(map[string]interface{}, len(mapping))
+	for status, column := range mapping {
+		name := toString(column)
+		if name == "" {
+			continue
+		}
+		if date, ok := first[status]; ok {
+			result[name] = o.inLocation(date).Format(dateFormat)
+		} else if includeMissing {
+			result[name] = nil
 		}
+	}
 
-	case map[string]interface{}:
-		additionalDataMap = v
+	return result, nil
+}
 
-	default:
-		return map[string]interface{}{}, nil
+// ageInDays computes the number of whole days between a date and a reference
+// date, e.g. customer tenure or ticket age.
+//
+// Parameters:
+//   - params[0]: Date (time.Time, unix seconds, or "2006-01-02 15:04:05",
+//     RFC3339 or "2006-01-02" text; []uint8 accepted)
+//   - params[1]: (Optional) Reference date in the same formats (default: now,
+//     from OperatorConfig.Now); nil or "" also means now
+//   - params[2]: (Optional) "signed" (or true) returns reference minus date,
+//     negative for dates after the reference (default: absolute value)
+//
+// Output:
+//   - int: Number of calendar days between the two dates
+//   - null.Int{} if the date or reference date is nil or invalid
+//
+// Implementation Notes:
+//   - Days are counted between calendar dates in OperatorConfig.Location
+//     (UTC when unset), so 23:00 to 01:00 the next day is 1 day
+//
+// Examples:
+//
+//	ageInDays("2024-01-01", "2024-03-01") -> 60
+//	ageInDays("2024-03-01", "2024-01-01") -> 60
+//	ageInDays("2024-03-01", "2024-01-01", "signed") -> -60
+//	ageInDays("not a date") -> null.Int{}
+func (o *operatorSet) ageInDays(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.Int{}, nil
 	}
 
-	if additionalDataMap == nil || len(additionalDataMap) == 0 {
-		return map[string]interface{}{}, nil
+	date, ok := toTime(params[0])
+	if !ok {
+		return null.Int{}, nil
 	}
 
-	// Process and add prefix to keys
-	// Preallocate result map with known capacity
-	result := make(map[string]interface{}, len(additionalDataMap))
+	reference := o.config.Now()
+	if len(params) > 1 && !isNullValue(params[1]) && toString(params[1]) != "" {
+		if reference, ok = toTime(params[1]); !ok {
+			return null.Int{}, nil
+		}
+	}
 
-	for key, value := range additionalDataMap {
-		// Sanitize key: replace spaces with underscores
-		sanitizedKey := strings.ReplaceAll(key, " ", "_")
+	signed := false
+	if len(params) > 2 {
+		flag := strings.ToLower(strings.TrimSpace(toString(params[2])))
+		signed = flag == "signed" || flag == "true"
+	}
 
-		// Add prefix
-		prefixedKey := prefix + "_" + sanitizedKey
+	days := calendarDays(o.inDayLocation(reference)) - calendarDays(o.inDayLocation(date))
+	if !signed && days < 0 {
+		days = -days
+	}
 
-		result[prefixedKey] = value
+	return days, nil
+}
+
+// inDayLocation converts t to the tenant timezone used for calendar-day math
+func (o *operatorSet) inDayLocation(t time.Time) time.Time {
+	if o.config.Location == nil {
+		return t.UTC()
 	}
+	return t.In(o.config.Location)
+}
 
-	return result, nil
+// calendarDays returns the day number of t's calendar date (days since the unix epoch)
+func calendarDays(t time.Time) int {
+	year, month, day := t.Date()
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
 }
 
-// transactionState maps transaction state values to descriptive strings.
-// This operator converts numeric transaction states to readable flow identifiers.
+// translate looks up a value in a named dictionary loaded from a file at
+// startup (see LoadDictionaries and OperatorConfig.Dictionaries).
+// This operator maps large static code lists (country codes, status codes) to labels.
 //
 // Parameters:
-//   - params[0]: Transaction state value (integer, string, or any type)
-//
-// Mapping:
-//   - 0 (or "0") → "primary"
-//   - Any other value → "flow {value}" (e.g., "flow 1", "flow 2")
-//   - nil → null.String{}
+//   - params[0]: Key to look up (any value is converted via toString and trimmed)
+//   - params[1]: Dictionary name (e.g. "country" for country.json)
+//   - params[2]: (Optional) Default returned when the key is not in the dictionary
 //
 // Output:
-//   - String: "primary" for initial state (0)
-//   - String: "flow N" for flow states (non-zero values)
-//   - null.String{} if source value is nil
-//
-// Memory efficiency:
-//   - Stack-allocated string comparison
-//   - Single string concatenation using + operator (compiler optimizes)
-//   - No intermediate allocations
-//   - fmt.Sprintf used only for type conversion
-//
-// Use Cases:
-//   - Tracking transaction workflow stages
-//   - Identifying primary vs. secondary transaction flows
-//   - Displaying transaction state in reports
+//   - String: The dictionary value for the key
+//   - params[2] if the key is missing and a default is given
+//   - null.String{} if the key is nil or missing without a default
+//   - Error if the dictionary is not configured
 //
 // Examples:
 //
-//	transactionState(0) -> "primary"
-//	transactionState("0") -> "primary"
-//	transactionState(1) -> "flow 1"
-//	transactionState(2) -> "flow 2"
-//	transactionState("3") -> "flow 3"
-//	transactionState(nil) -> null.String{}
-func transactionState(params []interface{}) (interface{}, error) {
-	if len(params) < 1 {
-		return null.String{}, nil
+//	translate("ID", "country") -> "Indonesia"
+//	translate(1, "status") -> "Open" (numeric keys match their text form)
+//	translate("XX", "country") -> null.String{}
+//	translate("XX", "country", "Unknown") -> "Unknown"
+func (o *operatorSet) translate(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("translate requires at least 2 parameters (value, dictionary)")
 	}
 
-	// Handle nil case
-	if params[0] == nil {
-		return null.String{}, nil
+	name := toString(params[1])
+	dictionary, ok := o.config.Dictionaries[name]
+	if !ok {
+		return nil, fmt.Errorf("translate: dictionary %q is not configured", name)
 	}
 
-	// Convert value to string for comparison (stack-allocated)
-	// Using fmt.Sprintf to handle any type consistently
-	textStr := fmt.Sprintf("%v", params[0])
+	if !isNullValue(params[0]) {
+		if value, ok := dictionary[strings.TrimSpace(toString(params[0]))]; ok {
+			return value, nil
+		}
+	}
 
-	// Check for primary state (0)
-	if textStr == "0" {
-		return "primary", nil
+	if len(params) > 2 && !isNullValue(params[2]) {
+		return toString(params[2]), nil
 	}
+	return null.String{}, nil
+}
 
-	// Return flow state with value
-	// String concatenation with + is optimized by Go compiler
-	return "flow " + textStr, nil
+// inLocation converts t to the configured tenant timezone, if any
+func (o *operatorSet) inLocation(t time.Time) time.Time {
+	if o.config.Location == nil {
+		return t
+	}
+	return t.In(o.config.Location)
 }
 
-// length returns the length of an array/slice parameter.
-// This operator counts the number of elements in array-like data structures.
+// parseDateFlexible parses a date written in any of the configured layouts
+// and normalizes it to RFC3339. This operator is for columns whose date format
+// varies between sources or imports.
 //
 // Parameters:
-//   - params[0]: Source value (should be array/slice type)
+//   - params[0]: Date (time.Time, text in one of OperatorConfig.DateLayouts,
+//     or unix seconds/milliseconds as a number or numeric text; []uint8 accepted)
 //
 // Output:
-//   - Integer: Number of elements in the array
-//   - 0 if parameter is not an array, is nil, or is empty
+//   - String: RFC3339 date in OperatorConfig.Location (UTC when unset)
+//   - null.String{} if the date is nil or matches no layout
 //
-// Memory efficiency:
-//   - Stack-allocated length calculation
-//   - No intermediate allocations
-//   - Direct type assertion (no reflection)
-//   - Built-in len() function is O(1) and stack-allocated
-//
-// Supported Types:
-//   - []interface{} (generic slice)
-//   - []any (Go 1.18+ alias)
-//   - Returns 0 for non-array types
-//
-// Use Cases:
-//   - Counting items in a list
-//   - Validating array sizes
-//   - Calculating metrics based on collection sizes
-//   - Report aggregations
+// Implementation Notes:
+//   - Layouts are tried in order (default: DefaultDateLayouts), so put the
+//     most specific first; "02/01/2006" and "01/02/2006" cannot both win
+//   - Layouts without a zone are read in OperatorConfig.Location
+//   - Epochs of 10^12 or more are milliseconds, smaller ones seconds
 //
 // Examples:
 //
-//	length([]interface{}{1, 2, 3}) -> 3
-//	length([]string{"a", "b"}) -> 2
-//	length([]interface{}{}) -> 0
-//	length("string") -> 0 (not an array)
-//	length(nil) -> 0
-//	length(123) -> 0 (not an array)
-func length(params []interface{}) (interface{}, error) {
-	if len(params) < 1 {
-		return 0, nil
-	}
-
-	// Handle nil case
-	if params[0] == nil {
-		return 0, nil
-	}
-
-	// Type assertion to array/slice - stack operation
-	// Check for []interface{} (most common case)
-	if arr, isArray := params[0].([]interface{}); isArray {
-		return len(arr), nil
+//	parseDateFlexible("2024-01-15T10:30:00+07:00") -> "2024-01-15T03:30:00Z"
+//	parseDateFlexible("2024-01-15 10:30:00") -> "2024-01-15T10:30:00Z"
+//	parseDateFlexible("15/01/2024") -> "2024-01-15T00:00:00Z"
+//	parseDateFlexible(1705314600000) -> "2024-01-15T10:30:00Z"
+//	parseDateFlexible("next tuesday") -> null.String{}
+func (o *operatorSet) parseDateFlexible(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
 	}
 
-	// Check for []any (Go 1.18+ generic)
-	if arr, isArray := params[0].([]any); isArray {
-		return len(arr), nil
+	date, ok := o.parseDate(params[0])
+	if !ok {
+		return null.String{}, nil
 	}
-
-	// Not an array - return 0
-	return 0, nil
+	return date.In(o.dateLocation()).Format(time.RFC3339), nil
 }
 
-// processSurveyAnswer processes survey answer data by transforming answer keys to
-// human-readable titles and mapping answer values based on question types.
-// This operator handles various survey question types (choices, multipletext, matrix, boolean, etc.).
+// coalesceDate returns the first param that is a valid, non-zero date, e.g.
+// first_response_at, else first_pickup_at, else created_at for SLA fields.
 //
 // Parameters:
-//   - params[0]: Survey answer data (JSON string or map[string]interface{})
-//   - params[1]: Questions metadata (JSON string or map[string]interface{}) - contains question definitions
+//   - params[0..n]: Candidate dates in priority order, in any form accepted by
+//     parseDateFlexible
 //
 // Output:
-//   - Transformed survey answer as JSON string with readable titles and mapped values
-//   - Original value if transformation fails or no questions metadata
-//   - null.String{} if no answer data
+//   - String: The first valid date as RFC3339 in OperatorConfig.Location (UTC when unset)
+//   - null.String{} if no param is a valid date
 //
-// Memory efficiency:
-//   - Stack-allocated maps with preallocated capacity
-//   - Single JSON marshal/unmarshal per processing
-//   - Reuses string buffers where possible
-//   - No intermediate slice allocations
-//   - Direct map operations without copying
+// Implementation Notes:
+//   - nil, empty text, zero time.Time values, MySQL zero dates such as
+//     "0000-00-00" and non-positive epochs are skipped
 //
-// Question Type Support:
-//   - "multipletext": Concatenates multiple text values
-//   - "matrixdynamic": Returns JSON representation of matrix data
-//   - "choices" (dropdown, checkbox, radio): Maps values to choice text
-//   - "boolean" (labelTrue/labelFalse): Maps bool to label text
-//   - Default: Returns value as-is or JSON representation
+// Examples:
 //
-// Processing Flow:
-//  1. Parse answer data (JSON string or map)
-//  2. Parse questions metadata
-//  3. For each answer key-value pair:
-//     a. Get mapped value text (if applicable)
-//     b. Get human-readable title for the key
-//     c. Store in new map with title as key
-//  4. Marshal to JSON and return
+//	coalesceDate(nil, "0000-00-00 00:00:00", "2024-01-15 10:30:00") -> "2024-01-15T10:30:00Z"
+//	coalesceDate("2024-01-16", "2024-01-15") -> "2024-01-16T00:00:00Z"
+//	coalesceDate(nil, "", 0) -> null.String{}
+func (o *operatorSet) coalesceDate(params []interface{}) (interface{}, error) {
+	for _, param := range params {
+		if date, ok := o.parseDate(param); ok {
+			return date.In(o.dateLocation()).Format(time.RFC3339), nil
+		}
+	}
+	return null.String{}, nil
+}
+
+// datePart extracts one part of a date in the tenant timezone, e.g. the day
+// of the week or the hour, for reports grouped by period.
 //
-// Examples:
+// Parameters:
+//   - params[0]: Date, in any form accepted by parseDateFlexible
+//   - params[1]: Part: "weekday", "month", "year", "hour" or "dayOfMonth"
+//   - params[2]: (Optional) "numeric" (or true) returns the weekday as a
+//     number instead of its name
 //
-//	// Question with choices
-//	answer = `{"q1":"choice_a"}`
-//	questions = `{"pages":[{"elements":[{"name":"q1","title":"Favorite Color","choices":[{"value":"choice_a","text":"Red"}]}]}]}`
-//	processSurveyAnswer(answer, questions) -> `{"Favorite Color":"Red"}`
+// Output:
+//   - String: English weekday name ("Monday") for "weekday"
+//   - int: The weekday number (ISO 8601: Monday = 1 ... Sunday = 7) with the
+//     numeric flag, the month (1-12), year, hour (0-23) or day of month (1-31)
+//   - null.String{} if the date is nil or invalid
+//   - Error for an unknown part
 //
-//	// Boolean question
-//	answer = `{"q2":true}`
-//	questions = `{"pages":[{"elements":[{"name":"q2","title":"Agree?","labelTrue":"Yes","labelFalse":"No"}]}]}`
-//	processSurveyAnswer(answer, questions) -> `{"Agree?":"Yes"}`
+// Implementation Notes:
+//   - The part is taken in OperatorConfig.Location (UTC when unset), so
+//     2024-01-15T20:00:00Z is a Tuesday, 03:00, in Asia/Jakarta
 //
-//	// Multiple text inputs
-//	answer = `{"q3":{"field1":"value1","field2":"value2"}}`
-//	questions = `{"pages":[{"elements":[{"name":"q3","title":"Contact","type":"multipletext"}]}]}`
-//	processSurveyAnswer(answer, questions) -> `{"Contact":"value1,value2"}`
-func processSurveyAnswer(params []interface{}) (interface{}, error) {
+// Examples:
+//
+//	datePart("2024-01-15 10:30:00", "weekday") -> "Monday"
+//	datePart("2024-01-15 10:30:00", "weekday", "numeric") -> 1
+//	datePart("2024-01-15", "month") -> 1
+//	datePart("2024-01-15T20:00:00Z", "hour") -> 3 (Asia/Jakarta)
+//	datePart("not a date", "year") -> null.String{}
+func (o *operatorSet) datePart(params []interface{}) (interface{}, error) {
 	if len(params) < 2 {
-		// Need both answer and questions
-		if len(params) == 1 && params[0] != nil {
-			// Return original if only answer provided (no transformation)
-			return params[0], nil
-		}
-		return null.String{}, nil
+		return nil, fmt.Errorf("datePart requires at least 2 parameters (date, part)")
 	}
 
-	// Parse answer data
-	var answerData map[string]interface{}
-	switch v := params[0].(type) {
-	case string:
-		if strings.TrimSpace(v) == "" {
-			return null.String{}, nil
+	part := toString(params[1])
+	switch part {
+	case "weekday", "month", "year", "hour", "dayOfMonth":
+	default:
+		return nil, fmt.Errorf("datePart: unknown part %q (use weekday, month, year, hour or dayOfMonth)", part)
+	}
+
+	date, ok := o.parseDate(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+	date = date.In(o.dateLocation())
+
+	switch part {
+	case "weekday":
+		numeric := false
+		if len(params) > 2 {
+			flag := strings.ToLower(strings.TrimSpace(toString(params[2])))
+			numeric = flag == "numeric" || flag == "true"
 		}
-		if err := json.Unmarshal([]byte(v), &answerData); err != nil {
-			// Return original if can't parse
-			return v, nil
+		if !numeric {
+			return date.Weekday().String(), nil
 		}
-	case map[string]interface{}:
-		answerData = v
-	case nil:
-		return null.String{}, nil
+		if date.Weekday() == time.Sunday {
+			return 7, nil
+		}
+		return int(date.Weekday()), nil
+	case "month":
+		return int(date.Month()), nil
+	case "year":
+		return date.Year(), nil
+	case "hour":
+		return date.Hour(), nil
 	default:
-		// Return original for unsupported types
-		return params[0], nil
+		return date.Day(), nil
 	}
+}
 
-	if answerData == nil || len(answerData) == 0 {
-		return null.String{}, nil
+// coalesceTracked returns the first non-empty value together with the name of
+// its source, for data lineage in exports: which column a coalesced value
+// actually came from.
+//
+// Parameters:
+//   - params[0..n]: (source, value) pairs in priority order, where source is
+//     a name literal, e.g. "'first_response_at' AS source1", "first_response_at",
+//     "'created_at' AS source2", "created_at"
+//
+// Output:
+//   - map[string]interface{}{"value": value, "source": source} of the first
+//     pair whose value is not empty; with outputFields ["value", "source"]
+//     they become two columns
+//   - null.String{} if every value is empty
+//   - Error if the params are not pairs
+//
+// Implementation Notes:
+//   - nil, invalid null values and blank strings are empty; 0 and false are not
+//   - []uint8 values (MySQL text columns) are returned as strings
+//
+// Examples:
+//
+//	coalesceTracked("email", "", "phone", "0812") -> {"value": "0812", "source": "phone"}
+//	coalesceTracked("a", nil, "b", nil) -> null.String{}
+func coalesceTracked(params []interface{}) (interface{}, error) {
+	if len(params) == 0 || len(params)%2 != 0 {
+		return nil, fmt.Errorf("coalesceTracked requires (source, value) pairs, got %d parameters", len(params))
 	}
 
-	// Parse questions metadata
-	var questionsData map[string]interface{}
-	switch v := params[1].(type) {
-	case string:
-		if strings.TrimSpace(v) == "" {
-			// No questions metadata, return original
-			if jsonBytes, err := json.Marshal(answerData); err == nil {
-				return string(jsonBytes), nil
-			}
-			return params[0], nil
+	for i := 0; i < len(params); i += 2 {
+		value := normalizeBytes(params[i+1])
+		if raw, ok := value.([]uint8); ok {
+			value = string(raw)
 		}
-		if err := json.Unmarshal([]byte(v), &questionsData); err != nil {
-			// Return original if can't parse questions
-			if jsonBytes, err := json.Marshal(answerData); err == nil {
-				return string(jsonBytes), nil
-			}
-			return params[0], nil
+		if isNullValue(value) {
+			continue
 		}
-	case map[string]interface{}:
-		questionsData = v
-	default:
-		// No valid questions, return original answer
-		if jsonBytes, err := json.Marshal(answerData); err == nil {
-			return string(jsonBytes), nil
+		if text, ok := value.(string); ok && strings.TrimSpace(text) == "" {
+			continue
 		}
-		return params[0], nil
+		return map[string]interface{}{"value": value, "source": toString(params[i])}, nil
 	}
 
-	// Transform answer data
-	// Preallocate with same capacity as answerData
-	transformedData := make(map[string]interface{}, len(answerData))
+	return null.String{}, nil
+}
 
-	for key, value := range answerData {
-		// Get mapped value text (for choices, boolean, etc.)
-		mappedValue := getTextByValue(key, value, questionsData)
-		if mappedValue != "" {
-			value = mappedValue
+// parseDate converts a date value with the configured layouts (see
+// parseDateFlexible); nil, zero and unparseable values are rejected
+func (o *operatorSet) parseDate(v interface{}) (time.Time, bool) {
+	var date time.Time
+	var ok bool
+	switch val := normalizeBytes(v).(type) {
+	case nil:
+		return time.Time{}, false
+	case time.Time:
+		date, ok = val, true
+	case null.Time:
+		date, ok = val.Time, val.Valid
+	case string, []uint8, null.String:
+		date, ok = parseDateLayouts(toString(val), o.config.DateLayouts, o.dateLocation())
+	default:
+		if epoch, isNumber := toFloat64(val); isNumber {
+			date, ok = epochTime(epoch)
 		}
+	}
+	return date, ok && !date.IsZero()
+}
 
-		// Get human-readable title for the key
-		title := getTitleByName(key, questionsData)
-		if title != "" {
-			transformedData[title] = value
-		} else {
-			transformedData[key] = value
-		}
+// dateLocation returns the timezone dates are read and written in
+func (o *operatorSet) dateLocation() *time.Location {
+	if o.config.Location == nil {
+		return time.UTC
 	}
+	return o.config.Location
+}
 
-	// Marshal back to JSON string
-	if jsonBytes, err := json.Marshal(transformedData); err == nil {
-		return string(jsonBytes), nil
+// DefaultDateLayouts returns the layouts tried by parseDateFlexible when
+// OperatorConfig.DateLayouts is empty
+func DefaultDateLayouts() []string {
+	return []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "02/01/2006"}
+}
+
+// parseDateLayouts parses text with the first matching layout (zone-less
+// layouts in loc), falling back to unix seconds or milliseconds
+func parseDateLayouts(text string, layouts []string, loc *time.Location) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, loc); err == nil {
+			return t, true
+		}
 	}
 
-	// Fallback to original if marshal fails
-	if jsonBytes, err := json.Marshal(answerData); err == nil {
-		return string(jsonBytes), nil
+	epoch, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return time.Time{}, false
 	}
+	return epochTime(epoch)
+}
 
-	return null.String{}, nil
+// epochTime converts unix seconds, or milliseconds from 10^12 on, to a local
+// time; non-positive epochs are rejected like in toTime
+func epochTime(epoch float64) (time.Time, bool) {
+	if epoch <= 0 || math.IsInf(epoch, 0) || math.IsNaN(epoch) {
+		return time.Time{}, false
+	}
+	if epoch >= 1e12 {
+		return time.UnixMilli(int64(epoch)), true
+	}
+	return time.Unix(int64(epoch), 0), true
 }
 
-// getTextByValue maps answer values to display text based on question type.
-// This handles different question types: choices, multipletext, matrixdynamic, boolean, etc.
+// additionalData processes additional data fields by parsing JSON and structuring the output.
+// This operator handles dynamic additional data that can contain arbitrary key-value pairs.
+//
+// Parameters:
+//   - params[0]: Additional data field (JSON string or map)
+//   - params[1]: (Optional) Prefix for output keys (default: OperatorConfig.AdditionalDataPrefix, "additional")
+//
+// Output:
+//   - Map containing parsed additional data with optional prefix
+//   - Structure: {"additional_key1": "value1", "additional_key2": "value2"}
+//   - Keys are sanitized (spaces replaced with underscores)
+//   - Returns empty map if no valid additional data
 //
 // Memory efficiency:
-//   - Stack-allocated iterations
-//   - No intermediate allocations for simple types
-//   - JSON marshal only when necessary
-//   - Direct string operations
-func getTextByValue(name string, value interface{}, questions map[string]interface{}) string {
-	pages, ok := questions["pages"].([]interface{})
-	if !ok {
-		return ""
+//   - Preallocates map with estimated capacity
+//   - Stack-allocated string operations
+//   - Minimal allocations during key transformation
+//   - No unnecessary intermediate structures
+//
+// Input format:
+//   - JSON string: '{"custom_field1":"value1","custom_field2":"value2"}'
+//   - Map with additional data
+//
+// Examples:
+//
+//	additionalData('{"field1":"value1","field2":"value2"}')
+//	  → {"additional_field1":"value1","additional_field2":"value2"}
+//
+//	additionalData('{"field1":"value1"}', "custom")
+//	  → {"custom_field1":"value1"}
+//
+//	additionalData('{"Customer Name":"John Doe"}')
+//	  → {"additional_Customer_Name":"John Doe"}  // Spaces replaced with underscores
+func (o *operatorSet) additionalData(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return map[string]interface{}{}, nil
 	}
 
-	// Find the question element
-	for _, page := range pages {
-		pageMap, ok := page.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	additionalField := params[0]
+	if additionalField == nil {
+		return map[string]interface{}{}, nil
+	}
 
-		elements, ok := pageMap["elements"].([]interface{})
-		if !ok {
-			continue
+	// Optional prefix (default from OperatorConfig, "additional")
+	prefix := o.config.AdditionalDataPrefix
+	if len(params) > 1 {
+		if p, ok := params[1].(string); ok && p != "" {
+			prefix = p
+		}
+	}
+
+	// Parse additional data
+	var additionalDataMap map[string]interface{}
+
+	switch v := additionalField.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return map[string]interface{}{}, nil
+		}
+
+		// Try parsing as JSON
+		if err := json.Unmarshal([]byte(v), &additionalDataMap); err != nil {
+			// If parsing fails, return empty map
+			return map[string]interface{}{}, nil
+		}
+
+	case map[string]interface{}:
+		additionalDataMap = v
+
+	default:
+		return map[string]interface{}{}, nil
+	}
+
+	if additionalDataMap == nil || len(additionalDataMap) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	// Process and add prefix to keys
+	// Preallocate result map with known capacity
+	result := make(map[string]interface{}, len(additionalDataMap))
+
+	for key, value := range additionalDataMap {
+		// Sanitize key: replace spaces with underscores
+		sanitizedKey := strings.ReplaceAll(key, " ", "_")
+
+		// Add prefix
+		prefixedKey := prefix + "_" + sanitizedKey
+
+		result[prefixedKey] = value
+	}
+
+	return result, nil
+}
+
+// transactionState maps transaction state values to descriptive strings.
+// This operator converts numeric transaction states to readable flow identifiers.
+//
+// Parameters:
+//   - params[0]: Transaction state value (integer, string, or any type)
+//
+// Mapping:
+//   - 0 (or "0") → "primary"
+//   - Any other value → "flow {value}" (e.g., "flow 1", "flow 2")
+//   - nil → null.String{}
+//
+// Output:
+//   - String: "primary" for initial state (0)
+//   - String: "flow N" for flow states (non-zero values)
+//   - null.String{} if source value is nil
+//
+// Memory efficiency:
+//   - Stack-allocated string comparison
+//   - Single string concatenation using + operator (compiler optimizes)
+//   - No intermediate allocations
+//   - fmt.Sprintf used only for type conversion
+//
+// Use Cases:
+//   - Tracking transaction workflow stages
+//   - Identifying primary vs. secondary transaction flows
+//   - Displaying transaction state in reports
+//
+// Examples:
+//
+//	transactionState(0) -> "primary"
+//	transactionState("0") -> "primary"
+//	transactionState(1) -> "flow 1"
+//	transactionState(2) -> "flow 2"
+//	transactionState("3") -> "flow 3"
+//	transactionState(nil) -> null.String{}
+func transactionState(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+
+	// Handle nil case
+	if params[0] == nil {
+		return null.String{}, nil
+	}
+
+	// Convert value to string for comparison (stack-allocated)
+	// Using fmt.Sprintf to handle any type consistently
+	textStr := fmt.Sprintf("%v", params[0])
+
+	// Check for primary state (0)
+	if textStr == "0" {
+		return "primary", nil
+	}
+
+	// Return flow state with value
+	// String concatenation with + is optimized by Go compiler
+	return "flow " + textStr, nil
+}
+
+// length returns the length of an array/slice parameter.
+// This operator counts the number of elements in array-like data structures.
+//
+// Parameters:
+//   - params[0]: Source value (should be array/slice type)
+//
+// Output:
+//   - Integer: Number of elements in the array
+//   - 0 if parameter is not an array, is nil, or is empty
+//
+// Memory efficiency:
+//   - Stack-allocated length calculation
+//   - No intermediate allocations
+//   - Direct type assertion (no reflection)
+//   - Built-in len() function is O(1) and stack-allocated
+//
+// Supported Types:
+//   - []interface{} (generic slice)
+//   - []any (Go 1.18+ alias)
+//   - Returns 0 for non-array types
+//
+// Use Cases:
+//   - Counting items in a list
+//   - Validating array sizes
+//   - Calculating metrics based on collection sizes
+//   - Report aggregations
+//
+// Examples:
+//
+//	length([]interface{}{1, 2, 3}) -> 3
+//	length([]string{"a", "b"}) -> 2
+//	length([]interface{}{}) -> 0
+//	length("string") -> 0 (not an array)
+//	length(nil) -> 0
+//	length(123) -> 0 (not an array)
+func length(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return 0, nil
+	}
+
+	// Handle nil case
+	if params[0] == nil {
+		return 0, nil
+	}
+
+	// Type assertion to array/slice - stack operation
+	// Check for []interface{} (most common case)
+	if arr, isArray := params[0].([]interface{}); isArray {
+		return len(arr), nil
+	}
+
+	// Check for []any (Go 1.18+ generic)
+	if arr, isArray := params[0].([]any); isArray {
+		return len(arr), nil
+	}
+
+	// Not an array - return 0
+	return 0, nil
+}
+
+// processSurveyAnswer processes survey answer data by transforming answer keys to
+// human-readable titles and mapping answer values based on question types.
+// This operator handles various survey question types (choices, multipletext, matrix, boolean, etc.).
+//
+// Parameters:
+//   - params[0]: Survey answer data (JSON string or map[string]interface{})
+//   - params[1]: Questions metadata (JSON string or map[string]interface{}) - contains question definitions
+//
+// Output:
+//   - Transformed survey answer as JSON string with readable titles and mapped values
+//   - Original value if transformation fails or no questions metadata
+//   - null.String{} if no answer data
+//
+// Memory efficiency:
+//   - Stack-allocated maps with preallocated capacity
+//   - Single JSON marshal/unmarshal per processing
+//   - Reuses string buffers where possible
+//   - No intermediate slice allocations
+//   - Direct map operations without copying
+//
+// Question Type Support:
+//   - "multipletext": Concatenates multiple text values
+//   - "matrixdynamic": Returns JSON representation of matrix data
+//   - "choices" (dropdown, checkbox, radio): Maps values to choice text
+//   - "boolean" (labelTrue/labelFalse): Maps bool to label text
+//   - Default: Returns value as-is or JSON representation
+//
+// Processing Flow:
+//  1. Parse answer data (JSON string or map)
+//  2. Parse questions metadata
+//  3. For each answer key-value pair:
+//     a. Get mapped value text (if applicable)
+//     b. Get human-readable title for the key
+//     c. Store in new map with title as key
+//  4. Marshal to JSON and return
+//
+// Examples:
+//
+//	// Question with choices
+//	answer = `{"q1":"choice_a"}`
+//	questions = `{"pages":[{"elements":[{"name":"q1","title":"Favorite Color","choices":[{"value":"choice_a","text":"Red"}]}]}]}`
+//	processSurveyAnswer(answer, questions) -> `{"Favorite Color":"Red"}`
+//
+//	// Boolean question
+//	answer = `{"q2":true}`
+//	questions = `{"pages":[{"elements":[{"name":"q2","title":"Agree?","labelTrue":"Yes","labelFalse":"No"}]}]}`
+//	processSurveyAnswer(answer, questions) -> `{"Agree?":"Yes"}`
+//
+//	// Multiple text inputs
+//	answer = `{"q3":{"field1":"value1","field2":"value2"}}`
+//	questions = `{"pages":[{"elements":[{"name":"q3","title":"Contact","type":"multipletext"}]}]}`
+//	processSurveyAnswer(answer, questions) -> `{"Contact":"value1,value2"}`
+func processSurveyAnswer(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		// Need both answer and questions
+		if len(params) == 1 && params[0] != nil {
+			// Return original if only answer provided (no transformation)
+			return params[0], nil
+		}
+		return null.String{}, nil
+	}
+
+	// Parse answer data
+	var answerData map[string]interface{}
+	switch v := params[0].(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return null.String{}, nil
+		}
+		if err := json.Unmarshal([]byte(v), &answerData); err != nil {
+			// Return original if can't parse
+			return v, nil
+		}
+	case map[string]interface{}:
+		answerData = v
+	case nil:
+		return null.String{}, nil
+	default:
+		// Return original for unsupported types
+		return params[0], nil
+	}
+
+	if answerData == nil || len(answerData) == 0 {
+		return null.String{}, nil
+	}
+
+	// Parse questions metadata
+	var questionsData map[string]interface{}
+	switch v := params[1].(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			// No questions metadata, return original
+			if jsonBytes, err := json.Marshal(answerData); err == nil {
+				return string(jsonBytes), nil
+			}
+			return params[0], nil
+		}
+		if err := json.Unmarshal([]byte(v), &questionsData); err != nil {
+			// Return original if can't parse questions
+			if jsonBytes, err := json.Marshal(answerData); err == nil {
+				return string(jsonBytes), nil
+			}
+			return params[0], nil
+		}
+	case map[string]interface{}:
+		questionsData = v
+	default:
+		// No valid questions, return original answer
+		if jsonBytes, err := json.Marshal(answerData); err == nil {
+			return string(jsonBytes), nil
+		}
+		return params[0], nil
+	}
+
+	// Transform answer data
+	// Preallocate with same capacity as answerData
+	transformedData := make(map[string]interface{}, len(answerData))
+
+	for key, value := range answerData {
+		// Get mapped value text (for choices, boolean, etc.)
+		mappedValue := getTextByValue(key, value, questionsData)
+		if mappedValue != "" {
+			value = mappedValue
+		}
+
+		// Get human-readable title for the key
+		title := getTitleByName(key, questionsData)
+		if title != "" {
+			transformedData[title] = value
+		} else {
+			transformedData[key] = value
+		}
+	}
+
+	// Marshal back to JSON string
+	if jsonBytes, err := json.Marshal(transformedData); err == nil {
+		return string(jsonBytes), nil
+	}
+
+	// Fallback to original if marshal fails
+	if jsonBytes, err := json.Marshal(answerData); err == nil {
+		return string(jsonBytes), nil
+	}
+
+	return null.String{}, nil
+}
+
+// getTextByValue maps answer values to display text based on question type.
+// This handles different question types: choices, multipletext, matrixdynamic, boolean, etc.
+//
+// Memory efficiency:
+//   - Stack-allocated iterations
+//   - No intermediate allocations for simple types
+//   - JSON marshal only when necessary
+//   - Direct string operations
+func getTextByValue(name string, value interface{}, questions map[string]interface{}) string {
+	pages, ok := questions["pages"].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	// Find the question element
+	for _, page := range pages {
+		pageMap, ok := page.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		elements, ok := pageMap["elements"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, elem := range elements {
+			element, ok := elem.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			elementName, ok := element["name"].(string)
+			if !ok || elementName != name {
+				continue
+			}
+
+			// Found the element, process based on type
+			elementType, _ := element["type"].(string)
+
+			switch elementType {
+			case "multipletext":
+				// Multiple text inputs - concatenate values
+				if valueMap, ok := value.(map[string]interface{}); ok {
+					// Preallocate slice with estimated capacity
+					values := make([]string, 0, len(valueMap))
+					for _, v := range valueMap {
+						if str, ok := v.(string); ok {
+							values = append(values, str)
+						}
+					}
+					return strings.Join(values, ",")
+				}
+
+			case "matrixdynamic":
+				// Matrix data - return as JSON
+				if jsonBytes, err := json.Marshal(value); err == nil {
+					return string(jsonBytes)
+				}
+			}
+
+			// Check for choices (dropdown, checkbox, radiogroup, etc.)
+			if choices, ok := element["choices"].([]interface{}); ok {
+				// Handle array of values (for checkbox/multi-select)
+				if valueArray, ok := value.([]interface{}); ok {
+					results := make([]string, 0, len(valueArray))
+					for _, val := range valueArray {
+						if valStr, ok := val.(string); ok {
+							for _, choice := range choices {
+								if choiceMap, ok := choice.(map[string]interface{}); ok {
+									if choiceValue, ok := choiceMap["value"].(string); ok && choiceValue == valStr {
+										if text, exists := choiceMap["text"]; exists {
+											results = append(results, translationTitleSurvey(text))
+										}
+										break
+									}
+								}
+							}
+						}
+					}
+					return strings.Join(results, ",")
+				} else {
+					// Handle single value (for dropdown/radio)
+					if valueStr, ok := value.(string); ok {
+						for _, choice := range choices {
+							if choiceMap, ok := choice.(map[string]interface{}); ok {
+								if choiceValue, ok := choiceMap["value"].(string); ok && choiceValue == valueStr {
+									if text, exists := choiceMap["text"]; exists {
+										return translationTitleSurvey(text)
+									}
+									break
+								}
+							}
+						}
+					}
+				}
+			}
+
+			// Check for boolean type with labelTrue/labelFalse
+			if labelTrue, ok := element["labelTrue"]; ok {
+				if valueBool, ok := value.(bool); ok && valueBool {
+					return translationTitleSurvey(labelTrue)
+				}
+			}
+			if labelFalse, ok := element["labelFalse"]; ok {
+				if valueBool, ok := value.(bool); ok && !valueBool {
+					return translationTitleSurvey(labelFalse)
+				}
+			}
+
+			// For complex types (map/slice), return as JSON
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				if jsonBytes, err := json.Marshal(value); err == nil {
+					return string(jsonBytes)
+				}
+			}
+
+			// Return empty to use original value
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// getTitleByName retrieves the human-readable title for a question name.
+// Handles comment fields (name-Comment suffix) by getting commentText.
+//
+// Memory efficiency:
+//   - Stack-allocated string operations
+//   - Single pass through questions
+//   - No intermediate allocations
+func getTitleByName(name string, questions map[string]interface{}) string {
+	pages, ok := questions["pages"].([]interface{})
+	if !ok {
+		return ""
+	}
+
+	// Check if this is a comment field (name-Comment)
+	newName := name
+	isComment := false
+	parts := strings.Split(name, "-")
+	if len(parts) > 1 && parts[1] == "Comment" {
+		newName = parts[0]
+		isComment = true
+	}
+
+	// Find the question element
+	for _, page := range pages {
+		pageMap, ok := page.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		elements, ok := pageMap["elements"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, elem := range elements {
+			element, ok := elem.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			elementName, ok := element["name"].(string)
+			if !ok || elementName != newName {
+				continue
+			}
+
+			title, ok := element["title"]
+			if !ok {
+				continue
+			}
+
+			// Handle comment fields
+			if isComment {
+				if commentText, ok := element["commentText"]; ok {
+					// Combine original name and comment text
+					return fmt.Sprintf("%s-%s", parts[0], translationTitleSurvey(commentText))
+				}
+			}
+
+			return translationTitleSurvey(title)
+		}
+	}
+
+	return ""
+}
+
+// translationTitleSurvey extracts the text from title field.
+// Handles both string and multi-language object formats.
+//
+// Memory efficiency:
+//   - Direct type assertions (no reflection)
+//   - Stack-allocated operations
+func translationTitleSurvey(title interface{}) string {
+	// Simple string case
+	if str, ok := title.(string); ok {
+		return str
+	}
+
+	// Multi-language object case
+	if titleMap, ok := title.(map[string]interface{}); ok {
+		if defaultTitle, ok := titleMap["default"].(string); ok {
+			return defaultTitle
+		}
+	}
+
+	return ""
+}
+
+// concat concatenates all parameters with a space separator
+func concat(params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	var parts []string
+	for _, param := range params {
+		parts = append(parts, toString(param))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// upper converts the first parameter to uppercase
+func upper(params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("upper requires at least 1 parameter")
+	}
+
+	str := toString(params[0])
+	return strings.ToUpper(str), nil
+}
+
+// lower converts the first parameter to lowercase
+func lower(params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("lower requires at least 1 parameter")
+	}
+
+	str := toString(params[0])
+	return strings.ToLower(str), nil
+}
+
+// formatDate formats a date parameter using a specified layout
+// If no layout is provided, uses "2006-01-02".
+// Accepts time.Time and date text ([]uint8 from SQLite/MySQL); numbers and
+// numeric text are read as unix seconds (local time) only with
+// OperatorConfig.FormatDateEpochs. Anything else is returned as text.
+func (o *operatorSet) formatDate(params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("formatDate requires at least 1 parameter (date)")
+	}
+
+	layout := "2006-01-02"
+	if len(params) > 1 {
+		layout = toString(params[1])
+	}
+
+	// time.Time is formatted as-is, including the zero time
+	if t, ok := params[0].(time.Time); ok {
+		return t.Format(layout), nil
+	}
+
+	// Date text (SQLite returns dates as []uint8) and, when enabled, unix seconds
+	if _, isNumber := toFloat64(params[0]); isNumber && !o.config.FormatDateEpochs {
+		return toString(params[0]), nil
+	}
+	if t, ok := toTime(params[0]); ok {
+		return t.Format(layout), nil
+	}
+	return toString(params[0]), nil
+}
+
+// percentOf calculates a ratio as a percentage (numerator / denominator * 100).
+// This operator derives dashboard percentages such as resolved/total per row.
+//
+// Parameters:
+//   - params[0]: Numerator (numeric value, numeric string, or []uint8)
+//   - params[1]: Denominator (numeric value, numeric string, or []uint8)
+//   - params[2]: (Optional) Number of decimals to round to (default: 1)
+//   - params[3]: (Optional) Result for a zero/invalid denominator: "zero" returns 0,
+//     anything else returns null (default: null)
+//
+// Output:
+//   - float64: Percentage rounded to the requested number of decimals
+//   - json.Number: Exact percentage when an input is a DECIMAL json.Number
+//   - null.Float{} (or 0 when configured) if the denominator is zero or invalid
+//   - null.Float{} if the numerator is not numeric
+//
+// Examples:
+//
+//	percentOf(25, 200) -> 12.5
+//	percentOf(1, 3, 2) -> 33.33
+//	percentOf(5, 0) -> null.Float{}
+//	percentOf(5, 0, 1, "zero") -> 0
+//	percentOf("abc", 10) -> null.Float{}
+func percentOf(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("percentOf requires at least 2 parameters (numerator, denominator)")
+	}
+
+	// Optional decimals (default 1)
+	decimals := 1
+	if len(params) > 2 && params[2] != nil {
+		if d, ok := toFloat64(params[2]); ok && d >= 0 {
+			decimals = int(d)
+		}
+	}
+
+	// Optional zero-denominator behavior (default null)
+	var invalidResult interface{} = null.Float{}
+	if len(params) > 3 && strings.EqualFold(toString(params[3]), "zero") {
+		invalidResult = float64(0)
+	}
+
+	// DECIMAL text is computed exactly to avoid float error
+	if isDecimalText(params[0]) || isDecimalText(params[1]) {
+		denominator, ok := toDecimal(params[1])
+		if !ok || denominator.Sign() == 0 {
+			return invalidResult, nil
+		}
+
+		numerator, ok := toDecimal(params[0])
+		if !ok {
+			return null.Float{}, nil
+		}
+
+		ratio := new(big.Rat).Quo(numerator, denominator)
+		ratio.Mul(ratio, big.NewRat(100, 1))
+		return decimalResult(ratio, decimals, isDecimalNumber(params[0]) || isDecimalNumber(params[1])), nil
+	}
+
+	denominator, ok := toFloat64(params[1])
+	if !ok || denominator == 0 {
+		return invalidResult, nil
+	}
+
+	numerator, ok := toFloat64(params[0])
+	if !ok {
+		return null.Float{}, nil
+	}
+
+	return roundFloat(numerator/denominator*100, decimals), nil
+}
+
+// scale multiplies a numeric value by a factor and rounds the result.
+// This operator converts base units for display (cents -> dollars, grams -> kg,
+// seconds -> minutes) without a bespoke operator per unit.
+//
+// Parameters:
+//   - params[0]: Value (numeric value, numeric string, or []uint8)
+//   - params[1]: Multiplier (e.g. 0.01 for cents -> dollars, 0.001 for grams -> kg)
+//   - params[2]: (Optional) Number of decimals to round to (default: 2)
+//
+// Output:
+//   - float64: value * multiplier rounded to the requested number of decimals
+//   - json.Number: Exact result when an input is a DECIMAL json.Number
+//   - null.Float{} if the value or multiplier is not numeric
+//
+// Examples:
+//
+//	scale(12345, 0.01) -> 123.45
+//	scale(-250, 0.01) -> -2.5
+//	scale(1500, 0.001, 1) -> 1.5
+//	scale(json.Number("1234.56"), 1) -> json.Number("1234.56")
+//	scale("abc", 0.01) -> null.Float{}
+func scale(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("scale requires at least 2 parameters (value, multiplier)")
+	}
+
+	// Optional decimals (default 2)
+	decimals := 2
+	if len(params) > 2 && params[2] != nil {
+		if d, ok := toFloat64(params[2]); ok && d >= 0 {
+			decimals = int(d)
+		}
+	}
+
+	// DECIMAL text is computed exactly to avoid float error
+	if isDecimalText(params[0]) || isDecimalText(params[1]) {
+		value, ok := toDecimal(params[0])
+		if !ok {
+			return null.Float{}, nil
+		}
+
+		multiplier, ok := toDecimal(params[1])
+		if !ok {
+			return null.Float{}, nil
+		}
+
+		scaled := new(big.Rat).Mul(value, multiplier)
+		return decimalResult(scaled, decimals, isDecimalNumber(params[0]) || isDecimalNumber(params[1])), nil
+	}
+
+	value, ok := toFloat64(params[0])
+	if !ok {
+		return null.Float{}, nil
+	}
+
+	multiplier, ok := toFloat64(params[1])
+	if !ok {
+		return null.Float{}, nil
+	}
+
+	return roundFloat(value*multiplier, decimals), nil
+}
+
+// sumFields adds up the numeric params, e.g. "total_interactions = a + b + c".
+// Params that are nil or not numeric are skipped.
+//
+// Parameters:
+//   - params[0..n]: Values to add (numeric values, numeric strings, or []uint8)
+//
+// Output:
+//   - int64: Sum when it is a whole number
+//   - float64: Sum otherwise
+//   - json.Number: Exact sum when an input is a DECIMAL json.Number (scale of
+//     the most precise input)
+//   - null.Float{} if no param is numeric
+//
+// Implementation Notes:
+//   - Values are added as exact rationals (see toDecimal), so 0.1 + 0.2 is 0.3
+//     and DECIMAL text is not rounded through float64
+//
+// Examples:
+//
+//	sumFields(1, 2, 3) -> int64(6)
+//	sumFields(1, 2.5, nil, "abc") -> 3.5
+//	sumFields(0.1, 0.2) -> 0.3
+//	sumFields(nil, "abc") -> null.Float{}
+func sumFields(params []interface{}) (interface{}, error) {
+	sum, count, scale, exact := sumNumeric(params)
+	if count == 0 {
+		return null.Float{}, nil
+	}
+	return numericResult(sum, scale, exact), nil
+}
+
+// avgFields averages the numeric params, skipping nil and non-numeric ones
+// (they do not count towards the divisor).
+//
+// Parameters:
+//   - params[0..n]: Values to average (numeric values, numeric strings, or []uint8)
+//
+// Output:
+//   - int64: Average when it is a whole number
+//   - float64: Average otherwise
+//   - json.Number: Exact average when an input is a DECIMAL json.Number,
+//     rounded to the scale of the most precise input plus 2 decimals
+//   - null.Float{} if no param is numeric
+//
+// Examples:
+//
+//	avgFields(1, 2, 3) -> int64(2)
+//	avgFields(1, 2, nil) -> 1.5
+//	avgFields(1, 2, 2) -> 1.6666666666666667
+//	avgFields("n/a", nil) -> null.Float{}
+func avgFields(params []interface{}) (interface{}, error) {
+	sum, count, scale, exact := sumNumeric(params)
+	if count == 0 {
+		return null.Float{}, nil
+	}
+	avg := sum.Quo(sum, new(big.Rat).SetInt64(int64(count)))
+	return numericResult(avg, scale+2, exact), nil
+}
+
+// sumNumeric adds the numeric params exactly. It returns the sum, the number
+// of numeric params, the largest decimal scale of the DECIMAL json.Number
+// params and whether any param was one.
+func sumNumeric(params []interface{}) (*big.Rat, int, int, bool) {
+	sum := new(big.Rat)
+	count, scale, exact := 0, 0, false
+
+	for _, param := range params {
+		if isNullValue(param) {
+			continue
+		}
+		value, ok := toDecimal(param)
+		if !ok {
+			continue
+		}
+		sum.Add(sum, value)
+		count++
+
+		if number, ok := param.(stdjson.Number); ok {
+			exact = true
+			if _, fraction, found := strings.Cut(string(number), "."); found && len(fraction) > scale {
+				scale = len(fraction)
+			}
+		}
+	}
+
+	return sum, count, scale, exact
+}
+
+// numericResult returns an exact sum or average: a json.Number rounded to
+// decimals for DECIMAL inputs, an int64 for whole numbers in range, and the
+// nearest float64 otherwise
+func numericResult(value *big.Rat, decimals int, exact bool) interface{} {
+	if exact {
+		return decimalResult(value, decimals, true)
+	}
+	if value.IsInt() && value.Num().IsInt64() {
+		return value.Num().Int64()
+	}
+	f, _ := value.Float64()
+	return f
+}
+
+// byteUnits are the unit labels of formatBytes per mode, from bytes upwards
+var byteUnits = map[string][]string{
+	"binary":  {"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"},
+	"decimal": {"B", "KB", "MB", "GB", "TB", "PB", "EB"},
+}
+
+// formatBytes converts a byte count into a human-readable size, e.g. an
+// attachment size of 10485760 into "10.0 MiB".
+//
+// Parameters:
+//   - params[0]: Byte count (numeric value, numeric string, or []uint8)
+//   - params[1]: (Optional) "binary" for powers of 1024 (KiB, MiB, ...) or
+//     "decimal" for powers of 1000 (KB, MB, ...) (default: "binary")
+//
+// Output:
+//   - String: Whole bytes below one kilobyte ("512 B"), otherwise one decimal
+//     and the largest unit keeping the value below 1024 (or 1000)
+//   - null.String{} if the byte count is not numeric
+//   - Error if the mode is not "binary" or "decimal"
+//
+// Examples:
+//
+//	formatBytes(0) -> "0 B"
+//	formatBytes(1023) -> "1023 B"
+//	formatBytes(1048576) -> "1.0 MiB"
+//	formatBytes(10485760, "decimal") -> "10.5 MB"
+//	formatBytes("abc") -> null.String{}
+func formatBytes(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+
+	mode := "binary"
+	if len(params) > 1 && !isNullValue(params[1]) {
+		mode = strings.ToLower(strings.TrimSpace(toString(params[1])))
+	}
+	units, ok := byteUnits[mode]
+	if !ok {
+		return nil, fmt.Errorf("formatBytes: unknown mode %q (use binary or decimal)", mode)
+	}
+	base := 1024.0
+	if mode == "decimal" {
+		base = 1000
+	}
+
+	size, ok := toFloat64(params[0])
+	if !ok || math.IsNaN(size) || math.IsInf(size, 0) {
+		return null.String{}, nil
+	}
+
+	magnitude := math.Abs(size)
+	if magnitude < base {
+		return fmt.Sprintf("%d B", int64(size)), nil
+	}
+
+	unit := 0
+	for magnitude >= base && unit < len(units)-1 {
+		magnitude /= base
+		unit++
+	}
+	// 1023.96 KiB rounds to "1024.0 KiB"; show it as the next unit instead
+	if roundFloat(magnitude, 1) >= base && unit < len(units)-1 {
+		magnitude /= base
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", math.Copysign(magnitude, size), units[unit]), nil
+}
+
+// formatNumber writes a number with the thousands separator and decimal mark
+// of a locale, e.g. an amount of 1234567.891 as "1,234,567.89" for a US export
+// or "1.234.567,89" for an Indonesian one.
+//
+// Parameters:
+//   - params[0]: Number (numeric value, numeric string, or []uint8)
+//   - params[1]: (Optional) Number of decimals, 0 to 20 (default: 2)
+//   - params[2]: (Optional) Locale such as "en-US" or "id-ID" (default: the
+//     configured NumberLocale); see ParseNumberLocale
+//
+// Output:
+//   - String: The number rounded to the decimals, digits grouped by three
+//   - null.String{} if the number is nil or not numeric
+//   - Error for an invalid number of decimals or an unknown locale
+//
+// Examples:
+//
+//	formatNumber(1234567.891) -> "1,234,567.89"
+//	formatNumber(1234567.891, 2, "id-ID") -> "1.234.567,89"
+//	formatNumber(-1500, 0, "de") -> "-1.500"
+//	formatNumber("abc") -> null.String{}
+func (o *operatorSet) formatNumber(params []interface{}) (interface{}, error) {
+	decimals := 2
+	if len(params) > 1 && !isNullValue(params[1]) {
+		decimals = toInt(params[1])
+		if decimals < 0 || decimals > 20 {
+			return nil, fmt.Errorf("formatNumber: decimals must be between 0 and 20, got %s", toString(params[1]))
+		}
+	}
+
+	locale := o.config.NumberLocale
+	if len(params) > 2 && !isNullValue(params[2]) && toString(params[2]) != "" {
+		var ok bool
+		if locale, ok = ParseNumberLocale(toString(params[2])); !ok {
+			return nil, fmt.Errorf("formatNumber: unsupported locale %q (use e.g. en-US or id-ID)", toString(params[2]))
+		}
+	}
+
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+	value, ok := toFloat64(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+	text, ok := locale.format(value, decimals)
+	if !ok {
+		return null.String{}, nil
+	}
+	return text, nil
+}
+
+// bucketRule is a single range rule for the bucket operator.
+// A rule without max is open-ended and matches any remaining value.
+type bucketRule struct {
+	Max   *float64 `json:"max"`
+	Label string   `json:"label"`
+}
+
+// bucket maps a numeric value to the label of the first matching range.
+// This operator groups ages, amounts or durations into report buckets without
+// a hand-written mapping operator per range.
+//
+// Parameters:
+//   - params[0]: Numeric value (numeric value, numeric string, or []uint8)
+//   - params[1]: JSON array of rules evaluated in order, e.g.
+//     '[{"max":18,"label":"0-18"},{"max":35,"label":"19-35"},{"label":"36+"}]'
+//
+// Output:
+//   - Label of the first rule where value <= max (or the first rule without max)
+//   - null.String{} if the value is not numeric or no rule matches
+//   - Error if the rules are not a valid JSON array
+//
+// Examples:
+//
+//	bucket(18, rules) -> "0-18"
+//	bucket(19, rules) -> "19-35"
+//	bucket(80, rules) -> "36+"
+//	bucket(80, '[{"max":18,"label":"child"}]') -> null.String{}
+func bucket(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("bucket requires 2 parameters (value, rules)")
+	}
+
+	var rules []bucketRule
+	switch v := params[1].(type) {
+	case string:
+		if err := json.Unmarshal([]byte(v), &rules); err != nil {
+			return nil, fmt.Errorf("bucket: invalid rules: %w", err)
+		}
+	case []uint8:
+		if err := json.Unmarshal(v, &rules); err != nil {
+			return nil, fmt.Errorf("bucket: invalid rules: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("bucket: rules must be a JSON array string, got %T", params[1])
+	}
+
+	value, ok := toFloat64(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+
+	for _, rule := range rules {
+		if rule.Max == nil || value <= *rule.Max {
+			return rule.Label, nil
+		}
+	}
+
+	return null.String{}, nil
+}
+
+// hashBucket assigns a value to one of N buckets by hashing it, so the same
+// value always gets the same bucket. This operator gives UIs a stable color or
+// shard per value (e.g. an A/B group per customer).
+//
+// Parameters:
+//   - params[0]: Value to hash (any value is converted via toString)
+//   - params[1]: Bucket count (>= 1)
+//
+// Output:
+//   - int64: Bucket index in [0, count)
+//   - null.String{} if the value is nil
+//   - Error if the bucket count is missing or below 1
+//
+// Implementation Notes:
+//   - The index is the 32-bit FNV-1a hash of the text modulo the count, so
+//     it is stable across processes and releases; 42 and "42" share a bucket
+//
+// Examples:
+//
+//	hashBucket("customer-42", 10) -> 6 (always the same)
+//	hashBucket(42, 1) -> 0
+//	hashBucket(nil, 10) -> null.String{}
+func hashBucket(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("hashBucket requires 2 parameters (value, count)")
+	}
+
+	count := toInt(params[1])
+	if count < 1 {
+		return nil, fmt.Errorf("hashBucket: bucket count must be at least 1, got %v", params[1])
+	}
+
+	if isNullValue(params[0]) {
+		return null.String{}, nil
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(toString(params[0])))
+	return int64(hash.Sum32() % uint32(count)), nil
+}
+
+// jsonMerge deep-merges several JSON object columns into a single object.
+// This operator combines metadata that is fragmented across columns for exports.
+//
+// Parameters:
+//   - params[0..n]: JSON objects (JSON string, []uint8, or map[string]interface{})
+//
+// Output:
+//   - map[string]interface{}: Objects merged left to right; later keys win on
+//     conflict, nested objects are merged recursively
+//   - Empty map if nothing could be merged
+//
+// Implementation Notes:
+//   - Invalid JSON, non-object values and nil params are skipped
+//   - Input maps are never modified; nested objects are copied before merging
+//
+// Examples:
+//
+//	jsonMerge('{"a":1,"b":{"x":1}}', '{"b":{"y":2},"c":3}') -> {"a":1,"b":{"x":1,"y":2},"c":3}
+//	jsonMerge('{"a":1}', '{"a":2}') -> {"a":2}
+//	jsonMerge('{"a":1}', "not json", '[1,2]') -> {"a":1}
+func jsonMerge(params []interface{}) (interface{}, error) {
+	merged := make(map[string]interface{})
+
+	for _, param := range params {
+		var object map[string]interface{}
+
+		switch v := param.(type) {
+		case map[string]interface{}:
+			object = v
+		case string:
+			if err := json.Unmarshal([]byte(v), &object); err != nil {
+				continue
+			}
+		case []uint8:
+			if err := json.Unmarshal(v, &object); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		deepMerge(merged, object)
+	}
+
+	return merged, nil
+}
+
+// deepMerge merges src into dst recursively. Nested objects present on both
+// sides are merged; any other value from src replaces the one in dst.
+func deepMerge(dst, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcObject, srcIsObject := srcValue.(map[string]interface{})
+		if !srcIsObject {
+			dst[key] = srcValue
+			continue
+		}
+
+		dstObject, dstIsObject := dst[key].(map[string]interface{})
+		if !dstIsObject {
+			// Copy so later merges never write into the caller's map
+			dstObject = make(map[string]interface{}, len(srcObject))
+			dst[key] = dstObject
+		}
+		deepMerge(dstObject, srcObject)
+	}
+}
+
+// jsonSet sets a field of a JSON object column, creating the intermediate
+// objects of a dot-path as needed. This operator enriches metadata columns
+// with a computed value before export.
+//
+// Parameters:
+//   - params[0]: JSON object (JSON string, []uint8, or map[string]interface{})
+//   - params[1]: Dot-path of the key to set, e.g. "meta.source"
+//   - params[2]: Value to set (nil sets a JSON null)
+//
+// Output:
+//   - JSON string of the object with the key set
+//   - null.String{} if the input is nil, invalid JSON or not an object, or
+//     the path is empty or has an empty segment ("a..b")
+//
+// Implementation Notes:
+//   - An existing key is overridden; an intermediate value that is not an
+//     object is replaced by one
+//   - Input maps are never modified; the objects along the path are copied
+//   - Keys of the result are sorted, so equal objects give the same string
+//   - []uint8 values are set as text
+//
+// Examples:
+//
+//	jsonSet('{"a":1}', "b", 2) -> `{"a":1,"b":2}`
+//	jsonSet('{"a":1}', "meta.source", "web") -> `{"a":1,"meta":{"source":"web"}}`
+//	jsonSet('{"a":1}', "a", "x") -> `{"a":"x"}`
+//	jsonSet("not json", "a", 1) -> null.String{}
+func jsonSet(params []interface{}) (interface{}, error) {
+	if len(params) < 3 || isNullValue(params[1]) {
+		return null.String{}, nil
+	}
+
+	document, ok := toJSONDocument(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return null.String{}, nil
+	}
+
+	keys := strings.Split(toString(params[1]), ".")
+	for _, key := range keys {
+		if key == "" {
+			return null.String{}, nil
+		}
+	}
+
+	var value interface{}
+	if !isNullValue(params[2]) {
+		value = normalizeBytes(params[2])
+		if raw, ok := value.([]uint8); ok {
+			value = string(raw)
+		}
+	}
+
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(setJSONPath(object, keys, value))
+	if err != nil {
+		return nil, fmt.Errorf("jsonSet failed to serialize the object: %w", err)
+	}
+	return string(data), nil
+}
+
+// setJSONPath returns a copy of object with value set at keys. Only the
+// objects along the path are copied; a nil object is treated as empty.
+func setJSONPath(object map[string]interface{}, keys []string, value interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(object)+1)
+	for key, v := range object {
+		copied[key] = v
+	}
+
+	if len(keys) == 1 {
+		copied[keys[0]] = value
+		return copied
+	}
+
+	child, _ := copied[keys[0]].(map[string]interface{})
+	copied[keys[0]] = setJSONPath(child, keys[1:], value)
+	return copied
+}
+
+// parseKeyValue parses a "k1=v1;k2=v2" string into a JSON object.
+// This operator exports legacy settings columns (e.g. "color=red;size=large")
+// as structured data.
+//
+// Parameters:
+//   - params[0]: Source string (any value is converted via toString)
+//   - params[1]: (Optional) Pair separator (default ";")
+//   - params[2]: (Optional) Key/value separator (default "=")
+//
+// Output:
+//   - map[string]interface{}: Values by key, as strings
+//   - Empty map if the string has no valid pair
+//   - null.String{} if the source is nil
+//
+// Implementation Notes:
+//   - Keys and values are trimmed; a pair is split on the first key/value
+//     separator only, so values may contain it ("q=a=b" -> {"q":"a=b"})
+//   - Malformed pairs (no separator or an empty key) and empty pairs, e.g.
+//     from a trailing pair separator, are skipped
+//   - A repeated key keeps its last value
+//
+// Examples:
+//
+//	parseKeyValue("color=red; size=large") -> {"color":"red","size":"large"}
+//	parseKeyValue("url=a?b=c;;flag", ";") -> {"url":"a?b=c"}
+//	parseKeyValue("a:1|b:2", "|", ":") -> {"a":"1","b":"2"}
+func parseKeyValue(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
+	}
+
+	pairSeparator, kvSeparator := ";", "="
+	if len(params) > 1 && toString(params[1]) != "" {
+		pairSeparator = toString(params[1])
+	}
+	if len(params) > 2 && toString(params[2]) != "" {
+		kvSeparator = toString(params[2])
+	}
+
+	result := make(map[string]interface{})
+	for _, pair := range strings.Split(toString(params[0]), pairSeparator) {
+		key, value, found := strings.Cut(pair, kvSeparator)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		result[key] = strings.TrimSpace(value)
+	}
+
+	return result, nil
+}
+
+// convertCase converts a string between case conventions (snake, camel, kebab, pascal).
+// This operator normalizes imported keys and field values for integration targets.
+//
+// Parameters:
+//   - params[0]: Source string (any value is converted via toString)
+//   - params[1]: Target convention: "snake", "camel", "kebab" or "pascal"
+//
+// Output:
+//   - Converted string
+//   - null.String{} if source field is nil
+//   - Error if the target convention is missing or unsupported
+//
+// Implementation Notes:
+//   - Words are split on any non-alphanumeric character and on case changes
+//   - Acronyms stay together ("CustomerID" -> customer, id; "HTTPServer" -> http, server)
+//   - Letters and digits form separate words ("address2Line" -> address, 2, line)
+//
+// Examples:
+//
+//	convertCase("customer name", "snake") -> "customer_name"
+//	convertCase("CustomerID", "kebab") -> "customer-id"
+//	convertCase("first-name", "camel") -> "firstName"
+//	convertCase("first-name", "pascal") -> "FirstName"
+//	convertCase(nil, "snake") -> null.String{}
+func convertCase(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("convertCase requires 2 parameters (value, convention)")
+	}
+
+	if params[0] == nil {
+		return null.String{}, nil
+	}
+
+	convention := strings.ToLower(toString(params[1]))
+	converted, ok := toCase(toString(params[0]), convention)
+	if !ok {
+		return nil, fmt.Errorf("convertCase: unsupported convention '%s' (expected snake, camel, kebab or pascal)", convention)
+	}
+	return converted, nil
+}
+
+// toCase converts text to a case convention ("snake", "camel", "kebab" or
+// "pascal"); ok is false for other conventions
+func toCase(text, convention string) (string, bool) {
+	words := splitWords(text)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+
+	switch convention {
+	case "snake":
+		return strings.Join(words, "_"), true
+	case "kebab":
+		return strings.Join(words, "-"), true
+	case "camel", "pascal":
+		var result strings.Builder
+		for i, word := range words {
+			if i == 0 && convention == "camel" {
+				result.WriteString(word)
+				continue
+			}
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			result.WriteString(string(runes))
+		}
+		return result.String(), true
+	default:
+		return "", false
+	}
+}
+
+// stripEmoji removes emoji and pictographic symbols from text.
+// This operator cleans ticket text for export targets with limited charset support.
+//
+// Parameters:
+//   - params[0]: Source string (any value is converted via toString)
+//   - params[1]: Optional mode: "emoji" (default) or "printable"
+//
+// Output:
+//   - Cleaned string
+//   - null.String{} if source field is nil
+//   - Error if the mode is unsupported
+//
+// Implementation Notes:
+//   - Whole emoji sequences are removed: variation selectors, skin tone
+//     modifiers, keycaps, combining marks and ZWJ-joined parts go with their base
+//   - Text symbols (©, ®, ™) are kept unless followed by the emoji selector U+FE0F
+//   - "printable" mode also drops control and format characters, keeping
+//     newlines and tabs
+//   - Combining marks and ZWJ outside emoji sequences are preserved ("é", Indic scripts)
+//
+// Examples:
+//
+//	stripEmoji("Thanks 👍🏽!") -> "Thanks !"
+//	stripEmoji("Family 👨‍👩‍👧 trip") -> "Family  trip"
+//	stripEmoji("Press 1️⃣") -> "Press "
+//	stripEmoji("a\u0000b 🙂", "printable") -> "ab "
+//	stripEmoji(nil) -> null.String{}
+func stripEmoji(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || params[0] == nil {
+		return null.String{}, nil
+	}
+
+	printableOnly := false
+	if len(params) > 1 && params[1] != nil {
+		switch mode := strings.ToLower(toString(params[1])); mode {
+		case "", "emoji":
+		case "printable":
+			printableOnly = true
+		default:
+			return nil, fmt.Errorf("stripEmoji: unsupported mode '%s'", mode)
+		}
+	}
+
+	runes := []rune(toString(params[0]))
+	var builder strings.Builder
+	builder.Grow(len(runes))
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if unicode.Is(emojiRunes, r) || (i+1 < len(runes) && (runes[i+1] == 0xFE0F || runes[i+1] == 0x20E3)) {
+			i = emojiSequenceEnd(runes, i) - 1
+			continue
+		}
+
+		// Stray presentation selectors and tags left from a broken sequence
+		if r == 0xFE0E || r == 0xFE0F || r == 0x20E3 || (r >= 0xE0020 && r <= 0xE007F) {
+			continue
+		}
+
+		if printableOnly && !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			continue
+		}
+
+		builder.WriteRune(r)
+	}
+
+	return builder.String(), nil
+}
+
+// matches reports whether a value matches a regular expression.
+// This operator flags data-quality issues (e.g. invalid emails or phone numbers) in exports.
+//
+// Parameters:
+//   - params[0]: Source value (any value is converted via toString)
+//   - params[1]: Regular expression (RE2 syntax, see package regexp)
+//
+// Output:
+//   - bool: true if the value matches, false otherwise
+//   - null.Bool{} if the value is nil or the pattern is missing or invalid
+//
+// Implementation Notes:
+//   - The pattern is unanchored; use ^...$ to match the whole value
+//   - Compiled patterns are cached, so a constant pattern compiles once
+//
+// Examples:
+//
+//	matches("user@example.com", "^[^@\\s]+@[^@\\s]+\\.[a-z]+$") -> true
+//	matches("not-an-email", "^[^@\\s]+@[^@\\s]+\\.[a-z]+$") -> false
+//	matches("abc", "([") -> null.Bool{}
+func matches(params []interface{}) (interface{}, error) {
+	if len(params) < 2 || params[0] == nil || params[1] == nil {
+		return null.Bool{}, nil
+	}
+
+	pattern, err := compilePattern(toString(params[1]))
+	if err != nil {
+		return null.Bool{}, nil
+	}
+
+	return pattern.MatchString(toString(params[0])), nil
+}
+
+// lengthInRange reports whether the length of a text value is within bounds.
+// This operator flags data-quality issues (e.g. truncated or oversized
+// subjects) in QA exports, like matches.
+//
+// Parameters:
+//   - params[0]: Source value (any value is converted via toString)
+//   - params[1]: Minimum length in runes (inclusive; nil means no minimum)
+//   - params[2]: (Optional) Maximum length in runes (inclusive; nil or
+//     missing means no maximum)
+//
+// Output:
+//   - bool: true if the length is within [min, max], false otherwise
+//   - null.Bool{} if a bound is not a number
+//
+// Implementation Notes:
+//   - A nil or empty value has length 0
+//   - Length counts runes, so "café" has length 4
+//
+// Examples:
+//
+//	lengthInRange("Printer jam", 5, 100) -> true
+//	lengthInRange("Hi", 5, 100) -> false
+//	lengthInRange(nil, 1, 10) -> false
+//	lengthInRange(nil, 0, 10) -> true
+func lengthInRange(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("lengthInRange requires at least 2 parameters (value, min)")
+	}
+
+	length := 0
+	if !isNullValue(params[0]) {
+		length = utf8.RuneCountInString(toString(params[0]))
+	}
+
+	// bounds[0] is the minimum, bounds[1] the optional maximum
+	bounds := params[1:min(len(params), 3)]
+	for i, bound := range bounds {
+		if isNullValue(bound) {
+			continue
+		}
+		limit, ok := toFloat64(bound)
+		if !ok {
+			return null.Bool{}, nil
+		}
+		if (i == 0 && float64(length) < limit) || (i == 1 && float64(length) > limit) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// padLeft right-aligns a value in a field of exactly width runes, padding it
+// on the left. This operator builds fixed-width columns for legacy flat-file
+// consumers, e.g. zero-padded amounts or account numbers.
+//
+// Parameters:
+//   - params[0]: Source value (any value is converted via toString)
+//   - params[1]: Field width in runes
+//   - params[2]: (Optional) Pad character (default " "; only the first rune
+//     of a longer string is used)
+//
+// Output:
+//   - String of exactly width runes
+//   - Error if the width is missing, not a number or negative
+//
+// Implementation Notes:
+//   - Widths count runes, so multibyte values and pad characters keep the
+//     field aligned ("café" is 4 runes wide)
+//   - Values longer than the width are truncated to their first width runes
+//   - A nil value is padded like an empty string, so the field keeps its width
+//
+// Examples:
+//
+//	padLeft("42", 5, "0") -> "00042"
+//	padLeft("123456", 4) -> "1234"
+//	padLeft(nil, 3) -> "   "
+func padLeft(params []interface{}) (interface{}, error) {
+	return padFixedWidth("padLeft", params, true)
+}
+
+// padRight left-aligns a value in a field of exactly width runes, padding it
+// on the right. See padLeft for the parameters and notes.
+//
+// Examples:
+//
+//	padRight("Budi", 6) -> "Budi  "
+//	padRight("Jakarta Selatan", 7) -> "Jakarta"
+//	padRight("café", 6, "·") -> "café··"
+func padRight(params []interface{}) (interface{}, error) {
+	return padFixedWidth("padRight", params, false)
+}
+
+// padFixedWidth pads or truncates params[0] to params[1] runes with the pad
+// character params[2], on the left when left is set
+func padFixedWidth(name string, params []interface{}, left bool) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("%s requires at least 2 parameters (value, width)", name)
+	}
+
+	width, ok := toFloat64(params[1])
+	if !ok || width < 0 {
+		return nil, fmt.Errorf("%s: width must be a non-negative number, got %v", name, params[1])
+	}
+
+	pad := " "
+	if len(params) > 2 && toString(params[2]) != "" {
+		r, _ := utf8.DecodeRuneInString(toString(params[2]))
+		pad = string(r)
+	}
+
+	text := ""
+	if !isNullValue(params[0]) {
+		text = toString(params[0])
+	}
+
+	runes := []rune(text)
+	if len(runes) >= int(width) {
+		return string(runes[:int(width)]), nil
+	}
+
+	padding := strings.Repeat(pad, int(width)-len(runes))
+	if left {
+		return padding + text, nil
+	}
+	return text + padding, nil
+}
+
+// xmlStrip returns the text content of an XML fragment. Unlike stripHTML it
+// runs an XML tokenizer, so CDATA sections keep their text (including any
+// '<' or '>' inside) and comments are dropped as a whole.
+//
+// Parameters:
+//   - params[0]: Source field containing XML (any value is converted via toString)
+//
+// Output:
+//   - Text content of every element, in document order, with surrounding
+//     whitespace trimmed
+//   - null.String{} if the source field is nil
+//
+// Implementation Notes:
+//   - Uses encoding/xml in non-strict mode with the HTML entities and
+//     auto-closed elements, so fragments without a single root element,
+//     unquoted attributes and <br> are accepted
+//   - Entities are decoded once: "&amp;lt;b&amp;gt;" becomes "&lt;b&gt;"
+//   - Comments, processing instructions (<?xml ...?>) and directives
+//     (<!DOCTYPE ...>) are dropped
+//   - Content the tokenizer rejects falls back to stripHTML
+//
+// Examples:
+//
+//	xmlStrip("<note><to>Budi</to> <body>Hi</body></note>") -> "Budi Hi"
+//	xmlStrip("<![CDATA[a < b]]>") -> "a < b"
+//	xmlStrip("Before<!-- hidden -->After") -> "BeforeAfter"
+//	xmlStrip(nil) -> null.String{}
+func xmlStrip(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
+	}
+
+	text := toString(params[0])
+	if strings.IndexAny(text, "<&") == -1 {
+		return strings.TrimSpace(text), nil
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var result strings.Builder
+	result.Grow(len(text))
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return strings.TrimSpace(stripHTMLTags(text)), nil
+		}
+		if data, ok := token.(xml.CharData); ok {
+			result.Write(data)
+		}
+	}
+
+	return strings.TrimSpace(result.String()), nil
+}
+
+// ordinal writes an integer as an ordinal for report prose, e.g. "22nd".
+//
+// Parameters:
+//   - params[0]: Integer value (number or numeric string)
+//   - params[1]: (Optional) Locale: "en" (default) or "id"
+//
+// Output:
+//   - String: "1st", "2nd", "11th", "22nd" in English; "ke-1", "ke-22" in Indonesian
+//   - null.String{} if the value is nil, not numeric or not a whole number
+//   - Error for an unknown locale
+//
+// Implementation Notes:
+//   - 11, 12 and 13 (and 111, 212, ...) take "th" in English
+//   - Negative values keep their sign ("-1st")
+//
+// Examples:
+//
+//	ordinal(1) -> "1st"
+//	ordinal("13") -> "13th"
+//	ordinal(21) -> "21st"
+//	ordinal(3, "id") -> "ke-3"
+//	ordinal("abc") -> null.String{}
+func ordinal(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+	locale, err := numberLocale("ordinal", params)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := toWholeNumber(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+
+	text := strconv.FormatInt(n, 10)
+	if locale == "id" {
+		return "ke-" + text, nil
+	}
+
+	abs := n % 100
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= 11 && abs <= 13:
+		return text + "th", nil
+	case abs%10 == 1:
+		return text + "st", nil
+	case abs%10 == 2:
+		return text + "nd", nil
+	case abs%10 == 3:
+		return text + "rd", nil
+	default:
+		return text + "th", nil
+	}
+}
+
+// numberToWords spells out an integer for report prose, e.g. "twenty-two".
+//
+// Parameters:
+//   - params[0]: Integer value (number or numeric string)
+//   - params[1]: (Optional) Locale: "en" (default) or "id"
+//
+// Output:
+//   - String: The number in words, e.g. "one hundred five" or "seratus lima"
+//   - null.String{} if the value is nil, not numeric or not a whole number
+//   - Error for an unknown locale
+//
+// Implementation Notes:
+//   - English uses short scales (thousand, million, billion, ...) without
+//     "and"; tens and units are hyphenated ("forty-two")
+//   - Indonesian uses "se-" for one ten, hundred and thousand ("sebelas",
+//     "seratus", "seribu") but "satu juta" for a million
+//   - Negative values are prefixed with "minus"
+//
+// Examples:
+//
+//	numberToWords(0) -> "zero"
+//	numberToWords(42) -> "forty-two"
+//	numberToWords(1205) -> "one thousand two hundred five"
+//	numberToWords(1115, "id") -> "seribu seratus lima belas"
+//	numberToWords(2.5) -> null.String{}
+func numberToWords(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+	locale, err := numberLocale("numberToWords", params)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := toWholeNumber(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+
+	words := numberWords[locale]
+	if n == 0 {
+		return words.zero, nil
+	}
+
+	// Split into groups of three digits, the least significant first; the
+	// magnitude is kept unsigned so math.MinInt64 does not overflow
+	magnitude := uint64(n)
+	if n < 0 {
+		magnitude = -magnitude
+	}
+	var groups []int
+	for ; magnitude > 0; magnitude /= 1000 {
+		groups = append(groups, int(magnitude%1000))
+	}
+
+	var parts []string
+	if n < 0 {
+		parts = append(parts, "minus")
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		group := groups[i]
+		switch {
+		case group == 0:
+			continue
+		case i == 1 && group == 1 && words.oneThousand != "":
+			parts = append(parts, words.oneThousand)
+			continue
+		}
+		parts = append(parts, words.hundreds(group))
+		if i > 0 {
+			parts = append(parts, words.scales[i])
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// numberLocale returns the locale of params[1] for name ("en" when omitted)
+func numberLocale(name string, params []interface{}) (string, error) {
+	if len(params) < 2 || isNullValue(params[1]) || toString(params[1]) == "" {
+		return "en", nil
+	}
+	locale := strings.ToLower(toString(params[1]))
+	if _, ok := numberWords[locale]; !ok {
+		return "", fmt.Errorf("%s: unsupported locale %q (use en or id)", name, toString(params[1]))
+	}
+	return locale, nil
+}
+
+// toWholeNumber converts a number or numeric string without a fractional
+// part to int64
+func toWholeNumber(v interface{}) (int64, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case string:
+		if n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64); err == nil {
+			return n, true
+		}
+	case []uint8:
+		return toWholeNumber(string(val))
+	}
+
+	f, ok := toFloat64(v)
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// localeWords spells out numbers in one language
+type localeWords struct {
+	zero        string
+	units       [20]string // 0-19
+	tens        [10]string // 20, 30, ... at their tens digit
+	tensJoin    string     // between tens and units ("-" in "forty-two")
+	hundred     string     // "hundred", or the full "seratus" form with oneHundred
+	oneHundred  string     // replaces "<one> <hundred>" when set
+	oneThousand string     // replaces "<one> <thousand>" when set
+	scales      []string   // per group of three digits: "", thousand, million, ...
+}
+
+// numberWords are the supported numberToWords and ordinal locales
+var numberWords = map[string]localeWords{
+	"en": {
+		zero: "zero",
+		units: [20]string{"", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten",
+			"eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"},
+		tens:     [10]string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"},
+		tensJoin: "-",
+		hundred:  "hundred",
+		scales:   []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"},
+	},
+	"id": {
+		zero: "nol",
+		units: [20]string{"", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan", "sepuluh",
+			"sebelas", "dua belas", "tiga belas", "empat belas", "lima belas", "enam belas", "tujuh belas", "delapan belas", "sembilan belas"},
+		tens:        [10]string{"", "", "dua puluh", "tiga puluh", "empat puluh", "lima puluh", "enam puluh", "tujuh puluh", "delapan puluh", "sembilan puluh"},
+		tensJoin:    " ",
+		hundred:     "ratus",
+		oneHundred:  "seratus",
+		oneThousand: "seribu",
+		scales:      []string{"", "ribu", "juta", "miliar", "triliun", "kuadriliun", "kuintiliun"},
+	},
+}
+
+// hundreds spells out 1-999
+func (w localeWords) hundreds(n int) string {
+	var parts []string
+	if h := n / 100; h > 0 {
+		if h == 1 && w.oneHundred != "" {
+			parts = append(parts, w.oneHundred)
+		} else {
+			parts = append(parts, w.units[h]+" "+w.hundred)
 		}
+	}
+
+	switch rest := n % 100; {
+	case rest == 0:
+	case rest < 20:
+		parts = append(parts, w.units[rest])
+	case rest%10 == 0:
+		parts = append(parts, w.tens[rest/10])
+	default:
+		parts = append(parts, w.tens[rest/10]+w.tensJoin+w.units[rest%10])
+	}
+	return strings.Join(parts, " ")
+}
+
+// regexCapture extracts one capture group of the first regular expression
+// match. This operator parses structured text, e.g. the order number out of
+// a subject such as "Order #12345 delayed".
+//
+// Parameters:
+//   - params[0]: Source value (any value is converted via toString)
+//   - params[1]: Regular expression (RE2 syntax, see package regexp)
+//   - params[2]: (Optional) Group index (default 1; 0 is the whole match)
+//
+// Output:
+//   - String: The text captured by the group in the first match
+//   - null.String{} if the value is nil, the pattern is missing or invalid,
+//     nothing matches, the group does not exist or did not take part in the match
+//
+// Implementation Notes:
+//   - Patterns are compiled and cached like those of matches
+//
+// Examples:
+//
+//	regexCapture("Order #12345 delayed", "#(\\d+)") -> "12345"
+//	regexCapture("2024-05-17", "(\\d+)-(\\d+)-(\\d+)", 2) -> "05"
+//	regexCapture("No order", "#(\\d+)") -> null.String{}
+//	regexCapture("abc", "([") -> null.String{}
+func regexCapture(params []interface{}) (interface{}, error) {
+	if len(params) < 2 || isNullValue(params[0]) || isNullValue(params[1]) {
+		return null.String{}, nil
+	}
 
-		for _, elem := range elements {
-			element, ok := elem.(map[string]interface{})
-			if !ok {
-				continue
+	pattern, err := compilePattern(toString(params[1]))
+	if err != nil {
+		return null.String{}, nil
+	}
+
+	group := 1
+	if len(params) > 2 && !isNullValue(params[2]) {
+		group = toInt(params[2])
+	}
+	if group < 0 || group > pattern.NumSubexp() {
+		return null.String{}, nil
+	}
+
+	text := toString(params[0])
+	match := pattern.FindStringSubmatchIndex(text)
+	if match == nil || match[2*group] < 0 {
+		return null.String{}, nil
+	}
+	return text[match[2*group]:match[2*group+1]], nil
+}
+
+// slugify turns free text into a safe slug for filenames and export identifiers.
+// This operator builds per-row file names or keys from values such as ticket subjects.
+//
+// Parameters:
+//   - params[0]: Source string (any value is converted via toString)
+//   - params[1]: Optional maximum length in characters (<= 0 means no limit)
+//
+// Output:
+//   - Slug containing only lowercase letters, digits, hyphens and underscores
+//   - null.String{} if source field is nil
+//
+// Implementation Notes:
+//   - Whitespace and path separators (/ and \) become hyphens
+//   - Any other character that is not a letter, digit, hyphen or underscore is
+//     removed (punctuation, control and reserved characters)
+//   - Repeated hyphens are collapsed; leading/trailing hyphens are trimmed,
+//     also after truncation to the maximum length
+//
+// Examples:
+//
+//	slugify("Refund / Billing: URGENT!!") -> "refund-billing-urgent"
+//	slugify("  Hello World  ") -> "hello-world"
+//	slugify("Quarterly report 2024", 12) -> "quarterly-re"
+//	slugify(nil) -> null.String{}
+func slugify(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || params[0] == nil {
+		return null.String{}, nil
+	}
+
+	maxLength := 0
+	if len(params) > 1 {
+		maxLength = toInt(params[1])
+	}
+
+	var builder strings.Builder
+	pendingHyphen := false
+	length := 0
+
+	for _, r := range strings.ToLower(toString(params[0])) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			needed := 1
+			if pendingHyphen {
+				needed = 2
+			}
+			if maxLength > 0 && length+needed > maxLength {
+				return builder.String(), nil
 			}
+			if pendingHyphen {
+				builder.WriteByte('-')
+				length++
+				pendingHyphen = false
+			}
+			builder.WriteRune(r)
+			length++
+		case r == '-' || r == '/' || r == '\\' || unicode.IsSpace(r):
+			// Only emit a hyphen between two kept characters
+			pendingHyphen = length > 0
+		}
+	}
 
-			elementName, ok := element["name"].(string)
-			if !ok || elementName != name {
+	return builder.String(), nil
+}
+
+// changed reports whether two values differ, for "old vs new" audit columns.
+//
+// Parameters:
+//   - params[0]: Old value (any value is converted via toString)
+//   - params[1]: New value (any value is converted via toString)
+//
+// Output:
+//   - bool: true if the values differ, false otherwise
+//
+// Implementation Notes:
+//   - Values are compared as trimmed strings, so 5, "5" and []uint8("5 ") are equal
+//   - nil and invalid null.* values are null; two nulls are unchanged, while a
+//     null and any non-null value (including "") are changed
+//
+// Examples:
+//
+//	changed("open", "closed") -> true
+//	changed(5, "5") -> false
+//	changed(nil, nil) -> false
+//	changed(nil, "open") -> true
+func changed(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("changed requires 2 parameters (old, new)")
+	}
+
+	return valuesDiffer(params[0], params[1]), nil
+}
+
+// diffText renders a compact "old→new" string when two values differ.
+//
+// Parameters:
+//   - params[0]: Old value (any value is converted via toString)
+//   - params[1]: New value (any value is converted via toString)
+//
+// Output:
+//   - String "old→new" (trimmed values) if the values differ
+//   - "" if the values are equal (same comparison as changed)
+//
+// Examples:
+//
+//	diffText("open", "closed") -> "open→closed"
+//	diffText("open", "open") -> ""
+//	diffText(nil, "open") -> "→open"
+func diffText(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("diffText requires 2 parameters (old, new)")
+	}
+
+	if !valuesDiffer(params[0], params[1]) {
+		return "", nil
+	}
+
+	return strings.TrimSpace(toString(params[0])) + "→" + strings.TrimSpace(toString(params[1])), nil
+}
+
+// valuesDiffer compares two values after string normalization; see changed
+func valuesDiffer(a, b interface{}) bool {
+	aNull, bNull := isNullValue(a), isNullValue(b)
+	if aNull || bNull {
+		return aNull != bNull
+	}
+
+	return strings.TrimSpace(toString(a)) != strings.TrimSpace(toString(b))
+}
+
+// isNullValue reports whether v is nil or an invalid null.* value
+func isNullValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case null.String:
+		return !val.Valid
+	case null.Int:
+		return !val.Valid
+	case null.Float:
+		return !val.Valid
+	case null.Bool:
+		return !val.Valid
+	case null.Time:
+		return !val.Valid
+	default:
+		return false
+	}
+}
+
+// countMatching counts the objects in a JSON array whose field matches a value.
+// This operator summarizes list columns, e.g. the number of email contacts.
+//
+// Parameters:
+//   - params[0]: JSON array (JSON string, []byte or decoded []interface{})
+//   - params[1]: Field name of the array elements to compare
+//   - params[2]: Value to match
+//
+// Output:
+//   - Integer: Number of matching elements
+//   - 0 if params[0] is nil, not a JSON array, or has no matching elements
+//   - Error if the field name or the value parameter is missing
+//
+// Implementation Notes:
+//   - Values are compared like changed: trimmed string forms, so 1 matches "1"
+//   - A missing field is null and only matches a nil value
+//   - Elements that are not objects (e.g. nested arrays) are skipped
+//
+// Examples:
+//
+//	countMatching('[{"contact_type":"email"},{"contact_type":"phone"}]', "contact_type", "email") -> 1
+//	countMatching('[{"status":1},{"status":"1"}]', "status", 1) -> 2
+//	countMatching("not json", "contact_type", "email") -> 0
+func countMatching(params []interface{}) (interface{}, error) {
+	if len(params) < 3 {
+		return nil, fmt.Errorf("countMatching requires 3 parameters (array, field, value)")
+	}
+
+	field := toString(params[1])
+	if field == "" {
+		return nil, fmt.Errorf("countMatching field name must not be empty")
+	}
+
+	elements, ok := toJSONArray(params[0])
+	if !ok {
+		return 0, nil
+	}
+
+	count := 0
+	for _, element := range elements {
+		object, isObject := element.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+		if !valuesDiffer(object[field], params[2]) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// jsonArrayFilter keeps the objects of a JSON array whose field matches a
+// value. This operator narrows list columns before further processing, e.g.
+// only the email contacts.
+//
+// Parameters:
+//   - params[0]: JSON array (JSON string, []byte or decoded []interface{})
+//   - params[1]: Field name of the array elements to compare
+//   - params[2]: Value to match
+//
+// Output:
+//   - JSON string of the matching elements, in array order ("[]" if none match)
+//   - null.String{} if params[0] is nil or not a JSON array
+//   - Error if the field name or the value parameter is missing
+//
+// Implementation Notes:
+//   - Elements are matched like countMatching: trimmed string forms, so 1
+//     matches "1", and a missing field only matches a nil value
+//   - Elements that are not objects (e.g. nested arrays) are dropped
+//
+// Examples:
+//
+//	jsonArrayFilter('[{"type":"email","v":"a@b.co"},{"type":"phone","v":"123"}]', "type", "email") -> '[{"type":"email","v":"a@b.co"}]'
+//	jsonArrayFilter('[{"type":"phone"}]', "type", "email") -> '[]'
+//	jsonArrayFilter("not json", "type", "email") -> null.String{}
+func jsonArrayFilter(params []interface{}) (interface{}, error) {
+	if len(params) < 3 {
+		return nil, fmt.Errorf("jsonArrayFilter requires 3 parameters (array, field, value)")
+	}
+
+	field := toString(params[1])
+	if field == "" {
+		return nil, fmt.Errorf("jsonArrayFilter field name must not be empty")
+	}
+
+	elements, ok := toJSONArray(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+
+	matching := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		object, isObject := element.(map[string]interface{})
+		if !isObject {
+			continue
+		}
+		if !valuesDiffer(object[field], params[2]) {
+			matching = append(matching, element)
+		}
+	}
+
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(matching)
+	if err != nil {
+		return nil, fmt.Errorf("jsonArrayFilter failed to serialize the array: %w", err)
+	}
+	return string(data), nil
+}
+
+// dedupeArray removes duplicate elements from a JSON array, keeping the first
+// occurrence of each. This operator cleans list columns with repeated tags or
+// contacts.
+//
+// Parameters:
+//   - params[0]: JSON array (JSON string, []byte or decoded []interface{})
+//   - params[1]: (Optional) Field name: objects are duplicates when this field
+//     is equal, instead of when the whole element is equal
+//
+// Output:
+//   - JSON string of the array without duplicates, in first-seen order
+//   - null.String{} if params[0] is nil or not a JSON array
+//
+// Implementation Notes:
+//   - Elements are compared by their JSON encoding (object keys sorted), so
+//     {"a":1,"b":2} equals {"b":2,"a":1} but 1 differs from "1"
+//   - With a field name, elements that are not objects or lack the field are
+//     all kept
+//
+// Examples:
+//
+//	dedupeArray('["vip","new","vip"]') -> '["vip","new"]'
+//	dedupeArray('[{"id":1,"n":"a"},{"id":2},{"id":1,"n":"b"}]', "id") -> '[{"id":1,"n":"a"},{"id":2}]'
+//	dedupeArray("not json") -> null.String{}
+func dedupeArray(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
+
+	elements, ok := toJSONArray(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
+
+	field := ""
+	if len(params) > 1 && !isNullValue(params[1]) {
+		field = toString(params[1])
+	}
+
+	encoder := json.ConfigCompatibleWithStandardLibrary
+	seen := make(map[string]bool, len(elements))
+	unique := make([]interface{}, 0, len(elements))
+	for _, element := range elements {
+		key := element
+		if field != "" {
+			object, isObject := element.(map[string]interface{})
+			value, hasField := object[field]
+			if !isObject || !hasField {
+				unique = append(unique, element)
 				continue
 			}
+			key = value
+		}
 
-			// Found the element, process based on type
-			elementType, _ := element["type"].(string)
+		encoded, err := encoder.Marshal(key)
+		if err != nil {
+			return nil, fmt.Errorf("dedupeArray failed to serialize an element: %w", err)
+		}
+		if seen[string(encoded)] {
+			continue
+		}
+		seen[string(encoded)] = true
+		unique = append(unique, element)
+	}
 
-			switch elementType {
-			case "multipletext":
-				// Multiple text inputs - concatenate values
-				if valueMap, ok := value.(map[string]interface{}); ok {
-					// Preallocate slice with estimated capacity
-					values := make([]string, 0, len(valueMap))
-					for _, v := range valueMap {
-						if str, ok := v.(string); ok {
-							values = append(values, str)
-						}
-					}
-					return strings.Join(values, ",")
-				}
+	data, err := encoder.Marshal(unique)
+	if err != nil {
+		return nil, fmt.Errorf("dedupeArray failed to serialize the array: %w", err)
+	}
+	return string(data), nil
+}
 
-			case "matrixdynamic":
-				// Matrix data - return as JSON
-				if jsonBytes, err := json.Marshal(value); err == nil {
-					return string(jsonBytes)
-				}
-			}
+// mostCommon returns the most frequent value (the mode) of a JSON array.
+// This operator picks the most given answer of a survey or analytics list.
+//
+// Parameters:
+//   - params[0]: JSON array (JSON string, []byte or decoded []interface{})
+//   - params[1]: (Optional) Field name: for an array of objects, the value of
+//     this field is counted instead of the whole element
+//
+// Output:
+//   - The most frequent value as decoded from JSON (string, number, bool,
+//     object or array); on a tie, the value seen first
+//   - null.String{} if params[0] is nil, not a JSON array, or has no values
+//
+// Implementation Notes:
+//   - Values are compared by their JSON encoding (object keys sorted), so
+//     1 and "1" are different values
+//   - null elements, and with a field name elements that are not objects or
+//     lack the field (or hold null), are not counted
+//
+// Examples:
+//
+//	mostCommon('["yes","no","yes"]') -> "yes"
+//	mostCommon('[2,1,1,2]') -> 2 (tie: 2 is seen first)
+//	mostCommon('[{"rating":4},{"rating":5},{"rating":5}]', "rating") -> 5
+//	mostCommon('[]') -> null.String{}
+func mostCommon(params []interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return null.String{}, nil
+	}
 
-			// Check for choices (dropdown, checkbox, radiogroup, etc.)
-			if choices, ok := element["choices"].([]interface{}); ok {
-				// Handle array of values (for checkbox/multi-select)
-				if valueArray, ok := value.([]interface{}); ok {
-					results := make([]string, 0, len(valueArray))
-					for _, val := range valueArray {
-						if valStr, ok := val.(string); ok {
-							for _, choice := range choices {
-								if choiceMap, ok := choice.(map[string]interface{}); ok {
-									if choiceValue, ok := choiceMap["value"].(string); ok && choiceValue == valStr {
-										if text, exists := choiceMap["text"]; exists {
-											results = append(results, translationTitleSurvey(text))
-										}
-										break
-									}
-								}
-							}
-						}
-					}
-					return strings.Join(results, ",")
-				} else {
-					// Handle single value (for dropdown/radio)
-					if valueStr, ok := value.(string); ok {
-						for _, choice := range choices {
-							if choiceMap, ok := choice.(map[string]interface{}); ok {
-								if choiceValue, ok := choiceMap["value"].(string); ok && choiceValue == valueStr {
-									if text, exists := choiceMap["text"]; exists {
-										return translationTitleSurvey(text)
-									}
-									break
-								}
-							}
-						}
-					}
-				}
-			}
+	elements, ok := toJSONArray(params[0])
+	if !ok {
+		return null.String{}, nil
+	}
 
-			// Check for boolean type with labelTrue/labelFalse
-			if labelTrue, ok := element["labelTrue"]; ok {
-				if valueBool, ok := value.(bool); ok && valueBool {
-					return translationTitleSurvey(labelTrue)
-				}
-			}
-			if labelFalse, ok := element["labelFalse"]; ok {
-				if valueBool, ok := value.(bool); ok && !valueBool {
-					return translationTitleSurvey(labelFalse)
-				}
-			}
+	field := ""
+	if len(params) > 1 && !isNullValue(params[1]) {
+		field = toString(params[1])
+	}
+
+	// Count the values, keeping them in first-seen order
+	encoder := json.ConfigCompatibleWithStandardLibrary
+	counts := make(map[string]int, len(elements))
+	var keys []string
+	var values []interface{}
+	for _, element := range elements {
+		value := element
+		if field != "" {
+			object, _ := element.(map[string]interface{})
+			value = object[field]
+		}
+		if value == nil {
+			continue
+		}
+
+		encoded, err := encoder.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("mostCommon failed to serialize an element: %w", err)
+		}
+		key := string(encoded)
+		if counts[key] == 0 {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+		counts[key]++
+	}
 
-			// For complex types (map/slice), return as JSON
-			switch value.(type) {
-			case map[string]interface{}, []interface{}:
-				if jsonBytes, err := json.Marshal(value); err == nil {
-					return string(jsonBytes)
-				}
-			}
+	if len(keys) == 0 {
+		return null.String{}, nil
+	}
 
-			// Return empty to use original value
-			return ""
+	// Only a strictly higher count replaces the best, so ties keep the value
+	// seen first
+	best := 0
+	for i, key := range keys {
+		if counts[key] > counts[keys[best]] {
+			best = i
 		}
 	}
+	return values[best], nil
+}
 
-	return ""
+// splitToColumns splits a delimited string into an ordered list of values.
+// Combined with Formula.OutputFields, each element becomes its own column.
+//
+// Parameters:
+//   - params[0]: Source string (any value is converted via toString)
+//   - params[1]: (Optional) Delimiter (default: ","), e.g. "'|' AS delimiter"
+//
+// Output:
+//   - []interface{} of trimmed strings, in source order
+//   - nil if source field is nil or empty (all output fields become null)
+//
+// Examples:
+//
+//	splitToColumns("John|Doe|VIP", "|") -> ["John", "Doe", "VIP"]
+//	splitToColumns("a, b", ",") -> ["a", "b"]
+//	splitToColumns(nil) -> nil
+//
+// With "outputFields": ["first", "last", "tier"], the value "John|Doe" yields
+// {"first": "John", "last": "Doe", "tier": null}.
+func splitToColumns(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return nil, nil
+	}
+
+	text := toString(params[0])
+	if text == "" {
+		return nil, nil
+	}
+
+	delimiter := ","
+	if len(params) > 1 && !isNullValue(params[1]) && toString(params[1]) != "" {
+		delimiter = toString(params[1])
+	}
+
+	parts := strings.Split(text, delimiter)
+	values := make([]interface{}, len(parts))
+	for i, part := range parts {
+		values[i] = strings.TrimSpace(part)
+	}
+
+	return values, nil
 }
 
-// getTitleByName retrieves the human-readable title for a question name.
-// Handles comment fields (name-Comment suffix) by getting commentText.
+// jsonPath evaluates a JSONPath expression against a JSON value.
+// This operator extracts data from complex JSON columns where a plain key
+// lookup is not enough: filters, wildcards and recursive descent.
 //
-// Memory efficiency:
-//   - Stack-allocated string operations
-//   - Single pass through questions
-//   - No intermediate allocations
-func getTitleByName(name string, questions map[string]interface{}) string {
-	pages, ok := questions["pages"].([]interface{})
+// Parameters:
+//   - params[0]: JSON value (JSON string, []byte or decoded map/slice)
+//   - params[1]: JSONPath expression, e.g. "$.items[?(@.active==true)].sku"
+//
+// Output:
+//   - The matched value when exactly one value matches (scalar, object or array)
+//   - []interface{} of matched values when several values match
+//   - null.String{} if nothing matches, or the JSON or the path is invalid
+//
+// Implementation Notes:
+//   - Paths are evaluated by github.com/ohler55/ojg/jp (Goessner syntax)
+//   - Compiled paths are cached, so a constant path is parsed once
+//
+// Examples:
+//
+//	jsonPath('{"items":[{"sku":"A","active":true},{"sku":"B","active":false}]}', "$.items[?(@.active==true)].sku") -> "A"
+//	jsonPath('{"items":[{"sku":"A"},{"sku":"B"}]}', "$.items[*].sku") -> ["A", "B"]
+//	jsonPath('{"a":1}', "$.b") -> null.String{}
+//	jsonPath('{"a":1}', "$.items[") -> null.String{}
+func jsonPath(params []interface{}) (interface{}, error) {
+	if len(params) < 2 || isNullValue(params[0]) || isNullValue(params[1]) {
+		return null.String{}, nil
+	}
+
+	expr, err := compileJSONPath(toString(params[1]))
+	if err != nil {
+		return null.String{}, nil
+	}
+
+	document, ok := toJSONDocument(params[0])
 	if !ok {
-		return ""
+		return null.String{}, nil
 	}
 
-	// Check if this is a comment field (name-Comment)
-	newName := name
-	isComment := false
-	parts := strings.Split(name, "-")
-	if len(parts) > 1 && parts[1] == "Comment" {
-		newName = parts[0]
-		isComment = true
+	results := expr.Get(document)
+	switch len(results) {
+	case 0:
+		return null.String{}, nil
+	case 1:
+		return results[0], nil
+	default:
+		return results, nil
 	}
+}
 
-	// Find the question element
-	for _, page := range pages {
-		pageMap, ok := page.(map[string]interface{})
-		if !ok {
-			continue
-		}
+// toJSON serializes a value into a JSON string for embedding structured data
+// (e.g. contacts or jsonPath results) in a single CSV/XML cell.
+//
+// Parameters:
+//   - params[0]: Any value (map, slice or scalar)
+//
+// Output:
+//   - JSON string of the value
+//   - "" if the value is nil or an invalid null.* value
+//   - Error if the value cannot be serialized
+//
+// Implementation Notes:
+//   - Map keys are sorted, so equal values always produce the same cell
+//   - []byte columns are serialized as text, not base64
+//   - Valid null.* values are serialized as their plain value
+//
+// Examples:
+//
+//	toJSON(map[string]interface{}{"b": 1, "a": "x"}) -> `{"a":"x","b":1}`
+//	toJSON([]interface{}{"a", 2}) -> `["a",2]`
+//	toJSON("hello") -> `"hello"`
+//	toJSON(nil) -> ""
+func toJSON(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return "", nil
+	}
 
-		elements, ok := pageMap["elements"].([]interface{})
-		if !ok {
-			continue
-		}
+	value := normalizeBytes(params[0])
+	if raw, ok := value.([]uint8); ok {
+		value = string(raw)
+	}
 
-		for _, elem := range elements {
-			element, ok := elem.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("toJSON failed to serialize %T: %w", params[0], err)
+	}
 
-			elementName, ok := element["name"].(string)
-			if !ok || elementName != newName {
-				continue
-			}
+	return string(data), nil
+}
 
-			title, ok := element["title"]
-			if !ok {
-				continue
-			}
+// redact masks personal data in free text using a named rule, so exports can
+// share a single, consistently applied redaction for each kind of PII.
+//
+// Parameters:
+//   - params[0]: Source text (any value is converted via toString)
+//   - params[1]: Rule name: "email", "phone", "card", "nric", "nik" or "ssn"
+//
+// Output:
+//   - Text with every match of the rule's pattern masked; text without a
+//     match is returned unchanged
+//   - null.String{} if source field is nil
+//   - Error if the rule is missing or unknown, so a typo never leaks the
+//     unredacted value into an export
+//
+// Implementation Notes:
+//   - Rules are defined once in redactionRules (pattern + masking strategy)
+//   - email keeps the first character of the local part and the domain
+//   - phone and card keep the last 4 digits and all separators
+//   - nric keeps the prefix and checksum letters, ssn the last 4 digits
+//   - nik keeps the last 4 digits of 16-digit numbers that are valid NIKs
+//     (see validateNationalId)
+//   - phone matches with fewer than 7 digits (e.g. dates) are left as-is
+//
+// Examples:
+//
+//	redact("mail john.doe@example.com", "email") -> "mail j***@example.com"
+//	redact("+62 812-3456-7890", "phone") -> "+** ***-****-7890"
+//	redact("4111 1111 1111 1111", "card") -> "**** **** **** 1111"
+//	redact("S1234567D", "nric") -> "S*******D"
+//	redact("NIK 3201014509900001", "nik") -> "NIK ************0001"
+//	redact("123-45-6789", "ssn") -> "***-**-6789"
+func redact(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("redact requires 2 parameters (value, rule)")
+	}
 
-			// Handle comment fields
-			if isComment {
-				if commentText, ok := element["commentText"]; ok {
-					// Combine original name and comment text
-					return fmt.Sprintf("%s-%s", parts[0], translationTitleSurvey(commentText))
-				}
-			}
+	name := toString(params[1])
+	rule, ok := redactionRules[name]
+	if !ok {
+		return nil, fmt.Errorf("redact: unknown rule %q", name)
+	}
 
-			return translationTitleSurvey(title)
-		}
+	if params[0] == nil {
+		return null.String{}, nil
 	}
 
-	return ""
+	return rule.pattern.ReplaceAllStringFunc(toString(params[0]), rule.mask), nil
 }
 
-// translationTitleSurvey extracts the text from title field.
-// Handles both string and multi-language object formats.
+// validateNationalId checks that a value is a well-formed Indonesian national
+// ID number (NIK), so exports can flag bad identity data before it is masked
+// with redact(value, "nik").
 //
-// Memory efficiency:
-//   - Direct type assertions (no reflection)
-//   - Stack-allocated operations
-func translationTitleSurvey(title interface{}) string {
-	// Simple string case
-	if str, ok := title.(string); ok {
-		return str
+// Parameters:
+//   - params[0]: NIK (any value is converted via toString); spaces, dots and
+//     dashes between the digits are ignored
+//
+// Output:
+//   - bool: true for a valid NIK, false otherwise
+//   - null.Bool{} if the value is nil
+//
+// Implementation Notes:
+//   - A NIK is 16 digits: province (11 or above), regency and district codes
+//     (not 00), birth date as DDMMYY (DD + 40 for women) and a serial number
+//     (not 0000)
+//   - The birth date must exist: 31-04 or 30-02 is invalid; the century is
+//     unknown, so 29-02 is valid in every year divisible by 4
+//
+// Examples:
+//
+//	validateNationalId("3201014509900001") -> true (woman born 05-09-1990)
+//	validateNationalId("3201 0112 0590 0001") -> true
+//	validateNationalId("320101120590001") -> false (15 digits)
+//	validateNationalId("3201013102900001") -> false (31 February)
+//	validateNationalId(nil) -> null.Bool{}
+func validateNationalId(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.Bool{}, nil
 	}
 
-	// Multi-language object case
-	if titleMap, ok := title.(map[string]interface{}); ok {
-		if defaultTitle, ok := titleMap["default"].(string); ok {
-			return defaultTitle
+	nik := strings.NewReplacer(" ", "", ".", "", "-", "").Replace(strings.TrimSpace(toString(params[0])))
+	return isValidNIK(nik), nil
+}
+
+// isValidNIK reports whether s is a 16-digit NIK with valid region codes,
+// birth date and serial number (see validateNationalId)
+func isValidNIK(s string) bool {
+	if len(s) != 16 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
 		}
 	}
 
-	return ""
+	number := func(from int) int { return int(s[from]-'0')*10 + int(s[from+1]-'0') }
+	province, regency, district := number(0), number(2), number(4)
+	day, month, year := number(6), number(8), number(10)
+	if province < 11 || regency == 0 || district == 0 || s[12:] == "0000" {
+		return false
+	}
+
+	if day > 40 {
+		day -= 40
+	}
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	// Any leap year ending in year has the most days; 2000 + year is one
+	// exactly when a year ending in it is (2000 itself is leap)
+	return day <= time.Date(2000+year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
 }
 
-// concat concatenates all parameters with a space separator
-func concat(params []interface{}) (interface{}, error) {
-	if len(params) == 0 {
-		return "", nil
+// hexEncode writes a value as lowercase hexadecimal, an opaque token for IDs
+// sent to integrations. hexDecode reverses it.
+//
+// Parameters:
+//   - params[0]: Value to encode (any value is converted via toString; byte
+//     columns are encoded byte for byte)
+//
+// Output:
+//   - String: Two hex digits per byte ("" for an empty value)
+//   - null.String{} if the value is nil
+//
+// Examples:
+//
+//	hexEncode("TKT-42") -> "544b542d3432"
+//	hexEncode(42) -> "3432"
+//	hexEncode(nil) -> null.String{}
+func hexEncode(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
 	}
+	return hex.EncodeToString([]byte(toString(params[0]))), nil
+}
 
-	var parts []string
-	for _, param := range params {
-		parts = append(parts, toString(param))
+// hexDecode reads a hexadecimal token written by hexEncode.
+//
+// Parameters:
+//   - params[0]: Hex string, upper or lower case; surrounding spaces are ignored
+//
+// Output:
+//   - String: The decoded value
+//   - null.String{} if the value is nil or not valid hex (odd length or
+//     other characters)
+//
+// Examples:
+//
+//	hexDecode("544b542d3432") -> "TKT-42"
+//	hexDecode("544B542D3432") -> "TKT-42"
+//	hexDecode("54x") -> null.String{}
+func hexDecode(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
 	}
 
-	return strings.Join(parts, " "), nil
+	decoded, err := hex.DecodeString(strings.TrimSpace(toString(params[0])))
+	if err != nil {
+		return null.String{}, nil
+	}
+	return string(decoded), nil
 }
 
-// upper converts the first parameter to uppercase
-func upper(params []interface{}) (interface{}, error) {
-	if len(params) == 0 {
-		return nil, fmt.Errorf("upper requires at least 1 parameter")
+// base32Token is the RFC 4648 base32 encoding without padding used by the
+// base32 operators: tokens are uppercase letters and digits 2-7 only
+var base32Token = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// base32Encode writes a value as unpadded RFC 4648 base32, an opaque,
+// case-insensitive token for IDs sent to integrations. base32Decode reverses it.
+//
+// Parameters:
+//   - params[0]: Value to encode (any value is converted via toString; byte
+//     columns are encoded byte for byte)
+//
+// Output:
+//   - String: Uppercase base32 without "=" padding ("" for an empty value)
+//   - null.String{} if the value is nil
+//
+// Examples:
+//
+//	base32Encode("TKT-42") -> "KRFVILJUGI"
+//	base32Encode(nil) -> null.String{}
+func base32Encode(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
 	}
+	return base32Token.EncodeToString([]byte(toString(params[0]))), nil
+}
 
-	str := toString(params[0])
-	return strings.ToUpper(str), nil
+// base32Decode reads a base32 token written by base32Encode.
+//
+// Parameters:
+//   - params[0]: RFC 4648 base32 string, upper or lower case, with or without
+//     "=" padding; surrounding spaces are ignored
+//
+// Output:
+//   - String: The decoded value
+//   - null.String{} if the value is nil or not valid base32
+//
+// Examples:
+//
+//	base32Decode("KRFVILJUGI") -> "TKT-42"
+//	base32Decode("krfviljugi======") -> "TKT-42"
+//	base32Decode("KRFV1") -> null.String{}
+func base32Decode(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
+	}
+
+	token := strings.TrimRight(strings.ToUpper(strings.TrimSpace(toString(params[0]))), "=")
+	// The decoder accepts truncated tokens; whole bytes end after 0, 2, 4, 5
+	// or 7 characters of a block
+	switch len(token) % 8 {
+	case 1, 3, 6:
+		return null.String{}, nil
+	}
+	decoded, err := base32Token.DecodeString(token)
+	if err != nil {
+		return null.String{}, nil
+	}
+	return string(decoded), nil
 }
 
-// lower converts the first parameter to lowercase
-func lower(params []interface{}) (interface{}, error) {
-	if len(params) == 0 {
-		return nil, fmt.Errorf("lower requires at least 1 parameter")
+// maskFormat masks the letters and digits of a structured identifier while
+// keeping its separators, so "AB12-CD34" stays recognizable as "XXXX-XXXX".
+//
+// Parameters:
+//   - params[0]: Source value (any value is converted via toString)
+//   - params[1]: (Optional) Mask character (default: "X"; only the first
+//     character is used, nil or "" keeps the default)
+//   - params[2]: (Optional) Number of trailing letters/digits left visible
+//     (default: 0); separators do not count
+//
+// Output:
+//   - String: The value with every letter and digit masked, except the last
+//     params[2] of them; punctuation, spaces and symbols are kept
+//   - null.String{} if source field is nil
+//
+// Examples:
+//
+//	maskFormat("AB12-CD34") -> "XXXX-XXXX"
+//	maskFormat("AB12-CD34", "*", 2) -> "****-**34"
+//	maskFormat("1234567890", "#", 4) -> "######7890"
+//	maskFormat("John Doe") -> "XXXX XXX"
+func maskFormat(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
 	}
 
-	str := toString(params[0])
-	return strings.ToLower(str), nil
+	mask := 'X'
+	if len(params) > 1 && !isNullValue(params[1]) {
+		if text := toString(params[1]); text != "" {
+			mask, _ = utf8.DecodeRuneInString(text)
+		}
+	}
+
+	reveal := 0
+	if len(params) > 2 && params[2] != nil {
+		if k, ok := toFloat64(params[2]); ok && k > 0 {
+			reveal = int(k)
+		}
+	}
+
+	runes := []rune(toString(params[0]))
+	for i := len(runes) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			continue
+		}
+		if reveal > 0 {
+			reveal--
+			continue
+		}
+		runes[i] = mask
+	}
+	return string(runes), nil
 }
 
-// formatDate formats a date parameter using a specified layout
-// If no layout is provided, uses "2006-01-02"
-func formatDate(params []interface{}) (interface{}, error) {
-	if len(params) == 0 {
-		return nil, fmt.Errorf("formatDate requires at least 1 parameter (date)")
+// substituteTemplate renders a Go text/template with the row's fields as data.
+// This operator builds composite display columns such as
+// "Ticket {{.ticket_no}} for {{.customer_name}} ({{.status}})".
+//
+// Parameters:
+//   - params[0]: Template text (usually a literal, e.g. "'...' AS tpl")
+//   - params[1:]: Further params only make sure the fields used by the
+//     template are selected; their values are not used directly
+//   - Last param: The whole row, appended by the mapper (RowContextOperators)
+//
+// Output:
+//   - String: The rendered template
+//   - null.String{} if the template is nil, fails to parse or fails to
+//     execute (e.g. {{.a.b}} on a text field)
+//   - null.String{} if the template uses {{range}}, {{with}}, {{define}},
+//     {{template}} or {{block}}, or renders more than 64 KiB
+//
+// Implementation Notes:
+//   - Fields are referenced by column name or alias ({{.ticket_no}}) and are
+//     available as text: null is "", dates are RFC 3339
+//   - Only fields in the SELECT list are available; a missing field renders
+//     as "" (missingkey=zero)
+//   - Parsed templates are cached like the patterns of matches
+//
+// Examples:
+//
+//	substituteTemplate("Ticket {{.ticket_no}} ({{.status}})", row) -> "Ticket TKT-1 (open)"
+//	substituteTemplate("Hi {{.missing}}!", row) -> "Hi !"
+//	substituteTemplate("{{.unclosed", row) -> null.String{}
+func substituteTemplate(params []interface{}) (interface{}, error) {
+	if len(params) < 1 || isNullValue(params[0]) {
+		return null.String{}, nil
+	}
+
+	tmpl, err := compileTemplate(toString(params[0]))
+	if err != nil {
+		return null.String{}, nil
+	}
+
+	var data map[string]string
+	if len(params) > 1 {
+		if row, ok := params[len(params)-1].(map[string]interface{}); ok {
+			data = make(map[string]string, len(row))
+			for key, value := range row {
+				if t, ok := value.(time.Time); ok {
+					data[key] = t.Format(time.RFC3339)
+				} else {
+					data[key] = toString(value)
+				}
+			}
+		}
 	}
 
-	layout := "2006-01-02"
-	if len(params) > 1 {
-		layout = toString(params[1])
+	out := limitedWriter{limit: maxTemplateOutput}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return null.String{}, nil
 	}
+	return out.String(), nil
+}
 
-	// Handle various date types
-	switch v := params[0].(type) {
-	case time.Time:
-		return v.Format(layout), nil
+// toJSONDocument returns v as a decoded JSON value. Strings and []byte are
+// parsed; ok is false for invalid JSON and values that are not JSON.
+func toJSONDocument(v interface{}) (interface{}, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case map[string]interface{}, []interface{}:
+		return val, true
 	case string:
-		// Try to parse the string as a date first
-		if t, err := time.Parse(time.RFC3339, v); err == nil {
-			return t.Format(layout), nil
+		return toJSONDocument([]uint8(val))
+	case []uint8:
+		var document interface{}
+		if err := json.Unmarshal(val, &document); err != nil {
+			return nil, false
+		}
+		return document, true
+	case null.String:
+		if !val.Valid {
+			return nil, false
+		}
+		return toJSONDocument(val.String)
+	default:
+		return nil, false
+	}
+}
+
+// toJSONArray returns v as a decoded JSON array. Strings and []byte are
+// parsed; ok is false for nil, invalid JSON and non-array values.
+func toJSONArray(v interface{}) ([]interface{}, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case []interface{}:
+		return val, true
+	case []map[string]interface{}:
+		elements := make([]interface{}, len(val))
+		for i, object := range val {
+			elements[i] = object
 		}
-		return v, nil
+		return elements, true
+	case string:
+		return toJSONArray([]uint8(val))
 	case []uint8:
-		// SQLite returns dates as []uint8
-		str := string(v)
-		if t, err := time.Parse("2006-01-02 15:04:05", str); err == nil {
-			return t.Format(layout), nil
+		var elements []interface{}
+		if err := json.Unmarshal(val, &elements); err != nil {
+			return nil, false
+		}
+		return elements, elements != nil
+	case null.String:
+		if !val.Valid {
+			return nil, false
 		}
-		return str, nil
+		return toJSONArray(val.String)
 	default:
-		return toString(v), nil
+		return nil, false
 	}
 }
 
@@ -1261,7 +4230,7 @@ func formatDate(params []interface{}) (interface{}, error) {
 //	decrypt("base64_encrypted_email") -> "user@example.com"
 //	decrypt("") -> null.String{}
 //	decrypt(nil) -> null.String{}
-func decrypt(params []interface{}) (interface{}, error) {
+func (o *operatorSet) decrypt(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return null.String{}, nil
 	}
@@ -1283,7 +4252,7 @@ func decrypt(params []interface{}) (interface{}, error) {
 	}
 
 	// Decrypt using helper function (stack-allocated string operation)
-	decrypted := decryptAESCBC(encrypted)
+	decrypted := decryptAESCBC(encrypted, o.config.DecryptKey)
 
 	return decrypted, nil
 }
@@ -1324,7 +4293,7 @@ func decrypt(params []interface{}) (interface{}, error) {
 //   - Decrypting encrypted HTML email bodies for plain text export
 //   - Displaying encrypted rich text descriptions as plain text
 //   - Processing encrypted formatted content for search indexing
-func stripDecrypt(params []interface{}) (interface{}, error) {
+func (o *operatorSet) stripDecrypt(params []interface{}) (interface{}, error) {
 	if len(params) < 1 {
 		return null.String{}, nil
 	}
@@ -1346,30 +4315,453 @@ func stripDecrypt(params []interface{}) (interface{}, error) {
 	}
 
 	// Step 1: Decrypt the content (stack-allocated)
-	decrypted := decryptAESCBC(encrypted)
+	decrypted := decryptAESCBC(encrypted, o.config.DecryptKey)
+
+	// Step 2: Strip HTML tags (same logic as stripHTML operator)
+	return stripHTMLTags(decrypted), nil
+}
+
+// stripHTMLTags removes HTML tags from text in a single pass.
+// A '<' only starts a tag when followed by a letter, '/', '!' or '?' and a matching
+// '>' exists outside quoted attribute values; otherwise it is kept as plain text.
+//
+// Memory efficiency:
+//   - Single preallocated strings.Builder
+//   - Byte-level scanning (UTF-8 sequences are copied through untouched)
+//   - No regex compilation
+func stripHTMLTags(text string) string {
+	if strings.IndexByte(text, '<') == -1 {
+		return text
+	}
 
-	// Step 2: Strip HTML tags
-	// Use the same efficient HTML stripping logic as stripHTML operator
-	// Stack-allocated string builder
 	var result strings.Builder
-	result.Grow(len(decrypted)) // Preallocate capacity
+	result.Grow(len(text)) // Preallocate capacity (avoid reallocation)
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '<' {
+			result.WriteByte(text[i])
+			continue
+		}
+
+		end := htmlTagEnd(text, i)
+		if end == -1 {
+			// Not a tag (or unterminated) - keep the bracket as text
+			result.WriteByte('<')
+			continue
+		}
+		i = end
+	}
+
+	return result.String()
+}
+
+// htmlTagEnd returns the index of the '>' closing the tag that starts at text[start],
+// or -1 if text[start] does not begin a well-formed tag.
+func htmlTagEnd(text string, start int) int {
+	if start+1 >= len(text) {
+		return -1
+	}
+
+	next := text[start+1]
+	isLetter := (next >= 'a' && next <= 'z') || (next >= 'A' && next <= 'Z')
+	if !isLetter && next != '/' && next != '!' && next != '?' {
+		return -1
+	}
+
+	// Comments end at "-->" and may contain quotes or '>'
+	if strings.HasPrefix(text[start:], "<!--") {
+		end := strings.Index(text[start+4:], "-->")
+		if end == -1 {
+			return -1
+		}
+		return start + 4 + end + 2
+	}
+
+	// Find closing '>' outside of quoted attribute values
+	var quote byte
+	for i := start + 1; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '>':
+			return i
+		case ch == '<':
+			// A new '<' before the tag closed means this one was not a tag
+			return -1
+		}
+	}
+
+	return -1
+}
+
+// splitWords splits an identifier or phrase into words for case conversion.
+// A new word starts at each non-alphanumeric separator, at a lower-to-upper
+// transition, at the last capital of an acronym followed by lowercase
+// ("IDNumber" -> ID, Number) and at every letter/digit boundary.
+func splitWords(text string) []string {
+	runes := []rune(text)
+	words := make([]string, 0, 4)
+	start := -1
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+
+		if start >= 0 {
+			prev := runes[i-1]
+			boundary := (unicode.IsLower(prev) && unicode.IsUpper(r)) ||
+				(unicode.IsDigit(prev) != unicode.IsDigit(r)) ||
+				(unicode.IsUpper(prev) && unicode.IsUpper(r) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+			if boundary {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+			continue
+		}
+
+		start = i
+	}
+
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// emojiRunes covers the emoji and pictographic blocks, including regional
+// indicators (flags) and skin tone modifiers
+var emojiRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x203C, Hi: 0x203C, Stride: 1},
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21A9, Hi: 0x21AA, Stride: 1},
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x2328, Hi: 0x2328, Stride: 1},
+		{Lo: 0x23CF, Hi: 0x23CF, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23F3, Stride: 1},
+		{Lo: 0x23F8, Hi: 0x23FA, Stride: 1},
+		{Lo: 0x24C2, Hi: 0x24C2, Stride: 1},
+		{Lo: 0x25AA, Hi: 0x25AB, Stride: 1},
+		{Lo: 0x25B6, Hi: 0x25B6, Stride: 1},
+		{Lo: 0x25C0, Hi: 0x25C0, Stride: 1},
+		{Lo: 0x25FB, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2B05, Hi: 0x2B07, Stride: 1},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B50, Stride: 1},
+		{Lo: 0x2B55, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x3030, Stride: 1},
+		{Lo: 0x303D, Hi: 0x303D, Stride: 1},
+		{Lo: 0x3297, Hi: 0x3297, Stride: 1},
+		{Lo: 0x3299, Hi: 0x3299, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F000, Hi: 0x1FAFF, Stride: 1},
+	},
+}
+
+// emojiSequenceEnd returns the index just past the emoji sequence starting at
+// start, consuming variation selectors, skin tone modifiers, keycaps, tags,
+// combining marks and any ZWJ-joined emoji that follow the base
+func emojiSequenceEnd(runes []rune, start int) int {
+	end := start + 1
+
+	for end < len(runes) {
+		r := runes[end]
+
+		switch {
+		case r == 0x200D:
+			// Zero width joiner glues the next emoji onto this sequence
+			end++
+			if end < len(runes) {
+				end++
+			}
+		case r == 0xFE0E || r == 0xFE0F || r == 0x20E3,
+			r >= 0x1F3FB && r <= 0x1F3FF,
+			r >= 0xE0020 && r <= 0xE007F,
+			unicode.In(r, unicode.Mn, unicode.Me):
+			end++
+		default:
+			return end
+		}
+	}
+
+	return end
+}
+
+// redactionRule pairs a PII pattern with the strategy masking each match
+type redactionRule struct {
+	pattern *regexp.Regexp
+	mask    func(match string) string
+}
+
+// redactionRules are the named rules available to redact
+var redactionRules = map[string]redactionRule{
+	"email": {
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		mask:    maskEmail,
+	},
+	"phone": {
+		pattern: regexp.MustCompile(`\+?\(?\d[\d\s().-]{5,}\d`),
+		mask:    maskPhone,
+	},
+	"card": {
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		mask:    func(match string) string { return maskDigits(match, 4) },
+	},
+	"nric": {
+		pattern: regexp.MustCompile(`\b[STFGMstfgm]\d{7}[A-Za-z]\b`),
+		mask:    maskInner,
+	},
+	"nik": {
+		pattern: regexp.MustCompile(`\b\d{16}\b`),
+		mask:    maskNIK,
+	},
+	"ssn": {
+		pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		mask:    func(match string) string { return maskDigits(match, 4) },
+	},
+}
+
+// maskEmail keeps the first character of the local part and the domain
+func maskEmail(match string) string {
+	at := strings.LastIndexByte(match, '@')
+	return match[:1] + "***" + match[at:]
+}
+
+// maskPhone masks all but the last 4 digits of numbers with at least 7 digits
+func maskPhone(match string) string {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 {
+		return match
+	}
+	return maskDigits(match, 4)
+}
+
+// maskNIK masks all but the last 4 digits of valid NIKs; other 16-digit
+// numbers (e.g. card numbers, left to the card rule) are kept
+func maskNIK(match string) string {
+	if !isValidNIK(match) {
+		return match
+	}
+	return maskDigits(match, 4)
+}
 
-	inTag := false
-	for _, char := range decrypted {
-		if char == '<' {
-			inTag = true
+// maskDigits replaces every digit except the last keep digits with '*'
+func maskDigits(match string, keep int) string {
+	out := []byte(match)
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i] < '0' || out[i] > '9' {
+			continue
+		}
+		if keep > 0 {
+			keep--
 			continue
 		}
-		if char == '>' {
-			inTag = false
+		out[i] = '*'
+	}
+	return string(out)
+}
+
+// maskInner replaces every character except the first and last with '*'
+func maskInner(match string) string {
+	if len(match) <= 2 {
+		return match
+	}
+	return match[:1] + strings.Repeat("*", len(match)-2) + match[len(match)-1:]
+}
+
+// patternCache holds compiled regular expressions for matches.
+// The cache is bounded so per-row patterns cannot grow it without limit.
+var (
+	patternCache     sync.Map
+	patternCacheSize atomic.Int32
+)
+
+const maxCachedPatterns = 256
+
+// jsonPathCache holds parsed JSONPath expressions for jsonPath, bounded
+// like patternCache
+var (
+	jsonPathCache     sync.Map
+	jsonPathCacheSize atomic.Int32
+)
+
+// compileJSONPath parses a JSONPath expression, reusing cached expressions
+func compileJSONPath(path string) (jp.Expr, error) {
+	if cached, ok := jsonPathCache.Load(path); ok {
+		return cached.(jp.Expr), nil
+	}
+
+	expr, err := jp.ParseString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if jsonPathCacheSize.Load() < maxCachedPatterns {
+		if _, loaded := jsonPathCache.LoadOrStore(path, expr); !loaded {
+			jsonPathCacheSize.Add(1)
+		}
+	}
+
+	return expr, nil
+}
+
+// templateCache holds parsed templates for substituteTemplate, bounded like
+// patternCache
+var (
+	templateCache     sync.Map
+	templateCacheSize atomic.Int32
+)
+
+// compileTemplate parses a template, reusing cached templates
+func compileTemplate(text string) (*template.Template, error) {
+	if cached, ok := templateCache.Load(text); ok {
+		return cached.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("substituteTemplate").
+		Option("missingkey=zero").
+		Funcs(template.FuncMap{"printf": boundedPrintf}).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("template definitions are not allowed")
+	}
+	if err := checkTemplateNodes(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+
+	if templateCacheSize.Load() < maxCachedPatterns {
+		if _, loaded := templateCache.LoadOrStore(text, tmpl); !loaded {
+			templateCacheSize.Add(1)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// maxTemplateOutput is the most bytes substituteTemplate renders for a row
+const maxTemplateOutput = 64 << 10
+
+// errTemplateOutputTooLarge aborts a template rendering past maxTemplateOutput
+var errTemplateOutputTooLarge = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+
+// checkTemplateNodes rejects the actions that let a client's template run
+// longer than its own text: loops ({{range}}, also over integers), {{with}}
+// and calls of other templates ({{template}}, {{block}}). Without them a
+// template executes each of its nodes at most once.
+func checkTemplateNodes(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateNodes(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		if err := checkTemplateNodes(n.List); err != nil {
+			return err
+		}
+		return checkTemplateNodes(n.ElseList)
+	case *parse.RangeNode:
+		return fmt.Errorf("{{range}} is not allowed in templates")
+	case *parse.WithNode:
+		return fmt.Errorf("{{with}} is not allowed in templates")
+	case *parse.TemplateNode:
+		return fmt.Errorf("{{template}} is not allowed in templates")
+	}
+	return nil
+}
+
+// boundedPrintf replaces the printf template function, whose widths and
+// precisions ({{printf "%1000000000d" 1}}) would allocate before the output
+// limit applies. Their sum is capped at maxTemplateOutput.
+func boundedPrintf(format string, args ...interface{}) (string, error) {
+	total := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
 			continue
 		}
-		if !inTag {
-			result.WriteRune(char)
+		// Sum the numbers between % and the verb: argument indexes ([2])
+		// count too, which only makes the bound stricter
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0; i++ {
+			if format[i] == '*' {
+				return "", fmt.Errorf("printf: * widths are not allowed in templates")
+			}
+			if format[i] < '1' || format[i] > '9' {
+				continue
+			}
+			n := 0
+			for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+				if n = n*10 + int(format[i]-'0'); n > maxTemplateOutput {
+					return "", errTemplateOutputTooLarge
+				}
+			}
+			i--
+			if total += n; total > maxTemplateOutput {
+				return "", errTemplateOutputTooLarge
+			}
+		}
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// limitedWriter is a strings.Builder that fails writes past limit bytes,
+// aborting the template execution writing to it
+type limitedWriter struct {
+	strings.Builder
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > w.limit {
+		return 0, errTemplateOutputTooLarge
+	}
+	return w.Builder.Write(p)
+}
+
+// compilePattern compiles a regular expression, reusing cached compilations
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := patternCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if patternCacheSize.Load() < maxCachedPatterns {
+		if _, loaded := patternCache.LoadOrStore(pattern, compiled); !loaded {
+			patternCacheSize.Add(1)
 		}
 	}
 
-	return result.String(), nil
+	return compiled, nil
 }
 
 // toString converts any value to string, handling null values
@@ -1378,7 +4770,7 @@ func toString(v interface{}) string {
 		return ""
 	}
 
-	switch val := v.(type) {
+	switch val := normalizeBytes(v).(type) {
 	case string:
 		return val
 	case []uint8:
@@ -1408,6 +4800,8 @@ func toString(v interface{}) string {
 			return val.Time.Format(time.RFC3339)
 		}
 		return ""
+	case stdjson.Number:
+		return string(val)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
@@ -1425,7 +4819,7 @@ func toInt(v interface{}) int {
 		return 0
 	}
 
-	switch val := v.(type) {
+	switch val := normalizeBytes(v).(type) {
 	case int:
 		return val
 	case int8:
@@ -1451,15 +4845,12 @@ func toInt(v interface{}) int {
 	case float64:
 		return int(val)
 	case string:
-		// Try to parse string as integer
-		var num int
-		fmt.Sscanf(val, "%d", &num)
-		return num
+		return parseIntText(val)
 	case []uint8:
 		// Database bytes representation
-		var num int
-		fmt.Sscanf(string(val), "%d", &num)
-		return num
+		return parseIntText(string(val))
+	case stdjson.Number:
+		return parseIntText(string(val))
 	case null.Int:
 		if val.Valid {
 			return int(val.Int64)
@@ -1470,11 +4861,258 @@ func toInt(v interface{}) int {
 			return int(val.Float64)
 		}
 		return 0
+	case null.String:
+		if val.Valid {
+			return parseIntText(val.String)
+		}
+		return 0
 	default:
 		return 0
 	}
 }
 
+// parseIntText parses integer text such as "1696000208", " 42 " or "3661.0".
+// Decimal and exponent forms are truncated toward zero; text with a leading
+// integer ("12abc") keeps that integer.
+func parseIntText(text string) int {
+	text = strings.TrimSpace(text)
+	if num, err := strconv.Atoi(text); err == nil {
+		return num
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return int(f)
+	}
+
+	var num int
+	fmt.Sscanf(text, "%d", &num)
+	return num
+}
+
+// normalizeBytes converts driver byte types to plain []uint8.
+// sql.RawBytes is a distinct named type, so without this it would miss the
+// []uint8 cases of the coercion helpers. The bytes are copied because the
+// driver may reuse a RawBytes buffer on the next scan.
+func normalizeBytes(v interface{}) interface{} {
+	if raw, ok := v.(sql.RawBytes); ok {
+		return append([]uint8(nil), raw...)
+	}
+	return v
+}
+
+// toTime converts a date value to time.Time.
+// Accepts time.Time, null.Time, unix seconds (numbers, numeric strings,
+// []uint8 and json.Number) and "2006-01-02 15:04:05", RFC3339 or
+// "2006-01-02" text. Epochs are returned in local time, like time.Unix;
+// non-positive epochs are rejected.
+//
+// Examples:
+//
+//	toTime([]uint8("1696000208")) -> 2023-09-29 22:10:08 +0700 WIB (time.Local = Asia/Jakarta), true
+//	toTime("2024-01-15 10:30:00") -> 2024-01-15 10:30:00 UTC, true
+//	toTime("not a date") -> time.Time{}, false
+func toTime(v interface{}) (time.Time, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case nil:
+		return time.Time{}, false
+	case time.Time:
+		return val, !val.IsZero()
+	case null.Time:
+		return val.Time, val.Valid
+	case string:
+		return parseTimeText(val)
+	case []uint8:
+		return parseTimeText(string(val))
+	case null.String:
+		if !val.Valid {
+			return time.Time{}, false
+		}
+		return parseTimeText(val.String)
+	}
+
+	seconds, ok := toFloat64(v)
+	if !ok || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// parseTimeText parses date text in the layouts stored by the databases,
+// falling back to unix seconds for numeric text
+func parseTimeText(text string) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// toFloat64 converts any numeric value to float64, handling numeric strings,
+// database bytes and null types. The boolean reports whether conversion succeeded.
+//
+// Memory efficiency:
+//   - Stack-allocated return value
+//   - strconv.ParseFloat only for string/[]uint8 inputs
+func toFloat64(v interface{}) (float64, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case nil:
+		return 0, false
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int8:
+		return float64(val), true
+	case int16:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint8:
+		return float64(val), true
+	case uint16:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	case []uint8:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(val)), 64)
+		return f, err == nil
+	case stdjson.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	case null.Int:
+		return float64(val.Int64), val.Valid
+	case null.Float:
+		return val.Float64, val.Valid
+	case null.String:
+		if !val.Valid {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(val.String), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// toDecimal converts a value to an exact rational number.
+// DECIMAL columns arrive as []uint8/string (or json.Number from
+// stream.TypedRowScanner) and are parsed without going through float64,
+// so "1234.56" stays exactly 1234.56.
+//
+// Examples:
+//
+//	toDecimal([]uint8("1234.56")) -> 1234.56, true
+//	toDecimal(int64(5)) -> 5, true
+//	toDecimal("abc") -> nil, false
+func toDecimal(v interface{}) (*big.Rat, bool) {
+	switch val := normalizeBytes(v).(type) {
+	case string:
+		return parseDecimal(val)
+	case []uint8:
+		return parseDecimal(string(val))
+	case stdjson.Number:
+		return parseDecimal(string(val))
+	case int:
+		return new(big.Rat).SetInt64(int64(val)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(val)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(val)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(val)), true
+	case int64:
+		return new(big.Rat).SetInt64(val), true
+	case uint:
+		return new(big.Rat).SetUint64(uint64(val)), true
+	case uint8:
+		return new(big.Rat).SetUint64(uint64(val)), true
+	case uint16:
+		return new(big.Rat).SetUint64(uint64(val)), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(val)), true
+	case uint64:
+		return new(big.Rat).SetUint64(val), true
+	case float32:
+		return parseDecimal(strconv.FormatFloat(float64(val), 'g', -1, 32))
+	case float64:
+		return parseDecimal(strconv.FormatFloat(val, 'g', -1, 64))
+	case null.Int:
+		if !val.Valid {
+			return nil, false
+		}
+		return new(big.Rat).SetInt64(val.Int64), true
+	case null.Float:
+		if !val.Valid {
+			return nil, false
+		}
+		return parseDecimal(strconv.FormatFloat(val.Float64, 'g', -1, 64))
+	default:
+		return nil, false
+	}
+}
+
+// parseDecimal parses decimal text ("1234.56", "-0.5", "1e3") into an exact rational.
+// Fractions such as "1/3" are rejected since they are not database numbers.
+func parseDecimal(text string) (*big.Rat, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.Contains(text, "/") {
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
+
+// isDecimalText reports whether v is a textual number as returned for DECIMAL columns
+func isDecimalText(v interface{}) bool {
+	switch v.(type) {
+	case string, []uint8, stdjson.Number:
+		return true
+	default:
+		return false
+	}
+}
+
+// isDecimalNumber reports whether v was explicitly typed as a DECIMAL by the scanner
+func isDecimalNumber(v interface{}) bool {
+	_, ok := v.(stdjson.Number)
+	return ok
+}
+
+// decimalResult rounds an exact result to the given decimals (halves away from zero).
+// DECIMAL-typed inputs produce a json.Number so precision survives JSON encoding;
+// other inputs produce the nearest float64 for consistency with existing outputs.
+func decimalResult(value *big.Rat, decimals int, exact bool) interface{} {
+	text := value.FloatString(decimals)
+	if exact {
+		return stdjson.Number(text)
+	}
+
+	f, _ := strconv.ParseFloat(text, 64)
+	return f
+}
+
+// roundFloat rounds a float64 to the given number of decimals (half away from zero)
+func roundFloat(value float64, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(value*pow) / pow
+}
+
 // secondsToHHMMSS converts seconds to HH:MM:SS format.
 // Handles durations longer than 24 hours (e.g., 25:30:00).
 //
@@ -1533,7 +5171,7 @@ func secondsToHHMMSS(seconds int) string {
 //
 //	decryptAESCBC("encrypted_base64_string") -> "decrypted_text"
 //	decryptAESCBC("") -> ""
-func decryptAESCBC(encrypted string) string {
+func decryptAESCBC(encrypted, key string) string {
 	// PLACEHOLDER IMPLEMENTATION
 	// Replace with actual AES-CBC decryption logic
 	// This placeholder simply returns the input for development/testing purposes
@@ -1557,11 +5195,8 @@ func decryptAESCBC(encrypted string) string {
 			return ""
 		}
 
-		// Get key from config
-		key := []byte("your-32-byte-encryption-key-here")
-
-		// Create AES cipher
-		block, err := aes.NewCipher(key)
+		// Create AES cipher with the key from OperatorConfig
+		block, err := aes.NewCipher([]byte(key))
 		if err != nil {
 			return ""
 		}
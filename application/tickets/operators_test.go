@@ -1,9 +1,16 @@
 package tickets
 
 import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/guregu/null/v5"
 )
 
 func TestTicketIdMasking(t *testing.T) {
@@ -94,6 +101,16 @@ func TestDifftime(t *testing.T) {
 			params: []interface{}{100, 90100},
 			want:   "25:00:00",
 		},
+		{
+			name:   "MySQL byte timestamps",
+			params: []interface{}{[]byte("1696000208"), []byte("1696003808")},
+			want:   "01:00:00",
+		},
+		{
+			name:   "sql.RawBytes and int64 timestamps",
+			params: []interface{}{sql.RawBytes("1696000208"), int64(1696000268)},
+			want:   "00:01:00",
+		},
 		{
 			name:   "invalid params (only 1 param)",
 			params: []interface{}{1000},
@@ -196,6 +213,78 @@ func TestSentimentMapping(t *testing.T) {
 	}
 }
 
+func TestSentimentMapping_Scales(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"five-point very negative", []interface{}{-2, "five"}, "Very Negative"},
+		{"five-point negative", []interface{}{"-1", "five"}, "Negative"},
+		{"five-point neutral", []interface{}{0, "five"}, "Neutral"},
+		{"five-point positive", []interface{}{int64(1), "five"}, "Positive"},
+		{"five-point very positive", []interface{}{2.0, "five"}, "Very Positive"},
+		{"five-point out of range", []interface{}{3, "five"}, null.String{}},
+		{"stars bucket", []interface{}{2, "stars"}, "Negative"},
+		{"stars neutral", []interface{}{3, "stars"}, "Neutral"},
+		{"stars upper bucket", []interface{}{5, "stars"}, "Positive"},
+		{"stars out of range", []interface{}{0, "stars"}, null.String{}},
+		{"nil with a scale", []interface{}{nil, "stars"}, null.String{}},
+		{"empty scale keeps the legacy labels", []interface{}{1, ""}, "Positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sentimentMapping(tt.params)
+			if err != nil {
+				t.Fatalf("sentimentMapping() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("sentimentMapping() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := sentimentMapping([]interface{}{1, "ten"}); err == nil {
+		t.Error("sentimentMapping() with an unknown scale should return an error")
+	}
+
+	t.Run("configured scale", func(t *testing.T) {
+		scales, err := ParseSentimentScales(`{"stars": [{"min": 1, "max": 3, "label": "Bad"}, {"min": 4, "max": 5, "label": "Good"}], "ten": [{"min": 0, "max": 10, "label": "Scored"}]}`)
+		if err != nil {
+			t.Fatalf("ParseSentimentScales() error = %v", err)
+		}
+		mapping := NewOperatorRegistry(OperatorConfig{SentimentScales: scales})["sentimentMapping"]
+
+		for _, tt := range []struct {
+			params []interface{}
+			want   interface{}
+		}{
+			{[]interface{}{3, "stars"}, "Bad"},
+			{[]interface{}{4, "stars"}, "Good"},
+			{[]interface{}{7, "ten"}, "Scored"},
+			{[]interface{}{2, "five"}, "Very Positive"}, // built-in scales are kept
+			{[]interface{}{-1}, "Negative"},
+		} {
+			if result, err := mapping(tt.params); err != nil || result != tt.want {
+				t.Errorf("sentimentMapping(%v) = %#v, %v, want %#v", tt.params, result, err, tt.want)
+			}
+		}
+	})
+
+	for _, invalid := range []string{
+		`[1, 2]`,
+		`{"empty": []}`,
+		`{"unlabeled": [{"min": 1, "max": 2}]}`,
+		`{"reversed": [{"min": 5, "max": 1, "label": "x"}]}`,
+		`{"overlap": [{"min": 1, "max": 3, "label": "a"}, {"min": 3, "max": 5, "label": "b"}]}`,
+	} {
+		if _, err := ParseSentimentScales(invalid); err == nil {
+			t.Errorf("ParseSentimentScales(%s) should return an error", invalid)
+		}
+	}
+}
+
 func TestConcat(t *testing.T) {
 	params := []interface{}{"Hello", "World", 123}
 	result, err := concat(params)
@@ -261,6 +350,16 @@ func TestFormatDate(t *testing.T) {
 			params: []interface{}{[]uint8("2025-01-15 10:30:00")},
 			want:   "2025-01-15",
 		},
+		{
+			name:   "unix seconds kept as text",
+			params: []interface{}{int64(1696000208), time.RFC3339},
+			want:   "1696000208",
+		},
+		{
+			name:   "numeric MySQL bytes kept as text",
+			params: []interface{}{[]byte("1696000208"), time.RFC3339},
+			want:   "1696000208",
+		},
 	}
 
 	for _, tt := range tests {
@@ -277,6 +376,50 @@ func TestFormatDate(t *testing.T) {
 	}
 }
 
+// TestEpochDates_LocalTime pins the behavior of exports predating the shared
+// date coercion: epochs are local times, and formatDate reads them only when
+// FormatDateEpochs is set
+func TestEpochDates_LocalTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("WIB", 7*60*60)
+	defer func() { time.Local = local }()
+
+	const epoch = 1696000208 // 2023-09-29 15:10:08 UTC
+
+	t.Run("ticketDate", func(t *testing.T) {
+		for _, value := range []interface{}{int64(epoch), float64(epoch), []byte("1696000208")} {
+			result, err := ticketDate([]interface{}{map[string]interface{}{"status_id": 1, "date_create": value}, "2006-01-02 15:04:05"})
+			if err != nil {
+				t.Fatalf("ticketDate() error = %v", err)
+			}
+			if got := result.([]map[string]interface{})[0]["date_create"]; got != "2023-09-29 22:10:08" {
+				t.Errorf("ticketDate(%v) date_create = %v, want local 2023-09-29 22:10:08", value, got)
+			}
+		}
+	})
+
+	t.Run("toTime", func(t *testing.T) {
+		got, ok := toTime(int64(epoch))
+		if !ok || got.Location() != time.Local {
+			t.Errorf("toTime(%d) = %v, %v, want a local time", epoch, got, ok)
+		}
+	})
+
+	t.Run("formatDate", func(t *testing.T) {
+		params := []interface{}{[]byte("1696000208"), "2006-01-02 15:04:05"}
+		if got, _ := formatDate(params); got != "1696000208" {
+			t.Errorf("formatDate() = %v, want the epoch as text", got)
+		}
+
+		config := DefaultOperatorConfig()
+		config.FormatDateEpochs = true
+		ops := &operatorSet{config: config}
+		if got, _ := ops.formatDate(params); got != "2023-09-29 22:10:08" {
+			t.Errorf("formatDate() with FormatDateEpochs = %v, want local 2023-09-29 22:10:08", got)
+		}
+	})
+}
+
 func TestPassThrough(t *testing.T) {
 	params := []interface{}{42, "ignored"}
 	result, err := passThrough(params)
@@ -398,6 +541,16 @@ func TestFormatTime(t *testing.T) {
 			params: []interface{}{"3661"},
 			want:   "01:01:01",
 		},
+		{
+			name:   "seconds as MySQL bytes",
+			params: []interface{}{[]byte("1696000208")},
+			want:   "471111:10:08",
+		},
+		{
+			name:   "seconds as sql.RawBytes decimal",
+			params: []interface{}{sql.RawBytes("3661.000")},
+			want:   "01:01:01",
+		},
 		{
 			name:      "nil param",
 			params:    []interface{}{nil},
@@ -499,6 +652,36 @@ func TestStripHTML(t *testing.T) {
 			params: []interface{}{12345},
 			want:   "12345",
 		},
+		{
+			name:   "stray angle brackets in plain text",
+			params: []interface{}{"a < b > c"},
+			want:   "a < b > c",
+		},
+		{
+			name:   "comparison text is preserved",
+			params: []interface{}{"5 < 10 and 20 > 15"},
+			want:   "5 < 10 and 20 > 15",
+		},
+		{
+			name:   "unterminated tag does not swallow the rest",
+			params: []interface{}{"Hello <b unclosed text"},
+			want:   "Hello <b unclosed text",
+		},
+		{
+			name:   "angle brackets inside attribute values",
+			params: []interface{}{`<a title="x > y" data-v='<'>Link</a>`},
+			want:   "Link",
+		},
+		{
+			name:   "HTML comment",
+			params: []interface{}{"Before<!-- <b>hidden</b> -->After"},
+			want:   "BeforeAfter",
+		},
+		{
+			name:   "stray bracket before real tag",
+			params: []interface{}{"1 <<b>2</b>"},
+			want:   "1 <2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -523,6 +706,49 @@ func TestStripHTML(t *testing.T) {
 	}
 }
 
+func FuzzStripHTML(f *testing.F) {
+	seeds := []string{
+		"<p>Hello</p>",
+		"a < b > c",
+		"5 < 10 and 20 > 15",
+		"Hello <b unclosed",
+		`<a href="x>y">Link</a>`,
+		"<!-- comment -->text",
+		"<<<>>>",
+		"日本語 <b>テキスト</b>",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		result, err := stripHTML([]interface{}{input})
+		if err != nil {
+			t.Fatalf("stripHTML(%q) error = %v", input, err)
+		}
+
+		output, ok := result.(string)
+		if !ok {
+			t.Fatalf("stripHTML(%q) returned %T, want string", input, result)
+		}
+
+		// Stripping never adds content
+		if len(output) > len(input) {
+			t.Errorf("stripHTML(%q) = %q, output longer than input", input, output)
+		}
+
+		// Text without '<' cannot contain tags and must be unchanged
+		if !strings.Contains(input, "<") && output != input {
+			t.Errorf("stripHTML(%q) = %q, want unchanged", input, output)
+		}
+
+		// Valid UTF-8 input stays valid UTF-8
+		if utf8.ValidString(input) && !utf8.ValidString(output) {
+			t.Errorf("stripHTML(%q) = %q, produced invalid UTF-8", input, output)
+		}
+	})
+}
+
 func TestContacts(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -648,6 +874,45 @@ func TestTicketDate(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "MySQL byte epoch in status map",
+			params: []interface{}{
+				map[string]interface{}{"status_id": 1, "date_create": []byte("1696000208")},
+				"2006-01-02 15:04:05",
+			},
+			checkFunc: func(t *testing.T, result interface{}) {
+				statusDates, ok := result.([]map[string]interface{})
+				if !ok || len(statusDates) != 1 {
+					t.Fatalf("Expected one status date, got %v", result)
+				}
+				if got := statusDates[0]["date_create"]; got != "2023-09-29 15:10:08" {
+					t.Errorf("date_create = %v, want 2023-09-29 15:10:08", got)
+				}
+			},
+		},
+		{
+			name:   "MySQL byte JSON with numeric epoch",
+			params: []interface{}{sql.RawBytes(`[{"status_id":1,"date_create":1696000208}]`)},
+			checkFunc: func(t *testing.T, result interface{}) {
+				statusDates, ok := result.([]map[string]interface{})
+				if !ok || len(statusDates) != 1 {
+					t.Fatalf("Expected one status date, got %v", result)
+				}
+				if got := statusDates[0]["date_create"]; got != "2023-09-29T15:10:08Z" {
+					t.Errorf("date_create = %v, want 2023-09-29T15:10:08Z", got)
+				}
+			},
+		},
+		{
+			name:   "unparseable date kept as text",
+			params: []interface{}{`[{"status_id":1,"date_create":"yesterday"}]`},
+			checkFunc: func(t *testing.T, result interface{}) {
+				statusDates := result.([]map[string]interface{})
+				if got := statusDates[0]["date_create"]; got != "yesterday" {
+					t.Errorf("date_create = %v, want yesterday", got)
+				}
+			},
+		},
 		{
 			name:   "empty string",
 			params: []interface{}{""},
@@ -786,6 +1051,18 @@ func TestGetOperatorRegistry(t *testing.T) {
 		"upper",
 		"lower",
 		"formatDate",
+		"percentOf",
+		"convertCase",
+		"scale",
+		"bucket",
+		"jsonMerge",
+		"stripEmoji",
+		"matches",
+		"slugify",
+		"changed",
+		"diffText",
+		"ageInDays",
+		"translate",
 	}
 
 	for _, op := range requiredOps {
@@ -2101,3 +2378,2560 @@ func BenchmarkProcessSurveyAnswer(b *testing.B) {
 		}
 	})
 }
+
+func TestPercentOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "normal ratio with default decimals",
+			params: []interface{}{25, 200},
+			want:   12.5,
+		},
+		{
+			name:   "custom decimals",
+			params: []interface{}{1, 3, 2},
+			want:   33.33,
+		},
+		{
+			name:   "zero decimals",
+			params: []interface{}{2, 3, 0},
+			want:   float64(67),
+		},
+		{
+			name:   "numeric strings and bytes",
+			params: []interface{}{"45", []uint8("60")},
+			want:   float64(75),
+		},
+		{
+			name:   "zero denominator returns null by default",
+			params: []interface{}{5, 0},
+			want:   null.Float{},
+		},
+		{
+			name:   "zero denominator returns 0 when configured",
+			params: []interface{}{5, 0, 1, "zero"},
+			want:   float64(0),
+		},
+		{
+			name:   "nil denominator",
+			params: []interface{}{5, nil},
+			want:   null.Float{},
+		},
+		{
+			name:   "non-numeric numerator",
+			params: []interface{}{"abc", 10},
+			want:   null.Float{},
+		},
+		{
+			name:   "non-numeric denominator",
+			params: []interface{}{10, "total"},
+			want:   null.Float{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := percentOf(tt.params)
+			if err != nil {
+				t.Errorf("percentOf() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("percentOf() = %v (%T), want %v (%T)", result, result, tt.want, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing denominator returns error", func(t *testing.T) {
+		if _, err := percentOf([]interface{}{10}); err == nil {
+			t.Error("percentOf() expected error for missing denominator")
+		}
+	})
+}
+
+func TestConvertCase(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      interface{}
+		convention string
+		want       interface{}
+	}{
+		{name: "phrase to snake", input: "customer name", convention: "snake", want: "customer_name"},
+		{name: "phrase to camel", input: "customer name", convention: "camel", want: "customerName"},
+		{name: "phrase to kebab", input: "customer name", convention: "kebab", want: "customer-name"},
+		{name: "phrase to pascal", input: "customer name", convention: "pascal", want: "CustomerName"},
+		{name: "acronym to snake", input: "CustomerID", convention: "snake", want: "customer_id"},
+		{name: "acronym to camel", input: "CustomerID", convention: "camel", want: "customerId"},
+		{name: "acronym to kebab", input: "CustomerID", convention: "kebab", want: "customer-id"},
+		{name: "acronym to pascal", input: "CustomerID", convention: "pascal", want: "CustomerId"},
+		{name: "kebab to snake", input: "first-name", convention: "snake", want: "first_name"},
+		{name: "kebab to camel", input: "first-name", convention: "camel", want: "firstName"},
+		{name: "kebab to kebab", input: "first-name", convention: "kebab", want: "first-name"},
+		{name: "kebab to pascal", input: "first-name", convention: "pascal", want: "FirstName"},
+		{name: "leading acronym", input: "HTTPServerURL", convention: "snake", want: "http_server_url"},
+		{name: "digit boundaries", input: "address2Line", convention: "snake", want: "address_2_line"},
+		{name: "repeated separators", input: "  ticket__no ", convention: "camel", want: "ticketNo"},
+		{name: "convention is case-insensitive", input: "ticket no", convention: "SNAKE", want: "ticket_no"},
+		{name: "bytes input", input: []uint8("TicketNo"), convention: "snake", want: "ticket_no"},
+		{name: "empty string", input: "", convention: "camel", want: ""},
+		{name: "nil input", input: nil, convention: "snake", want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := convertCase([]interface{}{tt.input, tt.convention})
+			if err != nil {
+				t.Errorf("convertCase() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("convertCase() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsupported convention returns error", func(t *testing.T) {
+		if _, err := convertCase([]interface{}{"ticket no", "title"}); err == nil {
+			t.Error("convertCase() expected error for unsupported convention")
+		}
+	})
+
+	t.Run("missing convention returns error", func(t *testing.T) {
+		if _, err := convertCase([]interface{}{"ticket no"}); err == nil {
+			t.Error("convertCase() expected error for missing convention")
+		}
+	})
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "cents to dollars",
+			params: []interface{}{12345, 0.01},
+			want:   123.45,
+		},
+		{
+			name:   "negative value",
+			params: []interface{}{-250, 0.01},
+			want:   -2.5,
+		},
+		{
+			name:   "grams to kilograms with custom decimals",
+			params: []interface{}{1567, 0.001, 1},
+			want:   1.6,
+		},
+		{
+			name:   "seconds to minutes with zero decimals",
+			params: []interface{}{150, 1.0 / 60, 0},
+			want:   float64(3),
+		},
+		{
+			name:   "numeric strings and bytes",
+			params: []interface{}{[]uint8("999"), "0.01"},
+			want:   9.99,
+		},
+		{
+			name:   "non-numeric value",
+			params: []interface{}{"abc", 0.01},
+			want:   null.Float{},
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil, 0.01},
+			want:   null.Float{},
+		},
+		{
+			name:   "non-numeric multiplier",
+			params: []interface{}{100, "cents"},
+			want:   null.Float{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scale(tt.params)
+			if err != nil {
+				t.Errorf("scale() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("scale() = %v (%T), want %v (%T)", result, result, tt.want, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing multiplier returns error", func(t *testing.T) {
+		if _, err := scale([]interface{}{100}); err == nil {
+			t.Error("scale() expected error for missing multiplier")
+		}
+	})
+}
+
+func TestSumFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "integers",
+			params: []interface{}{1, int64(2), uint8(3)},
+			want:   int64(6),
+		},
+		{
+			name:   "ints and floats",
+			params: []interface{}{1, 2.5, float32(0.5)},
+			want:   int64(4),
+		},
+		{
+			name:   "nil and non-numeric params are skipped",
+			params: []interface{}{1, nil, "abc", 2.25, null.Int{}, []uint8("10")},
+			want:   13.25,
+		},
+		{
+			name:   "decimal-safe addition",
+			params: []interface{}{0.1, 0.2},
+			want:   0.3,
+		},
+		{
+			name:   "DECIMAL text",
+			params: []interface{}{[]uint8("1234.56"), "0.04", null.FloatFrom(0.5)},
+			want:   1235.1,
+		},
+		{
+			name:   "DECIMAL json.Number stays exact",
+			params: []interface{}{json.Number("0.10"), json.Number("0.205"), 1},
+			want:   json.Number("1.305"),
+		},
+		{
+			name:   "all non-numeric",
+			params: []interface{}{nil, "abc", null.String{}},
+			want:   null.Float{},
+		},
+		{
+			name:   "no params",
+			params: []interface{}{},
+			want:   null.Float{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sumFields(tt.params)
+			if err != nil {
+				t.Fatalf("sumFields() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("sumFields() = %v (%T), want %v (%T)", result, result, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvgFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "whole average",
+			params: []interface{}{1, 2, 3},
+			want:   int64(2),
+		},
+		{
+			name:   "ints and floats",
+			params: []interface{}{1, 2.5},
+			want:   1.75,
+		},
+		{
+			name:   "nil and non-numeric params do not count",
+			params: []interface{}{4, nil, "n/a", 5.0},
+			want:   4.5,
+		},
+		{
+			name:   "repeating fraction",
+			params: []interface{}{1, 2, 2},
+			want:   5.0 / 3,
+		},
+		{
+			name:   "DECIMAL json.Number stays exact",
+			params: []interface{}{json.Number("1.10"), json.Number("2.05")},
+			want:   json.Number("1.5750"),
+		},
+		{
+			name:   "all non-numeric",
+			params: []interface{}{"n/a", nil},
+			want:   null.Float{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := avgFields(tt.params)
+			if err != nil {
+				t.Fatalf("avgFields() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("avgFields() = %v (%T), want %v (%T)", result, result, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "zero", params: []interface{}{0}, want: "0 B"},
+		{name: "just under 1 KiB", params: []interface{}{1023}, want: "1023 B"},
+		{name: "just under 1 KB decimal", params: []interface{}{999, "decimal"}, want: "999 B"},
+		{name: "1000 bytes is 1 KB decimal", params: []interface{}{1000, "decimal"}, want: "1.0 KB"},
+		{name: "1000 bytes is still bytes in binary", params: []interface{}{1000}, want: "1000 B"},
+		{name: "exactly 1 MiB", params: []interface{}{1048576}, want: "1.0 MiB"},
+		{name: "exactly 1 MiB in decimal", params: []interface{}{1048576, "decimal"}, want: "1.0 MB"},
+		{name: "10 MiB from bytes column", params: []interface{}{[]uint8("10485760"), "binary"}, want: "10.0 MiB"},
+		{name: "10 MiB in decimal", params: []interface{}{10485760, "DECIMAL"}, want: "10.5 MB"},
+		{name: "rounding promotes the unit", params: []interface{}{1048575}, want: "1.0 MiB"},
+		{name: "gigabytes", params: []interface{}{int64(5368709120)}, want: "5.0 GiB"},
+		{name: "negative delta", params: []interface{}{-2048}, want: "-2.0 KiB"},
+		{name: "nil mode uses binary", params: []interface{}{2048, nil}, want: "2.0 KiB"},
+		{name: "non-numeric", params: []interface{}{"large"}, want: null.String{}},
+		{name: "nil", params: []interface{}{nil}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := formatBytes(tt.params)
+			if err != nil {
+				t.Fatalf("formatBytes() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("formatBytes() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := formatBytes([]interface{}{1024, "metric"}); err == nil {
+		t.Error("formatBytes() with an unknown mode should return an error")
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "en-US", params: []interface{}{1234567.891, 2, "en-US"}, want: "1,234,567.89"},
+		{name: "id-ID", params: []interface{}{1234567.891, 2, "id-ID"}, want: "1.234.567,89"},
+		{name: "default locale and decimals", params: []interface{}{1234567.891}, want: "1,234,567.89"},
+		{name: "bare language", params: []interface{}{1234567.891, 2, "id"}, want: "1.234.567,89"},
+		{name: "underscore tag", params: []interface{}{1234567.891, 2, "ID_id"}, want: "1.234.567,89"},
+		{name: "narrow no-break space", params: []interface{}{1234567.891, 2, "fr-FR"}, want: "1 234 567,89"},
+		{name: "no decimals", params: []interface{}{-1500, 0, "de-DE"}, want: "-1.500"},
+		{name: "rounds half away", params: []interface{}{2.5, 0}, want: "3"},
+		{name: "below a thousand", params: []interface{}{999.5, 1, "id-ID"}, want: "999,5"},
+		{name: "negative zero", params: []interface{}{-0.001, 2}, want: "0.00"},
+		{name: "bytes column", params: []interface{}{[]uint8("1000000"), 0}, want: "1,000,000"},
+		{name: "nil decimals and locale", params: []interface{}{1000, nil, nil}, want: "1,000.00"},
+		{name: "non-numeric", params: []interface{}{"abc"}, want: null.String{}},
+		{name: "nil", params: []interface{}{nil}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := formatNumber(tt.params)
+			if err != nil {
+				t.Fatalf("formatNumber() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("formatNumber() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("configured locale", func(t *testing.T) {
+		formatNumber := NewOperatorRegistry(OperatorConfig{NumberLocale: "id-ID"})["formatNumber"]
+		if result, err := formatNumber([]interface{}{1234567.891}); err != nil || result != "1.234.567,89" {
+			t.Errorf("formatNumber() = %v, %v, want 1.234.567,89", result, err)
+		}
+		if result, err := formatNumber([]interface{}{1234567.891, 2, "en-US"}); err != nil || result != "1,234,567.89" {
+			t.Errorf("formatNumber() with en-US = %v, %v, want 1,234,567.89", result, err)
+		}
+	})
+
+	if _, err := formatNumber([]interface{}{1, 2, "xx-XX"}); err == nil {
+		t.Error("formatNumber() with an unknown locale should return an error")
+	}
+	if _, err := formatNumber([]interface{}{1, -1}); err == nil {
+		t.Error("formatNumber() with negative decimals should return an error")
+	}
+}
+
+func TestDecimalPrecision(t *testing.T) {
+	t.Run("toDecimal parses DECIMAL text exactly", func(t *testing.T) {
+		for _, v := range []interface{}{"1234.56", []uint8("1234.56"), json.Number("1234.56")} {
+			d, ok := toDecimal(v)
+			if !ok {
+				t.Fatalf("toDecimal(%v) failed", v)
+			}
+			if got := d.FloatString(2); got != "1234.56" {
+				t.Errorf("toDecimal(%v) = %s, want 1234.56", v, got)
+			}
+		}
+
+		for _, v := range []interface{}{"abc", "1/3", "", nil, null.Float{}} {
+			if _, ok := toDecimal(v); ok {
+				t.Errorf("toDecimal(%v) expected failure", v)
+			}
+		}
+	})
+
+	tests := []struct {
+		name string
+		op   OperatorFunc
+		args []interface{}
+		want interface{}
+	}{
+		{
+			name: "DECIMAL value survives scale round-trip",
+			op:   scale,
+			args: []interface{}{json.Number("1234.56"), 1},
+			want: json.Number("1234.56"),
+		},
+		{
+			name: "DECIMAL beyond float64 precision",
+			op:   scale,
+			args: []interface{}{json.Number("12345678901234567.89"), 1},
+			want: json.Number("12345678901234567.89"),
+		},
+		{
+			name: "DECIMAL cents to dollars",
+			op:   scale,
+			args: []interface{}{json.Number("-1999"), "0.01"},
+			want: json.Number("-19.99"),
+		},
+		{
+			name: "DECIMAL percentage",
+			op:   percentOf,
+			args: []interface{}{json.Number("0.3"), json.Number("0.9"), 4},
+			want: json.Number("33.3333"),
+		},
+		{
+			name: "DECIMAL zero denominator",
+			op:   percentOf,
+			args: []interface{}{json.Number("1.5"), json.Number("0.00")},
+			want: null.Float{},
+		},
+		{
+			name: "decimal bytes keep float64 output",
+			op:   scale,
+			args: []interface{}{[]uint8("1234.56"), 1},
+			want: 1234.56,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.op(tt.args)
+			if err != nil {
+				t.Fatalf("operator error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %v (%T), want %v (%T)", result, result, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucket(t *testing.T) {
+	ageRules := `[{"max":18,"label":"0-18"},{"max":35,"label":"19-35"},{"label":"36+"}]`
+	closedRules := `[{"max":0,"label":"none"},{"max":100,"label":"small"}]`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "exactly at first max",
+			params: []interface{}{18, ageRules},
+			want:   "0-18",
+		},
+		{
+			name:   "just above first max",
+			params: []interface{}{18.5, ageRules},
+			want:   "19-35",
+		},
+		{
+			name:   "exactly at second max",
+			params: []interface{}{[]uint8("35"), ageRules},
+			want:   "19-35",
+		},
+		{
+			name:   "below all ranges",
+			params: []interface{}{-5, closedRules},
+			want:   "none",
+		},
+		{
+			name:   "above all ranges falls into open-ended bucket",
+			params: []interface{}{80, ageRules},
+			want:   "36+",
+		},
+		{
+			name:   "above all ranges without open-ended bucket",
+			params: []interface{}{250, closedRules},
+			want:   null.String{},
+		},
+		{
+			name:   "rules from bytes",
+			params: []interface{}{50, []uint8(closedRules)},
+			want:   "small",
+		},
+		{
+			name:   "non-numeric value",
+			params: []interface{}{"abc", ageRules},
+			want:   null.String{},
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil, ageRules},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := bucket(tt.params)
+			if err != nil {
+				t.Errorf("bucket() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("bucket() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid rules return error", func(t *testing.T) {
+		if _, err := bucket([]interface{}{10, "not json"}); err == nil {
+			t.Error("bucket() expected error for invalid rules")
+		}
+	})
+
+	t.Run("missing rules return error", func(t *testing.T) {
+		if _, err := bucket([]interface{}{10}); err == nil {
+			t.Error("bucket() expected error for missing rules")
+		}
+	})
+}
+
+func TestHashBucket(t *testing.T) {
+	t.Run("same input gives same bucket", func(t *testing.T) {
+		first, err := hashBucket([]interface{}{"customer-42", 10})
+		if err != nil {
+			t.Fatalf("hashBucket() error = %v", err)
+		}
+		if first != int64(6) {
+			t.Errorf("hashBucket(customer-42, 10) = %v, want 6", first)
+		}
+		for i := 0; i < 5; i++ {
+			if again, _ := hashBucket([]interface{}{"customer-42", "10"}); again != first {
+				t.Fatalf("hashBucket() = %v, then %v for the same input", first, again)
+			}
+		}
+		// Values hash by their text form
+		if fromBytes, _ := hashBucket([]interface{}{[]uint8("customer-42"), int64(10)}); fromBytes != first {
+			t.Errorf("hashBucket([]uint8) = %v, want %v", fromBytes, first)
+		}
+	})
+
+	t.Run("buckets are evenly used", func(t *testing.T) {
+		const buckets, values = 10, 10000
+		counts := make([]int, buckets)
+		for i := 0; i < values; i++ {
+			result, err := hashBucket([]interface{}{fmt.Sprintf("customer-%d", i), buckets})
+			if err != nil {
+				t.Fatalf("hashBucket() error = %v", err)
+			}
+			index := result.(int64)
+			if index < 0 || index >= buckets {
+				t.Fatalf("hashBucket() = %d, want [0, %d)", index, buckets)
+			}
+			counts[index]++
+		}
+		// Each bucket should get about values/buckets; allow 20% either way
+		for index, count := range counts {
+			if count < values/buckets*8/10 || count > values/buckets*12/10 {
+				t.Errorf("bucket %d has %d values, want about %d: %v", index, count, values/buckets, counts)
+			}
+		}
+	})
+
+	t.Run("single bucket", func(t *testing.T) {
+		for _, value := range []interface{}{"a", 42, "customer-42"} {
+			if result, err := hashBucket([]interface{}{value, 1}); err != nil || result != int64(0) {
+				t.Errorf("hashBucket(%v, 1) = %v, %v, want 0", value, result, err)
+			}
+		}
+	})
+
+	t.Run("nil value", func(t *testing.T) {
+		if result, err := hashBucket([]interface{}{nil, 10}); err != nil || result != (null.String{}) {
+			t.Errorf("hashBucket(nil, 10) = %v, %v, want null", result, err)
+		}
+	})
+
+	t.Run("invalid bucket count", func(t *testing.T) {
+		for _, params := range [][]interface{}{{"a", 0}, {"a", -3}, {"a", "many"}, {"a"}} {
+			if _, err := hashBucket(params); err == nil {
+				t.Errorf("hashBucket(%v) should fail", params)
+			}
+		}
+	})
+}
+
+func TestJSONMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "overlapping keys, later wins",
+			params: []interface{}{`{"a":1,"b":"old"}`, `{"b":"new","c":true}`},
+			want:   map[string]interface{}{"a": float64(1), "b": "new", "c": true},
+		},
+		{
+			name:   "nested merge",
+			params: []interface{}{`{"meta":{"source":"email","tags":{"vip":true}}}`, []uint8(`{"meta":{"channel":"web","tags":{"new":true}}}`)},
+			want: map[string]interface{}{
+				"meta": map[string]interface{}{
+					"source":  "email",
+					"channel": "web",
+					"tags":    map[string]interface{}{"vip": true, "new": true},
+				},
+			},
+		},
+		{
+			name:   "non-object replaces nested object",
+			params: []interface{}{`{"meta":{"a":1}}`, `{"meta":null}`},
+			want:   map[string]interface{}{"meta": nil},
+		},
+		{
+			name:   "invalid param is skipped",
+			params: []interface{}{`{"a":1}`, "not json", `[1,2]`, nil, 42, `{"b":2}`},
+			want:   map[string]interface{}{"a": float64(1), "b": float64(2)},
+		},
+		{
+			name:   "nothing merges returns empty object",
+			params: []interface{}{"", nil, "invalid"},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "no params returns empty object",
+			params: []interface{}{},
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := jsonMerge(tt.params)
+			if err != nil {
+				t.Fatalf("jsonMerge() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("jsonMerge() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("input maps are not modified", func(t *testing.T) {
+		first := map[string]interface{}{"meta": map[string]interface{}{"a": 1}}
+		second := map[string]interface{}{"meta": map[string]interface{}{"b": 2}}
+
+		if _, err := jsonMerge([]interface{}{first, second}); err != nil {
+			t.Fatalf("jsonMerge() error = %v", err)
+		}
+		if len(first["meta"].(map[string]interface{})) != 1 {
+			t.Errorf("jsonMerge() modified its input: %v", first)
+		}
+	})
+}
+
+func TestJSONSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "top-level key",
+			params: []interface{}{`{"a":1}`, "b", "x"},
+			want:   `{"a":1,"b":"x"}`,
+		},
+		{
+			name:   "nested key creates intermediate objects",
+			params: []interface{}{[]uint8(`{"a":1}`), "meta.source.channel", "web"},
+			want:   `{"a":1,"meta":{"source":{"channel":"web"}}}`,
+		},
+		{
+			name:   "existing key is overridden",
+			params: []interface{}{`{"a":1,"meta":{"source":"email","vip":true}}`, "meta.source", "web"},
+			want:   `{"a":1,"meta":{"source":"web","vip":true}}`,
+		},
+		{
+			name:   "non-object intermediate is replaced",
+			params: []interface{}{`{"meta":"legacy"}`, "meta.source", int64(2)},
+			want:   `{"meta":{"source":2}}`,
+		},
+		{
+			name:   "nil value sets null",
+			params: []interface{}{`{"a":1}`, "a", nil},
+			want:   `{"a":null}`,
+		},
+		{
+			name:   "invalid JSON returns null",
+			params: []interface{}{"not json", "a", 1},
+			want:   null.String{},
+		},
+		{
+			name:   "non-object JSON returns null",
+			params: []interface{}{`[1,2]`, "a", 1},
+			want:   null.String{},
+		},
+		{
+			name:   "nil input returns null",
+			params: []interface{}{nil, "a", 1},
+			want:   null.String{},
+		},
+		{
+			name:   "empty path segment returns null",
+			params: []interface{}{`{"a":1}`, "a..b", 1},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := jsonSet(tt.params)
+			if err != nil {
+				t.Fatalf("jsonSet() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("jsonSet() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("input maps are not modified", func(t *testing.T) {
+		object := map[string]interface{}{"meta": map[string]interface{}{"a": 1}}
+
+		if _, err := jsonSet([]interface{}{object, "meta.b", 2}); err != nil {
+			t.Fatalf("jsonSet() error = %v", err)
+		}
+		if len(object["meta"].(map[string]interface{})) != 1 {
+			t.Errorf("jsonSet() modified its input: %v", object)
+		}
+	})
+}
+
+func TestParseKeyValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "key value pairs",
+			params: []interface{}{"color=red; size = large"},
+			want:   map[string]interface{}{"color": "red", "size": "large"},
+		},
+		{
+			name:   "value containing the separator",
+			params: []interface{}{"url=https://example.com/?a=1&b=2;mode=fast"},
+			want:   map[string]interface{}{"url": "https://example.com/?a=1&b=2", "mode": "fast"},
+		},
+		{
+			name:   "trailing separator",
+			params: []interface{}{"color=red;size=large;"},
+			want:   map[string]interface{}{"color": "red", "size": "large"},
+		},
+		{
+			name:   "malformed pairs are skipped",
+			params: []interface{}{"color=red;;flag;=orphan;empty="},
+			want:   map[string]interface{}{"color": "red", "empty": ""},
+		},
+		{
+			name:   "custom separators",
+			params: []interface{}{[]uint8("a:1|b:2:3"), "|", ":"},
+			want:   map[string]interface{}{"a": "1", "b": "2:3"},
+		},
+		{
+			name:   "repeated key keeps last value",
+			params: []interface{}{"a=1;a=2"},
+			want:   map[string]interface{}{"a": "2"},
+		},
+		{
+			name:   "empty string",
+			params: []interface{}{""},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseKeyValue(tt.params)
+			if err != nil {
+				t.Fatalf("parseKeyValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("parseKeyValue() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOperatorRegistry(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	registry := NewOperatorRegistry(OperatorConfig{
+		TicketPrefix:         "ACME",
+		AdditionalDataPrefix: "acme",
+		SentimentLabels:      map[int]string{-1: "Unhappy", 0: "Okay", 1: "Happy"},
+		EscalatedLabels:      map[int]string{1: "Yes", 0: "No"},
+		Location:             jakarta,
+	})
+
+	tests := []struct {
+		name     string
+		operator string
+		params   []interface{}
+		want     interface{}
+	}{
+		{name: "custom ticket prefix", operator: "ticketIdMasking", params: []interface{}{42}, want: "ACME-0000000042"},
+		{name: "custom sentiment label", operator: "sentimentMapping", params: []interface{}{1}, want: "Happy"},
+		{name: "unmapped sentiment", operator: "sentimentMapping", params: []interface{}{5}, want: null.String{}},
+		{name: "custom escalated label", operator: "escalatedMapping", params: []interface{}{0}, want: "No"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry[tt.operator](tt.params)
+			if err != nil {
+				t.Fatalf("%s() error = %v", tt.operator, err)
+			}
+			if result != tt.want {
+				t.Errorf("%s() = %v, want %v", tt.operator, result, tt.want)
+			}
+		})
+	}
+
+	t.Run("custom additional data prefix", func(t *testing.T) {
+		result, err := registry["additionalData"]([]interface{}{`{"source":"web"}`})
+		if err != nil {
+			t.Fatalf("additionalData() error = %v", err)
+		}
+		want := map[string]interface{}{"acme_source": "web"}
+		if !reflect.DeepEqual(result, want) {
+			t.Errorf("additionalData() = %v, want %v", result, want)
+		}
+	})
+
+	t.Run("ticketDate converts to configured timezone", func(t *testing.T) {
+		result, err := registry["ticketDate"]([]interface{}{`[{"date_create":"2025-01-15 10:30:00"}]`, "2006-01-02 15:04"})
+		if err != nil {
+			t.Fatalf("ticketDate() error = %v", err)
+		}
+		dates := result.([]map[string]interface{})
+		if got := dates[0]["date_create"]; got != "2025-01-15 17:30" {
+			t.Errorf("ticketDate() date_create = %v, want 2025-01-15 17:30", got)
+		}
+	})
+
+	t.Run("partial config keeps defaults", func(t *testing.T) {
+		partial := NewOperatorRegistry(OperatorConfig{TicketPrefix: "ACME"})
+
+		if result, _ := partial["sentimentMapping"]([]interface{}{-1}); result != "Negative" {
+			t.Errorf("sentimentMapping() = %v, want Negative", result)
+		}
+		if result, _ := partial["escalatedMapping"]([]interface{}{1}); result != "escalated" {
+			t.Errorf("escalatedMapping() = %v, want escalated", result)
+		}
+	})
+
+	t.Run("default registry is unchanged", func(t *testing.T) {
+		if result, _ := GetOperatorRegistry()["ticketIdMasking"]([]interface{}{42}); result != "TICKET-0000000042" {
+			t.Errorf("ticketIdMasking() = %v, want TICKET-0000000042", result)
+		}
+	})
+}
+
+func TestStripEmoji(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "single emoji",
+			params: []interface{}{"Great service 😀"},
+			want:   "Great service ",
+		},
+		{
+			name:   "emoji with skin tone modifier",
+			params: []interface{}{"Thanks 👍🏽!"},
+			want:   "Thanks !",
+		},
+		{
+			name:   "family ZWJ sequence",
+			params: []interface{}{"Family 👨‍👩‍👧‍👦 trip"},
+			want:   "Family  trip",
+		},
+		{
+			name:   "flag",
+			params: []interface{}{"From 🇮🇩 office"},
+			want:   "From  office",
+		},
+		{
+			name:   "variation selector and keycap",
+			params: []interface{}{"Love ❤️ press 1️⃣ now"},
+			want:   "Love  press  now",
+		},
+		{
+			name:   "plain text unchanged",
+			params: []interface{}{"Order #123 - 50% off, café (naïve) & résumé"},
+			want:   "Order #123 - 50% off, café (naïve) & résumé",
+		},
+		{
+			name:   "combining marks outside emoji kept",
+			params: []interface{}{"cafe\u0301"},
+			want:   "cafe\u0301",
+		},
+		{
+			name:   "text symbols kept",
+			params: []interface{}{"© 2024 Acme™"},
+			want:   "© 2024 Acme™",
+		},
+		{
+			name:   "non-latin text unchanged",
+			params: []interface{}{"こんにちは 世界"},
+			want:   "こんにちは 世界",
+		},
+		{
+			name:   "emoji mode keeps control characters",
+			params: []interface{}{"a\u0000b\n🙂"},
+			want:   "a\u0000b\n",
+		},
+		{
+			name:   "printable mode drops control characters",
+			params: []interface{}{"a\u0000b\u200b\tc\n🙂", "printable"},
+			want:   "ab\tc\n",
+		},
+		{
+			name:   "bytes input",
+			params: []interface{}{[]uint8("ok 🚀")},
+			want:   "ok ",
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := stripEmoji(tt.params)
+			if err != nil {
+				t.Errorf("stripEmoji() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("stripEmoji() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsupported mode returns error", func(t *testing.T) {
+		if _, err := stripEmoji([]interface{}{"text", "ascii"}); err == nil {
+			t.Error("stripEmoji() expected error for unsupported mode")
+		}
+	})
+}
+
+func TestMatches(t *testing.T) {
+	emailPattern := `^[^@\s]+@[^@\s]+\.[a-z]+$`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "matching value",
+			params: []interface{}{"user@example.com", emailPattern},
+			want:   true,
+		},
+		{
+			name:   "non-matching value",
+			params: []interface{}{"not-an-email", emailPattern},
+			want:   false,
+		},
+		{
+			name:   "pattern from bytes",
+			params: []interface{}{"+62812345678", []uint8(`^\+?[0-9]{8,15}$`)},
+			want:   true,
+		},
+		{
+			name:   "numeric value",
+			params: []interface{}{int64(12345), `^\d+$`},
+			want:   true,
+		},
+		{
+			name:   "invalid pattern",
+			params: []interface{}{"abc", "(["},
+			want:   null.Bool{},
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil, emailPattern},
+			want:   null.Bool{},
+		},
+		{
+			name:   "missing pattern",
+			params: []interface{}{"abc"},
+			want:   null.Bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := matches(tt.params)
+			if err != nil {
+				t.Errorf("matches() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("matches() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestLengthInRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "below min",
+			params: []interface{}{"Hi", 5, 100},
+			want:   false,
+		},
+		{
+			name:   "in range",
+			params: []interface{}{"Printer jam", 5, 100},
+			want:   true,
+		},
+		{
+			name:   "bounds are inclusive",
+			params: []interface{}{"abcde", "5", "5"},
+			want:   true,
+		},
+		{
+			name:   "above max",
+			params: []interface{}{"This subject is far too long", 5, 10},
+			want:   false,
+		},
+		{
+			name:   "runes are counted, not bytes",
+			params: []interface{}{"café", 0, int64(4)},
+			want:   true,
+		},
+		{
+			name:   "nil input has length 0",
+			params: []interface{}{nil, 1, 10},
+			want:   false,
+		},
+		{
+			name:   "nil input within a zero minimum",
+			params: []interface{}{nil, 0, 10},
+			want:   true,
+		},
+		{
+			name:   "missing max is unbounded",
+			params: []interface{}{"a long enough value", 3},
+			want:   true,
+		},
+		{
+			name:   "invalid bound",
+			params: []interface{}{"abc", "three", 10},
+			want:   null.Bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := lengthInRange(tt.params)
+			if err != nil {
+				t.Fatalf("lengthInRange() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("lengthInRange() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := lengthInRange([]interface{}{"abc"}); err == nil {
+		t.Error("Expected error when min is missing")
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     OperatorFunc
+		params []interface{}
+		want   string
+	}{
+		{"padLeft short value", padLeft, []interface{}{"42", 5, "0"}, "00042"},
+		{"padRight short value", padRight, []interface{}{"Budi", int64(6)}, "Budi  "},
+		{"padLeft truncates a long value", padLeft, []interface{}{"123456", 4}, "1234"},
+		{"padRight truncates a long value", padRight, []interface{}{"Jakarta Selatan", "7"}, "Jakarta"},
+		{"exact width is unchanged", padRight, []interface{}{"abc", 3, "*"}, "abc"},
+		{"multibyte value and pad", padRight, []interface{}{"café", 6, "·"}, "café··"},
+		{"multibyte value truncated on runes", padLeft, []interface{}{"日本語テキスト", 3, "＿"}, "日本語"},
+		{"multibyte pad on the left", padLeft, []interface{}{"ab", 4, "─x"}, "──ab"},
+		{"numbers are stringified", padLeft, []interface{}{int64(125), 6, "0"}, "000125"},
+		{"nil value keeps the width", padLeft, []interface{}{nil, 3}, "   "},
+		{"zero width", padRight, []interface{}{"abc", 0}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.op(tt.params)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %q, want %q", result, tt.want)
+			}
+			if got := utf8.RuneCountInString(result.(string)); got != toInt(tt.params[1]) {
+				t.Errorf("width = %d runes, want %v", got, tt.params[1])
+			}
+		})
+	}
+
+	for _, params := range [][]interface{}{{"abc"}, {"abc", -1}, {"abc", "wide"}, {"abc", nil}} {
+		if _, err := padLeft(params); err == nil {
+			t.Errorf("padLeft(%v) should fail", params)
+		}
+	}
+}
+
+func TestXMLStrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"CDATA text is kept", []interface{}{"<msg><![CDATA[a < b && c > d]]></msg>"}, "a < b && c > d"},
+		{"comment is dropped", []interface{}{"Before<!-- <b>hidden</b> -->After"}, "BeforeAfter"},
+		{"nested elements", []interface{}{"<note><to>Budi</to> <body>Hi <b>there</b></body></note>"}, "Budi Hi there"},
+		{"declaration and doctype", []interface{}{"<?xml version=\"1.0\"?>\n<!DOCTYPE note>\n<note>Hello</note>\n"}, "Hello"},
+		{"entities are decoded once", []interface{}{"<p>&amp;lt;b&amp;gt; &copy; &#65;</p>"}, "&lt;b&gt; © A"},
+		{"fragment without a root", []interface{}{"<a>x</a><br><a>y</a>"}, "xy"},
+		{"plain text", []interface{}{"  Plain text "}, "Plain text"},
+		{"malformed falls back to stripHTML", []interface{}{"5 < 10 <b>ok</b>"}, "5 < 10 ok"},
+		{"nil", []interface{}{nil}, null.String{}},
+		{"no params", []interface{}{}, null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := xmlStrip(tt.params)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"1st", []interface{}{1}, "1st"},
+		{"2nd", []interface{}{int64(2)}, "2nd"},
+		{"3rd", []interface{}{"3"}, "3rd"},
+		{"4th", []interface{}{4.0}, "4th"},
+		{"11th", []interface{}{11}, "11th"},
+		{"12th", []interface{}{"12"}, "12th"},
+		{"13th", []interface{}{13}, "13th"},
+		{"21st", []interface{}{21}, "21st"},
+		{"22nd from bytes", []interface{}{[]uint8("22")}, "22nd"},
+		{"111th", []interface{}{111}, "111th"},
+		{"101st", []interface{}{101}, "101st"},
+		{"0th", []interface{}{0}, "0th"},
+		{"negative", []interface{}{-1}, "-1st"},
+		{"indonesian", []interface{}{22, "id"}, "ke-22"},
+		{"explicit english", []interface{}{23, "EN"}, "23rd"},
+		{"not numeric", []interface{}{"abc"}, null.String{}},
+		{"fraction", []interface{}{2.5}, null.String{}},
+		{"nil", []interface{}{nil}, null.String{}},
+		{"no params", []interface{}{}, null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ordinal(tt.params)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := ordinal([]interface{}{1, "fr"}); err == nil {
+		t.Error("expected an error for an unsupported locale")
+	}
+}
+
+func TestNumberToWords(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"zero", []interface{}{0}, "zero"},
+		{"units", []interface{}{7}, "seven"},
+		{"teens", []interface{}{13}, "thirteen"},
+		{"tens", []interface{}{40}, "forty"},
+		{"hyphenated", []interface{}{"42"}, "forty-two"},
+		{"hundreds", []interface{}{105}, "one hundred five"},
+		{"thousands", []interface{}{1205}, "one thousand two hundred five"},
+		{"skips empty groups", []interface{}{2000021}, "two million twenty-one"},
+		{"negative", []interface{}{-15}, "minus fifteen"},
+		{"min int64", []interface{}{"-9223372036854775808"}, "minus nine quintillion two hundred twenty-three quadrillion three hundred seventy-two trillion thirty-six billion eight hundred fifty-four million seven hundred seventy-five thousand eight hundred eight"},
+		{"indonesian zero", []interface{}{0, "id"}, "nol"},
+		{"indonesian teens", []interface{}{11, "id"}, "sebelas"},
+		{"indonesian tens", []interface{}{22, "id"}, "dua puluh dua"},
+		{"indonesian hundreds", []interface{}{115, "id"}, "seratus lima belas"},
+		{"indonesian thousands", []interface{}{1010, "id"}, "seribu sepuluh"},
+		{"indonesian millions", []interface{}{1250000, "id"}, "satu juta dua ratus lima puluh ribu"},
+		{"not numeric", []interface{}{"abc"}, null.String{}},
+		{"fraction", []interface{}{2.5}, null.String{}},
+		{"nil", []interface{}{nil}, null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := numberToWords(tt.params)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := numberToWords([]interface{}{1, "fr"}); err == nil {
+		t.Error("expected an error for an unsupported locale")
+	}
+}
+
+func TestRegexCapture(t *testing.T) {
+	orderPattern := `#(\d+)`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "captures first group by default",
+			params: []interface{}{"Order #12345 delayed", orderPattern},
+			want:   "12345",
+		},
+		{
+			name:   "group index",
+			params: []interface{}{"2024-05-17", `(\d+)-(\d+)-(\d+)`, 2},
+			want:   "05",
+		},
+		{
+			name:   "group zero is the whole match",
+			params: []interface{}{"Order #12345 delayed", orderPattern, "0"},
+			want:   "#12345",
+		},
+		{
+			name:   "first match only",
+			params: []interface{}{"Order #1 and #2", orderPattern},
+			want:   "1",
+		},
+		{
+			name:   "value from bytes",
+			params: []interface{}{[]uint8("Refund #987"), orderPattern},
+			want:   "987",
+		},
+		{
+			name:   "no match",
+			params: []interface{}{"No order number", orderPattern},
+			want:   null.String{},
+		},
+		{
+			name:   "group index out of range",
+			params: []interface{}{"Order #12345 delayed", orderPattern, 2},
+			want:   null.String{},
+		},
+		{
+			name:   "negative group index",
+			params: []interface{}{"Order #12345 delayed", orderPattern, -1},
+			want:   null.String{},
+		},
+		{
+			name:   "group not taking part in the match",
+			params: []interface{}{"Order 12345", `(#)?(\d+)`, 1},
+			want:   null.String{},
+		},
+		{
+			name:   "invalid pattern",
+			params: []interface{}{"abc", "(["},
+			want:   null.String{},
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil, orderPattern},
+			want:   null.String{},
+		},
+		{
+			name:   "missing pattern",
+			params: []interface{}{"Order #12345"},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := regexCapture(tt.params)
+			if err != nil {
+				t.Errorf("regexCapture() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("regexCapture() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "slashes and punctuation",
+			params: []interface{}{"Refund / Billing: URGENT!! (ref #42)"},
+			want:   "refund-billing-urgent-ref-42",
+		},
+		{
+			name:   "path traversal and reserved characters",
+			params: []interface{}{`..\reports/../"Q1"*<draft>?.csv`},
+			want:   "reports-q1draftcsv",
+		},
+		{
+			name:   "leading and trailing space",
+			params: []interface{}{"  Hello World  "},
+			want:   "hello-world",
+		},
+		{
+			name:   "repeated hyphens collapse, underscores kept",
+			params: []interface{}{"order --- id_2024\t\nitems"},
+			want:   "order-id_2024-items",
+		},
+		{
+			name:   "control characters removed",
+			params: []interface{}{"line\x00one\x1f"},
+			want:   "lineone",
+		},
+		{
+			name:   "long input capped at max length",
+			params: []interface{}{strings.Repeat("long subject ", 20), 20},
+			want:   "long-subject-long-su",
+		},
+		{
+			name:   "cap never leaves trailing hyphen",
+			params: []interface{}{"quarterly report", 10},
+			want:   "quarterly",
+		},
+		{
+			name:   "max length as string",
+			params: []interface{}{"Hello World", "5"},
+			want:   "hello",
+		},
+		{
+			name:   "nil value",
+			params: []interface{}{nil},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := slugify(tt.params)
+			if err != nil {
+				t.Errorf("slugify() error = %v", err)
+				return
+			}
+			if result != tt.want {
+				t.Errorf("slugify() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestToIntByteInputs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  int
+	}{
+		{name: "bytes", input: []byte("1696000208"), want: 1696000208},
+		{name: "sql.RawBytes", input: sql.RawBytes("42"), want: 42},
+		{name: "padded bytes", input: []byte(" 7 "), want: 7},
+		{name: "decimal bytes", input: []byte("3661.9"), want: 3661},
+		{name: "json.Number", input: json.Number("1696000208"), want: 1696000208},
+		{name: "null.String", input: null.StringFrom("15"), want: 15},
+		{name: "leading integer", input: "12abc", want: 12},
+		{name: "invalid bytes", input: []byte("abc"), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toInt(tt.input); got != tt.want {
+				t.Errorf("toInt(%v) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToTime(t *testing.T) {
+	want := time.Date(2023, 9, 29, 15, 10, 8, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		input  interface{}
+		wantOK bool
+	}{
+		{name: "bytes epoch", input: []byte("1696000208"), wantOK: true},
+		{name: "sql.RawBytes epoch", input: sql.RawBytes("1696000208"), wantOK: true},
+		{name: "int64 epoch", input: int64(1696000208), wantOK: true},
+		{name: "float64 epoch", input: float64(1696000208), wantOK: true},
+		{name: "string epoch", input: "1696000208", wantOK: true},
+		{name: "datetime bytes", input: []byte("2023-09-29 15:10:08"), wantOK: true},
+		{name: "RFC3339 string", input: "2023-09-29T15:10:08Z", wantOK: true},
+		{name: "zero epoch", input: 0},
+		{name: "invalid text", input: []byte("soon")},
+		{name: "nil", input: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := toTime(tt.input)
+			if ok != tt.wantOK {
+				t.Fatalf("toTime(%v) ok = %v, want %v", tt.input, ok, tt.wantOK)
+			}
+			if ok && !got.Equal(want) {
+				t.Errorf("toTime(%v) = %v, want %v", tt.input, got, want)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   bool
+	}{
+		{name: "equal strings", params: []interface{}{"open", "open"}, want: false},
+		{name: "differing strings", params: []interface{}{"open", "closed"}, want: true},
+		{name: "number and numeric string", params: []interface{}{5, "5"}, want: false},
+		{name: "bytes with whitespace", params: []interface{}{[]uint8("open "), "open"}, want: false},
+		{name: "both nil", params: []interface{}{nil, nil}, want: false},
+		{name: "nil and invalid null", params: []interface{}{nil, null.String{}}, want: false},
+		{name: "old nil", params: []interface{}{nil, "open"}, want: true},
+		{name: "new nil", params: []interface{}{"open", nil}, want: true},
+		{name: "nil and empty string", params: []interface{}{nil, ""}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := changed(tt.params)
+			if err != nil {
+				t.Fatalf("changed() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("changed() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := changed([]interface{}{"only one"}); err == nil {
+		t.Error("changed() with 1 param should return an error")
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{name: "equal values", params: []interface{}{"open", "open"}, want: ""},
+		{name: "differing values", params: []interface{}{"open", "closed"}, want: "open→closed"},
+		{name: "numbers", params: []interface{}{int64(3), 4}, want: "3→4"},
+		{name: "trimmed values", params: []interface{}{" low ", "high\n"}, want: "low→high"},
+		{name: "both nil", params: []interface{}{nil, nil}, want: ""},
+		{name: "old nil", params: []interface{}{nil, "open"}, want: "→open"},
+		{name: "new nil", params: []interface{}{"open", null.String{}}, want: "open→"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := diffText(tt.params)
+			if err != nil {
+				t.Fatalf("diffText() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("diffText() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusTimestamps(t *testing.T) {
+	history := `[
+		{"status_id":1,"date_create":"2024-01-15 10:30:00"},
+		{"status_id":2,"date_create":"2024-01-15 11:00:00"},
+		{"status_id":1,"date_create":"2024-01-16 08:00:00"},
+		{"status_id":3,"date_create":"2024-01-16 09:00:00"},
+		{"status_id":"1","date_create":"2024-01-14 23:00:00"},
+		{"status_id":4,"date_create":"2024-01-17 12:00:00"},
+		{"status_id":3,"date_create":"not a date"}
+	]`
+	mapping := `{"1":"first_open_at","2":"first_pending_at","3":"first_resolved_at","5":"first_closed_at"}`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "first occurrence per mapped status",
+			params: []interface{}{history, mapping},
+			want: map[string]interface{}{
+				"first_open_at":     "2024-01-14T23:00:00Z",
+				"first_pending_at":  "2024-01-15T11:00:00Z",
+				"first_resolved_at": "2024-01-16T09:00:00Z",
+			},
+		},
+		{
+			name:   "missing statuses as null with custom format",
+			params: []interface{}{[]uint8(history), map[string]interface{}{"3": "resolved_on", "5": "closed_on"}, "null", "2006-01-02"},
+			want:   map[string]interface{}{"resolved_on": "2024-01-16", "closed_on": nil},
+		},
+		{
+			name:   "nil history",
+			params: []interface{}{nil, mapping},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "invalid history with null flag",
+			params: []interface{}{"not json", `{"1":"first_open_at"}`, true},
+			want:   map[string]interface{}{"first_open_at": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := statusTimestamps(tt.params)
+			if err != nil {
+				t.Fatalf("statusTimestamps() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("statusTimestamps() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid mapping returns error", func(t *testing.T) {
+		for _, params := range [][]interface{}{{history}, {history, nil}, {history, "not json"}, {history, `["first_open_at"]`}} {
+			if _, err := statusTimestamps(params); err == nil {
+				t.Errorf("statusTimestamps(%v) should fail", params[1:])
+			}
+		}
+	})
+
+	t.Run("outputFields spread the columns", func(t *testing.T) {
+		formulas := []Formula{
+			{Params: []string{"id"}, Field: "id", Position: 1},
+			{
+				Params:       []string{"status_dates", "'" + mapping + "' AS mapping"},
+				Operator:     "statusTimestamps",
+				OutputFields: []string{"first_open_at", "first_resolved_at", "first_closed_at"},
+				Position:     2,
+			},
+		}
+		row := RowData{"id": 7, "status_dates": history, "mapping": mapping}
+
+		result, err := TransformRow(row, formulas, GetOperatorRegistry())
+		if err != nil {
+			t.Fatalf("TransformRow() error = %v", err)
+		}
+		want := []TransformedField{
+			{Key: "id", Value: 7},
+			{Key: "first_open_at", Value: "2024-01-14T23:00:00Z"},
+			{Key: "first_resolved_at", Value: "2024-01-16T09:00:00Z"},
+			{Key: "first_closed_at", Value: null.String{}},
+		}
+		if !reflect.DeepEqual(result.fields, want) {
+			t.Errorf("TransformRow() = %v, want %v", result.fields, want)
+		}
+	})
+}
+
+func TestAgeInDays(t *testing.T) {
+	fixedNow := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	registry := NewOperatorRegistry(OperatorConfig{Now: func() time.Time { return fixedNow }})
+	ageInDays := registry["ageInDays"]
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "date string against now", params: []interface{}{"2024-01-01"}, want: 60},
+		{name: "time.Time against now", params: []interface{}{fixedNow.Add(-72 * time.Hour)}, want: 3},
+		{name: "unix seconds bytes", params: []interface{}{[]uint8("1706745600")}, want: 29},
+		{name: "same day", params: []interface{}{"2024-03-01 00:05:00"}, want: 0},
+		{name: "future date is absolute by default", params: []interface{}{"2024-03-11"}, want: 10},
+		{name: "future date signed", params: []interface{}{"2024-03-11", nil, "signed"}, want: -10},
+		{name: "past date signed", params: []interface{}{"2024-02-25", "", true}, want: 5},
+		{name: "explicit reference date", params: []interface{}{"2024-01-01", "2024-01-31 23:59:59"}, want: 30},
+		{name: "invalid date", params: []interface{}{"not a date"}, want: null.Int{}},
+		{name: "invalid reference", params: []interface{}{"2024-01-01", "soon"}, want: null.Int{}},
+		{name: "nil date", params: []interface{}{nil}, want: null.Int{}},
+		{name: "no params", params: []interface{}{}, want: null.Int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ageInDays(tt.params)
+			if err != nil {
+				t.Fatalf("ageInDays() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("ageInDays() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("calendar days in configured timezone", func(t *testing.T) {
+		jakarta := time.FixedZone("WIB", 7*60*60)
+		// 2024-03-01 18:00 UTC is already 2024-03-02 in Jakarta
+		now := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+		local := NewOperatorRegistry(OperatorConfig{
+			Location: jakarta,
+			Now:      func() time.Time { return now },
+		})["ageInDays"]
+
+		result, err := local([]interface{}{time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatalf("ageInDays() error = %v", err)
+		}
+		if result != 1 {
+			t.Errorf("ageInDays() = %v, want 1", result)
+		}
+	})
+}
+
+func TestParseDateFlexible(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "RFC3339 with offset", params: []interface{}{"2024-01-15T10:30:00+07:00"}, want: "2024-01-15T03:30:00Z"},
+		{name: "datetime", params: []interface{}{"2024-01-15 10:30:00"}, want: "2024-01-15T10:30:00Z"},
+		{name: "datetime bytes", params: []interface{}{[]uint8(" 2024-01-15 10:30:00 ")}, want: "2024-01-15T10:30:00Z"},
+		{name: "date only", params: []interface{}{"2024-01-15"}, want: "2024-01-15T00:00:00Z"},
+		{name: "day/month/year", params: []interface{}{"15/01/2024"}, want: "2024-01-15T00:00:00Z"},
+		{name: "unix seconds", params: []interface{}{int64(1705314600)}, want: "2024-01-15T10:30:00Z"},
+		{name: "unix seconds text", params: []interface{}{"1705314600"}, want: "2024-01-15T10:30:00Z"},
+		{name: "unix milliseconds", params: []interface{}{int64(1705314600000)}, want: "2024-01-15T10:30:00Z"},
+		{name: "time.Time", params: []interface{}{time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)}, want: "2024-01-15T10:30:00Z"},
+		{name: "unparseable text", params: []interface{}{"next tuesday"}, want: null.String{}},
+		{name: "month/day/year is not a default layout", params: []interface{}{"01/15/2024"}, want: null.String{}},
+		{name: "negative epoch", params: []interface{}{-5}, want: null.String{}},
+		{name: "nil", params: []interface{}{nil}, want: null.String{}},
+		{name: "no params", params: []interface{}{}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseDateFlexible(tt.params)
+			if err != nil {
+				t.Fatalf("parseDateFlexible() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("parseDateFlexible() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("configured timezone and layouts", func(t *testing.T) {
+		jakarta := time.FixedZone("WIB", 7*60*60)
+		parse := NewOperatorRegistry(OperatorConfig{
+			Location:    jakarta,
+			DateLayouts: []string{"01/02/2006 15:04", "2006-01-02"},
+		})["parseDateFlexible"]
+
+		for input, want := range map[interface{}]interface{}{
+			"01/15/2024 10:30":     "2024-01-15T10:30:00+07:00",
+			"2024-01-15":           "2024-01-15T00:00:00+07:00",
+			int64(1705314600):      "2024-01-15T17:30:00+07:00",
+			"15/01/2024":           null.String{},
+			"2024-01-15T10:30:00Z": null.String{},
+		} {
+			result, err := parse([]interface{}{input})
+			if err != nil {
+				t.Fatalf("parseDateFlexible(%v) error = %v", input, err)
+			}
+			if result != want {
+				t.Errorf("parseDateFlexible(%v) = %v, want %v", input, result, want)
+			}
+		}
+	})
+}
+
+func TestCoalesceDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "third param used after invalid and zero dates",
+			params: []interface{}{"not a date", "0000-00-00 00:00:00", "2024-01-15 10:30:00"},
+			want:   "2024-01-15T10:30:00Z",
+		},
+		{
+			name:   "nil and zero time skipped",
+			params: []interface{}{nil, time.Time{}, []uint8("2024-01-15")},
+			want:   "2024-01-15T00:00:00Z",
+		},
+		{
+			name:   "zero date bytes and null time skipped",
+			params: []interface{}{[]uint8("0000-00-00"), null.Time{}, int64(1705314600)},
+			want:   "2024-01-15T10:30:00Z",
+		},
+		{
+			name:   "first valid date wins",
+			params: []interface{}{"2024-01-16", "2024-01-15"},
+			want:   "2024-01-16T00:00:00Z",
+		},
+		{
+			name:   "all invalid",
+			params: []interface{}{nil, "", "0000-00-00", 0, "soon"},
+			want:   null.String{},
+		},
+		{
+			name:   "no params",
+			params: []interface{}{},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := coalesceDate(tt.params)
+			if err != nil {
+				t.Fatalf("coalesceDate() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("coalesceDate() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("configured timezone", func(t *testing.T) {
+		local := NewOperatorRegistry(OperatorConfig{Location: time.FixedZone("WIB", 7*60*60)})["coalesceDate"]
+		result, err := local([]interface{}{"", time.Date(2024, 1, 15, 3, 30, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatalf("coalesceDate() error = %v", err)
+		}
+		if result != "2024-01-15T10:30:00+07:00" {
+			t.Errorf("coalesceDate() = %v, want 2024-01-15T10:30:00+07:00", result)
+		}
+	})
+}
+
+func TestDatePart(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"weekday name", []interface{}{"2024-01-15 10:30:00", "weekday"}, "Monday"},
+		{"weekday number", []interface{}{"2024-01-15 10:30:00", "weekday", "numeric"}, 1},
+		{"sunday is 7", []interface{}{"2024-01-21", "weekday", true}, 7},
+		{"numeric month", []interface{}{[]uint8("2024-03-05"), "month"}, 3},
+		{"year", []interface{}{time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), "year"}, 2023},
+		{"hour", []interface{}{"2024-01-15T10:30:00Z", "hour"}, 10},
+		{"day of month", []interface{}{int64(1705314600), "dayOfMonth"}, 15},
+		{"invalid date", []interface{}{"not a date", "year"}, null.String{}},
+		{"nil date", []interface{}{nil, "weekday"}, null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := datePart(tt.params)
+			if err != nil {
+				t.Fatalf("datePart() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("datePart() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("configured timezone", func(t *testing.T) {
+		local := NewOperatorRegistry(OperatorConfig{Location: time.FixedZone("WIB", 7*60*60)})["datePart"]
+		for _, tt := range []struct {
+			date, part string
+			want       interface{}
+		}{
+			// 20:00 UTC on Monday is 03:00 on Tuesday in Jakarta
+			{"2024-01-15T20:00:00Z", "weekday", "Tuesday"},
+			{"2024-01-15T20:00:00Z", "hour", 3},
+			// and on January 31st it is already February 1st
+			{"2024-01-31T20:00:00Z", "dayOfMonth", 1},
+			{"2024-01-31T20:00:00Z", "month", 2},
+		} {
+			result, err := local([]interface{}{tt.date, tt.part})
+			if err != nil {
+				t.Fatalf("datePart(%s) error = %v", tt.part, err)
+			}
+			if result != tt.want {
+				t.Errorf("datePart(%s, %s) = %#v, want %#v", tt.date, tt.part, result, tt.want)
+			}
+		}
+	})
+
+	for _, params := range [][]interface{}{{"2024-01-15", "minute"}, {"2024-01-15"}} {
+		if _, err := datePart(params); err == nil {
+			t.Errorf("datePart(%v) expected an error", params)
+		}
+	}
+}
+
+func TestCoalesceTracked(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{
+			name:   "first source empty, second chosen",
+			params: []interface{}{"email", "", "phone", "0812"},
+			want:   map[string]interface{}{"value": "0812", "source": "phone"},
+		},
+		{
+			name:   "first source chosen",
+			params: []interface{}{"email", "a@x.com", "phone", "0812"},
+			want:   map[string]interface{}{"value": "a@x.com", "source": "email"},
+		},
+		{
+			name:   "nil, null and blank values are skipped",
+			params: []interface{}{"a", nil, "b", null.String{}, "c", "  ", "d", []uint8("from bytes")},
+			want:   map[string]interface{}{"value": "from bytes", "source": "d"},
+		},
+		{
+			name:   "zero is a value",
+			params: []interface{}{"a", nil, "b", 0},
+			want:   map[string]interface{}{"value": 0, "source": "b"},
+		},
+		{
+			name:   "every value empty",
+			params: []interface{}{"a", nil, "b", ""},
+			want:   null.String{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := coalesceTracked(tt.params)
+			if err != nil {
+				t.Fatalf("coalesceTracked() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("coalesceTracked() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	for _, params := range [][]interface{}{{}, {"a"}, {"a", 1, "b"}} {
+		if _, err := coalesceTracked(params); err == nil {
+			t.Errorf("coalesceTracked(%v) should fail", params)
+		}
+	}
+
+	t.Run("outputFields spread value and source", func(t *testing.T) {
+		formulas := []Formula{{
+			Params:       []string{"'first_response_at' AS s1", "first_response_at", "'created_at' AS s2", "created_at"},
+			Operator:     "coalesceTracked",
+			OutputFields: []string{"value", "source"},
+			Position:     1,
+		}}
+		row := RowData{"s1": "first_response_at", "first_response_at": nil, "s2": "created_at", "created_at": "2024-01-15 10:30:00"}
+
+		result, err := TransformRow(row, formulas, GetOperatorRegistry())
+		if err != nil {
+			t.Fatalf("TransformRow() error = %v", err)
+		}
+		want := []TransformedField{
+			{Key: "value", Value: "2024-01-15 10:30:00"},
+			{Key: "source", Value: "created_at"},
+		}
+		if !reflect.DeepEqual(result.fields, want) {
+			t.Errorf("TransformRow() = %v, want %v", result.fields, want)
+		}
+	})
+}
+
+func TestCountMatching(t *testing.T) {
+	contactsJSON := `[{"contact_type":"email","contact_value":"a@x.com"},{"contact_type":"phone"},{"contact_type":"email"}]`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   int
+	}{
+		{name: "matches in JSON string", params: []interface{}{contactsJSON, "contact_type", "email"}, want: 2},
+		{name: "matches in bytes", params: []interface{}{[]uint8(contactsJSON), "contact_type", "phone"}, want: 1},
+		{name: "no matches", params: []interface{}{contactsJSON, "contact_type", "fax"}, want: 0},
+		{name: "unknown field", params: []interface{}{contactsJSON, "channel", "email"}, want: 0},
+		{name: "number matches numeric string", params: []interface{}{`[{"status":1},{"status":"1"},{"status":2}]`, "status", 1}, want: 2},
+		{name: "missing field matches nil", params: []interface{}{`[{"status":1},{}]`, "status", nil}, want: 1},
+		{name: "decoded array", params: []interface{}{[]interface{}{map[string]interface{}{"a": "x"}, "x"}, "a", "x"}, want: 1},
+		{name: "nested arrays are skipped", params: []interface{}{`[[{"contact_type":"email"}],{"contact_type":"email"}]`, "contact_type", "email"}, want: 1},
+		{name: "invalid JSON", params: []interface{}{`[{"contact_type":"email"`, "contact_type", "email"}, want: 0},
+		{name: "object instead of array", params: []interface{}{`{"contact_type":"email"}`, "contact_type", "email"}, want: 0},
+		{name: "nil input", params: []interface{}{nil, "contact_type", "email"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := countMatching(tt.params)
+			if err != nil {
+				t.Fatalf("countMatching() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("countMatching() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := countMatching([]interface{}{contactsJSON, "contact_type"}); err == nil {
+		t.Error("countMatching() with 2 params should return an error")
+	}
+	if _, err := countMatching([]interface{}{contactsJSON, "", "email"}); err == nil {
+		t.Error("countMatching() with empty field should return an error")
+	}
+}
+
+func TestJSONArrayFilter(t *testing.T) {
+	contactsJSON := `[{"contact_type":"email","contact_value":"a@x.com"},{"contact_type":"phone"},{"contact_type":"email"}]`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "matches in JSON string", params: []interface{}{contactsJSON, "contact_type", "email"}, want: `[{"contact_type":"email","contact_value":"a@x.com"},{"contact_type":"email"}]`},
+		{name: "matches in bytes", params: []interface{}{[]uint8(contactsJSON), "contact_type", "phone"}, want: `[{"contact_type":"phone"}]`},
+		{name: "no matches", params: []interface{}{contactsJSON, "contact_type", "fax"}, want: "[]"},
+		{name: "number matches numeric string", params: []interface{}{`[{"status":1},{"status":"1"},{"status":2}]`, "status", 1}, want: `[{"status":1},{"status":"1"}]`},
+		{name: "missing field matches nil", params: []interface{}{`[{"status":1},{}]`, "status", nil}, want: "[{}]"},
+		{name: "decoded array", params: []interface{}{[]interface{}{map[string]interface{}{"a": "x"}, "x"}, "a", "x"}, want: `[{"a":"x"}]`},
+		{name: "array of scalars", params: []interface{}{`["email","phone"]`, "contact_type", "email"}, want: "[]"},
+		{name: "nested arrays are dropped", params: []interface{}{`[[{"contact_type":"email"}],{"contact_type":"email"}]`, "contact_type", "email"}, want: `[{"contact_type":"email"}]`},
+		{name: "empty array", params: []interface{}{"[]", "contact_type", "email"}, want: "[]"},
+		{name: "invalid JSON", params: []interface{}{`[{"contact_type":"email"`, "contact_type", "email"}, want: null.String{}},
+		{name: "object instead of array", params: []interface{}{`{"contact_type":"email"}`, "contact_type", "email"}, want: null.String{}},
+		{name: "nil input", params: []interface{}{nil, "contact_type", "email"}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := jsonArrayFilter(tt.params)
+			if err != nil {
+				t.Fatalf("jsonArrayFilter() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("jsonArrayFilter() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := jsonArrayFilter([]interface{}{contactsJSON, "contact_type"}); err == nil {
+		t.Error("jsonArrayFilter() with 2 params should return an error")
+	}
+	if _, err := jsonArrayFilter([]interface{}{contactsJSON, "", "email"}); err == nil {
+		t.Error("jsonArrayFilter() with empty field should return an error")
+	}
+}
+
+func TestDedupeArray(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "scalars", params: []interface{}{`["vip","new","vip","new","old"]`}, want: `["vip","new","old"]`},
+		{name: "order of first occurrence", params: []interface{}{`[3,1,3,2,1]`}, want: `[3,1,2]`},
+		{name: "number and numeric string differ", params: []interface{}{`[1,"1",1]`}, want: `[1,"1"]`},
+		{name: "whole objects ignore key order", params: []interface{}{`[{"a":1,"b":2},{"b":2,"a":1},{"a":2}]`}, want: `[{"a":1,"b":2},{"a":2}]`},
+		{name: "objects by field keep the first", params: []interface{}{`[{"id":1,"n":"a"},{"id":2},{"id":1,"n":"b"}]`, "id"}, want: `[{"id":1,"n":"a"},{"id":2}]`},
+		{name: "objects without the field are kept", params: []interface{}{`[{"id":1},{"x":1},{"x":1},"id",{"id":1}]`, "id"}, want: `[{"id":1},{"x":1},{"x":1},"id"]`},
+		{name: "bytes", params: []interface{}{[]uint8(`["a","a"]`)}, want: `["a"]`},
+		{name: "empty array", params: []interface{}{`[]`}, want: `[]`},
+		{name: "object instead of array", params: []interface{}{`{"a":1}`}, want: null.String{}},
+		{name: "invalid JSON", params: []interface{}{`["a"`}, want: null.String{}},
+		{name: "nil", params: []interface{}{nil}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dedupeArray(tt.params)
+			if err != nil {
+				t.Fatalf("dedupeArray() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("dedupeArray() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestMostCommon(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{"clear winner", []interface{}{`["yes","no","yes","maybe"]`}, "yes"},
+		{"tie keeps the first seen", []interface{}{`[2,1,1,2]`}, float64(2)},
+		{"tie after a late run", []interface{}{[]interface{}{"b", "a", "a", "b"}}, "b"},
+		{"number and string differ", []interface{}{`[1,"1","1"]`}, "1"},
+		{"object field pluck", []interface{}{`[{"rating":4},{"rating":5},{"x":5},{"rating":5},{"rating":4},{"rating":5}]`, "rating"}, float64(5)},
+		{"field on scalars counts nothing", []interface{}{`[1,1]`, "rating"}, null.String{}},
+		{"nulls are skipped", []interface{}{`[null,null,"a"]`}, "a"},
+		{"bytes", []interface{}{[]uint8(`[true,false,false]`)}, false},
+		{"empty array", []interface{}{`[]`}, null.String{}},
+		{"not an array", []interface{}{`{"a":1}`}, null.String{}},
+		{"invalid JSON", []interface{}{"not json"}, null.String{}},
+		{"nil", []interface{}{nil}, null.String{}},
+		{"no params", []interface{}{}, null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := mostCommon(tt.params)
+			if err != nil {
+				t.Fatalf("mostCommon() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("mostCommon() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitToColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   []interface{}
+	}{
+		{name: "pipe delimiter", params: []interface{}{"John|Doe|VIP", "|"}, want: []interface{}{"John", "Doe", "VIP"}},
+		{name: "default comma with spaces", params: []interface{}{"a, b ,c"}, want: []interface{}{"a", "b", "c"}},
+		{name: "empty element kept", params: []interface{}{[]uint8("John||VIP"), "|"}, want: []interface{}{"John", "", "VIP"}},
+		{name: "nil source", params: []interface{}{nil, "|"}, want: nil},
+		{name: "empty source", params: []interface{}{"", "|"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := splitToColumns(tt.params)
+			if err != nil {
+				t.Fatalf("splitToColumns() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, interface{}(tt.want)) && !(tt.want == nil && result == nil) {
+				t.Errorf("splitToColumns() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	order := `{"items":[{"sku":"A","active":true,"qty":2},{"sku":"B","active":false,"qty":1},{"sku":"C","active":true,"qty":5}],"shipping":{"sku":"SHIP"}}`
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "filter expression", params: []interface{}{order, "$.items[?(@.active==true)].sku"}, want: []interface{}{"A", "C"}},
+		{name: "filter with single match", params: []interface{}{order, "$.items[?(@.qty == 1)].sku"}, want: "B"},
+		{name: "wildcard", params: []interface{}{[]uint8(order), "$.items[*].sku"}, want: []interface{}{"A", "B", "C"}},
+		{name: "object match", params: []interface{}{order, "$.shipping"}, want: map[string]interface{}{"sku": "SHIP"}},
+		{name: "decoded input", params: []interface{}{map[string]interface{}{"a": map[string]interface{}{"b": "x"}}, "$.a.b"}, want: "x"},
+		{name: "no match", params: []interface{}{order, "$.customer.name"}, want: null.String{}},
+		{name: "invalid path", params: []interface{}{order, "$.items[?(@.active=="}, want: null.String{}},
+		{name: "invalid JSON", params: []interface{}{`{"items":`, "$.items"}, want: null.String{}},
+		{name: "nil value", params: []interface{}{nil, "$.items"}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := jsonPath(tt.params)
+			if err != nil {
+				t.Fatalf("jsonPath() error = %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("jsonPath() = %#v, want %#v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("recursive descent", func(t *testing.T) {
+		result, _ := jsonPath([]interface{}{order, "$..sku"})
+		skus, ok := result.([]interface{})
+		if !ok || len(skus) != 4 {
+			t.Errorf("jsonPath() = %#v, want 4 skus", result)
+		}
+	})
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{name: "map with sorted keys", params: []interface{}{map[string]interface{}{"b": 1, "a": "x", "c": nil}}, want: `{"a":"x","b":1,"c":null}`},
+		{name: "slice of maps", params: []interface{}{[]map[string]interface{}{{"contact_type": "email"}, {"contact_type": "phone"}}}, want: `[{"contact_type":"email"},{"contact_type":"phone"}]`},
+		{name: "slice of scalars", params: []interface{}{[]interface{}{"a", 2, true}}, want: `["a",2,true]`},
+		{name: "string scalar", params: []interface{}{`say "hi"`}, want: `"say \"hi\""`},
+		{name: "number scalar", params: []interface{}{int64(42)}, want: `42`},
+		{name: "bytes as text", params: []interface{}{[]uint8("raw")}, want: `"raw"`},
+		{name: "valid null type", params: []interface{}{null.StringFrom("x")}, want: `"x"`},
+		{name: "nil", params: []interface{}{nil}, want: ""},
+		{name: "invalid null type", params: []interface{}{null.Int{}}, want: ""},
+		{name: "no params", params: []interface{}{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := toJSON(tt.params)
+			if err != nil {
+				t.Fatalf("toJSON() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("toJSON() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := toJSON([]interface{}{make(chan int)}); err == nil {
+		t.Error("toJSON() with a channel should return an error")
+	}
+}
+
+func TestMaskFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "identifier keeps separator", params: []interface{}{"AB12-CD34"}, want: "XXXX-XXXX"},
+		{name: "identifier with reveal", params: []interface{}{"AB12-CD34", "*", 2}, want: "****-**34"},
+		{name: "reveal skips separators", params: []interface{}{"AB12-CD34", "*", "5"}, want: "***2-CD34"},
+		{name: "all digits", params: []interface{}{"1234567890"}, want: "XXXXXXXXXX"},
+		{name: "all digits with reveal", params: []interface{}{int64(1234567890), "#", 4}, want: "######7890"},
+		{name: "spaces kept", params: []interface{}{"John  Doe Jr."}, want: "XXXX  XXX XX."},
+		{name: "bytes and multi-byte mask", params: []interface{}{[]uint8("ab/12"), "•"}, want: "••/••"},
+		{name: "unicode letters", params: []interface{}{"Zoë-7"}, want: "XXX-X"},
+		{name: "empty mask keeps default", params: []interface{}{"a1", ""}, want: "XX"},
+		{name: "reveal longer than value", params: []interface{}{"A-1", nil, 10}, want: "A-1"},
+		{name: "no letters or digits", params: []interface{}{"--/"}, want: "--/"},
+		{name: "nil", params: []interface{}{nil}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := maskFormat(tt.params)
+			if err != nil {
+				t.Fatalf("maskFormat() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("maskFormat() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "email", params: []interface{}{"john.doe@example.com", "email"}, want: "j***@example.com"},
+		{name: "email in text", params: []interface{}{"reply to a@b.co or x_y@mail.example.org", "email"}, want: "reply to a***@b.co or x***@mail.example.org"},
+		{name: "international phone", params: []interface{}{"+62 812-3456-7890", "phone"}, want: "+** ***-****-7890"},
+		{name: "phone in text", params: []interface{}{"call (021) 555-1234 today", "phone"}, want: "call (***) ***-1234 today"},
+		{name: "short number is not a phone", params: []interface{}{"order 12-34", "phone"}, want: "order 12-34"},
+		{name: "card with spaces", params: []interface{}{"4111 1111 1111 1111", "card"}, want: "**** **** **** 1111"},
+		{name: "card with dashes in text", params: []interface{}{"paid with 5500-0000-0000-0004.", "card"}, want: "paid with ****-****-****-0004."},
+		{name: "card without separators", params: []interface{}{"4111111111111111", "card"}, want: "************1111"},
+		{name: "nric", params: []interface{}{"NRIC S1234567D", "nric"}, want: "NRIC S*******D"},
+		{name: "nik", params: []interface{}{"NIK 3201014509900001", "nik"}, want: "NIK ************0001"},
+		{name: "invalid nik is kept", params: []interface{}{"3201013102900001", "nik"}, want: "3201013102900001"},
+		{name: "ssn", params: []interface{}{"123-45-6789", "ssn"}, want: "***-**-6789"},
+		{name: "no match", params: []interface{}{"nothing to hide", "email"}, want: "nothing to hide"},
+		{name: "bytes", params: []interface{}{[]uint8("a.b@c.io"), "email"}, want: "a***@c.io"},
+		{name: "nil", params: []interface{}{nil, "email"}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := redact(tt.params)
+			if err != nil {
+				t.Fatalf("redact() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("redact() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := redact([]interface{}{"john@example.com", "passport"}); err == nil {
+		t.Error("redact() with an unknown rule should return an error")
+	}
+	if _, err := redact([]interface{}{"john@example.com"}); err == nil {
+		t.Error("redact() without a rule should return an error")
+	}
+}
+
+func TestValidateNationalId(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "valid man", params: []interface{}{"3171011203850002"}, want: true},
+		{name: "valid woman", params: []interface{}{"3201014509900001"}, want: true},
+		{name: "spaces and dots", params: []interface{}{"3201 0112.0590-0001"}, want: true},
+		{name: "number", params: []interface{}{int64(3201011205900001)}, want: true},
+		{name: "29 February", params: []interface{}{"3201012902920001"}, want: true},
+		{name: "too short", params: []interface{}{"320101120590001"}, want: false},
+		{name: "too long", params: []interface{}{"32010112059000011"}, want: false},
+		{name: "letters", params: []interface{}{"32010112059A0001"}, want: false},
+		{name: "31 February", params: []interface{}{"3201013102900001"}, want: false},
+		{name: "29 February in a common year", params: []interface{}{"3201012902910001"}, want: false},
+		{name: "month 13", params: []interface{}{"3201011213900001"}, want: false},
+		{name: "day 00", params: []interface{}{"3201010005900001"}, want: false},
+		{name: "day 72", params: []interface{}{"3201017205900001"}, want: false},
+		{name: "province 00", params: []interface{}{"0001011205900001"}, want: false},
+		{name: "serial 0000", params: []interface{}{"3201011205900000"}, want: false},
+		{name: "empty", params: []interface{}{""}, want: false},
+		{name: "nil", params: []interface{}{nil}, want: null.Bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := validateNationalId(tt.params)
+			if err != nil {
+				t.Fatalf("validateNationalId() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("validateNationalId() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestHexAndBase32(t *testing.T) {
+	pairs := []struct {
+		name   string
+		encode OperatorFunc
+		decode OperatorFunc
+	}{
+		{name: "hex", encode: hexEncode, decode: hexDecode},
+		{name: "base32", encode: base32Encode, decode: base32Decode},
+	}
+	values := []interface{}{"TKT-42", int64(1234567890), []uint8("ticket/7?x=1"), "tiket café", "a", ""}
+
+	for _, pair := range pairs {
+		t.Run(pair.name+" round trip", func(t *testing.T) {
+			for _, value := range values {
+				encoded, err := pair.encode([]interface{}{value})
+				if err != nil {
+					t.Fatalf("%sEncode(%v) error = %v", pair.name, value, err)
+				}
+				decoded, err := pair.decode([]interface{}{encoded})
+				if err != nil {
+					t.Fatalf("%sDecode(%v) error = %v", pair.name, encoded, err)
+				}
+				if decoded != toString(value) {
+					t.Errorf("%sDecode(%sEncode(%v)) = %v, want %v", pair.name, pair.name, value, decoded, toString(value))
+				}
+			}
+		})
+	}
+
+	tests := []struct {
+		name string
+		fn   OperatorFunc
+		in   interface{}
+		want interface{}
+	}{
+		{name: "hexEncode", fn: hexEncode, in: "TKT-42", want: "544b542d3432"},
+		{name: "hexEncode number", fn: hexEncode, in: 42, want: "3432"},
+		{name: "hexEncode nil", fn: hexEncode, in: nil, want: null.String{}},
+		{name: "hexDecode uppercase", fn: hexDecode, in: " 544B542D3432 ", want: "TKT-42"},
+		{name: "hexDecode odd length", fn: hexDecode, in: "544b5", want: null.String{}},
+		{name: "hexDecode non-hex character", fn: hexDecode, in: "54xb", want: null.String{}},
+		{name: "hexDecode nil", fn: hexDecode, in: nil, want: null.String{}},
+		{name: "base32Encode", fn: base32Encode, in: "TKT-42", want: "KRFVILJUGI"},
+		{name: "base32Encode nil", fn: base32Encode, in: nil, want: null.String{}},
+		{name: "base32Decode lowercase with padding", fn: base32Decode, in: "krfviljugi======", want: "TKT-42"},
+		{name: "base32Decode digit outside the alphabet", fn: base32Decode, in: "KRFV1LJUGI", want: null.String{}},
+		{name: "base32Decode impossible length", fn: base32Decode, in: "KRF", want: null.String{}},
+		{name: "base32Decode nil", fn: base32Decode, in: nil, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.fn([]interface{}{tt.in})
+			if err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+			if result != tt.want {
+				t.Errorf("%s(%v) = %v, want %v", tt.name, tt.in, result, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessDuration(t *testing.T) {
+	businessDuration := NewOperatorRegistry(OperatorConfig{})["businessDuration"]
+
+	// 2024-01-05 is a Friday, 2024-01-08 a Monday
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "spanning a weekend", params: []interface{}{"2024-01-05 16:00:00", "2024-01-08 10:00:00"}, want: "02:00:00"},
+		{name: "spanning an overnight gap", params: []interface{}{"2024-01-08 16:30:00", "2024-01-09 09:15:00"}, want: "00:45:00"},
+		{name: "within one day", params: []interface{}{"2024-01-08 10:00:00", "2024-01-08 12:30:45"}, want: "02:30:45"},
+		{name: "before opening to after closing", params: []interface{}{"2024-01-08 07:00:00", "2024-01-08 19:00:00"}, want: "08:00:00"},
+		{name: "entirely on a weekend", params: []interface{}{"2024-01-06 10:00:00", "2024-01-07 18:00:00"}, want: "00:00:00"},
+		{name: "reversed order", params: []interface{}{"2024-01-08 10:00:00", "2024-01-05 16:00:00"}, want: "02:00:00"},
+		{name: "two full weeks", params: []interface{}{"2024-01-08 09:00:00", "2024-01-22 09:00:00"}, want: "80:00:00"},
+		{name: "unix seconds", params: []interface{}{int64(1704470400), int64(1704708000)}, want: "02:00:00"},
+		{name: "missing end", params: []interface{}{"2024-01-08 10:00:00", nil}, want: null.String{}},
+		{name: "invalid start", params: []interface{}{"soon", "2024-01-08 10:00:00"}, want: null.String{}},
+		{name: "no params", params: []interface{}{}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := businessDuration(tt.params)
+			if err != nil {
+				t.Fatalf("businessDuration() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("businessDuration() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+
+	t.Run("business hours in the tenant timezone", func(t *testing.T) {
+		jakarta := time.FixedZone("WIB", 7*60*60)
+		op := NewOperatorRegistry(OperatorConfig{Location: jakarta})["businessDuration"]
+
+		// 01:00-03:00 UTC is 08:00-10:00 in Jakarta, of which 09:00-10:00 counts
+		result, err := op([]interface{}{"2024-01-08T01:00:00Z", "2024-01-08T03:00:00Z"})
+		if err != nil {
+			t.Fatalf("businessDuration() error = %v", err)
+		}
+		if result != "01:00:00" {
+			t.Errorf("businessDuration() = %v, want 01:00:00", result)
+		}
+	})
+
+	t.Run("custom business hours", func(t *testing.T) {
+		hours, err := ParseBusinessHours("08:00-12:00", "mon,wed-thu")
+		if err != nil {
+			t.Fatalf("ParseBusinessHours() error = %v", err)
+		}
+		op := NewOperatorRegistry(OperatorConfig{BusinessHours: hours})["businessDuration"]
+
+		// Mon 08:00 to Fri 08:00: Monday, Wednesday and Thursday count 4 hours each
+		result, err := op([]interface{}{"2024-01-08 08:00:00", "2024-01-12 08:00:00"})
+		if err != nil {
+			t.Fatalf("businessDuration() error = %v", err)
+		}
+		if result != "12:00:00" {
+			t.Errorf("businessDuration() = %v, want 12:00:00", result)
+		}
+	})
+}
+
+func TestParseBusinessHours(t *testing.T) {
+	hours, err := ParseBusinessHours("08:30-17:00", "sat-mon")
+	if err != nil {
+		t.Fatalf("ParseBusinessHours() error = %v", err)
+	}
+	if hours.Start != 8*time.Hour+30*time.Minute || hours.End != 17*time.Hour {
+		t.Errorf("hours = %v-%v, want 8h30m-17h", hours.Start, hours.End)
+	}
+	wantDays := []time.Weekday{time.Saturday, time.Sunday, time.Monday}
+	if !reflect.DeepEqual(hours.Days, wantDays) {
+		t.Errorf("days = %v, want %v", hours.Days, wantDays)
+	}
+
+	defaults, err := ParseBusinessHours("", "")
+	if err != nil || !reflect.DeepEqual(defaults, DefaultBusinessHours()) {
+		t.Errorf("ParseBusinessHours(\"\", \"\") = %+v, %v, want defaults", defaults, err)
+	}
+
+	for _, invalid := range [][2]string{{"09:00", ""}, {"17:00-09:00", ""}, {"9am-5pm", ""}, {"", "mon-funday"}} {
+		if _, err := ParseBusinessHours(invalid[0], invalid[1]); err == nil {
+			t.Errorf("ParseBusinessHours(%q, %q) should return an error", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestSubstituteTemplate(t *testing.T) {
+	row := map[string]interface{}{
+		"ticket_no":     []uint8("TKT-000001"),
+		"customer_name": "Budi",
+		"status":        "open",
+		"priority":      null.String{},
+		"created_at":    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name   string
+		params []interface{}
+		want   interface{}
+	}{
+		{name: "multiple fields", params: []interface{}{"Ticket {{.ticket_no}} for {{.customer_name}} ({{.status}})", row}, want: "Ticket TKT-000001 for Budi (open)"},
+		{name: "missing field renders empty", params: []interface{}{"{{.customer_name}}/{{.missing}}/", row}, want: "Budi//"},
+		{name: "null field renders empty", params: []interface{}{"[{{.priority}}]", row}, want: "[]"},
+		{name: "date field", params: []interface{}{"since {{.created_at}}", row}, want: "since 2025-01-02T03:04:05Z"},
+		{name: "template functions", params: []interface{}{`{{if eq .status "open"}}Open{{else}}Closed{{end}} {{printf "%.3s" .customer_name}}`, row}, want: "Open Bud"},
+		{name: "template from bytes", params: []interface{}{[]uint8("#{{.ticket_no}}"), row}, want: "#TKT-000001"},
+		{name: "no row context", params: []interface{}{"static {{.status}}"}, want: "static "},
+		{name: "parse error", params: []interface{}{"{{.unclosed", row}, want: null.String{}},
+		{name: "execution error", params: []interface{}{"{{.status.name}}", row}, want: null.String{}},
+		{name: "nil template", params: []interface{}{nil, row}, want: null.String{}},
+		{name: "no params", params: []interface{}{}, want: null.String{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := substituteTemplate(tt.params)
+			if err != nil {
+				t.Fatalf("substituteTemplate() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("substituteTemplate() = %v, want %v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubstituteTemplate_Hostile(t *testing.T) {
+	row := map[string]interface{}{
+		"status": "open",
+		"big":    strings.Repeat("x", maxTemplateOutput/2+1),
+	}
+
+	rejected := map[string]string{
+		"nested range over int": "{{range 1000000000}}{{range 1000000000}}x{{end}}{{end}}",
+		"range in else branch":  `{{if eq .status "closed"}}{{else}}{{range 10}}x{{end}}{{end}}`,
+		"with":                  "{{with .status}}{{.}}{{end}}",
+		"define and template":   `{{define "x"}}{{template "x"}}{{end}}{{template "x"}}`,
+		"block":                 `{{block "x" .}}{{.status}}{{end}}`,
+		"huge printf width":     `{{printf "%1000000000d" 1}}`,
+		"huge printf precision": `{{printf "%.1000000000f" 1.0}}`,
+		"star printf width":     `{{printf "%*d" 1000000000 1}}`,
+		"output over the limit": "{{.big}}{{.big}}",
+	}
+	for name, text := range rejected {
+		t.Run(name, func(t *testing.T) {
+			done := make(chan interface{}, 1)
+			go func() {
+				result, _ := substituteTemplate([]interface{}{text, row})
+				done <- result
+			}()
+			select {
+			case result := <-done:
+				if result != (null.String{}) {
+					t.Errorf("substituteTemplate(%q) = %v, want null", text, result)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("substituteTemplate(%q) did not return", text)
+			}
+		})
+	}
+
+	result, err := substituteTemplate([]interface{}{"{{.big}}", row})
+	if err != nil || result != row["big"] {
+		t.Errorf("substituteTemplate() under the limit = %v, %v, want the field", len(toString(result)), err)
+	}
+}
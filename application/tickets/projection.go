@@ -0,0 +1,100 @@
+package tickets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateFields checks the projected output fields of a payload. Names are
+// checked against the formulas here; without formulas the output fields are
+// the table columns, which newProjection checks once they are known.
+func validateFields(payload *QueryPayload) error {
+	outputs := make(map[string]bool)
+	for _, formula := range payload.Formulas {
+		for _, name := range formula.OutputNames() {
+			outputs[name] = true
+		}
+	}
+
+	seen := make(map[string]bool, len(payload.Fields))
+	for i, field := range payload.Fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("invalid fields at index %d: name is required", i)
+		}
+		if len(payload.Formulas) > 0 && !outputs[field] {
+			return fmt.Errorf("invalid fields at index %d: '%s' is not an output field", i, field)
+		}
+		if seen[field] {
+			return fmt.Errorf("invalid fields at index %d: '%s' is listed twice", i, field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
+
+// projection is the set of output fields streamed to the client; nil keeps
+// every field
+type projection map[string]bool
+
+// newProjection returns the projection of fields over rows with the output
+// fields of formulas; nil when fields is empty
+func newProjection(fields []string, formulas []Formula) (projection, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	for _, name := range outputFieldNames(formulas) {
+		known[name] = true
+	}
+
+	p := make(projection, len(fields))
+	for _, field := range fields {
+		if !known[field] {
+			return nil, fmt.Errorf("field '%s' is not an output field", field)
+		}
+		p[field] = true
+	}
+	return p, nil
+}
+
+// apply returns the names kept by p, in their order
+func (p projection) apply(names []string) []string {
+	if p == nil {
+		return names
+	}
+	kept := make([]string, 0, len(p))
+	for _, name := range names {
+		if p[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// projectionEncoder drops the fields outside the projection before the
+// wrapped encoder writes a row. It runs after the transform, so formulas the
+// kept fields do not show still run (and feed the summary row).
+type projectionEncoder struct {
+	rowEncoder
+	fields projection
+	kept   []TransformedField // reused per row; encoders run on one goroutine
+}
+
+// withProjection wraps encoder to apply fields (encoder itself for nil)
+func withProjection(encoder rowEncoder, fields projection) rowEncoder {
+	if fields == nil {
+		return encoder
+	}
+	return &projectionEncoder{rowEncoder: encoder, fields: fields}
+}
+
+func (e *projectionEncoder) appendRow(buf []byte, row TransformedRow) ([]byte, error) {
+	e.kept = e.kept[:0]
+	for _, field := range row.fields {
+		if e.fields[field.Key] {
+			e.kept = append(e.kept, field)
+		}
+	}
+	return e.rowEncoder.appendRow(buf, TransformedRow{fields: e.kept})
+}
@@ -14,6 +14,7 @@ type QueryBuilder struct {
 	orderBy    []string
 	limit      int
 	offset     int
+	union      *UnionClause
 }
 
 // NewQueryBuilder creates a new QueryBuilder
@@ -24,6 +25,7 @@ func NewQueryBuilder(payload *QueryPayload) *QueryBuilder {
 		orderBy:   payload.OrderBy,
 		limit:     payload.GetLimit(),   // Use getter for default handling
 		offset:    payload.GetOffset(),
+		union:     payload.Union,
 	}
 }
 
@@ -32,45 +34,31 @@ func (qb *QueryBuilder) SetSelectColumns(cols []string) {
 	qb.selectCols = cols
 }
 
+// SetLimit overrides the payload limit for the SELECT query (0 means no limit)
+func (qb *QueryBuilder) SetLimit(limit int) {
+	qb.limit = limit
+}
+
+// SetOrderBy overrides the payload ordering with a [field, direction] pair
+func (qb *QueryBuilder) SetOrderBy(orderBy []string) {
+	qb.orderBy = orderBy
+}
+
+// SetWhere overrides the payload WHERE conditions, e.g. with one chunk of a
+// long IN list
+func (qb *QueryBuilder) SetWhere(where []WhereClause) {
+	qb.where = where
+}
+
 // BuildSelectQuery builds the main SELECT query with parameters
 func (qb *QueryBuilder) BuildSelectQuery() (string, []interface{}) {
 	var query strings.Builder
 	var args []interface{}
 
-	// SELECT clause
-	query.WriteString("SELECT ")
-	if len(qb.selectCols) == 0 {
-		query.WriteString("*")
-	} else {
-		// Use backticks to safely quote column names, but pass through SQL expressions
-		quotedCols := make([]string, len(qb.selectCols))
-		for i, col := range qb.selectCols {
-			if isSQLExpression(col) {
-				// SQL expression - use as-is
-				quotedCols[i] = col
-			} else {
-				// Regular column - quote it
-				quotedCols[i] = quoteIdentifier(col)
-			}
-		}
-		query.WriteString(strings.Join(quotedCols, ", "))
-	}
-
-	// FROM clause
-	query.WriteString(" FROM ")
-	query.WriteString(quoteIdentifier(qb.tableName))
-
-	// WHERE clause
-	if len(qb.where) > 0 {
-		query.WriteString(" WHERE ")
-		whereParts := make([]string, len(qb.where))
-		for i, where := range qb.where {
-			whereParts[i], args = qb.buildWhereClause(where, args)
-		}
-		query.WriteString(strings.Join(whereParts, " AND "))
-	}
+	// SELECT ... FROM ... WHERE, plus the UNION sub-query if declared
+	args = qb.buildSelectFrom(&query, args)
 
-	// ORDER BY clause
+	// ORDER BY clause (applies to the combined result for UNION)
 	if len(qb.orderBy) > 0 && len(qb.orderBy) == 2 {
 		query.WriteString(" ORDER BY ")
 		query.WriteString(quoteIdentifier(qb.orderBy[0]))
@@ -98,6 +86,14 @@ func (qb *QueryBuilder) BuildCountQuery() (string, []interface{}) {
 	var query strings.Builder
 	var args []interface{}
 
+	// UNION: count the combined rows so UNION de-duplication is respected
+	if qb.union != nil {
+		query.WriteString("SELECT COUNT(*) FROM (")
+		args = qb.buildSelectFrom(&query, args)
+		query.WriteString(") AS `union_rows`")
+		return query.String(), args
+	}
+
 	// SELECT COUNT(*)
 	query.WriteString("SELECT COUNT(*) FROM ")
 	query.WriteString(quoteIdentifier(qb.tableName))
@@ -158,6 +154,61 @@ func (qb *QueryBuilder) BuildSampleQuery() (string, []interface{}) {
 	return query.String(), args
 }
 
+// buildSelectFrom writes "SELECT cols FROM table WHERE ..." and, when a UNION
+// is declared, the second SELECT over the same columns
+func (qb *QueryBuilder) buildSelectFrom(query *strings.Builder, args []interface{}) []interface{} {
+	args = qb.writeSelect(query, qb.tableName, qb.where, args)
+
+	if qb.union != nil {
+		if qb.union.All {
+			query.WriteString(" UNION ALL ")
+		} else {
+			query.WriteString(" UNION ")
+		}
+		args = qb.writeSelect(query, qb.union.TableName, qb.union.Where, args)
+	}
+
+	return args
+}
+
+// writeSelect writes a single SELECT over the builder's select columns
+func (qb *QueryBuilder) writeSelect(query *strings.Builder, tableName string, where []WhereClause, args []interface{}) []interface{} {
+	// SELECT clause
+	query.WriteString("SELECT ")
+	if len(qb.selectCols) == 0 {
+		query.WriteString("*")
+	} else {
+		// Use backticks to safely quote column names, but pass through SQL expressions
+		quotedCols := make([]string, len(qb.selectCols))
+		for i, col := range qb.selectCols {
+			if isSQLExpression(col) {
+				// SQL expression - use as-is
+				quotedCols[i] = col
+			} else {
+				// Regular column - quote it
+				quotedCols[i] = quoteIdentifier(col)
+			}
+		}
+		query.WriteString(strings.Join(quotedCols, ", "))
+	}
+
+	// FROM clause
+	query.WriteString(" FROM ")
+	query.WriteString(quoteIdentifier(tableName))
+
+	// WHERE clause
+	if len(where) > 0 {
+		query.WriteString(" WHERE ")
+		whereParts := make([]string, len(where))
+		for i, clause := range where {
+			whereParts[i], args = qb.buildWhereClause(clause, args)
+		}
+		query.WriteString(strings.Join(whereParts, " AND "))
+	}
+
+	return args
+}
+
 // buildWhereClause builds a single WHERE clause with parameter binding
 func (qb *QueryBuilder) buildWhereClause(where WhereClause, args []interface{}) (string, []interface{}) {
 	var clause strings.Builder
@@ -186,6 +237,9 @@ func (qb *QueryBuilder) buildWhereClause(where WhereClause, args []interface{})
 			clause.WriteString("(?)")
 			args = append(args, where.Value)
 		}
+	} else if ref, ok := where.Value.(ColumnRef); ok {
+		// Column-to-column comparison: emit the quoted column, nothing to bind
+		clause.WriteString(quoteIdentifier(ref.Column))
 	} else {
 		// Standard operators: use parameter binding
 		clause.WriteString("?")
@@ -195,6 +249,12 @@ func (qb *QueryBuilder) buildWhereClause(where WhereClause, args []interface{})
 	return clause.String(), args
 }
 
+// isBlankColumn reports whether param is empty once quoted: blank, or only
+// backticks and spaces
+func isBlankColumn(param string) bool {
+	return strings.TrimSpace(strings.ReplaceAll(param, "`", "")) == ""
+}
+
 // quoteIdentifier safely quotes a SQL identifier (table or column name)
 func quoteIdentifier(identifier string) string {
 	// Use backticks for SQLite/MySQL compatibility
@@ -256,7 +316,9 @@ func extractAlias(expression string) string {
 }
 
 // GenerateUniqueSelectList generates a unique, deterministic list of columns
-// from formulas' params, sorted by formula position
+// from formulas' params, sorted by formula position. Blank params are
+// skipped; when no column remains the list is empty and the query builder
+// selects * instead of emitting an empty SELECT list.
 func GenerateUniqueSelectList(formulas []Formula) []string {
 	// First, sort formulas by position
 	sortedFormulas := make([]Formula, len(formulas))
@@ -271,6 +333,9 @@ func GenerateUniqueSelectList(formulas []Formula) []string {
 
 	for _, formula := range sortedFormulas {
 		for _, param := range formula.Params {
+			if isBlankColumn(param) {
+				continue
+			}
 			if !seen[param] {
 				seen[param] = true
 				selectList = append(selectList, param)
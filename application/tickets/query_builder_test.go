@@ -84,6 +84,62 @@ func TestQueryBuilder_BuildCountQuery(t *testing.T) {
 	}
 }
 
+func TestQueryBuilder_Union(t *testing.T) {
+	limit := 10
+	payload := &QueryPayload{
+		TableName: "tickets",
+		OrderBy:   []string{"id", "asc"},
+		Limit:     &limit,
+		Where: []WhereClause{
+			{Field: "status", Operator: "=", Value: "closed"},
+		},
+		Union: &UnionClause{
+			TableName: "report_ticket",
+			Where: []WhereClause{
+				{Field: "priority", Operator: "=", Value: "high"},
+			},
+			All: true,
+		},
+	}
+
+	qb := NewQueryBuilder(payload)
+	qb.SetSelectColumns([]string{"id", "status"})
+
+	t.Run("select query", func(t *testing.T) {
+		query, args := qb.BuildSelectQuery()
+
+		expectedQuery := "SELECT `id`, `status` FROM `tickets` WHERE `status` = ? UNION ALL SELECT `id`, `status` FROM `report_ticket` WHERE `priority` = ? ORDER BY `id` ASC LIMIT ?"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+
+		expectedArgs := []interface{}{"closed", "high", 10}
+		if len(args) != len(expectedArgs) {
+			t.Fatalf("Expected args %v, got %v", expectedArgs, args)
+		}
+		for i, want := range expectedArgs {
+			if args[i] != want {
+				t.Errorf("Expected arg %d to be %v, got %v", i, want, args[i])
+			}
+		}
+	})
+
+	t.Run("count query counts the combined rows", func(t *testing.T) {
+		payload.Union.All = false
+		defer func() { payload.Union.All = true }()
+
+		query, args := qb.BuildCountQuery()
+
+		expectedQuery := "SELECT COUNT(*) FROM (SELECT `id`, `status` FROM `tickets` WHERE `status` = ? UNION SELECT `id`, `status` FROM `report_ticket` WHERE `priority` = ?) AS `union_rows`"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+		if len(args) != 2 {
+			t.Errorf("Expected 2 args, got %v", args)
+		}
+	})
+}
+
 func TestGenerateUniqueSelectList(t *testing.T) {
 	formulas := []Formula{
 		{
@@ -121,6 +177,32 @@ func TestGenerateUniqueSelectList(t *testing.T) {
 	}
 }
 
+func TestGenerateUniqueSelectList_NoColumns(t *testing.T) {
+	formulas := []Formula{
+		{Params: []string{"", "``"}, Field: "a", Position: 1},
+		{Params: []string{" "}, Field: "b", Position: 2},
+	}
+
+	selectList := GenerateUniqueSelectList(formulas)
+	if len(selectList) != 0 {
+		t.Fatalf("Expected no columns, got %q", selectList)
+	}
+
+	// An empty list selects every column rather than "SELECT  FROM"
+	qb := NewQueryBuilder(&QueryPayload{TableName: "tickets"})
+	qb.SetSelectColumns(selectList)
+	query, _ := qb.BuildSelectQuery()
+	if !strings.HasPrefix(query, "SELECT * FROM `tickets`") {
+		t.Errorf("Expected SELECT * fallback, got %q", query)
+	}
+
+	// Blank params are dropped next to real columns
+	formulas = append(formulas, Formula{Params: []string{"id", ""}, Field: "id", Position: 3})
+	if selectList := GenerateUniqueSelectList(formulas); len(selectList) != 1 || selectList[0] != "id" {
+		t.Errorf("Expected [id], got %q", selectList)
+	}
+}
+
 func TestSortFormulas(t *testing.T) {
 	formulas := []Formula{
 		{Field: "third", Position: 3},
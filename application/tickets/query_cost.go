@@ -0,0 +1,147 @@
+package tickets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrQueryTooExpensive is returned by CheckQueryCost for a query the database
+// estimates to examine more rows than the configured maximum
+var ErrQueryTooExpensive = errors.New("query too expensive")
+
+// SetMaxQueryRows rejects SELECTs that the database estimates will examine
+// more than maxRows rows. CheckQueryCost runs EXPLAIN to get the estimate
+// before the query runs. 0 disables the check.
+func (r *Repository) SetMaxQueryRows(maxRows int64) {
+	r.maxQueryRows = maxRows
+}
+
+// CheckQueryCost runs EXPLAIN for a SELECT on the handle ExecuteQuery uses
+// and returns an ErrQueryTooExpensive error when its estimated rows exceed
+// the maximum set with SetMaxQueryRows. Without a maximum it does nothing,
+// and so it does on databases other than MySQL, whose EXPLAIN (e.g. the
+// SQLite bytecode listing) has no rows estimate.
+func (r *Repository) CheckQueryCost(ctx context.Context, query string, args []interface{}) error {
+	if r.maxQueryRows <= 0 {
+		return nil
+	}
+
+	db := r.selectDB()
+	if db.Dialector.Name() != "mysql" {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+
+	limiter := connLimiterFrom(ctx)
+	if err := limiter.acquire(ctx); err != nil {
+		return fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer limiter.release()
+
+	explainCtx := ctx
+	if r.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		explainCtx, cancel = context.WithTimeout(ctx, r.queryTimeout)
+		defer cancel()
+	}
+
+	rows, err := sqlDB.QueryContext(explainCtx, "EXPLAIN "+query, args...)
+	if err != nil {
+		if explainCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return fmt.Errorf("failed to explain query: %w after %v", ErrQueryTimeout, r.queryTimeout)
+		}
+		return fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	estimate, err := estimateRows(rows)
+	if err != nil {
+		return fmt.Errorf("failed to explain query: %w", err)
+	}
+	if estimate > r.maxQueryRows {
+		return fmt.Errorf("%w: an estimated %d rows to examine, at most %d allowed; narrow the where clauses", ErrQueryTooExpensive, estimate, r.maxQueryRows)
+	}
+	return nil
+}
+
+// estimateRows returns the rows a MySQL EXPLAIN plan expects to examine. The
+// tables of one SELECT (same id) are joined in nested loops, so their
+// estimates multiply; the SELECTs of a UNION add up. Plan rows without an
+// estimate (e.g. the UNION RESULT) are skipped.
+func estimateRows(rows *sql.Rows) (int64, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	idColumn, rowsColumn := -1, -1
+	for i, column := range columns {
+		switch strings.ToLower(column) {
+		case "id":
+			idColumn = i
+		case "rows":
+			rowsColumn = i
+		}
+	}
+	if rowsColumn < 0 {
+		return 0, fmt.Errorf("EXPLAIN returned no rows estimate (columns %v)", columns)
+	}
+
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	selects := make(map[string]int64) // estimated rows per SELECT id
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, err
+		}
+		if !values[rowsColumn].Valid {
+			continue
+		}
+		estimate, err := strconv.ParseInt(values[rowsColumn].String, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid EXPLAIN rows estimate %q", values[rowsColumn].String)
+		}
+
+		var id string
+		if idColumn >= 0 {
+			id = values[idColumn].String
+		}
+		if product, ok := selects[id]; ok {
+			estimate = saturatingMul(product, estimate)
+		}
+		selects[id] = estimate
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, estimate := range selects {
+		if total > math.MaxInt64-estimate {
+			return math.MaxInt64, nil
+		}
+		total += estimate
+	}
+	return total, nil
+}
+
+// saturatingMul returns a*b for non-negative a and b, or math.MaxInt64 when
+// the product overflows
+func saturatingMul(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
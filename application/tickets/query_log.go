@@ -0,0 +1,82 @@
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// QueryLogMode is what the query debug log records of every executed query
+type QueryLogMode string
+
+const (
+	// QueryLogOff logs no queries (default)
+	QueryLogOff QueryLogMode = ""
+	// QueryLogArgs logs the SQL with its bound args. Args hold filter values
+	// such as emails or phone numbers, so never use it in production.
+	QueryLogArgs QueryLogMode = "args"
+	// QueryLogRedacted logs the SQL with the type of each bound arg only
+	QueryLogRedacted QueryLogMode = "redacted"
+)
+
+// ParseQueryLogMode returns the QueryLogMode named name ("off", "args" or
+// "redacted", case-insensitive; "" and "false" are off, "true" is args); ok
+// is false for other names
+func ParseQueryLogMode(name string) (QueryLogMode, bool) {
+	switch mode := QueryLogMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "off", "false", QueryLogOff:
+		return QueryLogOff, true
+	case "true", QueryLogArgs:
+		return QueryLogArgs, true
+	case QueryLogRedacted:
+		return QueryLogRedacted, true
+	default:
+		return "", false
+	}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID logged with the
+// queries run under it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// requestIDFrom returns the request ID of ctx, "" when it has none
+func requestIDFrom(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// logQuery logs a query about to run at debug level, with its request ID and
+// its args as the query log mode allows. kind is "select" or "count".
+func (r *Repository) logQuery(ctx context.Context, kind, query string, args []interface{}) {
+	if r.queryLog == nil || r.queryLogMode == QueryLogOff {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("request_id", requestIDFrom(ctx)),
+		zap.String("kind", kind),
+		zap.String("query", query),
+	}
+	if r.queryLogMode == QueryLogArgs {
+		fields = append(fields, zap.Any("args", args))
+	} else {
+		fields = append(fields, zap.Strings("args", redactArgs(args)))
+	}
+	r.queryLog.Debug("Query", fields...)
+}
+
+// redactArgs returns the type of each arg in place of its value, e.g.
+// ["string", "int64"]
+func redactArgs(args []interface{}) []string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = fmt.Sprintf("%T", arg)
+	}
+	return types
+}
@@ -0,0 +1,177 @@
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guregu/null/v5"
+)
+
+// ReferenceTable is a database table mapping ids to labels, e.g. a status
+// table maintained by operations instead of code. The dbEnum operator
+// resolves ids through it by Name.
+type ReferenceTable struct {
+	Name        string // name passed to dbEnum, e.g. "status"
+	Table       string // table name, e.g. "ticket_statuses"
+	KeyColumn   string // id column, e.g. "id"
+	LabelColumn string // label column, e.g. "name"
+}
+
+// enumTables holds the labels of the reference tables by name, then by id
+// text. A loaded snapshot is never modified, so streams share it.
+type enumTables map[string]map[string]string
+
+// referenceCache loads the reference tables at most once per TTL. A stream
+// takes the current snapshot when it starts (reloading it if expired) and
+// keeps it until it ends.
+type referenceCache struct {
+	tables []ReferenceTable
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	enums    enumTables
+	loadedAt time.Time
+}
+
+// newReferenceCache validates tables and returns their cache
+func newReferenceCache(tables []ReferenceTable, ttl time.Duration) (*referenceCache, error) {
+	names := make(map[string]bool, len(tables))
+	for i, table := range tables {
+		if table.Name == "" {
+			return nil, fmt.Errorf("reference table at index %d: name is required", i)
+		}
+		if names[table.Name] {
+			return nil, fmt.Errorf("reference table '%s' is configured twice", table.Name)
+		}
+		names[table.Name] = true
+
+		for _, identifier := range []string{table.Table, table.KeyColumn, table.LabelColumn} {
+			if !isIdentifier(identifier) {
+				return nil, fmt.Errorf("reference table '%s': invalid identifier '%s'", table.Name, identifier)
+			}
+		}
+	}
+	return &referenceCache{tables: tables, ttl: ttl}, nil
+}
+
+// snapshot returns the labels of every reference table, loading them when
+// the cache is empty or older than the TTL. When a reload fails the previous
+// snapshot is kept (and logged) so a flaky reference query does not fail
+// every export; without one the error is returned.
+func (c *referenceCache) snapshot(ctx context.Context, repo *Repository) (enumTables, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.enums != nil && time.Since(c.loadedAt) < c.ttl {
+		return c.enums, nil
+	}
+
+	// Load the tables at once; the connection limit of the request bounds
+	// how many of their queries run together
+	labels := make([]map[string]string, len(c.tables))
+	errs := make([]error, len(c.tables))
+	var wg sync.WaitGroup
+	for i, table := range c.tables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			labels[i], errs[i] = loadReferenceTable(ctx, repo, table)
+		}()
+	}
+	wg.Wait()
+
+	enums := make(enumTables, len(c.tables))
+	for i, table := range c.tables {
+		if err := errs[i]; err != nil {
+			if c.enums != nil {
+				log.Printf("⚠️  Failed to reload reference table '%s', keeping cached labels: %v", table.Name, err)
+				return c.enums, nil
+			}
+			return nil, err
+		}
+		enums[table.Name] = labels[i]
+	}
+
+	c.enums, c.loadedAt = enums, time.Now()
+	return enums, nil
+}
+
+// loadReferenceTable reads the id→label pairs of table; null ids are skipped
+func loadReferenceTable(ctx context.Context, repo *Repository, table ReferenceTable) (map[string]string, error) {
+	query := fmt.Sprintf("SELECT %s, %s FROM %s",
+		quoteIdentifier(table.KeyColumn), quoteIdentifier(table.LabelColumn), quoteIdentifier(table.Table))
+
+	rows, err := repo.ExecuteQuery(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reference table '%s': %w", table.Name, err)
+	}
+	defer closeRows(ctx, rows)
+
+	labels := make(map[string]string)
+	for rows.Next() {
+		var key, label interface{}
+		if err := rows.Scan(&key, &label); err != nil {
+			return nil, fmt.Errorf("failed to read reference table '%s': %w", table.Name, err)
+		}
+		if isNullValue(key) {
+			continue
+		}
+		labels[strings.TrimSpace(toString(key))] = toString(label)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read reference table '%s': %w", table.Name, err)
+	}
+	return labels, nil
+}
+
+// usesDBEnum reports whether any formula resolves ids through dbEnum
+func usesDBEnum(formulas []Formula) bool {
+	for _, formula := range formulas {
+		if formula.Operator == "dbEnum" {
+			return true
+		}
+	}
+	return false
+}
+
+// dbEnum resolves an id to its label in a reference table loaded from the
+// database (see Service.SetReferenceTables). The tables are read once when a
+// stream starts and cached for the configured TTL, so status and priority
+// labels maintained in the database need no code change.
+//
+// Parameters:
+//   - params[0]: Id to resolve (any value is converted via toString and trimmed)
+//   - params[1]: Reference table name (ReferenceTable.Name)
+//
+// Output:
+//   - String: The label of the id
+//   - null.String{} if the id is nil or not in the table
+//   - Error if the reference table is not configured
+//
+// Examples:
+//
+//	dbEnum(1, "status") -> "Open"
+//	dbEnum("2", "status") -> "Closed" (ids match their text form)
+//	dbEnum(99, "status") -> null.String{}
+func (e enumTables) dbEnum(params []interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, fmt.Errorf("dbEnum requires 2 parameters (value, table)")
+	}
+
+	name := toString(params[1])
+	labels, ok := e[name]
+	if !ok {
+		return nil, fmt.Errorf("dbEnum: reference table %q is not configured", name)
+	}
+
+	if !isNullValue(params[0]) {
+		if label, ok := labels[strings.TrimSpace(toString(params[0]))]; ok {
+			return label, nil
+		}
+	}
+	return null.String{}, nil
+}
@@ -3,53 +3,259 @@ package tickets
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"stream/internal/stream"
+	"syscall"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// DefaultQueryTimeout is the per-query timeout applied to ExecuteQuery and ExecuteCount.
+// It is intentionally shorter than the HTTP WriteTimeout so a slow query fails fast
+// instead of holding a streaming goroutine until the connection is torn down.
+const DefaultQueryTimeout = 30 * time.Second
+
+// ErrQueryTimeout is returned when a single query exceeds the configured query timeout
+var ErrQueryTimeout = errors.New("query timeout exceeded")
+
+// DefaultCountRetries is how many times ExecuteCount retries a COUNT(*) that
+// failed with a transient error. The count is idempotent and runs before the
+// body streams, so retrying it cannot duplicate rows; the SELECT is never retried.
+const DefaultCountRetries = 2
+
+// countRetryBackoff is the wait before the first count retry; it doubles per retry
+var countRetryBackoff = 100 * time.Millisecond
+
 // Repository handles data access for tickets
 type Repository struct {
-	db *gorm.DB
+	db           *gorm.DB
+	queryTimeout time.Duration
+
+	// replica serves the streaming SELECT when set (nil uses db);
+	// countOnReplica routes COUNT(*) there too
+	replica        *gorm.DB
+	countOnReplica bool
+
+	// maxConnsPerRequest caps the connections held at once under a
+	// WithConnLimit context (0 disables the cap)
+	maxConnsPerRequest int
+
+	// countRetries bounds the retries of a COUNT(*) after a transient error
+	countRetries int
+
+	// queryLog logs every query at debug level as queryLogMode allows
+	// (nil: off)
+	queryLog     *zap.Logger
+	queryLogMode QueryLogMode
+
+	// maxQueryRows is the largest estimated rows a SELECT may examine,
+	// checked by CheckQueryCost (0: unchecked)
+	maxQueryRows int64
 }
 
 // NewRepository creates a new Repository
 func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{
+		db:                 db,
+		queryTimeout:       DefaultQueryTimeout,
+		maxConnsPerRequest: DefaultMaxConnsPerRequest,
+		countRetries:       DefaultCountRetries,
+	}
+}
+
+// SetQueryTimeout sets the per-query timeout (0 disables it)
+func (r *Repository) SetQueryTimeout(timeout time.Duration) {
+	r.queryTimeout = timeout
+}
+
+// SetMaxConnsPerRequest sets how many pooled connections one request may hold
+// at once (0 disables the cap); see WithConnLimit
+func (r *Repository) SetMaxConnsPerRequest(n int) {
+	r.maxConnsPerRequest = n
+}
+
+// SetCountRetries sets how many times a COUNT(*) failing with a transient
+// error is retried (0 disables retries)
+func (r *Repository) SetCountRetries(retries int) {
+	r.countRetries = retries
 }
 
-// ExecuteQuery executes a SELECT query and returns rows
+// SetQueryLog logs every SELECT and COUNT about to run to logger at debug
+// level, with the request ID of its context (see WithRequestID) and its args
+// as mode allows. A nil logger or QueryLogOff disables it.
+func (r *Repository) SetQueryLog(logger *zap.Logger, mode QueryLogMode) {
+	r.queryLog = logger
+	r.queryLogMode = mode
+}
+
+// SetReplica routes the streaming SELECT to a read replica so heavy exports do
+// not load the primary. COUNT(*) stays on the primary unless countOnReplica.
+// A nil replica sends every query to the primary.
+func (r *Repository) SetReplica(replica *gorm.DB, countOnReplica bool) {
+	r.replica = replica
+	r.countOnReplica = countOnReplica
+}
+
+// selectDB returns the handle for SELECT queries
+func (r *Repository) selectDB() *gorm.DB {
+	if r.replica != nil {
+		return r.replica
+	}
+	return r.db
+}
+
+// countDB returns the handle for COUNT queries
+func (r *Repository) countDB() *gorm.DB {
+	if r.replica != nil && r.countOnReplica {
+		return r.replica
+	}
+	return r.db
+}
+
+// ExecuteQuery executes a SELECT query and returns rows.
+// The query timeout only bounds the time until the database starts returning rows;
+// once QueryContext returns, the rows stay bound to the caller's context so that
+// streaming a large result set is not cut off mid-way.
+// Under a WithConnLimit context the rows hold a connection slot until they are
+// closed with closeRows.
 func (r *Repository) ExecuteQuery(ctx context.Context, query string, args []interface{}) (*sql.Rows, error) {
-	sqlDB, err := r.db.DB()
+	limiter := connLimiterFrom(ctx)
+	if err := limiter.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	r.logQuery(ctx, "select", query, args)
+	rows, err := r.executeQuery(ctx, query, args)
+	if err != nil {
+		limiter.release()
+		return nil, err
+	}
+	limiter.hold(rows)
+	return rows, nil
+}
+
+// executeQuery runs ExecuteQuery without the connection limit
+func (r *Repository) executeQuery(ctx context.Context, query string, args []interface{}) (*sql.Rows, error) {
+	sqlDB, err := r.selectDB().DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
 
-	rows, err := sqlDB.QueryContext(ctx, query, args...)
+	if r.queryTimeout <= 0 {
+		rows, err := sqlDB.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query: %w", err)
+		}
+		return rows, nil
+	}
+
+	// Cancel the query if it has not produced a result set within the timeout.
+	// The timer is stopped as soon as QueryContext returns, so the context remains
+	// valid for row iteration and is released together with the parent context.
+	queryCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(r.queryTimeout, cancel)
+
+	rows, err := sqlDB.QueryContext(queryCtx, query, args...)
+	timedOut := !timer.Stop()
+	if err == nil && timedOut {
+		// Timer fired right as the query returned; the rows are already cancelled
+		rows.Close()
+		err = context.DeadlineExceeded
+	}
 	if err != nil {
+		cancel()
+		if timedOut && ctx.Err() == nil {
+			return nil, fmt.Errorf("failed to execute query: %w after %v", ErrQueryTimeout, r.queryTimeout)
+		}
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 
 	return rows, nil
 }
 
-// ExecuteCount executes a COUNT query and returns the count
+// ExecuteCount executes a COUNT query and returns the count. Transient
+// failures (dropped connections, deadlocks, lock wait timeouts, too many
+// connections) are retried up to the configured count retries with
+// exponential backoff; query timeouts and cancellation are not.
 func (r *Repository) ExecuteCount(ctx context.Context, query string, args []interface{}) (int64, error) {
-	sqlDB, err := r.db.DB()
+	sqlDB, err := r.countDB().DB()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get database connection: %w", err)
 	}
 
+	r.logQuery(ctx, "count", query, args)
+	for attempt := 0; ; attempt++ {
+		count, err := r.executeCount(ctx, sqlDB, query, args)
+		if err == nil || attempt >= r.countRetries || !isTransientError(err) {
+			return count, err
+		}
+
+		select {
+		case <-time.After(countRetryBackoff << attempt):
+		case <-ctx.Done():
+			return 0, err
+		}
+	}
+}
+
+// executeCount runs one attempt of ExecuteCount
+func (r *Repository) executeCount(ctx context.Context, sqlDB *sql.DB, query string, args []interface{}) (int64, error) {
+	limiter := connLimiterFrom(ctx)
+	if err := limiter.acquire(ctx); err != nil {
+		return 0, fmt.Errorf("failed to execute count query: %w", err)
+	}
+	defer limiter.release()
+
+	countCtx := ctx
+	if r.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		countCtx, cancel = context.WithTimeout(ctx, r.queryTimeout)
+		defer cancel()
+	}
+
 	var count int64
-	err = sqlDB.QueryRowContext(ctx, query, args...).Scan(&count)
+	err := sqlDB.QueryRowContext(countCtx, query, args...).Scan(&count)
 	if err != nil {
+		if countCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return 0, fmt.Errorf("failed to execute count query: %w after %v", ErrQueryTimeout, r.queryTimeout)
+		}
 		return 0, fmt.Errorf("failed to execute count query: %w", err)
 	}
 
 	return count, nil
 }
 
-// FetchRows fetches all rows from a sql.Rows and returns them as RowData slice
+// transientMySQLErrors are the server errors worth retrying: too many
+// connections, lock wait timeout and deadlock
+var transientMySQLErrors = map[uint16]bool{
+	1040: true,
+	1205: true,
+	1213: true,
+}
+
+// isTransientError reports whether a failed query may succeed when retried
+func isTransientError(err error) bool {
+	if errors.Is(err, ErrQueryTimeout) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && transientMySQLErrors[mysqlErr.Number]
+}
+
+// FetchRows fetches all rows from a sql.Rows and returns them as RowData slice.
+// It has no request context, so rows from a WithConnLimit context keep their
+// connection slot until the request ends.
 func (r *Repository) FetchRows(rows *sql.Rows) ([]RowData, error) {
 	defer rows.Close()
 
@@ -76,15 +282,16 @@ func (r *Repository) FetchRows(rows *sql.Rows) ([]RowData, error) {
 }
 
 // FetchRowsStreaming fetches rows in batches and sends them to a channel
-// batchSize controls how many rows to fetch at a time (for memory efficiency)
-func (r *Repository) FetchRowsStreaming(rows *sql.Rows, batchSize int) (<-chan []RowData, <-chan error) {
+// batchSize controls how many rows to fetch at a time (for memory efficiency).
+// It stops when ctx is done, so it never blocks on a consumer that went away.
+func (r *Repository) FetchRowsStreaming(ctx context.Context, rows *sql.Rows, batchSize int) (<-chan []RowData, <-chan error) {
 	rowsChan := make(chan []RowData, 2)
 	errChan := make(chan error, 1)
 
 	go func() {
 		defer close(rowsChan)
 		defer close(errChan)
-		defer rows.Close()
+		defer closeRows(ctx, rows)
 
 		columns, err := rows.Columns()
 		if err != nil {
@@ -92,12 +299,14 @@ func (r *Repository) FetchRowsStreaming(rows *sql.Rows, batchSize int) (<-chan [
 			return
 		}
 
+		// DECIMAL columns keep their exact text (as json.Number)
+		scan := stream.TypedRowScanner()
 		batch := make([]RowData, 0, batchSize)
 
 		for rows.Next() {
-			row, err := ScanRowGeneric(rows, columns)
+			row, err := scan(rows, columns)
 			if err != nil {
-				errChan <- fmt.Errorf("failed to scan row: %w", err)
+				errChan <- err
 				return
 			}
 
@@ -108,14 +317,22 @@ func (r *Repository) FetchRowsStreaming(rows *sql.Rows, batchSize int) (<-chan [
 				// Create a copy to avoid race conditions
 				batchCopy := make([]RowData, len(batch))
 				copy(batchCopy, batch)
-				rowsChan <- batchCopy
+				select {
+				case rowsChan <- batchCopy:
+				case <-ctx.Done():
+					return
+				}
 				batch = batch[:0] // Reset batch
 			}
 		}
 
 		// Send remaining rows
 		if len(batch) > 0 {
-			rowsChan <- batch
+			select {
+			case rowsChan <- batch:
+			case <-ctx.Done():
+				return
+			}
 		}
 
 		if err := rows.Err(); err != nil {
@@ -132,7 +349,7 @@ func (r *Repository) GetColumnMetadataFromQuery(ctx context.Context, query strin
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer closeRows(ctx, rows)
 
 	metadata, err := GetColumnMetadata(rows)
 	if err != nil {
@@ -0,0 +1,979 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"stream/common"
+	"stream/internal/stream"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockRepository(t *testing.T) (*Repository, sqlmock.Sqlmock) {
+	db, mock := openMockDB(t)
+	return NewRepository(db), mock
+}
+
+// openMockDB opens a gorm handle backed by sqlmock
+func openMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to open gorm with mock: %v", err)
+	}
+
+	return db, mock
+}
+
+func TestRepository_Replica(t *testing.T) {
+	ctx := context.Background()
+	selectQuery := "SELECT `id` FROM `tickets`"
+	countQuery := "SELECT COUNT(*) FROM `tickets`"
+
+	t.Run("select runs on replica and count on primary", func(t *testing.T) {
+		primary, primaryMock := openMockDB(t)
+		replica, replicaMock := openMockDB(t)
+		repo := NewRepository(primary)
+		repo.SetReplica(replica, false)
+
+		replicaMock.ExpectQuery("SELECT `id`").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		primaryMock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		rows, err := repo.ExecuteQuery(ctx, selectQuery, nil)
+		if err != nil {
+			t.Fatalf("ExecuteQuery() error = %v", err)
+		}
+		rows.Close()
+		if _, err := repo.ExecuteCount(ctx, countQuery, nil); err != nil {
+			t.Fatalf("ExecuteCount() error = %v", err)
+		}
+
+		if err := replicaMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Replica: %v", err)
+		}
+		if err := primaryMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Primary: %v", err)
+		}
+	})
+
+	t.Run("count on replica when enabled", func(t *testing.T) {
+		primary, primaryMock := openMockDB(t)
+		replica, replicaMock := openMockDB(t)
+		repo := NewRepository(primary)
+		repo.SetReplica(replica, true)
+
+		replicaMock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		if _, err := repo.ExecuteCount(ctx, countQuery, nil); err != nil {
+			t.Fatalf("ExecuteCount() error = %v", err)
+		}
+		if err := replicaMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Replica: %v", err)
+		}
+		if err := primaryMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Primary: %v", err)
+		}
+	})
+
+	t.Run("no replica falls back to primary", func(t *testing.T) {
+		primary, primaryMock := openMockDB(t)
+		repo := NewRepository(primary)
+		repo.SetReplica(nil, true)
+
+		primaryMock.ExpectQuery("SELECT `id`").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		primaryMock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		rows, err := repo.ExecuteQuery(ctx, selectQuery, nil)
+		if err != nil {
+			t.Fatalf("ExecuteQuery() error = %v", err)
+		}
+		rows.Close()
+		if _, err := repo.ExecuteCount(ctx, countQuery, nil); err != nil {
+			t.Fatalf("ExecuteCount() error = %v", err)
+		}
+		if err := primaryMock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Primary: %v", err)
+		}
+	})
+}
+
+func TestRepository_QueryTimeout(t *testing.T) {
+	t.Run("ExecuteQuery fails fast on slow query", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetQueryTimeout(50 * time.Millisecond)
+
+		mock.ExpectQuery("SELECT").
+			WillDelayFor(2 * time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+		start := time.Now()
+		rows, err := repo.ExecuteQuery(context.Background(), "SELECT `id` FROM `tickets`", nil)
+		elapsed := time.Since(start)
+
+		if err == nil {
+			rows.Close()
+			t.Fatal("Expected timeout error, got nil")
+		}
+		if !errors.Is(err, ErrQueryTimeout) {
+			t.Errorf("Expected ErrQueryTimeout, got %v", err)
+		}
+		if elapsed > time.Second {
+			t.Errorf("Expected query to fail fast, took %v", elapsed)
+		}
+	})
+
+	t.Run("ExecuteCount fails fast on slow query", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetQueryTimeout(50 * time.Millisecond)
+
+		mock.ExpectQuery("SELECT COUNT").
+			WillDelayFor(2 * time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+		start := time.Now()
+		_, err := repo.ExecuteCount(context.Background(), "SELECT COUNT(*) FROM `tickets`", nil)
+		elapsed := time.Since(start)
+
+		if !errors.Is(err, ErrQueryTimeout) {
+			t.Errorf("Expected ErrQueryTimeout, got %v", err)
+		}
+		if elapsed > time.Second {
+			t.Errorf("Expected count to fail fast, took %v", elapsed)
+		}
+	})
+
+	t.Run("rows remain readable after a fast query", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetQueryTimeout(50 * time.Millisecond)
+
+		mock.ExpectQuery("SELECT").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+		rows, err := repo.ExecuteQuery(context.Background(), "SELECT `id` FROM `tickets`", nil)
+		if err != nil {
+			t.Fatalf("ExecuteQuery() error = %v", err)
+		}
+
+		// Wait past the timeout to make sure iteration is not cut off
+		time.Sleep(100 * time.Millisecond)
+
+		results, err := repo.FetchRows(rows)
+		if err != nil {
+			t.Fatalf("FetchRows() error = %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(results))
+		}
+	})
+
+	t.Run("parent cancellation is not reported as timeout", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetQueryTimeout(time.Second)
+
+		mock.ExpectQuery("SELECT COUNT").
+			WillDelayFor(2 * time.Second).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := repo.ExecuteCount(ctx, "SELECT COUNT(*) FROM `tickets`", nil)
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+		if errors.Is(err, ErrQueryTimeout) {
+			t.Errorf("Expected parent cancellation error, got query timeout: %v", err)
+		}
+	})
+}
+
+func TestRepository_ConnLimit(t *testing.T) {
+	const perRequest = 2
+	repo := NewRepository(openTestDB(t, filepath.Join(t.TempDir(), "tickets.db")))
+	repo.SetMaxConnsPerRequest(perRequest)
+
+	const query = "SELECT `id` FROM `tickets`"
+	const countQuery = "SELECT COUNT(*) FROM `tickets`"
+
+	t.Run("concurrent streams stay within their cap", func(t *testing.T) {
+		const requests, queriesPerRequest = 4, 6
+
+		limiters := make([]*connLimiter, requests)
+		errs := make(chan error, requests*queriesPerRequest*2)
+		var wg sync.WaitGroup
+
+		for i := 0; i < requests; i++ {
+			ctx := repo.WithConnLimit(context.Background())
+			limiters[i] = connLimiterFrom(ctx)
+
+			for j := 0; j < queriesPerRequest; j++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					rows, err := repo.ExecuteQuery(ctx, query, nil)
+					if err != nil {
+						errs <- err
+						return
+					}
+					// Hold the connection like a slow stream would
+					time.Sleep(10 * time.Millisecond)
+					closeRows(ctx, rows)
+				}()
+				go func() {
+					defer wg.Done()
+					if _, err := repo.ExecuteCount(ctx, countQuery, nil); err != nil {
+						errs <- err
+					}
+				}()
+			}
+		}
+
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("query error = %v", err)
+		}
+
+		for i, limiter := range limiters {
+			if limiter.peak > perRequest {
+				t.Errorf("request %d held %d connections, cap is %d", i, limiter.peak, perRequest)
+			}
+			if limiter.peak == 0 {
+				t.Errorf("request %d never took a connection slot", i)
+			}
+			if held := len(limiter.slots); held != 0 {
+				t.Errorf("request %d still holds %d slots after closing its rows", i, held)
+			}
+		}
+	})
+
+	t.Run("a request at its cap waits without blocking others", func(t *testing.T) {
+		ctx := repo.WithConnLimit(context.Background())
+		for i := 0; i < perRequest; i++ {
+			rows, err := repo.ExecuteQuery(ctx, query, nil)
+			if err != nil {
+				t.Fatalf("ExecuteQuery() error = %v", err)
+			}
+			defer closeRows(ctx, rows)
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := repo.ExecuteCount(waitCtx, countQuery, nil); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("ExecuteCount() at cap error = %v, want deadline exceeded", err)
+		}
+
+		other := repo.WithConnLimit(context.Background())
+		if _, err := repo.ExecuteCount(other, countQuery, nil); err != nil {
+			t.Errorf("ExecuteCount() of another request error = %v", err)
+		}
+	})
+
+	t.Run("zero disables the cap", func(t *testing.T) {
+		unlimited := NewRepository(openTestDB(t, filepath.Join(t.TempDir(), "tickets.db")))
+		unlimited.SetMaxConnsPerRequest(0)
+
+		ctx := unlimited.WithConnLimit(context.Background())
+		if connLimiterFrom(ctx) != nil {
+			t.Error("WithConnLimit() added a limiter with the cap disabled")
+		}
+	})
+}
+
+func TestRepository_CountRetry(t *testing.T) {
+	defer func(backoff time.Duration) { countRetryBackoff = backoff }(countRetryBackoff)
+	countRetryBackoff = time.Millisecond
+
+	deadlock := &mysqldriver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	t.Run("transient count failure is retried before a single stream", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+
+		mock.ExpectQuery("SELECT COUNT").WillReturnError(deadlock)
+		mock.ExpectQuery("SELECT COUNT").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery("SELECT \\* FROM `tickets`").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+		response := NewService(repo).StreamTickets(context.Background(), &QueryPayload{TableName: "tickets"})
+		if response.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", response.Error)
+		}
+		if response.TotalCount != 2 {
+			t.Errorf("Expected total count 2, got %d", response.TotalCount)
+		}
+
+		var body []byte
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("stream error = %v", chunk.Error)
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+		if string(body) != `[{"id":1},{"id":2}]` {
+			t.Errorf("body = %s, want [{\"id\":1},{\"id\":2}]", body)
+		}
+
+		// Exactly one SELECT ran; a second would be an unexpected query
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetCountRetries(1)
+
+		mock.ExpectQuery("SELECT COUNT").WillReturnError(deadlock)
+		mock.ExpectQuery("SELECT COUNT").WillReturnError(deadlock)
+
+		_, err := repo.ExecuteCount(context.Background(), "SELECT COUNT(*) FROM `tickets`", nil)
+		if !errors.Is(err, deadlock) {
+			t.Errorf("ExecuteCount() error = %v, want the deadlock error", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("permanent errors are not retried", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+
+		syntax := &mysqldriver.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}
+		mock.ExpectQuery("SELECT COUNT").WillReturnError(syntax)
+
+		_, err := repo.ExecuteCount(context.Background(), "SELECT COUNT(*) FROM `tickets`", nil)
+		if !errors.Is(err, syntax) {
+			t.Errorf("ExecuteCount() error = %v, want the syntax error", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestService_CountDeduplication(t *testing.T) {
+	repo, mock := setupMockRepository(t)
+	mock.MatchExpectationsInOrder(false)
+
+	// The count is expected exactly once; a second execution would fail
+	// with an unexpected query error
+	mock.ExpectQuery("SELECT COUNT").
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT \\* FROM `tickets`").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+
+	svc := NewService(repo)
+	svc.SetDeduplication(true)
+
+	results := make(chan int64, 2)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			payload := &QueryPayload{
+				TableName: "tickets",
+				Where:     []WhereClause{{Field: "status", Operator: "=", Value: "open"}},
+			}
+
+			response := svc.StreamTickets(context.Background(), payload)
+			if response.Error != nil {
+				errs <- response.Error
+				return
+			}
+			for range response.ChunkChan {
+			}
+			results <- response.TotalCount
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			t.Fatalf("StreamTickets() error = %v", err)
+		case count := <-results:
+			if count != 3 {
+				t.Errorf("Expected total count 3, got %d", count)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for concurrent requests")
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
+
+func TestService_ReferenceTables(t *testing.T) {
+	referenceQuery := regexp.QuoteMeta("SELECT `id`, `name` FROM `ticket_statuses`")
+	selectQuery := regexp.QuoteMeta("SELECT `id`, `status_id`, 'status' AS reference FROM `tickets`")
+
+	payload := func() *QueryPayload {
+		return &QueryPayload{
+			TableName:      "tickets",
+			IsDisableCount: true,
+			Formulas: []Formula{
+				{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+				{Params: []string{"status_id", "'status' AS reference"}, Field: "status", Operator: "dbEnum", Position: 2},
+			},
+		}
+	}
+	// readBody runs payload and returns the body; chunks are joined with
+	// commas like sendStream does
+	readBody := func(t *testing.T, svc *Service) string {
+		t.Helper()
+		response := svc.StreamTickets(context.Background(), payload())
+		if response.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", response.Error)
+		}
+		var body []byte
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Stream chunk error: %v", chunk.Error)
+			}
+			if next := *chunk.JSONBuf; len(body) > 0 && len(next) > 0 && next[0] != ',' && next[0] != ']' {
+				body = append(body, ',')
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+		return string(body)
+	}
+	// expectTickets expects the streamed SELECT: an id hit (with whitespace),
+	// a miss and a null id
+	expectTickets := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "status_id", "reference"}).
+			AddRow(1, 1, "status").AddRow(2, " 2 ", "status").AddRow(3, 99, "status").AddRow(4, nil, "status"))
+	}
+	expectReference := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(referenceQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Open").AddRow(2, []byte("Closed")))
+	}
+	tables := []ReferenceTable{{Name: "status", Table: "ticket_statuses", KeyColumn: "id", LabelColumn: "name"}}
+	want := `[{"id":1,"status":"Open"},{"id":2,"status":"Closed"},{"id":3,"status":null},{"id":4,"status":null}]`
+
+	t.Run("table is queried once per stream", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		for i := 0; i < 2; i++ {
+			expectReference(mock)
+			expectTickets(mock)
+		}
+
+		svc := NewService(repo)
+		// One row per batch, so the rows are transformed in several batches
+		svc.SetChunkConfig(stream.ChunkConfig{BatchSize: 1})
+		if err := svc.SetReferenceTables(tables, 0); err != nil {
+			t.Fatalf("SetReferenceTables() error = %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if got := readBody(t, svc); got != want {
+				t.Errorf("stream %d body = %s, want %s", i+1, got, want)
+			}
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("cached labels are reused within the TTL", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		expectReference(mock)
+		expectTickets(mock)
+		expectTickets(mock)
+
+		svc := NewService(repo)
+		if err := svc.SetReferenceTables(tables, time.Hour); err != nil {
+			t.Fatalf("SetReferenceTables() error = %v", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if got := readBody(t, svc); got != want {
+				t.Errorf("stream %d body = %s, want %s", i+1, got, want)
+			}
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("failed first load fails the stream", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		mock.ExpectQuery(referenceQuery).WillReturnError(errors.New("table missing"))
+
+		svc := NewService(repo)
+		if err := svc.SetReferenceTables(tables, 0); err != nil {
+			t.Fatalf("SetReferenceTables() error = %v", err)
+		}
+
+		if response := svc.StreamTickets(context.Background(), payload()); response.Error == nil {
+			t.Error("Expected an error when the reference table cannot be loaded")
+		}
+	})
+
+	t.Run("tables load at once within the connection cap", func(t *testing.T) {
+		const perRequest = 2
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxConnsPerRequest(perRequest)
+		mock.MatchExpectationsInOrder(false)
+
+		var tables []ReferenceTable
+		for _, name := range []string{"status", "priority", "channel", "category"} {
+			tables = append(tables, ReferenceTable{Name: name, Table: name + "s", KeyColumn: "id", LabelColumn: "name"})
+			// Slow queries overlap when they run at once
+			mock.ExpectQuery(regexp.QuoteMeta("FROM `" + name + "s`")).WillDelayFor(30 * time.Millisecond).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, name))
+		}
+		cache, err := newReferenceCache(tables, 0)
+		if err != nil {
+			t.Fatalf("newReferenceCache() error = %v", err)
+		}
+
+		ctx := repo.WithConnLimit(context.Background())
+		enums, err := cache.snapshot(ctx, repo)
+		if err != nil {
+			t.Fatalf("snapshot() error = %v", err)
+		}
+		if len(enums) != len(tables) || enums["channel"]["1"] != "channel" {
+			t.Errorf("snapshot() = %v, want every table", enums)
+		}
+
+		limiter := connLimiterFrom(ctx)
+		if limiter.peak != perRequest {
+			t.Errorf("peak connections = %d, want the cap of %d", limiter.peak, perRequest)
+		}
+		if held := len(limiter.slots); held != 0 {
+			t.Errorf("still holding %d slots after loading", held)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("invalid identifiers are rejected", func(t *testing.T) {
+		svc := NewService(nil)
+		err := svc.SetReferenceTables([]ReferenceTable{{Name: "status", Table: "statuses; DROP", KeyColumn: "id", LabelColumn: "name"}}, 0)
+		if err == nil {
+			t.Error("Expected an error for an invalid table name")
+		}
+	})
+}
+
+func TestService_ResultCache(t *testing.T) {
+	countQuery := regexp.QuoteMeta("SELECT COUNT(*) FROM `tickets`")
+	selectQuery := regexp.QuoteMeta("SELECT `id`, `status` FROM `tickets`")
+
+	payload := func() *QueryPayload {
+		return &QueryPayload{
+			TableName: "tickets",
+			Formulas: []Formula{
+				{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+				{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 2},
+			},
+		}
+	}
+	expectQueries := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(countQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).
+			AddRow(1, "open").AddRow(2, "closed"))
+	}
+	// readBody streams payload in format and returns the total count and
+	// the body; chunks are joined with commas like sendStream does
+	readBody := func(t *testing.T, svc *Service, payload *QueryPayload, format Format) (int64, string) {
+		t.Helper()
+		response := svc.StreamTicketsAs(context.Background(), payload, format)
+		if response.Error != nil {
+			t.Fatalf("StreamTicketsAs() error = %v", response.Error)
+		}
+		var body []byte
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Stream chunk error: %v", chunk.Error)
+			}
+			if next := *chunk.JSONBuf; format == FormatJSON && len(body) > 0 && len(next) > 0 && next[0] != ',' && next[0] != ']' {
+				body = append(body, ',')
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+		return response.TotalCount, string(body)
+	}
+
+	t.Run("identical requests within the TTL query once", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		expectQueries(mock)
+
+		svc := NewService(repo)
+		svc.SetResultCache(time.Minute, 0)
+
+		firstCount, first := readBody(t, svc, payload(), FormatJSON)
+		secondCount, second := readBody(t, svc, payload(), FormatJSON)
+
+		if want := `[{"id":1,"status":"OPEN"},{"id":2,"status":"CLOSED"}]`; first != want {
+			t.Errorf("first body = %s, want %s", first, want)
+		}
+		if second != first || secondCount != firstCount {
+			t.Errorf("cached response = %d %s, want %d %s", secondCount, second, firstCount, first)
+		}
+		// A second query would have failed the second stream
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("format and count are part of the key", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		expectQueries(mock)
+		expectQueries(mock)
+		mock.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(1, "open"))
+
+		svc := NewService(repo)
+		svc.SetResultCache(time.Minute, 0)
+
+		readBody(t, svc, payload(), FormatJSON)
+		if _, body := readBody(t, svc, payload(), FormatCSV); body != "id,status\n1,OPEN\n2,CLOSED\n" {
+			t.Errorf("CSV body = %q", body)
+		}
+		noCount := payload()
+		noCount.IsDisableCount = true
+		if count, _ := readBody(t, svc, noCount, FormatJSON); count != -1 {
+			t.Errorf("total count without COUNT(*) = %d, want -1", count)
+		}
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("expired and oversized results are not replayed", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		expectQueries(mock)
+		expectQueries(mock)
+
+		svc := NewService(repo)
+		svc.SetResultCache(time.Nanosecond, 0)
+		readBody(t, svc, payload(), FormatJSON)
+		time.Sleep(time.Millisecond)
+		readBody(t, svc, payload(), FormatJSON)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+
+		// A 10-byte budget cannot hold the body
+		repo, mock = setupMockRepository(t)
+		expectQueries(mock)
+		expectQueries(mock)
+
+		svc = NewService(repo)
+		svc.SetResultCache(time.Minute, 10)
+		readBody(t, svc, payload(), FormatJSON)
+		readBody(t, svc, payload(), FormatJSON)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestRepository_CheckQueryCost(t *testing.T) {
+	ctx := context.Background()
+	query := "SELECT `id` FROM `tickets` WHERE `status` = ?"
+	args := []interface{}{"open"}
+
+	// A join of two tables (10 * 20 rows) in a UNION with a 5 row SELECT
+	plan := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "select_type", "table", "rows"}).
+			AddRow(1, "PRIMARY", "tickets", 10).
+			AddRow(1, "PRIMARY", "customers", 20).
+			AddRow(2, "UNION", "tickets", 5).
+			AddRow(nil, "UNION RESULT", "<union1,2>", nil)
+	}
+
+	t.Run("within the maximum", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxQueryRows(205)
+		mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN " + query)).WithArgs("open").WillReturnRows(plan())
+
+		if err := repo.CheckQueryCost(ctx, query, args); err != nil {
+			t.Errorf("CheckQueryCost() error = %v, want nil", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("above the maximum", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxQueryRows(204)
+		mock.ExpectQuery(regexp.QuoteMeta("EXPLAIN " + query)).WithArgs("open").WillReturnRows(plan())
+
+		err := repo.CheckQueryCost(ctx, query, args)
+		if !errors.Is(err, ErrQueryTooExpensive) {
+			t.Fatalf("CheckQueryCost() error = %v, want ErrQueryTooExpensive", err)
+		}
+		if !strings.Contains(err.Error(), "an estimated 205 rows") {
+			t.Errorf("CheckQueryCost() error = %v, want the estimate", err)
+		}
+	})
+
+	t.Run("disabled runs no EXPLAIN", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+
+		if err := repo.CheckQueryCost(ctx, query, args); err != nil {
+			t.Errorf("CheckQueryCost() error = %v, want nil", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("plan without estimates", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxQueryRows(100)
+		mock.ExpectQuery("EXPLAIN").WillReturnRows(sqlmock.NewRows([]string{"addr", "opcode"}).AddRow(0, "Init"))
+
+		if err := repo.CheckQueryCost(ctx, query, args); err == nil || errors.Is(err, ErrQueryTooExpensive) {
+			t.Errorf("CheckQueryCost() error = %v, want an EXPLAIN error", err)
+		}
+	})
+}
+
+func TestService_QueryCost(t *testing.T) {
+	payload := func() *QueryPayload {
+		return &QueryPayload{
+			TableName:      "tickets",
+			IsDisableCount: true,
+			Formulas:       []Formula{{Params: []string{"id"}, Field: "id", Position: 1}},
+		}
+	}
+	estimate := func(rows int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "select_type", "table", "rows"}).AddRow(1, "SIMPLE", "tickets", rows)
+	}
+
+	t.Run("expensive query is rejected before it runs", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxQueryRows(1000)
+		// No SELECT is expected: running it fails the expectations
+		mock.ExpectQuery("EXPLAIN SELECT").WillReturnRows(estimate(50000))
+
+		response := NewService(repo).StreamTickets(context.Background(), payload())
+		if !errors.Is(response.Error, ErrQueryTooExpensive) {
+			t.Fatalf("StreamTickets() error = %v, want ErrQueryTooExpensive", response.Error)
+		}
+		if got := common.HTTPStatus(response.Error); got != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("cheap query proceeds", func(t *testing.T) {
+		repo, mock := setupMockRepository(t)
+		repo.SetMaxQueryRows(1000)
+		mock.ExpectQuery("EXPLAIN SELECT").WillReturnRows(estimate(3))
+		mock.ExpectQuery("^SELECT").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+		response := NewService(repo).StreamTickets(context.Background(), payload())
+		if response.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", response.Error)
+		}
+		var body []byte
+		for chunk := range response.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("chunk error = %v", chunk.Error)
+			}
+			body = append(body, *chunk.JSONBuf...)
+		}
+		if string(body) != `[{"id":1},{"id":2}]` {
+			t.Errorf("body = %s, want [{\"id\":1},{\"id\":2}]", body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestService_QueryCostSQLite(t *testing.T) {
+	// SQLite's EXPLAIN lists bytecode without a rows estimate: the limit is
+	// not applied instead of failing every query
+	repo := NewRepository(setupTestDB(t))
+	repo.SetMaxQueryRows(1)
+
+	response := NewService(repo).StreamTickets(context.Background(), &QueryPayload{
+		TableName: "tickets",
+		Formulas:  []Formula{{Params: []string{"id"}, Field: "id", Position: 1}},
+	})
+	if response.Error != nil {
+		t.Fatalf("StreamTickets() error = %v", response.Error)
+	}
+	var body []byte
+	for chunk := range response.ChunkChan {
+		if chunk.Error != nil {
+			t.Fatalf("chunk error = %v", chunk.Error)
+		}
+		body = append(body, *chunk.JSONBuf...)
+	}
+	if want := `[{"id":1},{"id":2},{"id":3}]`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestResultCache_LRU(t *testing.T) {
+	cache := newResultCache(time.Minute, 10)
+	cache.put(&cachedResult{key: "a", size: 4})
+	cache.put(&cachedResult{key: "b", size: 4})
+
+	// Using "a" makes "b" the least recently used
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("Expected a cached result for a")
+	}
+	cache.put(&cachedResult{key: "c", size: 4})
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("Expected a cached result for %s", key)
+		}
+	}
+	if cache.size != 8 {
+		t.Errorf("cache size = %d, want 8", cache.size)
+	}
+}
+
+func TestService_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	repo, mock := setupMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM `tickets`")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`, `status` FROM `tickets`")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(1, "open").AddRow(2, "closed"))
+
+	svc := NewService(repo)
+	svc.SetTracer(tracer)
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	response := svc.StreamTickets(ctx, &QueryPayload{
+		TableName: "tickets",
+		Formulas: []Formula{
+			{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+			{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 2},
+		},
+	})
+	if response.Error != nil {
+		t.Fatalf("StreamTickets() error = %v", response.Error)
+	}
+	for chunk := range response.ChunkChan {
+		if chunk.Error != nil {
+			t.Fatalf("Stream chunk error: %v", chunk.Error)
+		}
+	}
+	request.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	requestID := spans["request"].SpanContext().SpanID()
+	for _, name := range []string{"tickets.count", "tickets.select", "stream.fetch", "stream.transform"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("%s span not recorded", name)
+			continue
+		}
+		if span.Parent().SpanID() != requestID {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if got := attrs[stream.AttrTableName].AsString(); got != "tickets" {
+			t.Errorf("%s table = %q, want tickets", name, got)
+		}
+		if name != "tickets.select" {
+			if got := attrs[stream.AttrRowCount].AsInt64(); got != 2 {
+				t.Errorf("%s row_count = %d, want 2", name, got)
+			}
+		}
+	}
+	if _, ok := spans["stream.transform"]; ok {
+		if !slices.ContainsFunc(spans["stream.transform"].Attributes(), func(kv attribute.KeyValue) bool {
+			return kv.Key == stream.AttrTransformMs
+		}) {
+			t.Error("stream.transform has no transform_ms attribute")
+		}
+	}
+}
+
+func TestService_DecimalRoundTrip(t *testing.T) {
+	repo, mock := setupMockRepository(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT `id`, `amount` FROM `tickets`")).
+		WillReturnRows(sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("BIGINT", int64(0)),
+			sqlmock.NewColumn("amount").OfType("DECIMAL", []byte(nil)),
+		).AddRow(int64(1), []byte("1234.56")).AddRow(int64(2), []byte("0.10")))
+
+	response := NewService(repo).StreamTickets(context.Background(), &QueryPayload{
+		TableName:      "tickets",
+		IsDisableCount: true,
+		Formulas: []Formula{
+			{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+			{Params: []string{"amount"}, Field: "amount", Operator: "", Position: 2},
+			{Params: []string{"amount", "amount"}, Field: "double", Operator: "sumFields", Position: 3},
+		},
+	})
+	if response.Error != nil {
+		t.Fatalf("StreamTickets() error = %v", response.Error)
+	}
+	var body []byte
+	for chunk := range response.ChunkChan {
+		if chunk.Error != nil {
+			t.Fatalf("Stream chunk error: %v", chunk.Error)
+		}
+		body = append(body, *chunk.JSONBuf...)
+	}
+
+	// The DECIMAL text reaches the body exactly, as a JSON number
+	want := `[{"id":1,"amount":1234.56,"double":2469.12},{"id":2,"amount":0.10,"double":0.20}]`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %v", err)
+	}
+}
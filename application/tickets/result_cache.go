@@ -0,0 +1,190 @@
+package tickets
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"stream/middleware"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// DefaultResultCacheMaxBytes is the memory budget of the result cache when
+// none is configured
+const DefaultResultCacheMaxBytes = 64 << 20
+
+// resultCache keeps the encoded bodies of recent exports so an identical
+// request within the TTL is replayed without querying the database. Entries
+// are evicted least recently used first to stay within maxBytes.
+type resultCache struct {
+	ttl      time.Duration
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *cachedResult
+	lru     *list.List               // front is most recently used
+	size    int64
+}
+
+// cachedResult is one complete stream: its chunks as they were sent and the
+// response values known at its end
+type cachedResult struct {
+	key        string
+	chunks     [][]byte
+	size       int64
+	rows       int64
+	totalCount int64
+	hasMore    bool
+	fields     []string
+	expires    time.Time
+}
+
+// newResultCache returns a cache of the given TTL and memory budget
+// (DefaultResultCacheMaxBytes when maxBytes <= 0)
+func newResultCache(ttl time.Duration, maxBytes int64) *resultCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultResultCacheMaxBytes
+	}
+	return &resultCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// resultKey hashes everything that shapes a response: the validated payload
+// (including the count and preview settings) and the format
+func resultKey(payload *QueryPayload, format Format) (string, error) {
+	encoded, err := json.Marshal(struct {
+		Payload *QueryPayload `json:"payload"`
+		Preview int           `json:"preview"`
+		Format  Format        `json:"format"`
+	}{payload, payload.Preview, format})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// get returns the unexpired result stored under key
+func (c *resultCache) get(key string) (*cachedResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	result := element.Value.(*cachedResult)
+	if time.Now().After(result.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	return result, true
+}
+
+// put stores result, evicting the least recently used results over budget
+func (c *resultCache) put(result *cachedResult) {
+	if result.size > c.maxBytes {
+		return
+	}
+	result.expires = time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[result.key]; ok {
+		c.remove(element)
+	}
+	c.entries[result.key] = c.lru.PushFront(result)
+	c.size += result.size
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// clear drops every result, e.g. after the operators changed
+func (c *resultCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
+
+// remove drops element; c.mu must be held
+func (c *resultCache) remove(element *list.Element) {
+	result := c.lru.Remove(element).(*cachedResult)
+	delete(c.entries, result.key)
+	c.size -= result.size
+}
+
+// record forwards the chunks of response and stores the stream in the cache
+// once it completes, with its row count. Streams that fail, are cancelled or
+// outgrow the budget are not stored.
+func (c *resultCache) record(ctx context.Context, key string, response middleware.StreamResponse, hasMore *atomic.Bool, rowCount *atomic.Int64) middleware.StreamResponse {
+	in := response.ChunkChan
+	out := make(chan middleware.StreamChunk, cap(in))
+	result := &cachedResult{key: key, totalCount: response.TotalCount, fields: response.Fields}
+
+	go func() {
+		defer close(out)
+
+		complete := true
+		for chunk := range in {
+			if chunk.Error != nil {
+				complete = false
+			} else if complete && chunk.JSONBuf != nil && len(*chunk.JSONBuf) > 0 {
+				result.size += int64(len(*chunk.JSONBuf))
+				if result.size > c.maxBytes {
+					complete, result.chunks = false, nil
+				} else {
+					result.chunks = append(result.chunks, append([]byte(nil), *chunk.JSONBuf...))
+				}
+			}
+			out <- chunk
+		}
+
+		if complete && ctx.Err() == nil {
+			result.hasMore = hasMore.Load()
+			result.rows = rowCount.Load()
+			c.put(result)
+		}
+	}()
+
+	response.ChunkChan = out
+	return response
+}
+
+// replay streams a cached result as response chunks, copied into pooled
+// buffers like freshly encoded ones
+func (result *cachedResult) replay(ctx context.Context) <-chan middleware.StreamChunk {
+	chunkChan := make(chan middleware.StreamChunk, 1)
+
+	go func() {
+		defer close(chunkChan)
+		for _, chunk := range result.chunks {
+			jsonBuf := jsonBufferPool.Get().(*[]byte)
+			*jsonBuf = append((*jsonBuf)[:0], chunk...)
+
+			select {
+			case chunkChan <- middleware.StreamChunk{JSONBuf: jsonBuf}:
+			case <-ctx.Done():
+				jsonBufferPool.Put(jsonBuf)
+				return
+			}
+		}
+	}()
+
+	return chunkChan
+}
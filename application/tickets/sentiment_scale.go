@@ -0,0 +1,90 @@
+package tickets
+
+import (
+	"fmt"
+	"sort"
+
+	json "github.com/json-iterator/go"
+)
+
+// SentimentBucket labels the sentiment values from Min to Max, both included
+type SentimentBucket struct {
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Label string `json:"label"`
+}
+
+// SentimentScale maps the values of a sentiment scale to labels by bucket,
+// e.g. 1-2 stars to "Negative". Values outside every bucket have no label.
+type SentimentScale []SentimentBucket
+
+// DefaultSentimentScales returns the scales sentimentMapping knows without
+// configuration: "five" for -2..2 scores and "stars" for 1-5 star ratings
+func DefaultSentimentScales() map[string]SentimentScale {
+	return map[string]SentimentScale{
+		"five": {
+			{Min: -2, Max: -2, Label: "Very Negative"},
+			{Min: -1, Max: -1, Label: "Negative"},
+			{Min: 0, Max: 0, Label: "Neutral"},
+			{Min: 1, Max: 1, Label: "Positive"},
+			{Min: 2, Max: 2, Label: "Very Positive"},
+		},
+		"stars": {
+			{Min: 1, Max: 2, Label: "Negative"},
+			{Min: 3, Max: 3, Label: "Neutral"},
+			{Min: 4, Max: 5, Label: "Positive"},
+		},
+	}
+}
+
+// ParseSentimentScales parses named scales from JSON, e.g.
+// {"stars": [{"min": 1, "max": 2, "label": "Bad"}, {"min": 3, "max": 5, "label": "Good"}]}
+// Returns an error if a scale is empty, a bucket has no label or
+// Min > Max, or two buckets of a scale overlap.
+func ParseSentimentScales(text string) (map[string]SentimentScale, error) {
+	var scales map[string]SentimentScale
+	if err := json.Unmarshal([]byte(text), &scales); err != nil {
+		return nil, fmt.Errorf("sentiment scales must be a JSON object of bucket lists: %w", err)
+	}
+
+	for name, scale := range scales {
+		if err := scale.validate(); err != nil {
+			return nil, fmt.Errorf("sentiment scale %q: %w", name, err)
+		}
+	}
+	return scales, nil
+}
+
+// validate checks that the scale has labeled, non-overlapping buckets
+func (s SentimentScale) validate() error {
+	if len(s) == 0 {
+		return fmt.Errorf("at least one bucket is required")
+	}
+
+	buckets := make([]SentimentBucket, len(s))
+	copy(buckets, s)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Min < buckets[j].Min })
+
+	for i, bucket := range buckets {
+		if bucket.Label == "" {
+			return fmt.Errorf("bucket %d..%d has no label", bucket.Min, bucket.Max)
+		}
+		if bucket.Min > bucket.Max {
+			return fmt.Errorf("bucket %q starts after it ends (%d > %d)", bucket.Label, bucket.Min, bucket.Max)
+		}
+		if i > 0 && bucket.Min <= buckets[i-1].Max {
+			return fmt.Errorf("buckets %q and %q overlap", buckets[i-1].Label, bucket.Label)
+		}
+	}
+	return nil
+}
+
+// label returns the label of the bucket holding value
+func (s SentimentScale) label(value int) (string, bool) {
+	for _, bucket := range s {
+		if value >= bucket.Min && value <= bucket.Max {
+			return bucket.Label, true
+		}
+	}
+	return "", false
+}
@@ -2,36 +2,228 @@ package tickets
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"stream/common"
+	"stream/internal/stream"
 	"stream/middleware"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	json "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 // Service handles business logic for tickets streaming
 type Service struct {
-	repo      *Repository
+	repo *Repository
+
+	// operators holds the current registry; streams take a snapshot when they
+	// start, so a reload never changes a stream midway
+	operators atomic.Pointer[operatorRegistry]
+
+	// dedup shares in-flight count queries between identical requests
+	dedup      bool
+	countGroup singleflight.Group
+
+	// chunkConfig tunes chunk threshold and batch size for this endpoint
+	chunkConfig stream.ChunkConfig
+
+	// defaultOrderBy is applied when a payload omits orderBy (nil disables)
+	defaultOrderBy []string
+
+	// keyCase renames every output key (KeyCaseNone keeps the field names)
+	keyCase KeyCase
+
+	// references caches the reference tables resolved by dbEnum (nil: none)
+	references *referenceCache
+
+	// results replays recent identical exports without a query (nil: off)
+	results *resultCache
+
+	// logger logs a summary line per stream when it ends (nil: off)
+	logger *zap.Logger
+
+	// maxInListSize is the longest IN list bound in one query; longer lists
+	// are queried in chunks (0 disables chunking)
+	maxInListSize int
+
+	// errorMarker ends the body of a failed stream with an error marker
+	errorMarker bool
+
+	// tracer records query and streaming spans (nil disables tracing)
+	tracer trace.Tracer
+}
+
+// operatorRegistry is the operator registry built from one OperatorConfig
+type operatorRegistry struct {
 	operators map[string]OperatorFunc
+	config    OperatorConfig // with defaults; wraps operators bound per stream
+}
+
+// DefaultOrderBy returns the ordering applied when a payload omits orderBy:
+// the primary key, so OFFSET pagination never skips or repeats rows
+func DefaultOrderBy() []string {
+	return []string{"id", "asc"}
 }
 
 // NewService creates a new Service
 func NewService(repo *Repository) *Service {
-	return &Service{
-		repo:      repo,
-		operators: GetOperatorRegistry(),
+	s := &Service{
+		repo:           repo,
+		chunkConfig:    stream.DefaultChunkConfig(),
+		defaultOrderBy: DefaultOrderBy(),
+		maxInListSize:  DefaultMaxInListSize,
+	}
+	s.SetOperatorConfig(DefaultOperatorConfig())
+	return s
+}
+
+// SetOperatorConfig replaces the operator registry with one built from
+// tenant-specific configuration. It is safe to call while streaming (e.g. on
+// a config reload): in-flight streams keep the registry they started with.
+func (s *Service) SetOperatorConfig(config OperatorConfig) {
+	config = config.withDefaults()
+	s.operators.Store(&operatorRegistry{operators: NewOperatorRegistry(config), config: config})
+	if s.results != nil {
+		// Cached bodies were transformed by the previous operators
+		s.results.clear()
+	}
+}
+
+// SetChunkConfig tunes streaming for the endpoint's row width: ChunkThreshold
+// sets when a chunk is flushed and BatchSize how many rows are fetched per batch.
+// Zero values fall back to stream.DefaultChunkConfig.
+func (s *Service) SetChunkConfig(config stream.ChunkConfig) {
+	config.Validate()
+	s.chunkConfig = config
+}
+
+// SetDeduplication enables sharing of in-flight count queries: identical
+// exports fired concurrently (e.g. a double-clicked export button) execute
+// the COUNT(*) once and all receive its result
+func (s *Service) SetDeduplication(enabled bool) {
+	s.dedup = enabled
+}
+
+// SetErrorMarker makes a stream failing mid-way end its body with an error
+// marker in the response format, e.g. a final {"_error":"..."} NDJSON line,
+// besides the X-Stream-Error trailer
+func (s *Service) SetErrorMarker(enabled bool) {
+	s.errorMarker = enabled
+}
+
+// SetDefaultOrderBy sets the [field, direction] ordering applied when a
+// payload omits orderBy, e.g. the table's primary key. nil disables it.
+// Returns an error if orderBy is not a valid [field, direction] pair.
+func (s *Service) SetDefaultOrderBy(orderBy []string) error {
+	if orderBy != nil {
+		if err := validateOrderBy(orderBy); err != nil {
+			return fmt.Errorf("invalid default orderBy: %w", err)
+		}
 	}
+	s.defaultOrderBy = orderBy
+	return nil
+}
+
+// SetKeyCase sets the naming convention applied to every output key, e.g.
+// KeyCaseSnake for consumers requiring snake_case regardless of how the
+// formula fields are named. Field order is kept.
+func (s *Service) SetKeyCase(keyCase KeyCase) {
+	s.keyCase = keyCase
 }
 
-// StreamTickets processes the query payload and streams results
+// SetReferenceTables preloads id→label reference tables from the database
+// for the dbEnum operator. Streams using dbEnum read the tables once when they
+// start, reusing the previous load while it is younger than ttl (0 reloads
+// for every stream). Returns an error if a table is not a valid identifier.
+func (s *Service) SetReferenceTables(tables []ReferenceTable, ttl time.Duration) error {
+	references, err := newReferenceCache(tables, ttl)
+	if err != nil {
+		return err
+	}
+	s.references = references
+	return nil
+}
+
+// SetResultCache keeps the encoded body of every complete export for ttl, so
+// dashboards repeating an expensive export get it replayed without querying
+// the database. The cache holds at most maxBytes of bodies (evicting least
+// recently used ones; DefaultResultCacheMaxBytes when <= 0). A ttl <= 0
+// disables it.
+func (s *Service) SetResultCache(ttl time.Duration, maxBytes int64) {
+	if ttl <= 0 {
+		s.results = nil
+		return
+	}
+	s.results = newResultCache(ttl, maxBytes)
+}
+
+// SetLogger enables a summary log line per stream: table, format, rows,
+// bytes and duration once it ends, at error level with the error of a failed
+// stream. A nil logger disables it.
+func (s *Service) SetLogger(logger *zap.Logger) {
+	s.logger = logger
+}
+
+// SetMaxInListSize sets the longest IN list bound in a single query. A
+// payload with a longer list runs one query per chunk of at most n values and
+// streams their rows as one response; see splitInList for the restrictions.
+// n <= 0 binds every list in full.
+func (s *Service) SetMaxInListSize(n int) {
+	s.maxInListSize = n
+}
+
+// StreamTickets processes the query payload and streams results as a JSON array
 func (s *Service) StreamTickets(ctx context.Context, payload *QueryPayload) middleware.StreamResponse {
+	return s.StreamTicketsAs(ctx, payload, FormatJSON)
+}
+
+// StreamTicketsAs is StreamTickets with the rows encoded in format. For
+// formats other than JSON the response carries their ContentType.
+func (s *Service) StreamTicketsAs(ctx context.Context, payload *QueryPayload, format Format) middleware.StreamResponse {
+	start := time.Now()
+	rowCount := &atomic.Int64{}
+	response := s.streamTickets(ctx, payload, format, rowCount)
+	return s.logStream(ctx, payload.TableName, format, start, response, rowCount)
+}
+
+// streamTickets is StreamTicketsAs, counting the streamed rows in rowCount
+func (s *Service) streamTickets(ctx context.Context, payload *QueryPayload, format Format, rowCount *atomic.Int64) middleware.StreamResponse {
 	// Validate payload
 	if err := ValidatePayload(payload); err != nil {
+		err = common.NewValidationError(err)
 		return middleware.StreamResponse{
-			Code:  400,
-			Error: fmt.Errorf("validation failed: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
+		}
+	}
+
+	// Bound the pooled connections this request may hold at once
+	ctx = s.repo.WithConnLimit(ctx)
+
+	if payload.Preview > 0 {
+		applyPreview(payload)
+	}
+
+	// Replay a recent identical export
+	var cacheKey string // set when the result is to be cached
+	if s.results != nil {
+		if key, err := resultKey(payload, format); err == nil {
+			if result, ok := s.results.get(key); ok {
+				rowCount.Store(result.rows)
+				return s.replayResult(ctx, result, format, payload.DetectHasMore)
+			}
+			cacheKey = key
 		}
 	}
 
@@ -42,21 +234,67 @@ func (s *Service) StreamTickets(ctx context.Context, payload *QueryPayload) midd
 	selectCols := GenerateUniqueSelectList(sortedFormulas)
 
 	// Build queries
-	qb := NewQueryBuilder(payload)
-	qb.SetSelectColumns(selectCols)
+	qb := s.newQueryBuilder(payload, selectCols)
+
+	// Query a long IN list in chunks, merged in the default order: the order
+	// field is selected to merge by
+	inList, err := splitInList(payload, s.maxInListSize)
+	var mergeOrder []string
+	if inList != nil {
+		if mergeOrder = s.defaultOrderFor(payload); mergeOrder != nil {
+			err = inList.checkMerge(s.repo.maxConnsPerRequest)
+			if len(selectCols) > 0 && !slices.Contains(selectCols, mergeOrder[0]) {
+				qb.SetSelectColumns(append(slices.Clip(selectCols), mergeOrder[0]))
+			}
+		}
+	}
+	if err != nil {
+		err = common.NewValidationError(err)
+		return middleware.StreamResponse{
+			Code:  common.HTTPStatus(err),
+			Error: err,
+		}
+	}
+
+	// Operators of this stream; dbEnum resolves ids through the reference
+	// tables as loaded now
+	registry := s.operators.Load()
+	operators := registry.operators
+	if s.references != nil && usesDBEnum(sortedFormulas) {
+		enums, err := s.references.snapshot(ctx, s.repo)
+		if err != nil {
+			err = common.NewQueryError("reference", err)
+			return middleware.StreamResponse{
+				Code:  common.HTTPStatus(err),
+				Error: err,
+			}
+		}
+		operators = maps.Clone(operators)
+		operators["dbEnum"] = wrapOperator("dbEnum", enums.dbEnum, registry.config)
+	}
 
 	// Get total count (skip if disabled for performance)
 	var totalCount int64
 	if !payload.IsDisableCount {
-		countQuery, countArgs := qb.BuildCountQuery()
-		count, err := s.repo.ExecuteCount(ctx, countQuery, countArgs)
+		// The chunks are disjoint, so their counts add up
+		countCtx, span := s.startSpan(ctx, "tickets.count", payload.TableName)
+		err := inList.each(qb, func() error {
+			countQuery, countArgs := qb.BuildCountQuery()
+			count, err := s.executeCount(countCtx, countQuery, countArgs)
+			totalCount += count
+			return err
+		})
+		if span != nil {
+			span.SetAttributes(stream.AttrRowCount.Int64(totalCount))
+			endSpan(span, err)
+		}
 		if err != nil {
+			err = common.NewQueryError("count", err)
 			return middleware.StreamResponse{
-				Code:  500,
-				Error: fmt.Errorf("failed to get count: %w", err),
+				Code:  common.HTTPStatus(err),
+				Error: err,
 			}
 		}
-		totalCount = count
 	} else {
 		// When count is disabled, set to -1 to indicate count was not performed
 		totalCount = -1
@@ -71,15 +309,44 @@ func (s *Service) StreamTickets(ctx context.Context, payload *QueryPayload) midd
 	//fmt.Printf("Query: table=%s, limit=%s, offset=%d, where=%d conditions\n",
 	//	payload.TableName, limitStr, payload.GetOffset(), len(payload.Where))
 
-	// Build main query
-	mainQuery, mainArgs := qb.BuildSelectQuery()
+	// Fetch one extra row to detect a next page without COUNT(*)
+	detectHasMore := payload.DetectHasMore && actualLimit > 0
+	if detectHasMore {
+		qb.SetLimit(actualLimit + 1)
+	}
+
+	// Build main query, one per chunk of a long IN list
+	var queries []selectQuery
+	inList.each(qb, func() error {
+		query, args := qb.BuildSelectQuery()
+		queries = append(queries, selectQuery{query: query, args: args})
+		return nil
+	})
+
+	// Reject queries the database estimates too expensive before any runs
+	for _, q := range queries {
+		if err := s.repo.CheckQueryCost(ctx, q.query, q.args); err != nil {
+			if errors.Is(err, ErrQueryTooExpensive) {
+				err = common.NewValidationError(err)
+			} else {
+				err = common.NewQueryError("explain", err)
+			}
+			return middleware.StreamResponse{
+				Code:  common.HTTPStatus(err),
+				Error: err,
+			}
+		}
+	}
 
-	// Execute main query
-	rows, err := s.repo.ExecuteQuery(ctx, mainQuery, mainArgs)
+	// Execute main query; the other chunks run once it is streamed
+	selectCtx, span := s.startSpan(ctx, "tickets.select", payload.TableName)
+	rows, err := s.repo.ExecuteQuery(selectCtx, queries[0].query, queries[0].args)
+	endSpan(span, err)
 	if err != nil {
+		err = common.NewQueryError("select", err)
 		return middleware.StreamResponse{
-			Code:  500,
-			Error: fmt.Errorf("failed to execute query: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
 		}
 	}
 
@@ -88,10 +355,11 @@ func (s *Service) StreamTickets(ctx context.Context, payload *QueryPayload) midd
 	if len(sortedFormulas) == 0 {
 		columns, err := rows.Columns()
 		if err != nil {
-			rows.Close()
+			closeRows(ctx, rows)
+			err = common.NewQueryError("columns", fmt.Errorf("failed to get columns for auto-formula generation: %w", err))
 			return middleware.StreamResponse{
-				Code:  500,
-				Error: fmt.Errorf("failed to get columns for auto-formula generation: %w", err),
+				Code:  common.HTTPStatus(err),
+				Error: err,
 			}
 		}
 
@@ -107,45 +375,290 @@ func (s *Service) StreamTickets(ctx context.Context, payload *QueryPayload) midd
 		}
 	}
 
+	// Check the output fields now that they are known: the totals row
+	// columns, the projected fields and keys colliding once renamed
+	summary, err := newSummaryAccumulator(payload.Summary, sortedFormulas)
+	var fields projection
+	if err == nil {
+		fields, err = newProjection(payload.Fields, sortedFormulas)
+	}
+	if err == nil {
+		err = s.checkKeyCase(sortedFormulas)
+	}
+	if err != nil {
+		closeRows(ctx, rows)
+		err = common.NewValidationError(err)
+		return middleware.StreamResponse{
+			Code:  common.HTTPStatus(err),
+			Error: err,
+		}
+	}
+
 	// Stream processing with batching
-	batchSize := 1000 // Process 1000 rows at a time
+	batchSize := s.chunkConfig.BatchSize
 	if actualLimit > 0 && actualLimit < batchSize {
 		batchSize = actualLimit
 	}
 
-	chunkChan := s.streamProcessing(ctx, rows, sortedFormulas, batchSize, payload.IsFormatDate)
+	// The limit of chunked queries applies to each chunk, so the rows past
+	// it are dropped here like the "next page" row
+	rowLimit := 0
+	hasMore := &atomic.Bool{}
+	if detectHasMore || (inList != nil && actualLimit > 0) {
+		rowLimit = actualLimit
+	}
 
-	return middleware.StreamResponse{
+	encoder := newRowEncoder(format)
+	if envelope, ok := encoder.(*envelopeEncoder); ok {
+		offset := int64(payload.GetOffset())
+		envelope.meta = func() EnvelopeMeta {
+			return newEnvelopeMeta(totalCount, offset, rowCount.Load(), detectHasMore, hasMore.Load())
+		}
+	}
+	if sse, ok := encoder.(*sseEncoder); ok {
+		sse.cursor = int64(payload.GetOffset())
+	}
+
+	encoder = withProjection(withKeyCase(encoder, s.keyCase), fields)
+	chunkChan := s.streamProcessing(ctx, rows, queries[1:], mergeOrder, sortedFormulas, operators, batchSize, payload.IsFormatDate, rowLimit, hasMore, rowCount, encoder, summary, s.newStreamTrace(payload.TableName))
+
+	response := middleware.StreamResponse{
 		TotalCount: totalCount,
 		ChunkChan:  chunkChan,
-		Code:       200,
+		Code:       http.StatusOK,
+	}
+	if format != FormatJSON {
+		response.ContentType = format.ContentType()
+	}
+	if s.errorMarker {
+		response.ErrorMarker = encoder.errorMarker
+	}
+	if payload.DetectHasMore {
+		response.HasMore = hasMore.Load
+	}
+	if payload.Preview > 0 {
+		response.Fields = fields.apply(outputFieldNames(sortedFormulas))
+		for i, field := range response.Fields {
+			response.Fields[i] = s.keyCase.convert(field)
+		}
+	}
+
+	if cacheKey != "" {
+		response = s.results.record(ctx, cacheKey, response, hasMore, rowCount)
+	}
+
+	return response
+}
+
+// newEnvelopeMeta returns the meta of an envelope response that streamed
+// streamed rows from offset. Without next-page detection, more rows remain
+// when the total count is known and larger than the rows up to this page.
+func newEnvelopeMeta(totalCount, offset, streamed int64, detectHasMore, hasMore bool) EnvelopeMeta {
+	meta := EnvelopeMeta{StreamedCount: streamed, HasMore: hasMore}
+	if totalCount >= 0 {
+		meta.Count = &totalCount
+		if !detectHasMore {
+			meta.HasMore = offset+streamed < totalCount
+		}
+	}
+	if meta.HasMore {
+		cursor := offset + streamed
+		meta.Cursor = &cursor
+	}
+	return meta
+}
+
+// replayResult returns the response of a cached export
+func (s *Service) replayResult(ctx context.Context, result *cachedResult, format Format, detectHasMore bool) middleware.StreamResponse {
+	response := middleware.StreamResponse{
+		TotalCount: result.totalCount,
+		ChunkChan:  result.replay(ctx),
+		Code:       http.StatusOK,
+		Fields:     result.fields,
+	}
+	if format != FormatJSON {
+		response.ContentType = format.ContentType()
+	}
+	if detectHasMore {
+		hasMore := result.hasMore
+		response.HasMore = func() bool { return hasMore }
+	}
+	return response
+}
+
+// checkKeyCase reports output fields that would share a key once renamed by
+// the service key case, e.g. "ticketNo" and "ticket_no" in snake_case
+func (s *Service) checkKeyCase(formulas []Formula) error {
+	if s.keyCase == KeyCaseNone {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, field := range outputFieldNames(formulas) {
+		key := s.keyCase.convert(field)
+		if other, ok := fields[key]; ok {
+			return fmt.Errorf("fields '%s' and '%s' both become key '%s' in %s case", other, field, key, s.keyCase)
+		}
+		fields[key] = field
+	}
+	return nil
+}
+
+// applyPreview limits a validated payload to its first Preview rows (capped
+// at MaxPreviewRows, or the payload limit when smaller) and skips COUNT(*)
+func applyPreview(payload *QueryPayload) {
+	rows := min(payload.Preview, MaxPreviewRows)
+	if limit := payload.GetLimit(); limit > 0 && limit < rows {
+		rows = limit
+	}
+
+	payload.Preview = rows
+	payload.Limit = &rows
+	payload.IsDisableCount = true
+	payload.DetectHasMore = false
+}
+
+// outputFieldNames returns the output field names of sorted formulas in row
+// order, expanding formulas that fill OutputFields
+func outputFieldNames(formulas []Formula) []string {
+	names := make([]string, 0, len(formulas))
+	for _, formula := range formulas {
+		names = append(names, formula.OutputNames()...)
+	}
+	return names
+}
+
+// newQueryBuilder creates the query builder for payload, applying the default
+// ordering when the payload omits orderBy. Without an ORDER BY, MySQL may
+// return rows in a different order on each page. UNION results are left
+// alone: their ORDER BY may only name selected columns.
+func (s *Service) newQueryBuilder(payload *QueryPayload, selectCols []string) *QueryBuilder {
+	qb := NewQueryBuilder(payload)
+	qb.SetSelectColumns(selectCols)
+
+	if orderBy := s.defaultOrderFor(payload); orderBy != nil {
+		qb.SetOrderBy(orderBy)
+	}
+
+	return qb
+}
+
+// defaultOrderFor returns the default ordering applied to payload, nil when
+// it has its own or none applies
+func (s *Service) defaultOrderFor(payload *QueryPayload) []string {
+	if len(payload.OrderBy) == 0 && !payload.DisableDefaultOrder && payload.Union == nil {
+		return s.defaultOrderBy
 	}
+	return nil
 }
 
-// streamProcessing processes rows in batches and sends JSON chunks
+// executeCount runs the count query, joining an identical in-flight query
+// when de-duplication is enabled
+func (s *Service) executeCount(ctx context.Context, query string, args []interface{}) (int64, error) {
+	if !s.dedup {
+		return s.repo.ExecuteCount(ctx, query, args)
+	}
+
+	key, err := requestKey(query, args)
+	if err != nil {
+		return s.repo.ExecuteCount(ctx, query, args)
+	}
+
+	// The shared query must not be cancelled when only the first caller goes
+	// away; the repository query timeout still bounds it
+	sharedCtx := context.WithoutCancel(ctx)
+	resultChan := s.countGroup.DoChan(key, func() (interface{}, error) {
+		return s.repo.ExecuteCount(sharedCtx, query, args)
+	})
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case result := <-resultChan:
+		if result.Err != nil {
+			return 0, result.Err
+		}
+		return result.Val.(int64), nil
+	}
+}
+
+// requestKey hashes a query and its arguments into a de-duplication key
+func requestKey(query string, args []interface{}) (string, error) {
+	encodedArgs, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(query))
+	hash.Write([]byte{0})
+	hash.Write(encodedArgs)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// streamProcessing processes rows, then those of the next queries (merged by
+// order when set), in batches and sends JSON chunks.
+// When rowLimit > 0, rows past the limit are dropped and reported via hasMore.
+// The rows written are counted in rowCount. A non-nil summary accumulates the emitted rows and appends their totals row.
+// A non-nil tracing records the fetch and transform spans.
 func (s *Service) streamProcessing(
 	ctx context.Context,
 	rows *sql.Rows,
+	next []selectQuery,
+	order []string,
 	formulas []Formula,
+	operators map[string]OperatorFunc,
 	batchSize int,
 	isFormatDate bool,
+	rowLimit int,
+	hasMore *atomic.Bool,
+	rowCount *atomic.Int64,
+	encoder rowEncoder,
+	summary *summaryAccumulator,
+	tracing *streamTrace,
 ) <-chan middleware.StreamChunk {
 	chunkChan := make(chan middleware.StreamChunk, 4)
 
 	go func() {
 		defer close(chunkChan)
-		defer rows.Close()
+		defer closeRows(ctx, rows)
 
-		// Get buffer from pool for accumulation
+		// Get buffer from pool for accumulation; a buffer handed to the
+		// consumer is owned by it, so only the current one is returned here
 		jsonBuf := jsonBufferPool.Get().(*[]byte)
 		*jsonBuf = (*jsonBuf)[:0]
-		defer jsonBufferPool.Put(jsonBuf)
+		defer func() {
+			if jsonBuf != nil {
+				jsonBufferPool.Put(jsonBuf)
+			}
+		}()
 
-		// Start JSON array
-		*jsonBuf = append(*jsonBuf, '[')
+		// send delivers a chunk unless the consumer has gone away
+		send := func(chunk middleware.StreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Start the body (e.g. the JSON array)
+		*jsonBuf = encoder.open(*jsonBuf)
 
 		// Get rows streaming channel
-		rowsChan, errChan := s.repo.FetchRowsStreaming(rows, batchSize)
+		fetchLimit := 0
+		if rowLimit > 0 {
+			fetchLimit = rowLimit + 1
+		}
+		rowsChan, errChan := s.fetchRowsStreaming(ctx, rows, next, batchSize, fetchLimit, order)
+		rowsChan, errChan = tracing.fetch(ctx, rowsChan, errChan)
+		emitted := 0
+
+		transform := tracing.startTransform(ctx)
+		var transformErr error
+		defer func() { transform.end(transformErr) }()
 
 		for {
 			select {
@@ -155,57 +668,85 @@ func (s *Service) streamProcessing(
 
 			case err := <-errChan:
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: err,
-					}
+					send(middleware.StreamChunk{
+						Error: common.NewStreamError(err),
+					})
 					return
 				}
 
 			case batch, ok := <-rowsChan:
 				if !ok {
 					// Channel closed, all rows processed
-					// Close JSON array
-					*jsonBuf = append(*jsonBuf, ']')
+					// Append the totals row after the detail rows
+					if summary != nil {
+						var err error
+						*jsonBuf, err = encoder.appendRow(*jsonBuf, summary.row())
+						if err != nil {
+							send(middleware.StreamChunk{
+								Error: common.NewStreamError(fmt.Errorf("summary encoding failed: %w", err)),
+							})
+							return
+						}
+					}
+
+					// End the body (e.g. close the JSON array)
+					*jsonBuf = encoder.close(*jsonBuf)
 
 					// Flush final buffer
-					chunkChan <- middleware.StreamChunk{
-						JSONBuf: jsonBuf,
+					if send(middleware.StreamChunk{JSONBuf: jsonBuf}) {
+						jsonBuf = nil // Owned by the consumer now
 					}
-					// Don't put back to pool, already in defer
-					jsonBuf = nil
 					return
 				}
 
+				// Drop the extra "next page" row fetched beyond the limit
+				if rowLimit > 0 {
+					if emitted+len(batch) > rowLimit {
+						batch = batch[:rowLimit-emitted]
+						hasMore.Store(true)
+					}
+					emitted += len(batch)
+				}
+
 				// Transform batch
-				transformed, err := BatchTransformRows(batch, formulas, s.operators, isFormatDate)
+				started := transform.begin()
+				transformed, err := BatchTransformRows(batch, formulas, operators, isFormatDate)
+				transform.observe(len(transformed), started)
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("transformation failed: %w", err),
-					}
+					transformErr = err
+					send(middleware.StreamChunk{
+						Error: common.NewStreamError(fmt.Errorf("transformation failed: %w", err)),
+					})
 					return
 				}
 
 				// Accumulate rows into buffer
 				for _, row := range transformed {
-					// Marshal JSON
-					jsonData, err := json.Marshal(row)
+					if summary != nil {
+						summary.add(row)
+					}
+
+					// Encode the row in the response format
+					before := len(*jsonBuf)
+					*jsonBuf, err = encoder.appendRow(*jsonBuf, row)
 					if err != nil {
-						chunkChan <- middleware.StreamChunk{
-							Error: fmt.Errorf("JSON marshal failed: %w", err),
-						}
+						send(middleware.StreamChunk{
+							Error: common.NewStreamError(fmt.Errorf("row encoding failed: %w", err)),
+						})
 						return
 					}
-
-					// Add comma separator if not first row (length > 1 because of '[')
-					if len(*jsonBuf) > 1 {
-						*jsonBuf = append(*jsonBuf, ',')
+					if err := stream.CheckRowSize(len(*jsonBuf)-before, s.chunkConfig.MaxRowBytes); err != nil {
+						send(middleware.StreamChunk{
+							Error: common.NewStreamError(err),
+						})
+						return
 					}
-					*jsonBuf = append(*jsonBuf, jsonData...)
+					rowCount.Add(1)
 
-					// Send chunk if buffer exceeds 32KB
-					if len(*jsonBuf) > 32*1024 {
-						chunkChan <- middleware.StreamChunk{
-							JSONBuf: jsonBuf,
+					// Send chunk if buffer exceeds the chunk threshold
+					if len(*jsonBuf) > s.chunkConfig.ChunkThreshold {
+						if !send(middleware.StreamChunk{JSONBuf: jsonBuf}) {
+							return
 						}
 
 						// Get new buffer from pool for next chunk
@@ -0,0 +1,68 @@
+package tickets
+
+import (
+	"context"
+	"stream/middleware"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// logStream logs the summary line of a stream once it ends: at info level
+// when it completed, at error level with the error when it failed before or
+// while streaming or was cancelled. Without a logger response is returned
+// unchanged; with one, sendStream also logs its write failures to it.
+func (s *Service) logStream(ctx context.Context, table string, format Format, start time.Time, response middleware.StreamResponse, rowCount *atomic.Int64) middleware.StreamResponse {
+	if s.logger == nil {
+		return response
+	}
+	response.Logger = s.logger
+
+	// log writes the summary line of a stream of size bytes
+	log := func(size int64, err error) {
+		fields := []zap.Field{
+			zap.String("table", table),
+			zap.String("format", string(format)),
+			zap.Int64("rows", rowCount.Load()),
+			zap.Int64("bytes", size),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			s.logger.Error("Stream failed", append(fields, zap.Error(err))...)
+			return
+		}
+		s.logger.Info("Stream completed", fields...)
+	}
+
+	if response.Error != nil || response.ChunkChan == nil {
+		log(0, response.Error)
+		return response
+	}
+
+	in := response.ChunkChan
+	out := make(chan middleware.StreamChunk, cap(in))
+
+	go func() {
+		defer close(out)
+
+		var size int64
+		var err error
+		for chunk := range in {
+			if chunk.Error != nil && err == nil {
+				err = chunk.Error
+			} else if chunk.JSONBuf != nil {
+				size += int64(len(*chunk.JSONBuf))
+			}
+			out <- chunk
+		}
+
+		if err == nil {
+			err = ctx.Err()
+		}
+		log(size, err)
+	}()
+
+	response.ChunkChan = out
+	return response
+}
@@ -0,0 +1,171 @@
+package tickets
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/guregu/null/v5"
+)
+
+// SummaryColumn declares an aggregate over an output field. The aggregates
+// are accumulated while the rows stream and emitted as one totals row after
+// the detail rows, with the same fields as a detail row: summarized fields
+// hold their aggregate, the others are null.
+type SummaryColumn struct {
+	Field    string `json:"field" binding:"required"`    // Output field name (formula field or column)
+	Function string `json:"function" binding:"required"` // sum, count, avg, min or max
+}
+
+// AllowedSummaryFunctions are the aggregates a SummaryColumn may use
+var AllowedSummaryFunctions = map[string]bool{
+	"sum":   true,
+	"count": true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// validateSummary checks the summary columns of a payload. Fields are checked
+// against the formulas here; without formulas the output fields are the
+// table columns, which newSummaryAccumulator checks once they are known.
+func validateSummary(payload *QueryPayload) error {
+	outputs := make(map[string]bool)
+	for _, formula := range payload.Formulas {
+		for _, name := range formula.OutputNames() {
+			outputs[name] = true
+		}
+	}
+
+	seen := make(map[string]bool, len(payload.Summary))
+	for i, column := range payload.Summary {
+		if !AllowedSummaryFunctions[column.Function] {
+			return fmt.Errorf("invalid summary at index %d: function '%s' is not allowed (use sum, count, avg, min or max)", i, column.Function)
+		}
+		if column.Field == "" {
+			return fmt.Errorf("invalid summary at index %d: field is required", i)
+		}
+		if len(payload.Formulas) > 0 && !outputs[column.Field] {
+			return fmt.Errorf("invalid summary at index %d: field '%s' is not an output field", i, column.Field)
+		}
+		if seen[column.Field] {
+			return fmt.Errorf("invalid summary at index %d: field '%s' is summarized twice", i, column.Field)
+		}
+		seen[column.Field] = true
+	}
+	return nil
+}
+
+// summaryAccumulator aggregates the summary columns over the streamed rows
+type summaryAccumulator struct {
+	fields []string                 // output fields of the totals row, in row order
+	states map[string]*summaryState // per summarized field
+}
+
+// summaryState is the running aggregate of one field. Numbers are kept as
+// exact rationals (see toDecimal), like sumFields.
+type summaryState struct {
+	function string
+	count    int64    // non-null values
+	numeric  int64    // numeric values
+	sum      *big.Rat // of numeric values
+	min, max *big.Rat
+	exact    bool // a DECIMAL json.Number was seen
+	scale    int  // largest decimal scale of the json.Number values
+}
+
+// newSummaryAccumulator returns an accumulator for summary over rows with
+// the output fields of formulas; nil when summary is empty
+func newSummaryAccumulator(summary []SummaryColumn, formulas []Formula) (*summaryAccumulator, error) {
+	if len(summary) == 0 {
+		return nil, nil
+	}
+
+	fields := outputFieldNames(formulas)
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+
+	acc := &summaryAccumulator{fields: fields, states: make(map[string]*summaryState, len(summary))}
+	for _, column := range summary {
+		if !known[column.Field] {
+			return nil, fmt.Errorf("summary field '%s' is not an output field", column.Field)
+		}
+		acc.states[column.Field] = &summaryState{function: column.Function, sum: new(big.Rat)}
+	}
+	return acc, nil
+}
+
+// add accumulates the summarized fields of a transformed row
+func (a *summaryAccumulator) add(row TransformedRow) {
+	for _, field := range row.fields {
+		if state, ok := a.states[field.Key]; ok {
+			state.add(field.Value)
+		}
+	}
+}
+
+// row returns the totals row
+func (a *summaryAccumulator) row() TransformedRow {
+	fields := make([]TransformedField, len(a.fields))
+	for i, name := range a.fields {
+		var value interface{} = null.String{}
+		if state, ok := a.states[name]; ok {
+			value = state.result()
+		}
+		fields[i] = TransformedField{Key: name, Value: value}
+	}
+	return TransformedRow{fields: fields}
+}
+
+func (s *summaryState) add(v interface{}) {
+	if isNullValue(v) {
+		return
+	}
+	s.count++
+
+	value, ok := toDecimal(v)
+	if !ok {
+		return
+	}
+	s.numeric++
+	s.sum.Add(s.sum, value)
+	if s.min == nil || value.Cmp(s.min) < 0 {
+		s.min = value
+	}
+	if s.max == nil || value.Cmp(s.max) > 0 {
+		s.max = value
+	}
+
+	if number, ok := v.(stdjson.Number); ok {
+		s.exact = true
+		if _, fraction, found := strings.Cut(string(number), "."); found && len(fraction) > s.scale {
+			s.scale = len(fraction)
+		}
+	}
+}
+
+// result returns the aggregate: count is an int64; sum, avg, min and max are
+// numbers (see numericResult), null when no value was numeric
+func (s *summaryState) result() interface{} {
+	if s.function == "count" {
+		return s.count
+	}
+	if s.numeric == 0 {
+		return null.Float{}
+	}
+
+	switch s.function {
+	case "avg":
+		avg := new(big.Rat).Quo(s.sum, new(big.Rat).SetInt64(s.numeric))
+		return numericResult(avg, s.scale+2, s.exact)
+	case "min":
+		return numericResult(s.min, s.scale, s.exact)
+	case "max":
+		return numericResult(s.max, s.scale, s.exact)
+	default:
+		return numericResult(s.sum, s.scale, s.exact)
+	}
+}
@@ -0,0 +1,166 @@
+package tickets
+
+import (
+	"context"
+	"time"
+
+	"stream/internal/stream"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetTracer records OpenTelemetry spans for each stream: tickets.count,
+// tickets.select, stream.fetch and stream.transform, as children of the span
+// in the request context and with the span names and attributes of the v2
+// service. A nil tracer disables tracing.
+func (s *Service) SetTracer(tracer trace.Tracer) {
+	s.tracer = tracer
+}
+
+// startSpan starts a span carrying the table name, or returns a nil span
+// when tracing is disabled
+func (s *Service) startSpan(ctx context.Context, name, table string) (context.Context, trace.Span) {
+	if s.tracer == nil {
+		return ctx, nil
+	}
+	return s.tracer.Start(ctx, name, trace.WithAttributes(stream.AttrTableName.String(table)))
+}
+
+// endSpan records err (if any) and ends span; a nil span is ignored
+func endSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// streamTrace starts the stream.fetch and stream.transform spans of one
+// stream. All methods are no-ops on a nil *streamTrace (tracing disabled).
+type streamTrace struct {
+	tracer trace.Tracer
+	table  string
+}
+
+// newStreamTrace returns the spans of a stream of table, or nil when tracing
+// is disabled
+func (s *Service) newStreamTrace(table string) *streamTrace {
+	if s.tracer == nil {
+		return nil
+	}
+	return &streamTrace{tracer: s.tracer, table: table}
+}
+
+// fetch wraps the row batches of a fetch in a stream.fetch span that counts
+// the rows and ends once the batches are closed or an error is read
+func (t *streamTrace) fetch(ctx context.Context, rowsChan <-chan []RowData, errChan <-chan error) (<-chan []RowData, <-chan error) {
+	if t == nil {
+		return rowsChan, errChan
+	}
+
+	ctx, span := t.tracer.Start(ctx, "stream.fetch", trace.WithAttributes(stream.AttrTableName.String(t.table)))
+	out := make(chan []RowData)
+	outErr := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(outErr)
+
+		var rows int64
+		var fetchErr error
+		defer func() {
+			span.SetAttributes(stream.AttrRowCount.Int64(rows))
+			endSpan(span, fetchErr)
+		}()
+
+		for {
+			select {
+			case err, ok := <-errChan:
+				if !ok {
+					errChan = nil
+					continue
+				}
+				if err != nil {
+					fetchErr = err
+					outErr <- err
+					return
+				}
+
+			case batch, ok := <-rowsChan:
+				if !ok {
+					// Only an error that is already pending is still reported
+					select {
+					case err := <-errChan:
+						if err != nil {
+							fetchErr = err
+							outErr <- err
+						}
+					default:
+					}
+					return
+				}
+				select {
+				case out <- batch:
+					rows += int64(len(batch))
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, outErr
+}
+
+// transformSpan accumulates the rows transformed in a stream and the time
+// spent on them
+type transformSpan struct {
+	span    trace.Span
+	rows    int64
+	elapsed time.Duration
+}
+
+// startTransform starts the stream.transform span of a stream
+func (t *streamTrace) startTransform(ctx context.Context) *transformSpan {
+	if t == nil {
+		return nil
+	}
+	_, span := t.tracer.Start(ctx, "stream.transform", trace.WithAttributes(stream.AttrTableName.String(t.table)))
+	return &transformSpan{span: span}
+}
+
+// begin returns the start time of a transformed batch (zero when disabled)
+func (p *transformSpan) begin() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe records rows transformed in a batch started at started
+func (p *transformSpan) observe(rows int, started time.Time) {
+	if p == nil {
+		return
+	}
+	p.rows += int64(rows)
+	p.elapsed += time.Since(started)
+}
+
+// end records the totals and the error (if any) and ends the span
+func (p *transformSpan) end(err error) {
+	if p == nil {
+		return
+	}
+	p.span.SetAttributes(
+		stream.AttrRowCount.Int64(p.rows),
+		stream.AttrTransformMs.Int64(p.elapsed.Milliseconds()),
+	)
+	endSpan(p.span, err)
+}
@@ -8,16 +8,45 @@ import (
 
 // QueryPayload represents the incoming request payload
 type QueryPayload struct {
-	TableName     string        `json:"tableName" binding:"required"`
-	OrderBy       []string      `json:"orderBy"`
-	Limit         *int          `json:"limit" binding:"omitempty,min=1"` // Pointer to allow null, no max limit
-	Offset        int           `json:"offset" binding:"min=0"`
-	Where         []WhereClause `json:"where"`
-	Formulas      []Formula     `json:"formulas"`
-	IsFormatDate  bool          `json:"isFormatDate"`  // If true, format all date* fields to ISO 8601 GMT+7
-	IsDisableCount bool          `json:"isDisableCount"` // If true, skip COUNT(*) query for better performance
+	TableName      string                 `json:"tableName" binding:"required"`
+	OrderBy        []string               `json:"orderBy"`
+	Limit          *int                   `json:"limit"` // Pointer to allow null; see ValidatePayload for clamping
+	Offset         int                    `json:"offset" binding:"min=0"`
+	Where          []WhereClause          `json:"where"`
+	Formulas       []Formula              `json:"formulas"`
+	IsFormatDate   bool                   `json:"isFormatDate"`   // If true, format all date* fields to ISO 8601 GMT+7
+	IsDisableCount bool                   `json:"isDisableCount"` // If true, skip COUNT(*) query for better performance
+	DetectHasMore  bool                   `json:"detectHasMore"`  // If true, fetch Limit+1 rows to report X-Has-More without COUNT(*)
+	Params         map[string]interface{} `json:"params"`         // Named values for "$name" placeholders in WHERE values
+	Union          *UnionClause           `json:"union"`          // Optional second query combined via UNION / UNION ALL
+
+	// DisableDefaultOrder keeps the query without ORDER BY when orderBy is
+	// omitted, instead of applying the service's default ordering
+	DisableDefaultOrder bool `json:"disableDefaultOrder"`
+
+	// AllowUnbounded lets a missing or non-positive limit (and a limit above
+	// MaxLimit) export every matching row instead of being clamped to MaxLimit
+	AllowUnbounded bool `json:"allowUnbounded"`
+
+	// Summary declares aggregates emitted as a totals row after the detail rows
+	Summary []SummaryColumn `json:"summary"`
+
+	// Fields restricts the streamed output to these output fields, kept in
+	// row order; every formula still runs. ?fields= overrides it.
+	Fields []string `json:"fields"`
+
+	// Preview returns only the first Preview rows (at most MaxPreviewRows)
+	// with their field names and no COUNT(*); set from ?preview=, not JSON
+	Preview int `json:"-"`
 }
 
+// MaxLimit is the largest number of rows returned without allowUnbounded.
+// Payloads with a larger, missing or non-positive limit are clamped to it.
+var MaxLimit = 100000
+
+// MaxPreviewRows caps the rows returned in preview mode
+const MaxPreviewRows = 100
+
 // GetLimit returns the limit value, defaulting to 0 (unlimited) if not set
 func (q *QueryPayload) GetLimit() int {
 	if q.Limit == nil {
@@ -38,12 +67,38 @@ type WhereClause struct {
 	Value    interface{} `json:"value" binding:"required"`
 }
 
+// UnionClause declares a second query combined with the main one into a single
+// result set. It selects the same columns as the main query (derived from the
+// formulas), so only its source table and filters are declared. OrderBy, Limit
+// and Offset of the main payload apply to the combined result.
+type UnionClause struct {
+	TableName string        `json:"tableName" binding:"required"`
+	Where     []WhereClause `json:"where"`
+	All       bool          `json:"all"` // UNION ALL keeps duplicate rows
+}
+
+// ColumnRef marks a WHERE value as a reference to another column instead of a
+// literal, e.g. {"field": "updated_at", "op": ">", "value": {"column": "created_at"}}
+// produces `updated_at` > `created_at`
+type ColumnRef struct {
+	Column string `json:"column"`
+}
+
 // Formula represents a transformation formula
 type Formula struct {
-	Params   []string `json:"params" binding:"required"`
-	Field    string   `json:"field" binding:"required"`
-	Operator string   `json:"operator"`
-	Position int      `json:"position" binding:"required"`
+	Params       []string `json:"params" binding:"required"`
+	Field        string   `json:"field" binding:"required"`
+	Operator     string   `json:"operator"`
+	Position     int      `json:"position" binding:"required"`
+	OutputFields []string `json:"outputFields"` // When set, the operator's list result is spread over these fields instead of Field
+}
+
+// OutputNames returns the output field names produced by the formula
+func (f Formula) OutputNames() []string {
+	if len(f.OutputFields) > 0 {
+		return f.OutputFields
+	}
+	return []string{f.Field}
 }
 
 // ColumnMetadata holds metadata about a column from the database
@@ -164,20 +219,68 @@ var AllowedOperators = map[string]bool{
 
 // AllowedFormulaOperators is a whitelist of allowed formula operators
 var AllowedFormulaOperators = map[string]bool{
-	"":                 true, // Empty means pass-through (no transformation)
-	"ticketIdMasking":  true,
-	"difftime":         true,
-	"sentimentMapping": true,
-	"escalatedMapping": true,
-	"formatTime":       true,
-	"stripHTML":        true,
-	"contacts":         true,
-	"ticketDate":       true,
-	"additionalData":   true,
-	"decrypt":          true,
-	"stripDecrypt":     true,
-	"concat":           true,
-	"upper":            true,
-	"lower":            true,
-	"formatDate":       true,
+	"":                   true, // Empty means pass-through (no transformation)
+	"ticketIdMasking":    true,
+	"difftime":           true,
+	"sentimentMapping":   true,
+	"escalatedMapping":   true,
+	"formatTime":         true,
+	"stripHTML":          true,
+	"contacts":           true,
+	"ticketDate":         true,
+	"additionalData":     true,
+	"decrypt":            true,
+	"stripDecrypt":       true,
+	"concat":             true,
+	"upper":              true,
+	"lower":              true,
+	"formatDate":         true,
+	"percentOf":          true,
+	"convertCase":        true,
+	"scale":              true,
+	"bucket":             true,
+	"jsonMerge":          true,
+	"stripEmoji":         true,
+	"matches":            true,
+	"slugify":            true,
+	"changed":            true,
+	"diffText":           true,
+	"ageInDays":          true,
+	"translate":          true,
+	"countMatching":      true,
+	"splitToColumns":     true,
+	"jsonPath":           true,
+	"toJSON":             true,
+	"redact":             true,
+	"businessDuration":   true,
+	"substituteTemplate": true,
+	"sumFields":          true,
+	"avgFields":          true,
+	"parseDateFlexible":  true,
+	"coalesceDate":       true,
+	"formatBytes":        true,
+	"maskFormat":         true,
+	"dbEnum":             true,
+	"regexCapture":       true,
+	"parseKeyValue":      true,
+	"lengthInRange":      true,
+	"hashBucket":         true,
+	"jsonSet":            true,
+	"statusTimestamps":   true,
+	"padLeft":            true,
+	"padRight":           true,
+	"xmlStrip":           true,
+	"dedupeArray":        true,
+	"coalesceTracked":    true,
+	"ordinal":            true,
+	"numberToWords":      true,
+	"mostCommon":         true,
+	"datePart":           true,
+	"validateNationalId": true,
+	"jsonArrayFilter":    true,
+	"formatNumber":       true,
+	"hexEncode":          true,
+	"hexDecode":          true,
+	"base32Encode":       true,
+	"base32Decode":       true,
 }
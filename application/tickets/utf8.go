@@ -0,0 +1,79 @@
+package tickets
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/guregu/null/v5"
+)
+
+// UTF8Policy decides what happens to operator results that are not valid
+// UTF-8, such as decrypted values or text read from byte columns
+type UTF8Policy string
+
+const (
+	// UTF8Replace replaces every invalid byte sequence with U+FFFD (default)
+	UTF8Replace UTF8Policy = "replace"
+	// UTF8Null outputs the whole field as null
+	UTF8Null UTF8Policy = "null"
+)
+
+// ParseUTF8Policy returns the policy named name ("replace" or "null"); ok is
+// false for other names
+func ParseUTF8Policy(name string) (UTF8Policy, bool) {
+	switch policy := UTF8Policy(name); policy {
+	case UTF8Replace, UTF8Null:
+		return policy, true
+	default:
+		return "", false
+	}
+}
+
+// withUTF8Output wraps an operator so its result is valid UTF-8 text: byte
+// results ([]uint8, sql.RawBytes) are output as text instead of base64, and
+// invalid sequences are handled by policy. Only the top-level value is
+// checked; strings nested in maps or slices are left to the JSON encoder.
+func withUTF8Output(fn OperatorFunc, policy UTF8Policy) OperatorFunc {
+	return func(params []interface{}) (interface{}, error) {
+		value, err := fn(params)
+		if err != nil {
+			return value, err
+		}
+		return sanitizeUTF8(value, policy), nil
+	}
+}
+
+// sanitizeUTF8 applies policy to a single output value
+func sanitizeUTF8(value interface{}, policy UTF8Policy) interface{} {
+	switch val := value.(type) {
+	case string:
+		if utf8.ValidString(val) {
+			return val
+		}
+		return repairUTF8(val, policy)
+	case []uint8:
+		return repairUTF8(string(val), policy)
+	case sql.RawBytes:
+		return repairUTF8(string(val), policy)
+	case null.String:
+		if !val.Valid || utf8.ValidString(val.String) {
+			return val
+		}
+		return repairUTF8(val.String, policy)
+	default:
+		return value
+	}
+}
+
+// repairUTF8 returns text unchanged when it is valid, otherwise U+FFFD
+// replacements or null depending on policy
+func repairUTF8(text string, policy UTF8Policy) interface{} {
+	if utf8.ValidString(text) {
+		return text
+	}
+	if policy == UTF8Null {
+		return null.String{}
+	}
+	return strings.ToValidUTF8(text, "�")
+}
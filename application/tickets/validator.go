@@ -1,52 +1,66 @@
 package tickets
 
 import (
+	"errors"
 	"fmt"
+	"stream/common"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// ValidatePayload validates the incoming query payload
+// ValidatePayload validates the incoming query payload. Every problem is
+// reported, not only the first, so a client can fix them all at once: the
+// error joins one error per problem (see ValidationProblems), each naming
+// its place in the payload.
 func ValidatePayload(payload *QueryPayload) error {
+	var errs []error
+
 	// Normalize formulas before validation
 	// This auto-fills Field with Operator value when Field is empty
 	normalizeFormulas(payload.Formulas)
 
 	// Validate table name against whitelist
 	if !AllowedTables[payload.TableName] {
-		return fmt.Errorf("table '%s' is not allowed", payload.TableName)
-	}
-
-	// Validate limit if provided (only check minimum)
-	if payload.Limit != nil {
-		if *payload.Limit < 1 {
-			return fmt.Errorf("limit must be >= 1, got %d", *payload.Limit)
-		}
+		errs = append(errs, fmt.Errorf("table '%s' is not allowed", payload.TableName))
 	}
-	// If limit is null, GetLimit() will return 0 (unlimited)
 
 	// Validate offset
 	if payload.Offset < 0 {
-		return fmt.Errorf("offset must be >= 0, got %d", payload.Offset)
+		errs = append(errs, fmt.Errorf("offset must be >= 0, got %d", payload.Offset))
 	}
 
+	// Clamp the limit to MaxLimit unless the caller opted into an unbounded export
+	clampLimit(payload)
+
 	// Validate orderBy format
 	if len(payload.OrderBy) > 0 {
 		if err := validateOrderBy(payload.OrderBy); err != nil {
-			return fmt.Errorf("invalid orderBy: %w", err)
+			errs = append(errs, fmt.Errorf("invalid orderBy: %w", err))
 		}
 	}
 
 	// Validate WHERE clauses
 	for i, where := range payload.Where {
 		if err := validateWhereClause(&where); err != nil {
-			return fmt.Errorf("invalid where clause at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid where clause at index %d: %w", i, err))
 		}
 	}
 
+	// Resolve {"column": ...} WHERE values into column references
+	if err := resolveColumnRefs(payload.Where); err != nil {
+		errs = append(errs, fmt.Errorf("invalid where column reference: %w", err))
+	}
+
+	// Resolve named "$param" placeholders in WHERE values
+	if err := resolveWhereParams(payload.Where, payload.Params); err != nil {
+		errs = append(errs, fmt.Errorf("invalid where params: %w", err))
+	}
+
 	// Validate formulas
 	for i, formula := range payload.Formulas {
 		if err := validateFormula(&formula); err != nil {
-			return fmt.Errorf("invalid formula at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid formula at index %d: %w", i, err))
 		}
 	}
 
@@ -55,7 +69,79 @@ func ValidatePayload(payload *QueryPayload) error {
 
 	// Check for duplicate formula field names
 	if err := validateUniqueFieldNames(payload.Formulas); err != nil {
-		return err
+		errs = append(errs, err)
+	}
+
+	// Validate summary columns
+	if err := validateSummary(payload); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate the projected output fields
+	if err := validateFields(payload); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Validate UNION sub-query
+	if payload.Union != nil {
+		if err := validateUnion(payload); err != nil {
+			errs = append(errs, fmt.Errorf("invalid union: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidationProblems returns the message of every problem reported by a
+// ValidatePayload error, wrapped or not (e.g. in a common.ValidationError);
+// other errors are a single problem
+func ValidationProblems(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		var problems []string
+		for _, problem := range joined.Unwrap() {
+			problems = append(problems, problem.Error())
+		}
+		return problems
+	}
+
+	var validation *common.ValidationError
+	if errors.As(err, &validation) {
+		return []string{validation.Err.Error()}
+	}
+	return []string{err.Error()}
+}
+
+// validateUnion validates the UNION sub-query of a payload.
+// Both sides select the same columns; without formulas both sides are SELECT *,
+// which is only column-compatible when they read the same table.
+func validateUnion(payload *QueryPayload) error {
+	union := payload.Union
+
+	if !AllowedTables[union.TableName] {
+		return fmt.Errorf("table '%s' is not allowed", union.TableName)
+	}
+
+	if len(payload.Formulas) == 0 && union.TableName != payload.TableName {
+		return fmt.Errorf("union of '%s' and '%s' requires formulas to select matching columns", payload.TableName, union.TableName)
+	}
+
+	for i, where := range union.Where {
+		if err := validateWhereClause(&where); err != nil {
+			return fmt.Errorf("invalid where clause at index %d: %w", i, err)
+		}
+	}
+
+	if err := resolveColumnRefs(union.Where); err != nil {
+		return fmt.Errorf("invalid where column reference: %w", err)
+	}
+
+	if err := resolveWhereParams(union.Where, payload.Params); err != nil {
+		return fmt.Errorf("invalid where params: %w", err)
 	}
 
 	return nil
@@ -73,6 +159,27 @@ func normalizeFormulas(formulas []Formula) {
 	}
 }
 
+// clampLimit applies the limit semantics:
+//   - limit in 1..MaxLimit: used as-is
+//   - limit missing, <= 0 or above MaxLimit: MaxLimit, or no limit (nil, so
+//     GetLimit returns 0) when AllowUnbounded is set
+func clampLimit(payload *QueryPayload) {
+	limit := payload.GetLimit()
+	if limit >= 1 && limit <= MaxLimit {
+		return
+	}
+
+	if payload.AllowUnbounded {
+		if limit < 1 {
+			payload.Limit = nil
+		}
+		return
+	}
+
+	clamped := MaxLimit
+	payload.Limit = &clamped
+}
+
 // validateOrderBy validates the orderBy array
 // Expected format: ["field_name", "asc|desc"]
 func validateOrderBy(orderBy []string) error {
@@ -123,6 +230,163 @@ func validateWhereClause(where *WhereClause) error {
 	return nil
 }
 
+// resolveWhereParams replaces "$name" placeholders in WHERE values with values from params.
+// Placeholders are strings of the form "$" + identifier (e.g. "$statusParam"); strings that
+// do not match this pattern (e.g. "$100") are treated as literals.
+// Modifies where clauses in-place, like normalizeFormulas.
+//
+// Type checking:
+//   - IN / NOT IN accept a scalar or an array of scalars
+//   - All other operators accept scalars only (string, number, bool, null)
+func resolveWhereParams(where []WhereClause, params map[string]interface{}) error {
+	for i := range where {
+		upperOp := strings.ToUpper(where[i].Operator)
+		isList := upperOp == "IN" || upperOp == "NOT IN"
+
+		switch v := where[i].Value.(type) {
+		case string:
+			name, ok := placeholderName(v)
+			if !ok {
+				continue
+			}
+			value, err := lookupParam(name, params, isList)
+			if err != nil {
+				return fmt.Errorf("where clause at index %d: %w", i, err)
+			}
+			where[i].Value = value
+
+		case []interface{}:
+			// Resolve placeholders inside IN lists element by element
+			resolved := make([]interface{}, len(v))
+			for j, item := range v {
+				resolved[j] = item
+				str, isStr := item.(string)
+				if !isStr {
+					continue
+				}
+				name, ok := placeholderName(str)
+				if !ok {
+					continue
+				}
+				value, err := lookupParam(name, params, false)
+				if err != nil {
+					return fmt.Errorf("where clause at index %d: %w", i, err)
+				}
+				resolved[j] = value
+			}
+			where[i].Value = resolved
+		}
+	}
+
+	return nil
+}
+
+// resolveColumnRefs converts WHERE values of the form {"column": "name"} into
+// ColumnRef and validates the referenced column name.
+// Modifies where clauses in-place, like resolveWhereParams.
+func resolveColumnRefs(where []WhereClause) error {
+	for i := range where {
+		var ref ColumnRef
+		switch v := where[i].Value.(type) {
+		case ColumnRef:
+			ref = v
+		case map[string]interface{}:
+			column, ok := v["column"].(string)
+			if !ok || len(v) != 1 {
+				return fmt.Errorf("where clause at index %d: object values must be {\"column\": \"name\"}", i)
+			}
+			ref = ColumnRef{Column: column}
+		default:
+			continue
+		}
+
+		upperOp := strings.ToUpper(where[i].Operator)
+		if upperOp == "IN" || upperOp == "NOT IN" {
+			return fmt.Errorf("where clause at index %d: column reference is not supported with %s", i, where[i].Operator)
+		}
+
+		if !isIdentifier(ref.Column) {
+			return fmt.Errorf("where clause at index %d: invalid column reference '%s'", i, ref.Column)
+		}
+
+		where[i].Value = ref
+	}
+
+	return nil
+}
+
+// isIdentifier reports whether s is a plain column name (letters, digits and
+// underscores, not starting with a digit)
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, ch := range s {
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		isDigit := ch >= '0' && ch <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// placeholderName returns the parameter name if s is a "$identifier" placeholder
+func placeholderName(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '$' {
+		return "", false
+	}
+
+	name := s[1:]
+	for i, ch := range name {
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		isDigit := ch >= '0' && ch <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return "", false
+		}
+	}
+
+	return name, true
+}
+
+// lookupParam fetches a named parameter and checks that its type is bindable
+func lookupParam(name string, params map[string]interface{}, allowList bool) (interface{}, error) {
+	value, exists := params[name]
+	if !exists {
+		return nil, fmt.Errorf("missing value for parameter '$%s'", name)
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		if !allowList {
+			return nil, fmt.Errorf("parameter '$%s' must be a scalar value, got array", name)
+		}
+		for _, item := range list {
+			if !isScalarParam(item) {
+				return nil, fmt.Errorf("parameter '$%s' contains unsupported value type %T", name, item)
+			}
+		}
+		return list, nil
+	}
+
+	if !isScalarParam(value) {
+		return nil, fmt.Errorf("parameter '$%s' has unsupported value type %T", name, value)
+	}
+
+	return value, nil
+}
+
+// isScalarParam reports whether a value can be bound as a single SQL argument
+func isScalarParam(value interface{}) bool {
+	switch value.(type) {
+	case nil, string, bool, float64, float32, int, int64, int32:
+		return true
+	default:
+		return false
+	}
+}
+
 // validateFormula validates a single formula
 func validateFormula(formula *Formula) error {
 	if len(formula.Params) == 0 {
@@ -132,11 +396,23 @@ func validateFormula(formula *Formula) error {
 	if formula.Field == "" {
 		return fmt.Errorf("formula field cannot be empty")
 	}
+	if err := checkFieldName(formula.Field); err != nil {
+		return fmt.Errorf("invalid formula field %q: %w", formula.Field, err)
+	}
 
 	if formula.Position < 0 {
 		return fmt.Errorf("formula position must be >= 0, got %d", formula.Position)
 	}
 
+	for _, name := range formula.OutputFields {
+		if name == "" {
+			return fmt.Errorf("formula outputFields cannot contain empty names")
+		}
+		if err := checkFieldName(name); err != nil {
+			return fmt.Errorf("invalid formula outputFields name %q: %w", name, err)
+		}
+	}
+
 	// Validate operator against whitelist
 	if !AllowedFormulaOperators[formula.Operator] {
 		return fmt.Errorf("formula operator '%s' is not allowed", formula.Operator)
@@ -145,7 +421,11 @@ func validateFormula(formula *Formula) error {
 	// Validate params
 	// Note: SQL expressions are allowed in params (e.g., "COALESCE(...) AS alias")
 	// We only validate simple column names, not SQL expressions
-	for _, param := range formula.Params {
+	for i, param := range formula.Params {
+		// A blank param names no column and would be selected as ``
+		if isBlankColumn(param) {
+			return fmt.Errorf("formula param %d is blank: params must name a column or be a SQL expression", i)
+		}
 		// Skip validation for SQL expressions (they contain SQL functions or AS keyword)
 		if isSQLExpressionParam(param) {
 			// SQL expressions are allowed - skip validation
@@ -174,12 +454,29 @@ func validateUniquePositions(formulas []Formula) error {
 
 // validateUniqueFieldNames ensures no duplicate field names in formulas
 func validateUniqueFieldNames(formulas []Formula) error {
-	fields := make(map[string]bool)
-	for _, formula := range formulas {
-		if fields[formula.Field] {
-			return fmt.Errorf("duplicate formula field name: %s", formula.Field)
+	fields := make(map[string]int) // index of the formula producing each name
+	for i, formula := range formulas {
+		for _, name := range formula.OutputNames() {
+			if first, ok := fields[name]; ok {
+				return fmt.Errorf("duplicate formula field name: %s (formulas at index %d and %d)", name, first, i)
+			}
+			fields[name] = i
+		}
+	}
+	return nil
+}
+
+// checkFieldName checks that an output field name can be written as a JSON
+// object key as-is: valid UTF-8 without control characters (which would be
+// escaped and no longer match the name the client asked for)
+func checkFieldName(name string) error {
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("name is not valid UTF-8")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("name contains control character %U", r)
 		}
-		fields[formula.Field] = true
 	}
 	return nil
 }
@@ -1,6 +1,9 @@
 package tickets
 
 import (
+	"errors"
+	"stream/common"
+	"strings"
 	"testing"
 )
 
@@ -344,3 +347,314 @@ func TestContainsSuspiciousChars(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePayload_NamedParams(t *testing.T) {
+	t.Run("resolves named parameter into query args", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$statusParam"},
+				{Field: "priority", Operator: "IN", Value: "$priorities"},
+				{Field: "customer_id", Operator: "NOT IN", Value: []interface{}{"$excluded", float64(9)}},
+			},
+			Params: map[string]interface{}{
+				"statusParam": "open",
+				"priorities":  []interface{}{"high", "urgent"},
+				"excluded":    float64(3),
+			},
+			AllowUnbounded: true,
+		}
+
+		if err := ValidatePayload(payload); err != nil {
+			t.Fatalf("ValidatePayload() error = %v", err)
+		}
+
+		query, args := NewQueryBuilder(payload).BuildSelectQuery()
+
+		expectedQuery := "SELECT * FROM `tickets` WHERE `status` = ? AND `priority` IN (?, ?) AND `customer_id` NOT IN (?, ?)"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+
+		expectedArgs := []interface{}{"open", "high", "urgent", float64(3), float64(9)}
+		if len(args) != len(expectedArgs) {
+			t.Fatalf("Expected %d args, got %d: %v", len(expectedArgs), len(args), args)
+		}
+		for i, want := range expectedArgs {
+			if args[i] != want {
+				t.Errorf("Expected arg %d to be %v, got %v", i, want, args[i])
+			}
+		}
+	})
+
+	t.Run("literal dollar values are not treated as placeholders", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "subject", Operator: "=", Value: "$100"},
+			},
+		}
+
+		if err := ValidatePayload(payload); err != nil {
+			t.Fatalf("ValidatePayload() error = %v", err)
+		}
+		if payload.Where[0].Value != "$100" {
+			t.Errorf("Expected literal value to be preserved, got %v", payload.Where[0].Value)
+		}
+	})
+
+	t.Run("unresolved placeholder returns error", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$statusParam"},
+			},
+			Params: map[string]interface{}{"other": "value"},
+		}
+
+		err := ValidatePayload(payload)
+		if err == nil {
+			t.Fatal("Expected error for unresolved placeholder")
+		}
+		if !strings.Contains(err.Error(), "$statusParam") {
+			t.Errorf("Expected error to mention the parameter name, got %v", err)
+		}
+	})
+
+	t.Run("array parameter for scalar operator returns error", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$statuses"},
+			},
+			Params: map[string]interface{}{"statuses": []interface{}{"open", "closed"}},
+		}
+
+		if err := ValidatePayload(payload); err == nil {
+			t.Error("Expected type error for array parameter with '=' operator")
+		}
+	})
+
+	t.Run("object parameter returns error", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$status"},
+			},
+			Params: map[string]interface{}{"status": map[string]interface{}{"a": 1}},
+		}
+
+		if err := ValidatePayload(payload); err == nil {
+			t.Error("Expected type error for object parameter")
+		}
+	})
+}
+
+func TestValidatePayload_ColumnRef(t *testing.T) {
+	t.Run("compares against another column", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "updated_at", Operator: ">", Value: map[string]interface{}{"column": "created_at"}},
+				{Field: "status", Operator: "=", Value: "open"},
+			},
+			AllowUnbounded: true,
+		}
+
+		if err := ValidatePayload(payload); err != nil {
+			t.Fatalf("ValidatePayload() error = %v", err)
+		}
+
+		query, args := NewQueryBuilder(payload).BuildSelectQuery()
+
+		expectedQuery := "SELECT * FROM `tickets` WHERE `updated_at` > `created_at` AND `status` = ?"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+		if len(args) != 1 || args[0] != "open" {
+			t.Errorf("Expected args [open], got %v", args)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		where WhereClause
+	}{
+		{"invalid identifier", WhereClause{Field: "updated_at", Operator: ">", Value: map[string]interface{}{"column": "created_at; DROP TABLE tickets"}}},
+		{"expression instead of column", WhereClause{Field: "updated_at", Operator: ">", Value: ColumnRef{Column: "created_at + 1"}}},
+		{"empty column", WhereClause{Field: "updated_at", Operator: ">", Value: map[string]interface{}{"column": ""}}},
+		{"extra keys", WhereClause{Field: "updated_at", Operator: ">", Value: map[string]interface{}{"column": "created_at", "x": 1}}},
+		{"used with IN", WhereClause{Field: "id", Operator: "IN", Value: map[string]interface{}{"column": "parent_id"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &QueryPayload{
+				TableName: "tickets",
+				Where:     []WhereClause{tt.where},
+			}
+
+			if err := ValidatePayload(payload); err == nil {
+				t.Error("Expected error for invalid column reference")
+			}
+		})
+	}
+}
+
+func TestValidatePayload_Limit(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name           string
+		limit          *int
+		offset         int
+		allowUnbounded bool
+		wantLimit      int
+		wantError      bool
+	}{
+		{name: "limit within range", limit: intPtr(50), wantLimit: 50},
+		{name: "limit at MaxLimit", limit: intPtr(MaxLimit), wantLimit: MaxLimit},
+		{name: "missing limit defaults to MaxLimit", wantLimit: MaxLimit},
+		{name: "zero limit defaults to MaxLimit", limit: intPtr(0), wantLimit: MaxLimit},
+		{name: "negative limit defaults to MaxLimit", limit: intPtr(-5), wantLimit: MaxLimit},
+		{name: "huge limit is clamped", limit: intPtr(50_000_000), wantLimit: MaxLimit},
+		{name: "zero limit with allowUnbounded", limit: intPtr(0), allowUnbounded: true, wantLimit: 0},
+		{name: "missing limit with allowUnbounded", allowUnbounded: true, wantLimit: 0},
+		{name: "huge limit with allowUnbounded", limit: intPtr(50_000_000), allowUnbounded: true, wantLimit: 50_000_000},
+		{name: "negative offset", limit: intPtr(10), offset: -1, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &QueryPayload{
+				TableName:      "tickets",
+				Limit:          tt.limit,
+				Offset:         tt.offset,
+				AllowUnbounded: tt.allowUnbounded,
+			}
+
+			err := ValidatePayload(payload)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("ValidatePayload() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if got := payload.GetLimit(); got != tt.wantLimit {
+				t.Errorf("GetLimit() = %d, want %d", got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestValidatePayload_FieldNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		formulas []Formula
+		wantErr  string
+	}{
+		{
+			name: "duplicate field names",
+			formulas: []Formula{
+				{Params: []string{"id"}, Field: "ticket", Position: 1},
+				{Params: []string{"subject"}, Field: "subject", Position: 2},
+				{Params: []string{"ticket_no"}, Field: "ticket", Position: 3},
+			},
+			wantErr: "duplicate formula field name: ticket (formulas at index 0 and 2)",
+		},
+		{
+			name: "field name repeated by outputFields",
+			formulas: []Formula{
+				{Params: []string{"name"}, Field: "first", Position: 1},
+				{Params: []string{"name"}, Operator: "splitToColumns", OutputFields: []string{"first", "last"}, Position: 2},
+			},
+			wantErr: "duplicate formula field name: first (formulas at index 0 and 1)",
+		},
+		{
+			name:     "empty field name",
+			formulas: []Formula{{Params: []string{"id"}, Field: "", Position: 1}},
+			wantErr:  "formula field cannot be empty",
+		},
+		{
+			name:     "control character in field name",
+			formulas: []Formula{{Params: []string{"id"}, Field: "ticket\nid", Position: 1}},
+			wantErr:  `invalid formula field "ticket\nid": name contains control character U+000A`,
+		},
+		{
+			name:     "invalid UTF-8 in field name",
+			formulas: []Formula{{Params: []string{"id"}, Field: "ticket\xff", Position: 1}},
+			wantErr:  "name is not valid UTF-8",
+		},
+		{
+			name:     "control character in outputFields",
+			formulas: []Formula{{Params: []string{"name"}, Field: "name", Operator: "splitToColumns", OutputFields: []string{"first", "last\t"}, Position: 1}},
+			wantErr:  "name contains control character U+0009",
+		},
+		{
+			name:     "blank param",
+			formulas: []Formula{{Params: []string{"id", " "}, Field: "id", Position: 1}},
+			wantErr:  "invalid formula at index 0: formula param 1 is blank",
+		},
+		{
+			name:     "backticks only param",
+			formulas: []Formula{{Params: []string{"``"}, Field: "id", Position: 1}},
+			wantErr:  "formula param 0 is blank",
+		},
+		{
+			name: "unicode and punctuation are valid",
+			formulas: []Formula{
+				{Params: []string{"id"}, Field: "nomor tiket", Position: 1},
+				{Params: []string{"subject"}, Field: "subjek/ringkasan (café)", Position: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePayload(&QueryPayload{TableName: "tickets", Formulas: tt.formulas})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePayload() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePayload() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePayload_AllProblems(t *testing.T) {
+	payload := &QueryPayload{
+		TableName: "tickets",
+		OrderBy:   []string{"id", "sideways"},
+		Formulas: []Formula{
+			{Params: []string{"id"}, Field: "id", Operator: "noSuchOperator", Position: 1},
+			{Params: []string{"subject"}, Field: "subject", Position: 2},
+			{Params: []string{"ticket_no"}, Field: "subject", Position: 3},
+		},
+	}
+
+	err := ValidatePayload(payload)
+	if err == nil {
+		t.Fatal("ValidatePayload() error = nil, want three problems")
+	}
+
+	want := []string{
+		"invalid orderBy: orderBy direction must be 'asc' or 'desc', got 'sideways'",
+		"invalid formula at index 0: formula operator 'noSuchOperator' is not allowed",
+		"duplicate formula field name: subject (formulas at index 1 and 2)",
+	}
+	problems := ValidationProblems(common.NewValidationError(err))
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ValidationProblems() = %q, want %q", problems, want)
+	}
+
+	if got := ValidationProblems(common.NewValidationError(errors.New("offset is not supported"))); len(got) != 1 || got[0] != "offset is not supported" {
+		t.Errorf("ValidationProblems() of a single error = %q", got)
+	}
+	if got := ValidationProblems(nil); got != nil {
+		t.Errorf("ValidationProblems(nil) = %q, want nil", got)
+	}
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"database/sql"
+	"stream/internal/stream"
 	"stream/middleware"
 )
 
@@ -24,6 +25,18 @@ type Repository interface {
 	Close() error
 }
 
+// DataSource defines where streamed rows come from. The service only depends on
+// this interface, so SQL, REST APIs or files can feed the same formula pipeline.
+type DataSource interface {
+	// Count returns the total number of rows matching the payload
+	Count(ctx context.Context, payload *QueryPayload) (int64, error)
+
+	// Fetch opens the row stream for the payload. Formulas are used to pick the
+	// fields to load; the returned pass-through formulas cover every available
+	// field and are used when the payload has no formulas.
+	Fetch(ctx context.Context, payload *QueryPayload, formulas []Formula) (stream.DataFetcher[RowData], []Formula, error)
+}
+
 // QueryBuilder defines the interface for building SQL queries
 type QueryBuilder interface {
 	// SetSelectColumns sets the columns to select
@@ -74,6 +87,14 @@ type Service interface {
 	// StreamTicketsBatch streams ticket data using batch processing for better performance
 	StreamTicketsBatch(ctx context.Context, payload *QueryPayload) middleware.StreamResponse
 
+	// PublishTickets publishes each transformed row as a message (keyed by
+	// ticket id) to the configured sink instead of streaming an HTTP body
+	PublishTickets(ctx context.Context, payload *QueryPayload) (PublishSummary, error)
+
 	// LogRequest logs request information
 	LogRequest(requestID string, payload *QueryPayload, duration interface{}, err error)
+
+	// SetOperators replaces the operator registry (e.g. on a config reload);
+	// in-flight streams keep the registry they started with
+	SetOperators(operators map[string]OperatorFunc)
 }
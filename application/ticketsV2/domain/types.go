@@ -1,23 +1,31 @@
 package domain
 
 import (
-	json "github.com/json-iterator/go"
 	"github.com/guregu/null/v5"
+	json "github.com/json-iterator/go"
 )
 
 // QueryPayload represents the incoming request payload
 // Maintains full compatibility with tickets v1
 type QueryPayload struct {
-	TableName      string        `json:"tableName" binding:"required"`
-	OrderBy        []string      `json:"orderBy"`
-	Limit          *int          `json:"limit" binding:"omitempty,min=1"`
-	Offset         int           `json:"offset" binding:"min=0"`
-	Where          []WhereClause `json:"where"`
-	Formulas       []Formula     `json:"formulas"`
-	IsFormatDate   bool          `json:"isFormatDate"`
-	IsDisableCount bool          `json:"isDisableCount"`
+	TableName      string                 `json:"tableName" binding:"required"`
+	OrderBy        []string               `json:"orderBy"`
+	Limit          *int                   `json:"limit"`
+	Offset         int                    `json:"offset" binding:"min=0"`
+	Where          []WhereClause          `json:"where"`
+	Formulas       []Formula              `json:"formulas"`
+	IsFormatDate   bool                   `json:"isFormatDate"`
+	IsDisableCount bool                   `json:"isDisableCount"`
+	DetectHasMore  bool                   `json:"detectHasMore"`  // If true, fetch Limit+1 rows to report X-Has-More without COUNT(*)
+	Params         map[string]interface{} `json:"params"`         // Named values for "$name" placeholders in WHERE values
+	Union          *UnionClause           `json:"union"`          // Optional second query combined via UNION / UNION ALL
+	AllowUnbounded bool                   `json:"allowUnbounded"` // If true, a missing or non-positive limit means no limit instead of MaxLimit
 }
 
+// MaxLimit is the largest number of rows returned without allowUnbounded.
+// Payloads with a larger, missing or non-positive limit are clamped to it.
+var MaxLimit = 100000
+
 // GetLimit returns the limit value, defaulting to 0 (unlimited) if not set
 func (q *QueryPayload) GetLimit() int {
 	if q.Limit == nil {
@@ -38,12 +46,38 @@ type WhereClause struct {
 	Value    interface{} `json:"value" binding:"required"`
 }
 
+// UnionClause declares a second query combined with the main one into a single
+// result set. It selects the same columns as the main query (derived from the
+// formulas), so only its source table and filters are declared. OrderBy, Limit
+// and Offset of the main payload apply to the combined result.
+type UnionClause struct {
+	TableName string        `json:"tableName" binding:"required"`
+	Where     []WhereClause `json:"where"`
+	All       bool          `json:"all"` // UNION ALL keeps duplicate rows
+}
+
+// ColumnRef marks a WHERE value as a reference to another column instead of a
+// literal, e.g. {"field": "updated_at", "op": ">", "value": {"column": "created_at"}}
+// produces `updated_at` > `created_at`
+type ColumnRef struct {
+	Column string `json:"column"`
+}
+
 // Formula represents a transformation formula
 type Formula struct {
-	Params   []string `json:"params" binding:"required"`
-	Field    string   `json:"field" binding:"required"`
-	Operator string   `json:"operator"`
-	Position int      `json:"position" binding:"required"`
+	Params       []string `json:"params" binding:"required"`
+	Field        string   `json:"field" binding:"required"`
+	Operator     string   `json:"operator"`
+	Position     int      `json:"position" binding:"required"`
+	OutputFields []string `json:"outputFields"` // When set, the operator's list result is spread over these fields instead of Field
+}
+
+// OutputNames returns the output field names produced by the formula
+func (f Formula) OutputNames() []string {
+	if len(f.OutputFields) > 0 {
+		return f.OutputFields
+	}
+	return []string{f.Field}
 }
 
 // ColumnMetadata holds metadata about a column from the database
@@ -122,6 +156,13 @@ func (tr TransformedRow) Fields() []TransformedField {
 	return tr.fields
 }
 
+// PublishSummary reports the outcome of publishing an export to a message sink
+type PublishSummary struct {
+	Topic     string `json:"topic"`
+	Published int64  `json:"published"`       // Number of rows acknowledged by the sink
+	Error     string `json:"error,omitempty"` // Set when publishing stopped early
+}
+
 // OperatorFunc represents a formula operator function signature
 type OperatorFunc func(params []interface{}) (interface{}, error)
 
@@ -190,5 +231,52 @@ var (
 		"transactionState":    true,
 		"length":              true,
 		"processSurveyAnswer": true,
+		"percentOf":           true,
+		"convertCase":         true,
+		"scale":               true,
+		"bucket":              true,
+		"jsonMerge":           true,
+		"stripEmoji":          true,
+		"matches":             true,
+		"slugify":             true,
+		"changed":             true,
+		"diffText":            true,
+		"ageInDays":           true,
+		"translate":           true,
+		"countMatching":       true,
+		"splitToColumns":      true,
+		"jsonPath":            true,
+		"toJSON":              true,
+		"redact":              true,
+		"businessDuration":    true,
+		"substituteTemplate":  true,
+		"sumFields":           true,
+		"avgFields":           true,
+		"parseDateFlexible":   true,
+		"coalesceDate":        true,
+		"formatBytes":         true,
+		"maskFormat":          true,
+		"regexCapture":        true,
+		"parseKeyValue":       true,
+		"lengthInRange":       true,
+		"hashBucket":          true,
+		"jsonSet":             true,
+		"statusTimestamps":    true,
+		"padLeft":             true,
+		"padRight":            true,
+		"xmlStrip":            true,
+		"dedupeArray":         true,
+		"coalesceTracked":     true,
+		"ordinal":             true,
+		"numberToWords":       true,
+		"mostCommon":          true,
+		"datePart":            true,
+		"validateNationalId":  true,
+		"jsonArrayFilter":     true,
+		"formatNumber":        true,
+		"hexEncode":           true,
+		"hexDecode":           true,
+		"base32Encode":        true,
+		"base32Decode":        true,
 	}
 )
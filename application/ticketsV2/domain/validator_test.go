@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -22,6 +23,34 @@ func TestValidator_Validate(t *testing.T) {
 		}
 	})
 
+	t.Run("duplicate and control-character field names", func(t *testing.T) {
+		for _, formulas := range [][]Formula{
+			{
+				{Params: []string{"id"}, Field: "id", Operator: "", Position: 1},
+				{Params: []string{"subject"}, Field: "id", Operator: "", Position: 2},
+			},
+			{
+				{Params: []string{"id"}, Field: "id\x00", Operator: "", Position: 1},
+			},
+		} {
+			if err := validator.Validate(&QueryPayload{TableName: "tickets", Formulas: formulas}); err == nil {
+				t.Errorf("Expected error for field names %q", formulas[len(formulas)-1].Field)
+			}
+		}
+	})
+
+	t.Run("blank params", func(t *testing.T) {
+		for _, params := range [][]string{{""}, {"id", "  "}, {"``"}} {
+			payload := &QueryPayload{
+				TableName: "tickets",
+				Formulas:  []Formula{{Params: params, Field: "id", Operator: "", Position: 1}},
+			}
+			if err := validator.Validate(payload); err == nil || !strings.Contains(err.Error(), "is blank") {
+				t.Errorf("Expected blank param error for %q, got %v", params, err)
+			}
+		}
+	})
+
 	t.Run("invalid table name", func(t *testing.T) {
 		payload := &QueryPayload{
 			TableName: "invalid_table",
@@ -191,3 +220,103 @@ func TestContainsSuspiciousChars(t *testing.T) {
 		})
 	}
 }
+
+func TestValidator_NamedParams(t *testing.T) {
+	validator := NewValidator()
+
+	t.Run("resolves placeholder from params", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$statusParam"},
+			},
+			Params: map[string]interface{}{"statusParam": "open"},
+		}
+
+		if err := validator.Validate(payload); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if payload.Where[0].Value != "open" {
+			t.Errorf("Expected resolved value 'open', got %v", payload.Where[0].Value)
+		}
+	})
+
+	t.Run("unresolved placeholder", func(t *testing.T) {
+		payload := &QueryPayload{
+			TableName: "tickets",
+			Where: []WhereClause{
+				{Field: "status", Operator: "=", Value: "$statusParam"},
+			},
+		}
+
+		if err := validator.Validate(payload); err == nil {
+			t.Error("Expected error for unresolved placeholder")
+		}
+	})
+}
+
+func TestValidator_Limit(t *testing.T) {
+	validator := NewValidator()
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name           string
+		limit          *int
+		offset         int
+		allowUnbounded bool
+		wantLimit      int
+		wantError      bool
+	}{
+		{name: "limit within range", limit: intPtr(50), wantLimit: 50},
+		{name: "missing limit defaults to MaxLimit", wantLimit: MaxLimit},
+		{name: "zero limit defaults to MaxLimit", limit: intPtr(0), wantLimit: MaxLimit},
+		{name: "huge limit is clamped", limit: intPtr(50_000_000), wantLimit: MaxLimit},
+		{name: "zero limit with allowUnbounded", limit: intPtr(0), allowUnbounded: true, wantLimit: 0},
+		{name: "huge limit with allowUnbounded", limit: intPtr(50_000_000), allowUnbounded: true, wantLimit: 50_000_000},
+		{name: "negative offset", limit: intPtr(10), offset: -1, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &QueryPayload{
+				TableName:      "tickets",
+				Limit:          tt.limit,
+				Offset:         tt.offset,
+				AllowUnbounded: tt.allowUnbounded,
+			}
+
+			err := validator.Validate(payload)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("Validate() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+			if got := payload.GetLimit(); got != tt.wantLimit {
+				t.Errorf("GetLimit() = %d, want %d", got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestValidator_AllProblems(t *testing.T) {
+	payload := &QueryPayload{
+		TableName: "tickets",
+		OrderBy:   []string{"id", "sideways"},
+		Formulas: []Formula{
+			{Params: []string{"id"}, Field: "id", Operator: "noSuchOperator", Position: 1},
+			{Params: []string{"subject"}, Field: "subject", Position: 2},
+			{Params: []string{"ticket_no"}, Field: "subject", Position: 3},
+		},
+	}
+
+	err := NewValidator().Validate(payload)
+	if err == nil {
+		t.Fatal("Validate() error = nil, want three problems")
+	}
+	for _, want := range []string{"orderBy direction", "formula operator 'noSuchOperator' is not allowed", "duplicate formula field name: subject"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want it to report %q", err, want)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 	"stream/application/ticketsV2/domain"
+	"stream/common"
 	"stream/middleware"
 	"time"
 
@@ -21,18 +23,31 @@ func NewHandler(service domain.Service) *Handler {
 
 // RegisterRoutes registers the handler routes
 func (h *Handler) RegisterRoutes(api *gin.RouterGroup) {
-	tickets := api.Group("/v2/tickets")
-	{
-		tickets.POST("/stream", h.StreamTickets)
-		tickets.POST("/stream/batch", h.StreamTicketsBatch)
-	}
+	h.RegisterRoutesWithPrefix(api.Group("/v2/tickets"))
 }
 
 // RegisterRoutesWithPrefix registers the handler routes with a custom prefix
 // This is used to create separate endpoints for different databases
 func (h *Handler) RegisterRoutesWithPrefix(group *gin.RouterGroup) {
-	group.POST("/stream", h.StreamTickets)
-	group.POST("/stream/batch", h.StreamTicketsBatch)
+	RegisterRoutesWithSelector(group, func(*gin.Context) *Handler { return h })
+}
+
+// RegisterRoutesWithSelector registers the handler routes on group, serving
+// every request with the handler selectHandler returns for it (e.g. the
+// handler of the database named by a header). A nil handler means
+// selectHandler has already answered the request. The other Register
+// methods use it, so the routes are listed here only.
+func RegisterRoutesWithSelector(group *gin.RouterGroup, selectHandler func(c *gin.Context) *Handler) {
+	selected := func(route func(*Handler, *gin.Context)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if h := selectHandler(c); h != nil {
+				route(h, c)
+			}
+		}
+	}
+	group.POST("/stream", selected((*Handler).StreamTickets))
+	group.POST("/stream/batch", selected((*Handler).StreamTicketsBatch))
+	group.POST("/stream/publish", selected((*Handler).PublishTickets))
 }
 
 // StreamTickets handles the POST /v2/tickets/stream endpoint
@@ -43,11 +58,11 @@ func (h *Handler) StreamTickets(c *gin.Context) {
 
 	// Parse and bind payload
 	var payload domain.QueryPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := middleware.BindStrictJSON(c, &payload); err != nil {
 		send := c.MustGet("send").(func(middleware.Response))
 		send(middleware.Response{
-			Code:    http.StatusBadRequest,
-			Message: "Invalid JSON payload",
+			Code:    common.HTTPStatus(err),
+			Message: middleware.InvalidPayloadMessage(err),
 			Error:   err,
 		})
 		return
@@ -63,6 +78,14 @@ func (h *Handler) StreamTickets(c *gin.Context) {
 	duration := time.Since(startTime)
 	h.svc.LogRequest(requestID, &payload, duration, response.Error)
 
+	// Map typed errors (validation, query, cancellation) to HTTP status codes
+	if response.Error != nil {
+		response.Code = common.HTTPStatus(response.Error)
+	}
+
+	// Expose total count before the body starts streaming
+	setTotalCountHeader(c, response)
+
 	// Send streaming response
 	sendStream(response)
 }
@@ -75,11 +98,11 @@ func (h *Handler) StreamTicketsBatch(c *gin.Context) {
 
 	// Parse and bind payload
 	var payload domain.QueryPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := middleware.BindStrictJSON(c, &payload); err != nil {
 		send := c.MustGet("send").(func(middleware.Response))
 		send(middleware.Response{
-			Code:    http.StatusBadRequest,
-			Message: "Invalid JSON payload",
+			Code:    common.HTTPStatus(err),
+			Message: middleware.InvalidPayloadMessage(err),
 			Error:   err,
 		})
 		return
@@ -95,6 +118,68 @@ func (h *Handler) StreamTicketsBatch(c *gin.Context) {
 	duration := time.Since(startTime)
 	h.svc.LogRequest(requestID, &payload, duration, response.Error)
 
+	// Map typed errors (validation, query, cancellation) to HTTP status codes
+	if response.Error != nil {
+		response.Code = common.HTTPStatus(response.Error)
+	}
+
+	// Expose total count before the body starts streaming
+	setTotalCountHeader(c, response)
+
 	// Send streaming response
 	sendStream(response)
 }
+
+// PublishTickets handles the POST /v2/tickets/stream/publish endpoint.
+// Rows are published to the configured message sink and a summary is returned.
+func (h *Handler) PublishTickets(c *gin.Context) {
+	send := c.MustGet("send").(func(middleware.Response))
+	requestID := c.GetString("requestId")
+	startTime := time.Now()
+
+	// Parse and bind payload
+	var payload domain.QueryPayload
+	if err := middleware.BindStrictJSON(c, &payload); err != nil {
+		send(middleware.Response{
+			Code:    common.HTTPStatus(err),
+			Message: middleware.InvalidPayloadMessage(err),
+			Error:   err,
+		})
+		return
+	}
+
+	// Log request start
+	h.svc.LogRequest(requestID, &payload, 0, nil)
+
+	// Publish rows to the message sink
+	summary, err := h.svc.PublishTickets(c.Request.Context(), &payload)
+
+	// Log request completion
+	duration := time.Since(startTime)
+	h.svc.LogRequest(requestID, &payload, duration, err)
+
+	if err != nil {
+		send(middleware.Response{
+			Code:    common.HTTPStatus(err),
+			Message: "Publish failed",
+			Data:    summary,
+			Error:   err,
+		})
+		return
+	}
+
+	send(middleware.Response{
+		Code:    http.StatusOK,
+		Message: "Published",
+		Data:    summary,
+	})
+}
+
+// setTotalCountHeader exposes the total count as X-Total-Count before the body
+// is streamed. The header is omitted when the count was disabled (-1) or failed.
+func setTotalCountHeader(c *gin.Context, response middleware.StreamResponse) {
+	if response.Error != nil || response.TotalCount < 0 {
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(response.TotalCount, 10))
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"stream/application/ticketsV2/domain"
+	"stream/internal/stream"
+)
+
+// sqlDataSource implements the DataSource interface on top of a SQL Repository
+type sqlDataSource struct {
+	repo domain.Repository
+}
+
+// NewSQLDataSource creates a DataSource that queries the given Repository
+func NewSQLDataSource(repo domain.Repository) domain.DataSource {
+	return &sqlDataSource{
+		repo: repo,
+	}
+}
+
+// Count executes a COUNT query for the payload filters
+func (d *sqlDataSource) Count(ctx context.Context, payload *domain.QueryPayload) (int64, error) {
+	qb := NewQueryBuilder(payload)
+
+	// A UNION is counted over its combined rows, which depend on the select list
+	if payload.Union != nil {
+		qb.SetSelectColumns(GenerateUniqueSelectList(payload.Formulas))
+	}
+
+	countQuery, countArgs := qb.BuildCountQuery()
+	return d.repo.ExecuteCountQuery(ctx, countQuery, countArgs...)
+}
+
+// Fetch executes the SELECT query and returns a fetcher that scans rows lazily.
+// The fetcher owns the underlying sql.Rows and closes them when done.
+func (d *sqlDataSource) Fetch(ctx context.Context, payload *domain.QueryPayload, formulas []domain.Formula) (stream.DataFetcher[domain.RowData], []domain.Formula, error) {
+	qb := NewQueryBuilder(payload)
+	qb.SetSelectColumns(GenerateUniqueSelectList(formulas))
+
+	mainQuery, mainArgs := qb.BuildSelectQuery()
+
+	rows, err := d.repo.ExecuteQuery(ctx, mainQuery, mainArgs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, fields, err := d.repo.GetColumnNames(rows)
+	if err != nil {
+		rows.Close()
+		return nil, nil, err
+	}
+
+	// DECIMAL columns keep their exact text (as json.Number); the scanner
+	// reads the column types of this result set once
+	typed := stream.TypedRowScanner()
+	scanner := func(rows *sql.Rows, columns []string) (domain.RowData, error) {
+		return typed(rows, columns)
+	}
+
+	return stream.SQLFetcherWithColumns(rows, columns, scanner), fields, nil
+}
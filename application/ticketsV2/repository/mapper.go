@@ -3,9 +3,13 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"stream/application/tickets"
 	"stream/application/ticketsV2/domain"
+	"stream/internal/stream"
 	"strings"
 	"time"
+
+	"github.com/guregu/null/v5"
 )
 
 // rowScanner implements the RowScanner interface
@@ -16,29 +20,12 @@ func NewRowScanner() domain.RowScanner {
 	return &rowScanner{}
 }
 
-// ScanRow scans a single row into a RowData map using column metadata
+// ScanRow scans a single row into a RowData map using column metadata.
+// DECIMAL columns keep their exact text (as json.Number); the column types
+// are read on every call, so a result set is better scanned with
+// stream.TypedRowScanner, which reads them once.
 func (rs *rowScanner) ScanRow(rows *sql.Rows, columns []string) (domain.RowData, error) {
-	// Create slice of interface{} to hold column values
-	values := make([]interface{}, len(columns))
-	valuePtrs := make([]interface{}, len(columns))
-
-	// Create pointers to scan into
-	for i := range values {
-		valuePtrs[i] = &values[i]
-	}
-
-	// Scan the row
-	if err := rows.Scan(valuePtrs...); err != nil {
-		return nil, fmt.Errorf("failed to scan row: %w", err)
-	}
-
-	// Build the result map
-	result := make(domain.RowData, len(columns))
-	for i, colName := range columns {
-		result[colName] = values[i]
-	}
-
-	return result, nil
+	return stream.TypedRowScanner()(rows, columns)
 }
 
 // transformer implements the Transformer interface
@@ -55,10 +42,10 @@ func NewTransformer(operators map[string]domain.OperatorFunc) domain.Transformer
 
 // TransformRow applies formulas to a RowData to produce TransformedRow
 func (t *transformer) TransformRow(row domain.RowData, formulas []domain.Formula, isFormatDate bool) (domain.TransformedRow, error) {
-	// Pre-allocate slice with exact size
-	fields := make([]domain.TransformedField, len(formulas))
+	// Pre-allocate one field per formula; formulas with OutputFields may add more
+	fields := make([]domain.TransformedField, 0, len(formulas))
 
-	for i, formula := range formulas {
+	for _, formula := range formulas {
 		// Extract parameter values from the row
 		paramValues := make([]interface{}, len(formula.Params))
 		for j, paramName := range formula.Params {
@@ -75,6 +62,11 @@ func (t *transformer) TransformRow(row domain.RowData, formulas []domain.Formula
 			paramValues[j] = val
 		}
 
+		// Operators such as substituteTemplate also read the whole row
+		if tickets.RowContextOperators[formula.Operator] {
+			paramValues = append(paramValues, map[string]interface{}(row))
+		}
+
 		// Get the operator function
 		operatorFunc, exists := t.operators[formula.Operator]
 		if !exists {
@@ -87,11 +79,27 @@ func (t *transformer) TransformRow(row domain.RowData, formulas []domain.Formula
 			return domain.TransformedRow{}, fmt.Errorf("failed to execute operator '%s': %w", formula.Operator, err)
 		}
 
+		// Spread a list or object result over the declared output fields
+		if len(formula.OutputFields) > 0 {
+			values, ok := toOutputValues(transformedValue, formula.OutputFields)
+			if !ok {
+				return domain.TransformedRow{}, fmt.Errorf("operator '%s' must return a list or an object to fill outputFields, got %T", formula.Operator, transformedValue)
+			}
+			for k, name := range formula.OutputFields {
+				var value interface{} = null.String{}
+				if k < len(values) {
+					value = values[k]
+				}
+				fields = append(fields, domain.TransformedField{Key: name, Value: value})
+			}
+			continue
+		}
+
 		// Store in ordered slice
-		fields[i] = domain.TransformedField{
+		fields = append(fields, domain.TransformedField{
 			Key:   formula.Field,
 			Value: transformedValue,
-		}
+		})
 	}
 
 	transformed := domain.NewTransformedRow(fields)
@@ -109,6 +117,39 @@ func (t *transformer) GetOperatorRegistry() map[string]domain.OperatorFunc {
 	return t.operators
 }
 
+// toOutputValues returns the elements of an operator result spread over
+// OutputFields: list elements by position, object values by field name (a
+// missing key is null). A null result has no elements; ok is false for other
+// values.
+func toOutputValues(value interface{}, names []string) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			if field, ok := v[name]; ok {
+				values[i] = field
+			} else {
+				values[i] = null.String{}
+			}
+		}
+		return values, true
+	case []string:
+		values := make([]interface{}, len(v))
+		for i, s := range v {
+			values[i] = s
+		}
+		return values, true
+	case null.String:
+		return nil, !v.Valid
+	default:
+		return nil, false
+	}
+}
+
 // extractAliasFromParam extracts the alias from a SQL expression param
 func extractAliasFromParam(param string) string {
 	// Look for " AS alias" pattern (case insensitive)
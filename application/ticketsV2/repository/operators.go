@@ -8,8 +8,14 @@ import (
 // GetOperatorRegistry returns the operator registry
 // This wraps the operators from the original tickets package for reuse
 func GetOperatorRegistry() map[string]domain.OperatorFunc {
+	return NewOperatorRegistry(tickets.DefaultOperatorConfig())
+}
+
+// NewOperatorRegistry returns the operator registry with tenant-specific
+// operator values taken from config
+func NewOperatorRegistry(config tickets.OperatorConfig) map[string]domain.OperatorFunc {
 	// Get the original operator registry
-	originalOps := tickets.GetOperatorRegistry()
+	originalOps := tickets.NewOperatorRegistry(config)
 
 	// Convert to domain.OperatorFunc type
 	// Since the function signatures are identical, we can directly use them
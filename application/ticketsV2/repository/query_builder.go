@@ -15,6 +15,7 @@ type queryBuilder struct {
 	orderBy    []string
 	limit      int
 	offset     int
+	union      *domain.UnionClause
 }
 
 // NewQueryBuilder creates a new QueryBuilder
@@ -25,6 +26,7 @@ func NewQueryBuilder(payload *domain.QueryPayload) domain.QueryBuilder {
 		orderBy:   payload.OrderBy,
 		limit:     payload.GetLimit(),
 		offset:    payload.GetOffset(),
+		union:     payload.Union,
 	}
 }
 
@@ -38,37 +40,10 @@ func (qb *queryBuilder) BuildSelectQuery() (string, []interface{}) {
 	var query strings.Builder
 	var args []interface{}
 
-	// SELECT clause
-	query.WriteString("SELECT ")
-	if len(qb.selectCols) == 0 {
-		query.WriteString("*")
-	} else {
-		quotedCols := make([]string, len(qb.selectCols))
-		for i, col := range qb.selectCols {
-			if isSQLExpression(col) {
-				quotedCols[i] = col
-			} else {
-				quotedCols[i] = quoteIdentifier(col)
-			}
-		}
-		query.WriteString(strings.Join(quotedCols, ", "))
-	}
+	// SELECT ... FROM ... WHERE, plus the UNION sub-query if declared
+	args = qb.buildSelectFrom(&query, args)
 
-	// FROM clause
-	query.WriteString(" FROM ")
-	query.WriteString(quoteIdentifier(qb.tableName))
-
-	// WHERE clause
-	if len(qb.where) > 0 {
-		query.WriteString(" WHERE ")
-		whereParts := make([]string, len(qb.where))
-		for i, where := range qb.where {
-			whereParts[i], args = qb.buildWhereClause(where, args)
-		}
-		query.WriteString(strings.Join(whereParts, " AND "))
-	}
-
-	// ORDER BY clause
+	// ORDER BY clause (applies to the combined result for UNION)
 	if len(qb.orderBy) > 0 && len(qb.orderBy) == 2 {
 		query.WriteString(" ORDER BY ")
 		query.WriteString(quoteIdentifier(qb.orderBy[0]))
@@ -96,6 +71,14 @@ func (qb *queryBuilder) BuildCountQuery() (string, []interface{}) {
 	var query strings.Builder
 	var args []interface{}
 
+	// UNION: count the combined rows so UNION de-duplication is respected
+	if qb.union != nil {
+		query.WriteString("SELECT COUNT(*) FROM (")
+		args = qb.buildSelectFrom(&query, args)
+		query.WriteString(") AS `union_rows`")
+		return query.String(), args
+	}
+
 	// SELECT COUNT(*)
 	query.WriteString("SELECT COUNT(*) FROM ")
 	query.WriteString(quoteIdentifier(qb.tableName))
@@ -154,6 +137,58 @@ func (qb *queryBuilder) BuildSampleQuery() (string, []interface{}) {
 	return query.String(), args
 }
 
+// buildSelectFrom writes "SELECT cols FROM table WHERE ..." and, when a UNION
+// is declared, the second SELECT over the same columns
+func (qb *queryBuilder) buildSelectFrom(query *strings.Builder, args []interface{}) []interface{} {
+	args = qb.writeSelect(query, qb.tableName, qb.where, args)
+
+	if qb.union != nil {
+		if qb.union.All {
+			query.WriteString(" UNION ALL ")
+		} else {
+			query.WriteString(" UNION ")
+		}
+		args = qb.writeSelect(query, qb.union.TableName, qb.union.Where, args)
+	}
+
+	return args
+}
+
+// writeSelect writes a single SELECT over the builder's select columns
+func (qb *queryBuilder) writeSelect(query *strings.Builder, tableName string, where []domain.WhereClause, args []interface{}) []interface{} {
+	// SELECT clause
+	query.WriteString("SELECT ")
+	if len(qb.selectCols) == 0 {
+		query.WriteString("*")
+	} else {
+		quotedCols := make([]string, len(qb.selectCols))
+		for i, col := range qb.selectCols {
+			if isSQLExpression(col) {
+				quotedCols[i] = col
+			} else {
+				quotedCols[i] = quoteIdentifier(col)
+			}
+		}
+		query.WriteString(strings.Join(quotedCols, ", "))
+	}
+
+	// FROM clause
+	query.WriteString(" FROM ")
+	query.WriteString(quoteIdentifier(tableName))
+
+	// WHERE clause
+	if len(where) > 0 {
+		query.WriteString(" WHERE ")
+		whereParts := make([]string, len(where))
+		for i, clause := range where {
+			whereParts[i], args = qb.buildWhereClause(clause, args)
+		}
+		query.WriteString(strings.Join(whereParts, " AND "))
+	}
+
+	return args
+}
+
 // buildWhereClause builds a single WHERE clause with parameter binding
 func (qb *queryBuilder) buildWhereClause(where domain.WhereClause, args []interface{}) (string, []interface{}) {
 	var clause strings.Builder
@@ -180,6 +215,9 @@ func (qb *queryBuilder) buildWhereClause(where domain.WhereClause, args []interf
 			clause.WriteString("(?)")
 			args = append(args, where.Value)
 		}
+	} else if ref, ok := where.Value.(domain.ColumnRef); ok {
+		// Column-to-column comparison: emit the quoted column, nothing to bind
+		clause.WriteString(quoteIdentifier(ref.Column))
 	} else {
 		clause.WriteString("?")
 		args = append(args, where.Value)
@@ -224,7 +262,9 @@ func isSQLExpression(param string) bool {
 }
 
 // GenerateUniqueSelectList generates a unique, deterministic list of columns
-// from formulas' params, sorted by formula position
+// from formulas' params, sorted by formula position. Blank params are
+// skipped; when no column remains the list is empty and the query builder
+// selects * instead of emitting an empty SELECT list.
 func GenerateUniqueSelectList(formulas []domain.Formula) []string {
 	// Sort formulas by position
 	sortedFormulas := make([]domain.Formula, len(formulas))
@@ -239,6 +279,10 @@ func GenerateUniqueSelectList(formulas []domain.Formula) []string {
 
 	for _, formula := range sortedFormulas {
 		for _, param := range formula.Params {
+			// A blank param names no column (it would be selected as ``)
+			if strings.TrimSpace(strings.ReplaceAll(param, "`", "")) == "" {
+				continue
+			}
 			if !seen[param] {
 				seen[param] = true
 				selectList = append(selectList, param)
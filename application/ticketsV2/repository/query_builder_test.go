@@ -162,6 +162,58 @@ func TestQueryBuilder_BuildSelectQuery(t *testing.T) {
 			t.Errorf("Expected SQL expression to be preserved, got %q", query)
 		}
 	})
+
+	t.Run("WHERE comparing two columns", func(t *testing.T) {
+		payload := &domain.QueryPayload{
+			TableName: "tickets",
+			Where: []domain.WhereClause{
+				{Field: "updated_at", Operator: ">", Value: domain.ColumnRef{Column: "created_at"}},
+			},
+		}
+
+		qb := NewQueryBuilder(payload)
+		query, args := qb.BuildSelectQuery()
+
+		expectedQuery := "SELECT * FROM `tickets` WHERE `updated_at` > `created_at`"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+
+		if len(args) != 0 {
+			t.Errorf("Expected no args, got %v", args)
+		}
+	})
+
+	t.Run("SELECT with UNION", func(t *testing.T) {
+		limit := 10
+		payload := &domain.QueryPayload{
+			TableName: "tickets",
+			Where: []domain.WhereClause{
+				{Field: "status", Operator: "=", Value: "closed"},
+			},
+			OrderBy: []string{"id", "ASC"},
+			Limit:   &limit,
+			Union: &domain.UnionClause{
+				TableName: "tickets",
+				Where: []domain.WhereClause{
+					{Field: "priority", Operator: "=", Value: "high"},
+				},
+			},
+		}
+
+		qb := NewQueryBuilder(payload)
+		qb.SetSelectColumns([]string{"id", "status"})
+		query, args := qb.BuildSelectQuery()
+
+		expectedQuery := "SELECT `id`, `status` FROM `tickets` WHERE `status` = ? UNION SELECT `id`, `status` FROM `tickets` WHERE `priority` = ? ORDER BY `id` ASC LIMIT ?"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+
+		if len(args) != 3 || args[0] != "closed" || args[1] != "high" || args[2] != 10 {
+			t.Errorf("Expected args [closed high 10], got %v", args)
+		}
+	})
 }
 
 func TestQueryBuilder_BuildCountQuery(t *testing.T) {
@@ -203,6 +255,35 @@ func TestQueryBuilder_BuildCountQuery(t *testing.T) {
 			t.Errorf("Expected args [open], got %v", args)
 		}
 	})
+
+	t.Run("COUNT over UNION", func(t *testing.T) {
+		payload := &domain.QueryPayload{
+			TableName: "tickets",
+			Where: []domain.WhereClause{
+				{Field: "status", Operator: "=", Value: "open"},
+			},
+			Union: &domain.UnionClause{
+				TableName: "tickets",
+				Where: []domain.WhereClause{
+					{Field: "priority", Operator: "=", Value: "high"},
+				},
+				All: true,
+			},
+		}
+
+		qb := NewQueryBuilder(payload)
+		qb.SetSelectColumns([]string{"id"})
+		query, args := qb.BuildCountQuery()
+
+		expectedQuery := "SELECT COUNT(*) FROM (SELECT `id` FROM `tickets` WHERE `status` = ? UNION ALL SELECT `id` FROM `tickets` WHERE `priority` = ?) AS `union_rows`"
+		if query != expectedQuery {
+			t.Errorf("Expected query %q, got %q", expectedQuery, query)
+		}
+
+		if len(args) != 2 || args[0] != "open" || args[1] != "high" {
+			t.Errorf("Expected args [open high], got %v", args)
+		}
+	})
 }
 
 func TestGenerateUniqueSelectList(t *testing.T) {
@@ -248,6 +329,25 @@ func TestGenerateUniqueSelectList(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("blank params select every column", func(t *testing.T) {
+		formulas := []domain.Formula{
+			{Params: []string{"", "``"}, Field: "a", Operator: "", Position: 1},
+			{Params: []string{" "}, Field: "b", Operator: "", Position: 2},
+		}
+
+		selectList := GenerateUniqueSelectList(formulas)
+		if len(selectList) != 0 {
+			t.Fatalf("Expected no columns, got %q", selectList)
+		}
+
+		qb := NewQueryBuilder(&domain.QueryPayload{TableName: "tickets"})
+		qb.SetSelectColumns(selectList)
+		query, _ := qb.BuildSelectQuery()
+		if !strings.HasPrefix(query, "SELECT * FROM `tickets`") {
+			t.Errorf("Expected SELECT * fallback, got %q", query)
+		}
+	})
 }
 
 func TestIsSQLExpression(t *testing.T) {
@@ -12,6 +12,11 @@ import (
 // repository implements the Repository interface
 type repository struct {
 	db *gorm.DB
+
+	// replica serves SELECT queries when set (nil uses db);
+	// countOnReplica routes COUNT queries there too
+	replica        *gorm.DB
+	countOnReplica bool
 }
 
 // NewRepository creates a new Repository instance
@@ -19,9 +24,33 @@ func NewRepository(db *gorm.DB) domain.Repository {
 	return &repository{db: db}
 }
 
+// NewRepositoryWithReplica creates a Repository that streams SELECT queries
+// from a read replica, so heavy exports do not load the primary. COUNT
+// queries stay on the primary unless countOnReplica. A nil replica behaves
+// like NewRepository.
+func NewRepositoryWithReplica(primary, replica *gorm.DB, countOnReplica bool) domain.Repository {
+	return &repository{db: primary, replica: replica, countOnReplica: countOnReplica}
+}
+
+// selectDB returns the handle for SELECT queries
+func (r *repository) selectDB() *gorm.DB {
+	if r.replica != nil {
+		return r.replica
+	}
+	return r.db
+}
+
+// countDB returns the handle for COUNT queries
+func (r *repository) countDB() *gorm.DB {
+	if r.replica != nil && r.countOnReplica {
+		return r.replica
+	}
+	return r.db
+}
+
 // ExecuteQuery executes a SELECT query and returns sql.Rows
 func (r *repository) ExecuteQuery(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	sqlDB, err := r.db.DB()
+	sqlDB, err := r.selectDB().DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
@@ -35,7 +64,7 @@ func (r *repository) ExecuteQuery(ctx context.Context, query string, args ...int
 
 // ExecuteCountQuery executes a COUNT query and returns the count
 func (r *repository) ExecuteCountQuery(ctx context.Context, query string, args ...interface{}) (int64, error) {
-	sqlDB, err := r.db.DB()
+	sqlDB, err := r.countDB().DB()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get database connection: %w", err)
 	}
@@ -92,8 +121,18 @@ func (r *repository) GetColumnMetadata(rows *sql.Rows) ([]domain.ColumnMetadata,
 	return metadata, nil
 }
 
-// Close closes the underlying database connection
+// Close closes the underlying database connections
 func (r *repository) Close() error {
+	if r.replica != nil {
+		replicaDB, err := r.replica.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get replica connection: %w", err)
+		}
+		if err := replicaDB.Close(); err != nil {
+			return err
+		}
+	}
+
 	sqlDB, err := r.db.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database connection: %w", err)
@@ -2,128 +2,293 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
+	"stream/application/tickets"
 	"stream/application/ticketsV2/domain"
 	"stream/application/ticketsV2/repository"
+	"stream/common"
 	"stream/internal/stream"
 	"stream/middleware"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // service implements the Service interface
 type service struct {
-	repo        domain.Repository
+	source      domain.DataSource
 	validator   domain.Validator
-	transformer domain.Transformer
-	scanner     domain.RowScanner
+	transformer atomic.Pointer[domain.Transformer] // swapped by SetOperators
+
+	// chunkConfig tunes chunk threshold and batch size for this endpoint
+	chunkConfig stream.ChunkConfig
+
+	// producer and topic are only set for services that can publish exports
+	producer stream.Producer
+	topic    string
+
+	// tracer records query and streaming spans (nil disables tracing)
+	tracer trace.Tracer
+}
+
+// Config holds per-endpoint service settings
+type Config struct {
+	// Operators holds tenant-specific operator values
+	Operators tickets.OperatorConfig
+
+	// Chunk tunes streaming for the endpoint's row width; zero values fall
+	// back to stream.DefaultChunkConfig
+	Chunk stream.ChunkConfig
+
+	// Producer and Topic enable PublishTickets (optional)
+	Producer stream.Producer
+	Topic    string
+
+	// Tracer records count, select, fetch and transform spans as children of
+	// the request span (optional; nil disables tracing)
+	Tracer trace.Tracer
 }
 
-// NewService creates a new Service instance
+// NewService creates a new Service instance backed by a SQL repository
 func NewService(repo domain.Repository) domain.Service {
-	operators := repository.GetOperatorRegistry()
+	return NewServiceWithDataSource(repository.NewSQLDataSource(repo))
+}
 
-	return &service{
-		repo:        repo,
+// NewServiceWithConfig creates a new Service instance backed by a SQL repository
+// with tenant-specific operator configuration
+func NewServiceWithConfig(repo domain.Repository, config tickets.OperatorConfig) domain.Service {
+	return newService(repository.NewSQLDataSource(repo), repository.NewOperatorRegistry(config))
+}
+
+// NewServiceWithDataSource creates a new Service instance that streams rows from
+// any DataSource (e.g. SQL, REST API or file) through the formula pipeline
+func NewServiceWithDataSource(source domain.DataSource) domain.Service {
+	return newService(source, repository.GetOperatorRegistry())
+}
+
+// NewServiceFromConfig creates a new Service instance backed by a SQL repository
+// with per-endpoint settings: operator values, chunk tuning and an optional
+// message sink for publishing exports (e.g. a Kafka topic)
+func NewServiceFromConfig(repo domain.Repository, config Config) domain.Service {
+	svc := newService(repository.NewSQLDataSource(repo), repository.NewOperatorRegistry(config.Operators))
+
+	// Validate fills zero values with defaults
+	svc.chunkConfig = config.Chunk
+	svc.chunkConfig.Validate()
+
+	svc.producer = config.Producer
+	svc.topic = config.Topic
+	svc.tracer = config.Tracer
+	return svc
+}
+
+func newService(source domain.DataSource, operators map[string]domain.OperatorFunc) *service {
+	svc := &service{
+		source:      source,
 		validator:   domain.NewValidator(),
-		transformer: repository.NewTransformer(operators),
-		scanner:     repository.NewRowScanner(),
+		chunkConfig: stream.DefaultChunkConfig(),
 	}
+	svc.SetOperators(operators)
+	return svc
+}
+
+// SetOperators replaces the operator registry. It is safe to call while
+// streaming: transformers capture the registry when a stream starts.
+func (s *service) SetOperators(operators map[string]domain.OperatorFunc) {
+	transformer := repository.NewTransformer(operators)
+	s.transformer.Store(&transformer)
 }
 
 // StreamTickets streams ticket data using the internal/stream package
 func (s *service) StreamTickets(ctx context.Context, payload *domain.QueryPayload) middleware.StreamResponse {
 	// Step 1: Validate payload
 	if err := s.validator.Validate(payload); err != nil {
+		err = common.NewValidationError(err)
 		return middleware.StreamResponse{
-			Code:  400,
-			Error: fmt.Errorf("validation error: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
 		}
 	}
 
 	// Step 2: Sort formulas by position
 	sortedFormulas := s.validator.SortFormulas(payload.Formulas)
 
-	// Step 3: Generate SELECT list from formulas
-	selectList := repository.GenerateUniqueSelectList(sortedFormulas)
-
-	// Step 4: Build queries
-	qb := repository.NewQueryBuilder(payload)
-	qb.SetSelectColumns(selectList)
-
-	mainQuery, mainArgs := qb.BuildSelectQuery()
-
-	// Step 5: Execute count query (if not disabled)
+	// Step 3: Get total count (if not disabled)
 	var totalCount int64 = -1
 	if !payload.IsDisableCount {
-		countQuery, countArgs := qb.BuildCountQuery()
-		count, err := s.repo.ExecuteCountQuery(ctx, countQuery, countArgs...)
+		count, err := s.count(ctx, payload)
 		if err != nil {
+			err = common.NewQueryError("count", err)
 			return middleware.StreamResponse{
-				Code:  500,
-				Error: fmt.Errorf("failed to execute count query: %w", err),
+				Code:  common.HTTPStatus(err),
+				Error: err,
 			}
 		}
 		totalCount = count
 	}
 
-	// Step 6: Execute main query
-	rows, err := s.repo.ExecuteQuery(ctx, mainQuery, mainArgs...)
-	if err != nil {
-		return middleware.StreamResponse{
-			Code:  500,
-			Error: fmt.Errorf("failed to execute main query: %w", err),
-		}
-	}
-
-	// Step 7: Get column names
-	columns, formulas, err := s.repo.GetColumnNames(rows)
+	// Step 4: Open the data source
+	fetcher, fields, hasMore, err := s.tracedFetch(ctx, payload, sortedFormulas)
 	if err != nil {
-		rows.Close()
+		err = common.NewQueryError("select", err)
 		return middleware.StreamResponse{
-			Code:  500,
-			Error: fmt.Errorf("failed to get column names: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
 		}
 	}
 
-	if sortedFormulas == nil || len(sortedFormulas) == 0 {
-		sortedFormulas = formulas
+	if len(sortedFormulas) == 0 {
+		sortedFormulas = fields
 	}
 
-	// Step 8: Create streamer with default configuration
-	streamer := stream.NewDefaultStreamer[domain.RowData]()
+	// Step 5: Create streamer with the endpoint's chunk configuration
+	streamer := stream.NewTracedStreamer[domain.RowData](s.chunkConfig, s.tracer, stream.AttrTableName.String(payload.TableName))
 
-	// Step 9: Define data fetcher using stream.SQLFetcherWithColumns
-	scanner := s.createScanner()
-	fetcher := stream.SQLFetcherWithColumns(rows, columns, scanner)
-
-	// Step 10: Define transformer using enhanced helper
+	// Step 6: Define transformer using enhanced helper
 	domainTransform := s.createTransformer(sortedFormulas, payload.IsFormatDate)
 	transformer := stream.TransformerAdapter(domainTransform)
 
-	// Step 11: Stream using internal/stream package
+	// Step 7: Stream using internal/stream package
 	streamResp := streamer.Stream(ctx, fetcher, transformer)
 
-	// Step 12: Set total count
+	// Step 8: Set total count and next page detection
 	streamResp.TotalCount = totalCount
+	streamResp.HasMore = hasMore
 
 	return streamResp
 }
 
-// createScanner creates an SQLRowScanner that wraps the domain scanner.
-// This adapter allows using domain-specific scanner with stream helpers.
-func (s *service) createScanner() stream.SQLRowScanner[domain.RowData] {
-	return func(rows *sql.Rows, columns []string) (domain.RowData, error) {
-		return s.scanner.ScanRow(rows, columns)
+// count runs the data source count inside a tickets.count span
+func (s *service) count(ctx context.Context, payload *domain.QueryPayload) (int64, error) {
+	ctx, span := s.startSpan(ctx, "tickets.count", payload)
+	count, err := s.source.Count(ctx, payload)
+	if span != nil {
+		span.SetAttributes(stream.AttrRowCount.Int64(count))
+		endSpan(span, err)
+	}
+	return count, err
+}
+
+// tracedFetch runs fetch inside a tickets.select span. For SQL sources the
+// span covers executing the SELECT; reading rows is traced by the streamer.
+func (s *service) tracedFetch(ctx context.Context, payload *domain.QueryPayload, formulas []domain.Formula) (stream.DataFetcher[domain.RowData], []domain.Formula, func() bool, error) {
+	spanCtx, span := s.startSpan(ctx, "tickets.select", payload)
+	fetcher, fields, hasMore, err := s.fetch(spanCtx, payload, formulas)
+	if span != nil {
+		endSpan(span, err)
+	}
+	return fetcher, fields, hasMore, err
+}
+
+// startSpan starts a span carrying the table name, or returns a nil span
+// when tracing is disabled
+func (s *service) startSpan(ctx context.Context, name string, payload *domain.QueryPayload) (context.Context, trace.Span) {
+	if s.tracer == nil {
+		return ctx, nil
+	}
+	return s.tracer.Start(ctx, name, trace.WithAttributes(stream.AttrTableName.String(payload.TableName)))
+}
+
+// endSpan records err (if any) and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// fetch opens the data source. With DetectHasMore and a limit, it fetches one
+// extra row and caps the stream at the limit; the returned hasMore func reports
+// whether that extra row existed. hasMore is nil when detection is off.
+func (s *service) fetch(ctx context.Context, payload *domain.QueryPayload, formulas []domain.Formula) (stream.DataFetcher[domain.RowData], []domain.Formula, func() bool, error) {
+	limit := payload.GetLimit()
+
+	fetchPayload := payload
+	if payload.DetectHasMore && limit > 0 {
+		extended := *payload
+		fetchLimit := limit + 1
+		extended.Limit = &fetchLimit
+		fetchPayload = &extended
+	}
+
+	fetcher, fields, err := s.source.Fetch(ctx, fetchPayload, formulas)
+	if err != nil || !payload.DetectHasMore {
+		return fetcher, fields, nil, err
+	}
+
+	limited, hasMore := stream.LimitFetcher(fetcher, limit)
+	return limited, fields, hasMore, nil
+}
+
+// PublishTickets publishes each transformed row as one message to the
+// configured producer, keyed by the row's ticket id
+func (s *service) PublishTickets(ctx context.Context, payload *domain.QueryPayload) (domain.PublishSummary, error) {
+	summary := domain.PublishSummary{Topic: s.topic}
+
+	if s.producer == nil {
+		return summary, common.NewValidationError(fmt.Errorf("publishing is not configured"))
+	}
+
+	// Step 1: Validate payload
+	if err := s.validator.Validate(payload); err != nil {
+		return summary, common.NewValidationError(err)
+	}
+
+	// Step 2: Sort formulas by position
+	sortedFormulas := s.validator.SortFormulas(payload.Formulas)
+
+	// Step 3: Open the data source
+	fetcher, fields, _, err := s.fetch(ctx, payload, sortedFormulas)
+	if err != nil {
+		return summary, common.NewQueryError("select", err)
+	}
+
+	if len(sortedFormulas) == 0 {
+		sortedFormulas = fields
+	}
+
+	// Step 4: Publish rows with the same per-row encoding as streaming
+	domainTransform := s.createTransformer(sortedFormulas, payload.IsFormatDate)
+	published, err := stream.Publish(
+		ctx,
+		fetcher,
+		stream.TransformerAdapter(domainTransform),
+		s.producer,
+		ticketIDKey,
+		s.chunkConfig.BatchSize,
+	)
+	summary.Published = published
+	if err != nil {
+		err = common.NewStreamError(err)
+		summary.Error = err.Error()
+		return summary, err
+	}
+
+	return summary, nil
+}
+
+// ticketIDKey returns the row's "id" column as the message key (nil if absent)
+func ticketIDKey(row domain.RowData) []byte {
+	switch id := row["id"].(type) {
+	case nil:
+		return nil
+	case []byte:
+		return id
+	default:
+		return []byte(fmt.Sprint(id))
 	}
 }
 
 // createTransformer creates a transformer function that transforms RowData using domain-specific logic.
 // This adapter allows using domain-specific transformer with stream helpers.
 func (s *service) createTransformer(sortedFormulas []domain.Formula, isFormatDate bool) func(domain.RowData) (interface{}, error) {
+	transformer := *s.transformer.Load()
 	return func(row domain.RowData) (interface{}, error) {
-		return s.transformer.TransformRow(row, sortedFormulas, isFormatDate)
+		return transformer.TransformRow(row, sortedFormulas, isFormatDate)
 	}
 }
 
@@ -131,77 +296,60 @@ func (s *service) createTransformer(sortedFormulas []domain.Formula, isFormatDat
 func (s *service) StreamTicketsBatch(ctx context.Context, payload *domain.QueryPayload) middleware.StreamResponse {
 	// Step 1: Validate payload
 	if err := s.validator.Validate(payload); err != nil {
+		err = common.NewValidationError(err)
 		return middleware.StreamResponse{
-			Code:  400,
-			Error: fmt.Errorf("validation error: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
 		}
 	}
 
 	// Step 2: Sort formulas by position
 	sortedFormulas := s.validator.SortFormulas(payload.Formulas)
 
-	// Step 3: Generate SELECT list from formulas
-	selectList := repository.GenerateUniqueSelectList(sortedFormulas)
-
-	// Step 4: Build queries
-	qb := repository.NewQueryBuilder(payload)
-	qb.SetSelectColumns(selectList)
-
-	mainQuery, mainArgs := qb.BuildSelectQuery()
-
-	// Step 5: Execute count query (if not disabled)
+	// Step 3: Get total count (if not disabled)
 	var totalCount int64 = -1
 	if !payload.IsDisableCount {
-		countQuery, countArgs := qb.BuildCountQuery()
-		count, err := s.repo.ExecuteCountQuery(ctx, countQuery, countArgs...)
+		count, err := s.count(ctx, payload)
 		if err != nil {
+			err = common.NewQueryError("count", err)
 			return middleware.StreamResponse{
-				Code:  500,
-				Error: fmt.Errorf("failed to execute count query: %w", err),
+				Code:  common.HTTPStatus(err),
+				Error: err,
 			}
 		}
 		totalCount = count
 	}
 
-	// Step 6: Execute main query
-	rows, err := s.repo.ExecuteQuery(ctx, mainQuery, mainArgs...)
-	if err != nil {
-		return middleware.StreamResponse{
-			Code:  500,
-			Error: fmt.Errorf("failed to execute main query: %w", err),
-		}
-	}
-
-	// Step 7: Get column names
-	columns, formulas, err := s.repo.GetColumnNames(rows)
+	// Step 4: Open the data source
+	fetcher, fields, hasMore, err := s.tracedFetch(ctx, payload, sortedFormulas)
 	if err != nil {
-		rows.Close()
+		err = common.NewQueryError("select", err)
 		return middleware.StreamResponse{
-			Code:  500,
-			Error: fmt.Errorf("failed to get column names: %w", err),
+			Code:  common.HTTPStatus(err),
+			Error: err,
 		}
 	}
 
-	if sortedFormulas == nil || len(sortedFormulas) == 0 {
-		sortedFormulas = formulas
+	if len(sortedFormulas) == 0 {
+		sortedFormulas = fields
 	}
 
-	// Step 8: Create streamer with default configuration
-	streamer := stream.NewDefaultStreamer[domain.RowData]()
+	// Step 5: Create streamer with the endpoint's chunk configuration
+	streamer := stream.NewTracedStreamer[domain.RowData](s.chunkConfig, s.tracer, stream.AttrTableName.String(payload.TableName))
 
-	// Step 9: Define batch fetcher using stream.SQLBatchFetcherWithColumns
-	scanner := s.createScanner()
-	batchFetcher := stream.SQLBatchFetcherWithColumns(rows, columns, streamer.GetConfig().BatchSize, scanner)
+	// Step 6: Group fetched rows into batches
+	batchFetcher := stream.BatchFetcherFrom(fetcher, streamer.GetConfig().BatchSize)
 
-	// Step 10: Define batch transformer using enhanced helper
+	// Step 7: Define batch transformer using enhanced helper
 	domainTransform := s.createTransformer(sortedFormulas, payload.IsFormatDate)
 	batchTransformer := stream.BatchTransformerAdapter(domainTransform)
 
-	// Step 11: Stream using batch processing
+	// Step 8: Stream using batch processing
 	streamResp := streamer.StreamBatch(ctx, batchFetcher, batchTransformer)
 
-	// Step 12: Set total count
+	// Step 9: Set total count and next page detection
 	streamResp.TotalCount = totalCount
+	streamResp.HasMore = hasMore
 
 	return streamResp
 }
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"stream/application/ticketsV2/domain"
+	"stream/application/ticketsV2/repository"
+	"stream/common"
+	"stream/internal/stream"
+	"stream/middleware"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// memoryDataSource is an in-memory DataSource used to test the service
+// without a database
+type memoryDataSource struct {
+	rows     []domain.RowData
+	countErr error
+}
+
+func (m *memoryDataSource) Count(ctx context.Context, payload *domain.QueryPayload) (int64, error) {
+	if m.countErr != nil {
+		return 0, m.countErr
+	}
+	return int64(len(m.rows)), nil
+}
+
+func (m *memoryDataSource) Fetch(ctx context.Context, payload *domain.QueryPayload, formulas []domain.Formula) (stream.DataFetcher[domain.RowData], []domain.Formula, error) {
+	fields := []domain.Formula{
+		{Params: []string{"id"}, Field: "id", Position: 1},
+		{Params: []string{"status"}, Field: "status", Position: 2},
+	}
+	rows := m.rows
+	if limit := payload.GetLimit(); limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return stream.SliceFetcher(rows), fields, nil
+}
+
+// readStream collects the JSON body of a StreamResponse
+func readStream(t *testing.T, resp middleware.StreamResponse) string {
+	t.Helper()
+
+	var body []byte
+	for chunk := range resp.ChunkChan {
+		if chunk.Error != nil {
+			t.Fatalf("Unexpected stream error: %v", chunk.Error)
+		}
+		body = append(body, *chunk.JSONBuf...)
+	}
+	return string(body)
+}
+
+func TestService_DataSource(t *testing.T) {
+	source := &memoryDataSource{
+		rows: []domain.RowData{
+			{"id": 1, "status": "open"},
+			{"id": 2, "status": "closed"},
+		},
+	}
+	svc := NewServiceWithDataSource(source)
+
+	payload := func() *domain.QueryPayload {
+		return &domain.QueryPayload{
+			TableName: "tickets",
+			Formulas: []domain.Formula{
+				{Params: []string{"id"}, Field: "id", Position: 1},
+				{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 2},
+			},
+		}
+	}
+
+	want := `[{"id":1,"status":"OPEN"},{"id":2,"status":"CLOSED"}]`
+
+	t.Run("streams rows through formulas", func(t *testing.T) {
+		resp := svc.StreamTickets(context.Background(), payload())
+		if resp.Error != nil {
+			t.Fatalf("StreamTickets() error = %v", resp.Error)
+		}
+		if resp.TotalCount != 2 {
+			t.Errorf("TotalCount = %d, want 2", resp.TotalCount)
+		}
+		if got := readStream(t, resp); got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("batch streaming produces same output", func(t *testing.T) {
+		resp := svc.StreamTicketsBatch(context.Background(), payload())
+		if resp.Error != nil {
+			t.Fatalf("StreamTicketsBatch() error = %v", resp.Error)
+		}
+		if got := readStream(t, resp); got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("empty formulas use data source fields", func(t *testing.T) {
+		p := payload()
+		p.Formulas = nil
+		p.IsDisableCount = true
+
+		resp := svc.StreamTickets(context.Background(), p)
+		if resp.TotalCount != -1 {
+			t.Errorf("TotalCount = %d, want -1", resp.TotalCount)
+		}
+		if got, want := readStream(t, resp), `[{"id":1,"status":"open"},{"id":2,"status":"closed"}]`; got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("count failure maps to query error", func(t *testing.T) {
+		failing := NewServiceWithDataSource(&memoryDataSource{countErr: errors.New("api unavailable")})
+
+		resp := failing.StreamTickets(context.Background(), payload())
+		if !errors.Is(resp.Error, common.ErrQuery) {
+			t.Errorf("Error = %v, want query error", resp.Error)
+		}
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Code = %d, want 500", resp.Code)
+		}
+	})
+}
+
+func TestService_DetectHasMore(t *testing.T) {
+	source := &memoryDataSource{
+		rows: []domain.RowData{
+			{"id": 1, "status": "open"},
+			{"id": 2, "status": "open"},
+			{"id": 3, "status": "closed"},
+		},
+	}
+	svc := NewServiceWithDataSource(source)
+
+	tests := []struct {
+		name    string
+		limit   int
+		want    string
+		hasMore bool
+	}{
+		{"limit below row count", 2, `[{"id":1,"status":"open"},{"id":2,"status":"open"}]`, true},
+		{"limit equal to row count", 3, `[{"id":1,"status":"open"},{"id":2,"status":"open"},{"id":3,"status":"closed"}]`, false},
+	}
+
+	streams := map[string]func(context.Context, *domain.QueryPayload) middleware.StreamResponse{
+		"stream": svc.StreamTickets,
+		"batch":  svc.StreamTicketsBatch,
+	}
+
+	for mode, streamFn := range streams {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				limit := tt.limit
+				resp := streamFn(context.Background(), &domain.QueryPayload{
+					TableName:      "tickets",
+					Limit:          &limit,
+					IsDisableCount: true,
+					DetectHasMore:  true,
+				})
+				if resp.Error != nil {
+					t.Fatalf("StreamTickets() error = %v", resp.Error)
+				}
+
+				if got := readStream(t, resp); got != tt.want {
+					t.Errorf("body = %s, want %s", got, tt.want)
+				}
+				if resp.HasMore == nil {
+					t.Fatal("HasMore = nil, want detection enabled")
+				}
+				if got := resp.HasMore(); got != tt.hasMore {
+					t.Errorf("HasMore() = %v, want %v", got, tt.hasMore)
+				}
+			})
+		}
+	}
+
+	t.Run("detection off leaves HasMore unset", func(t *testing.T) {
+		limit := 2
+		resp := svc.StreamTickets(context.Background(), &domain.QueryPayload{TableName: "tickets", Limit: &limit})
+		readStream(t, resp)
+
+		if resp.HasMore != nil {
+			t.Error("HasMore should be nil when detectHasMore is false")
+		}
+	})
+}
+
+// fakeProducer records published messages instead of sending them to Kafka
+type fakeProducer struct {
+	messages []stream.Message
+	err      error
+}
+
+func (p *fakeProducer) WriteMessages(ctx context.Context, messages ...stream.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func TestService_PublishTickets(t *testing.T) {
+	source := &memoryDataSource{
+		rows: []domain.RowData{
+			{"id": int64(1), "status": "open"},
+			{"id": int64(2), "status": "closed"},
+		},
+	}
+
+	newPublisher := func(producer stream.Producer) domain.Service {
+		svc := newService(source, repository.GetOperatorRegistry())
+		svc.producer = producer
+		svc.topic = "tickets-export"
+		return svc
+	}
+
+	payload := &domain.QueryPayload{
+		TableName: "tickets",
+		Formulas: []domain.Formula{
+			{Params: []string{"id"}, Field: "id", Position: 1},
+			{Params: []string{"status"}, Field: "status", Operator: "upper", Position: 2},
+		},
+	}
+
+	t.Run("one message per row keyed by ticket id", func(t *testing.T) {
+		producer := &fakeProducer{}
+
+		summary, err := newPublisher(producer).PublishTickets(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("PublishTickets() error = %v", err)
+		}
+		if summary.Published != 2 || summary.Topic != "tickets-export" {
+			t.Errorf("summary = %+v, want 2 rows on tickets-export", summary)
+		}
+
+		want := []struct{ key, value string }{
+			{"1", `{"id":1,"status":"OPEN"}`},
+			{"2", `{"id":2,"status":"CLOSED"}`},
+		}
+		if len(producer.messages) != len(want) {
+			t.Fatalf("got %d messages, want %d", len(producer.messages), len(want))
+		}
+		for i, w := range want {
+			if got := string(producer.messages[i].Key); got != w.key {
+				t.Errorf("message %d key = %q, want %q", i, got, w.key)
+			}
+			if got := string(producer.messages[i].Value); got != w.value {
+				t.Errorf("message %d value = %s, want %s", i, got, w.value)
+			}
+		}
+	})
+
+	t.Run("producer failure is reported in summary", func(t *testing.T) {
+		summary, err := newPublisher(&fakeProducer{err: errors.New("broker unavailable")}).PublishTickets(context.Background(), payload)
+		if !errors.Is(err, common.ErrStream) {
+			t.Errorf("error = %v, want stream error", err)
+		}
+		if summary.Published != 0 || summary.Error == "" {
+			t.Errorf("summary = %+v, want error and nothing published", summary)
+		}
+	})
+
+	t.Run("publishing not configured", func(t *testing.T) {
+		_, err := NewServiceWithDataSource(source).PublishTickets(context.Background(), payload)
+		if !errors.Is(err, common.ErrValidation) {
+			t.Errorf("error = %v, want validation error", err)
+		}
+	})
+}
+
+func TestService_ChunkConfig(t *testing.T) {
+	source := &memoryDataSource{
+		rows: []domain.RowData{
+			{"id": 1, "status": "open"},
+			{"id": 2, "status": "open"},
+			{"id": 3, "status": "closed"},
+		},
+	}
+
+	newWithChunk := func(config stream.ChunkConfig) domain.Service {
+		svc := newService(source, repository.GetOperatorRegistry())
+		config.Validate()
+		svc.chunkConfig = config
+		return svc
+	}
+
+	countChunks := func(resp middleware.StreamResponse) int {
+		chunks := 0
+		for chunk := range resp.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Unexpected stream error: %v", chunk.Error)
+			}
+			chunks++
+		}
+		return chunks
+	}
+
+	payload := func() *domain.QueryPayload {
+		return &domain.QueryPayload{TableName: "tickets", IsDisableCount: true}
+	}
+
+	// Each row encodes to ~25 bytes: a 1 byte threshold flushes after every row,
+	// the default 32KB threshold keeps the whole result in one chunk
+	small := newWithChunk(stream.ChunkConfig{ChunkThreshold: 1})
+	large := newWithChunk(stream.DefaultChunkConfig())
+
+	if got := countChunks(small.StreamTickets(context.Background(), payload())); got < 3 {
+		t.Errorf("small threshold: got %d chunks, want at least one per row", got)
+	}
+	if got := countChunks(large.StreamTickets(context.Background(), payload())); got != 1 {
+		t.Errorf("default threshold: got %d chunks, want 1", got)
+	}
+}
+
+func TestService_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	source := &memoryDataSource{
+		rows: []domain.RowData{
+			{"id": 1, "status": "open"},
+			{"id": 2, "status": "closed"},
+		},
+	}
+	svc := newService(source, repository.GetOperatorRegistry())
+	svc.tracer = tracer
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	resp := svc.StreamTickets(ctx, &domain.QueryPayload{TableName: "tickets"})
+	readStream(t, resp)
+	request.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	requestID := spans["request"].SpanContext().SpanID()
+	for _, name := range []string{"tickets.count", "tickets.select", "stream.fetch", "stream.transform"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("%s span not recorded", name)
+			continue
+		}
+		if span.Parent().SpanID() != requestID {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if got := attrs[stream.AttrTableName].AsString(); got != "tickets" {
+			t.Errorf("%s table = %q, want tickets", name, got)
+		}
+		if name != "tickets.select" {
+			if got := attrs[stream.AttrRowCount].AsInt64(); got != 2 {
+				t.Errorf("%s row_count = %d, want 2", name, got)
+			}
+		}
+	}
+}
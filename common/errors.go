@@ -0,0 +1,112 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// StatusClientClosedRequest is the non-standard status used when the client
+// cancels the request before the response is complete (nginx convention)
+const StatusClientClosedRequest = 499
+
+// Sentinel errors for errors.Is checks against the typed errors below
+var (
+	ErrValidation = errors.New("validation error")
+	ErrQuery      = errors.New("query error")
+	ErrStream     = errors.New("stream error")
+	ErrCancelled  = errors.New("request cancelled")
+)
+
+// ValidationError indicates the request payload is invalid (HTTP 400)
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return "validation failed: " + e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// QueryError indicates a database failure while executing a query (HTTP 500)
+type QueryError struct {
+	Op  string // Query stage, e.g. "count" or "select"
+	Err error
+}
+
+func (e *QueryError) Error() string { return "query failed (" + e.Op + "): " + e.Err.Error() }
+func (e *QueryError) Unwrap() error { return e.Err }
+func (e *QueryError) Is(target error) bool {
+	return target == ErrQuery
+}
+
+// StreamError indicates a failure while fetching, transforming or encoding rows
+// after streaming has started (HTTP 500 if nothing was written yet)
+type StreamError struct {
+	Err error
+}
+
+func (e *StreamError) Error() string { return "stream failed: " + e.Err.Error() }
+func (e *StreamError) Unwrap() error { return e.Err }
+func (e *StreamError) Is(target error) bool {
+	return target == ErrStream
+}
+
+// CancelledError indicates the request context was cancelled by the client
+type CancelledError struct {
+	Err error
+}
+
+func (e *CancelledError) Error() string { return "request cancelled: " + e.Err.Error() }
+func (e *CancelledError) Unwrap() error { return e.Err }
+func (e *CancelledError) Is(target error) bool {
+	return target == ErrCancelled
+}
+
+// NewValidationError wraps err as a ValidationError (nil stays nil)
+func NewValidationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ValidationError{Err: err}
+}
+
+// NewQueryError wraps err as a QueryError, or as a CancelledError when the
+// underlying cause is a client cancellation (nil stays nil)
+func NewQueryError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return &CancelledError{Err: err}
+	}
+	return &QueryError{Op: op, Err: err}
+}
+
+// NewStreamError wraps err as a StreamError, or as a CancelledError when the
+// underlying cause is a client cancellation (nil stays nil)
+func NewStreamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return &CancelledError{Err: err}
+	}
+	return &StreamError{Err: err}
+}
+
+// HTTPStatus maps an error to the HTTP status code the handler should return.
+// Unknown errors are treated as internal server errors.
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
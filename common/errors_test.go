@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		sentinel   error
+		wantStatus int
+	}{
+		{
+			name:       "validation error",
+			err:        NewValidationError(errors.New("table 'x' is not allowed")),
+			sentinel:   ErrValidation,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "query error",
+			err:        NewQueryError("select", errors.New("connection refused")),
+			sentinel:   ErrQuery,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "stream error",
+			err:        NewStreamError(errors.New("transformation failed")),
+			sentinel:   ErrStream,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "cancelled query becomes CancelledError",
+			err:        NewQueryError("count", fmt.Errorf("driver: %w", context.Canceled)),
+			sentinel:   ErrCancelled,
+			wantStatus: StatusClientClosedRequest,
+		},
+		{
+			name:       "wrapped validation error keeps its status",
+			err:        fmt.Errorf("handler: %w", NewValidationError(errors.New("bad limit"))),
+			sentinel:   ErrValidation,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.sentinel) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tt.err, tt.sentinel)
+			}
+			if got := HTTPStatus(tt.err); got != tt.wantStatus {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("errors.As exposes query stage", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", NewQueryError("count", errors.New("timeout")))
+
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Fatal("errors.As() failed to find QueryError")
+		}
+		if queryErr.Op != "count" {
+			t.Errorf("QueryError.Op = %q, want %q", queryErr.Op, "count")
+		}
+	})
+
+	t.Run("nil stays nil", func(t *testing.T) {
+		if NewValidationError(nil) != nil || NewQueryError("select", nil) != nil || NewStreamError(nil) != nil {
+			t.Error("Expected nil error for nil input")
+		}
+		if HTTPStatus(nil) != http.StatusOK {
+			t.Errorf("HTTPStatus(nil) = %d, want 200", HTTPStatus(nil))
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for a download token that is malformed or
+	// whose signature does not match
+	ErrInvalidToken = errors.New("invalid download token")
+
+	// ErrTokenExpired is returned for a correctly signed token past its expiry
+	ErrTokenExpired = errors.New("download token expired")
+)
+
+// DownloadConfig configures the signed download links of export artifacts.
+type DownloadConfig struct {
+	// Dir is the local directory export artifacts are written to; signed
+	// names are relative to it.
+	Dir string
+
+	// Secret is the HMAC-SHA256 key tokens are signed with. Rotating it
+	// invalidates every outstanding link.
+	Secret []byte
+
+	// Expiry is how long a link stays valid after it is signed.
+	//
+	// Default: 15 minutes
+	Expiry time.Duration
+}
+
+// DownloadSigner issues time-limited download links for export artifacts and
+// serves them, so artifacts are reachable only through a link handed out by
+// the export completion callback and never listed.
+//
+// Implementation Notes:
+//   - A token is base64url("<expiry unix>:<name>") + "." + base64url(HMAC)
+//     and carries everything needed to verify it: nothing is stored
+//   - Only names local to Dir are signed or served (no "..", no absolute paths)
+//   - Signatures are compared in constant time
+type DownloadSigner struct {
+	config DownloadConfig
+	now    func() time.Time
+}
+
+// NewDownloadSigner creates a DownloadSigner from config.
+// Returns an error when the directory or secret is missing.
+func NewDownloadSigner(config DownloadConfig) (*DownloadSigner, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("download: directory is required")
+	}
+	if len(config.Secret) == 0 {
+		return nil, fmt.Errorf("download: signing secret is required")
+	}
+	if config.Expiry <= 0 {
+		config.Expiry = 15 * time.Minute
+	}
+
+	return &DownloadSigner{config: config, now: time.Now}, nil
+}
+
+// Sign returns a token granting access to the artifact name (relative to Dir)
+// until the returned expiry
+func (s *DownloadSigner) Sign(name string) (string, time.Time, error) {
+	name = filepath.ToSlash(name)
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", time.Time{}, fmt.Errorf("download: artifact %q is outside the export directory", name)
+	}
+
+	expires := s.now().Add(s.config.Expiry).Truncate(time.Second)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(expires.Unix(), 10) + ":" + name))
+	return payload + "." + s.signature(payload), expires, nil
+}
+
+// URL returns the download link of the artifact name under baseURL, e.g.
+// https://exports.example.com/download/<token>
+func (s *DownloadSigner) URL(baseURL, name string) (string, error) {
+	token, _, err := s.Sign(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/download/" + token, nil
+}
+
+// Verify returns the artifact name of token. Returns ErrInvalidToken when it
+// was not signed with the secret and ErrTokenExpired once it expired.
+func (s *DownloadSigner) Verify(token string) (string, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(payload))) {
+		return "", ErrInvalidToken
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	expiry, name, ok := strings.Cut(string(decoded), ":")
+	if !ok || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if !s.now().Before(time.Unix(unix, 0)) {
+		return "", ErrTokenExpired
+	}
+
+	return name, nil
+}
+
+// signature returns the base64url HMAC-SHA256 of payload
+func (s *DownloadSigner) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves GET /download/{token}: the artifact as an attachment, 403
+// for an invalid token, 410 for an expired one and 404 once the artifact was
+// removed (e.g. by the Janitor)
+func (s *DownloadSigner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, err := s.Verify(path.Base(r.URL.Path))
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		http.Error(w, "Download link expired", http.StatusGone)
+		return
+	case err != nil:
+		http.Error(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(s.config.Dir, filepath.FromSlash(name)))
+	if err != nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(name)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, path.Base(name), info.ModTime(), file)
+}
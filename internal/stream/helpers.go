@@ -3,8 +3,12 @@ package stream
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // PassThroughTransformer creates a Transformer that returns items unchanged.
@@ -186,17 +190,63 @@ func SQLFetcherWithColumns[T any](rows *sql.Rows, columns []string, scanner SQLR
 //   - Reduces channel communication overhead vs item-by-item
 //   - Better CPU cache locality with batch processing
 //   - Race-safe: Creates copy before sending batch
+//   - Backpressure: the next batch is scanned only once the consumer took
+//     the previous one, so a slow consumer holds at most two batches in
+//     memory (the one it processes and the one prefetched)
 //
 // Implementation Notes:
 //   - Pre-allocates batch slice with capacity = batchSize
 //   - Reuses slice between batches for memory efficiency
 //   - Copies batch data before sending to prevent race conditions
 //   - Sends remaining items even if batch not full at end
-//   - Respects context cancellation
-//   - Channel buffer size: 2 batches
+//   - Respects context cancellation: rows are closed as soon as ctx is done,
+//     even mid-batch, which releases the DB connection without waiting for
+//     the rest of the batch
+//   - Channel is unbuffered (one batch prefetched, see Backpressure)
 func SQLBatchFetcherWithColumns[T any](rows *sql.Rows, columns []string, batchSize int, scanner SQLRowScanner[T]) BatchFetcher[T] {
+	return SQLBatchFetcherWithStats(rows, columns, batchSize, scanner, nil)
+}
+
+// FetchStats records where a batch fetcher spends its time: scanning rows
+// from the database or waiting for the consumer to take a batch. It tells
+// whether a slow stream is DB-bound (tune the query) or consumer-bound (the
+// encoder or the client is the bottleneck). Safe for concurrent use.
+type FetchStats struct {
+	scan    atomic.Int64 // nanoseconds
+	wait    atomic.Int64 // nanoseconds
+	batches atomic.Int64
+}
+
+// ScanDuration is the time spent reading and scanning rows
+func (s *FetchStats) ScanDuration() time.Duration {
+	return time.Duration(s.scan.Load())
+}
+
+// WaitDuration is the time spent blocked until the consumer took a batch
+func (s *FetchStats) WaitDuration() time.Duration {
+	return time.Duration(s.wait.Load())
+}
+
+// Batches is the number of batches taken by the consumer
+func (s *FetchStats) Batches() int64 {
+	return s.batches.Load()
+}
+
+// ConsumerBound reports whether the fetcher waited for the consumer longer
+// than it spent on the database
+func (s *FetchStats) ConsumerBound() bool {
+	return s.WaitDuration() > s.ScanDuration()
+}
+
+// SQLBatchFetcherWithStats is SQLBatchFetcherWithColumns recording its scan
+// and wait times in stats (nil records nothing).
+func SQLBatchFetcherWithStats[T any](rows *sql.Rows, columns []string, batchSize int, scanner SQLRowScanner[T], stats *FetchStats) BatchFetcher[T] {
+	if stats == nil {
+		stats = &FetchStats{}
+	}
+
 	return func(ctx context.Context) (<-chan []T, <-chan error) {
-		batchChan := make(chan []T, 2)
+		batchChan := make(chan []T)
 		errChan := make(chan error, 1)
 
 		go func() {
@@ -204,9 +254,29 @@ func SQLBatchFetcherWithColumns[T any](rows *sql.Rows, columns []string, batchSi
 			defer close(errChan)
 			defer rows.Close()
 
+			// Release the connection as soon as the consumer goes away, even
+			// while a batch is being scanned
+			stopClose := context.AfterFunc(ctx, func() { rows.Close() })
+			defer stopClose()
+
+			// send hands batch to the consumer, timing the wait
+			send := func(batch []T) bool {
+				start := time.Now()
+				defer func() { stats.wait.Add(int64(time.Since(start))) }()
+
+				select {
+				case batchChan <- batch:
+					stats.batches.Add(1)
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+
 			// Pre-allocate batch slice with exact capacity
 			batch := make([]T, 0, batchSize)
 
+			scanStart := time.Now()
 			for rows.Next() {
 				// Check context cancellation
 				select {
@@ -227,29 +297,32 @@ func SQLBatchFetcherWithColumns[T any](rows *sql.Rows, columns []string, batchSi
 
 				// Send batch when full
 				if len(batch) >= batchSize {
+					stats.scan.Add(int64(time.Since(scanStart)))
+
 					// Create copy to prevent race conditions
 					batchCopy := make([]T, len(batch))
 					copy(batchCopy, batch)
 
-					select {
-					case batchChan <- batchCopy:
-					case <-ctx.Done():
+					if !send(batchCopy) {
 						return
 					}
 
 					// Reuse slice: reset length but keep capacity
 					// This avoids allocating new slice for next batch
 					batch = batch[:0]
+					scanStart = time.Now()
 				}
 			}
+			stats.scan.Add(int64(time.Since(scanStart)))
+
+			// Rows closed by a cancellation end without an error
+			if ctx.Err() != nil {
+				return
+			}
 
 			// Send remaining items
-			if len(batch) > 0 {
-				select {
-				case batchChan <- batch:
-				case <-ctx.Done():
-					return
-				}
+			if len(batch) > 0 && !send(batch) {
+				return
 			}
 
 			// Check for iteration errors
@@ -310,6 +383,79 @@ func GenericRowScanner() SQLRowScanner[map[string]interface{}] {
 	}
 }
 
+// TypedRowScanner creates a scanner like GenericRowScanner that also uses column
+// type metadata. DECIMAL/NUMERIC columns are surfaced as json.Number holding the
+// exact database text, so values like "1234.56" never pass through float64 and
+// are still encoded as JSON numbers.
+//
+// Returns:
+//   - SQLRowScanner for map-based data
+//
+// Usage:
+//
+//	scanner := stream.TypedRowScanner()
+//	fetcher := stream.SQLFetcherWithColumns(rows, columns, scanner)
+//
+// Implementation Notes:
+//   - Column types are read once on the first row and cached
+//   - Create one scanner per result set (the cache is not shared safely)
+//   - Non-DECIMAL values are returned exactly as GenericRowScanner would
+func TypedRowScanner() SQLRowScanner[map[string]interface{}] {
+	var decimalCols []bool
+
+	return func(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+		// Resolve DECIMAL columns once per result set
+		if decimalCols == nil {
+			columnTypes, err := rows.ColumnTypes()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get column types: %w", err)
+			}
+
+			decimalCols = make([]bool, len(columnTypes))
+			for i, columnType := range columnTypes {
+				decimalCols[i] = isDecimalType(columnType.DatabaseTypeName())
+			}
+		}
+
+		// Create slices for values
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+
+		// Create pointers for scanning
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		// Scan row
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// Build result map, keeping DECIMAL text exact
+		result := make(map[string]interface{}, len(columns))
+		for i, colName := range columns {
+			value := values[i]
+			if i < len(decimalCols) && decimalCols[i] {
+				switch raw := value.(type) {
+				case []byte:
+					value = json.Number(raw)
+				case string:
+					value = json.Number(raw)
+				}
+			}
+			result[colName] = value
+		}
+
+		return result, nil
+	}
+}
+
+// isDecimalType reports whether a database type name is a fixed-point decimal
+func isDecimalType(typeName string) bool {
+	typeName = strings.ToUpper(typeName)
+	return strings.Contains(typeName, "DECIMAL") || strings.Contains(typeName, "NUMERIC")
+}
+
 // ============================================================================
 // Enhanced Transformation Helpers
 // ============================================================================
@@ -707,3 +853,139 @@ func SliceBatchFetcher[T any](items []T, batchSize int) BatchFetcher[T] {
 		return batchChan, errChan
 	}
 }
+
+// BatchFetcherFrom groups the items of a DataFetcher into batches.
+// This lets any item-by-item source (SQL, API, file) be used with StreamBatch.
+//
+// Parameters:
+//   - fetcher: Source DataFetcher
+//   - batchSize: Number of items per batch
+//
+// Returns:
+//   - BatchFetcher[T]: Fetcher that streams the source items in batches
+//
+// Usage:
+//
+//	fetcher := stream.SliceFetcher(items)
+//	batchFetcher := stream.BatchFetcherFrom(fetcher, 1000)
+//	streamResp := streamer.StreamBatch(ctx, batchFetcher, batchTransformer)
+//
+// Implementation Notes:
+//   - Last batch may be smaller than batchSize
+//   - A source error is forwarded once the source data channel closes;
+//     the pending partial batch is dropped in that case
+//   - Channel buffer size: 2 batches
+//   - Respects context cancellation
+func BatchFetcherFrom[T any](fetcher DataFetcher[T], batchSize int) BatchFetcher[T] {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	return func(ctx context.Context) (<-chan []T, <-chan error) {
+		batchChan := make(chan []T, 2)
+		errChan := make(chan error, 1)
+
+		go func() {
+			defer close(batchChan)
+			defer close(errChan)
+
+			dataChan, srcErrChan := fetcher(ctx)
+
+			batch := make([]T, 0, batchSize)
+			for item := range dataChan {
+				batch = append(batch, item)
+
+				if len(batch) >= batchSize {
+					select {
+					case batchChan <- batch:
+					case <-ctx.Done():
+						return
+					}
+
+					// Batch is owned by the receiver now, start a new one
+					batch = make([]T, 0, batchSize)
+				}
+			}
+
+			// Sources send at most one error before closing their channels
+			if err := <-srcErrChan; err != nil {
+				errChan <- err
+				return
+			}
+
+			// Send remaining items
+			if len(batch) > 0 {
+				select {
+				case batchChan <- batch:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return batchChan, errChan
+	}
+}
+
+// LimitFetcher wraps a DataFetcher so that at most limit items are emitted.
+// Pair it with a source that fetches limit+1 items ("fetch one extra"): the
+// returned hasMore func reports whether that extra item existed, which tells
+// the client a next page is available without running COUNT(*).
+//
+// Type Parameters:
+//   - T: The data item type
+//
+// Parameters:
+//   - fetcher: Source fetcher, expected to yield up to limit+1 items
+//   - limit: Maximum number of items to emit (<= 0 disables the cap)
+//
+// Returns:
+//   - DataFetcher[T]: Fetcher emitting at most limit items
+//   - func() bool: Reports whether items beyond the limit were seen; only
+//     meaningful once the returned data channel is drained
+//
+// Implementation Notes:
+//   - Items past the limit are consumed from the source and dropped
+//   - Source errors are forwarded unchanged
+//   - Respects context cancellation
+func LimitFetcher[T any](fetcher DataFetcher[T], limit int) (DataFetcher[T], func() bool) {
+	hasMore := &atomic.Bool{}
+	if limit <= 0 {
+		return fetcher, hasMore.Load
+	}
+
+	limited := func(ctx context.Context) (<-chan T, <-chan error) {
+		dataChan := make(chan T, 10)
+		errChan := make(chan error, 1)
+
+		go func() {
+			defer close(dataChan)
+			defer close(errChan)
+
+			srcDataChan, srcErrChan := fetcher(ctx)
+
+			emitted := 0
+			for item := range srcDataChan {
+				if emitted >= limit {
+					hasMore.Store(true)
+					continue
+				}
+
+				select {
+				case dataChan <- item:
+					emitted++
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if err := <-srcErrChan; err != nil {
+				errChan <- err
+			}
+		}()
+
+		return dataChan, errChan
+	}
+
+	return limited, hasMore.Load
+}
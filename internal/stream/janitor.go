@@ -0,0 +1,158 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JanitorConfig configures the background cleanup of export artifacts.
+type JanitorConfig struct {
+	// Dir is the local directory export artifacts are written to.
+	// It is walked recursively; directories themselves are never removed.
+	Dir string
+
+	// TTL is how long an artifact is kept after its last modification
+	TTL time.Duration
+
+	// Interval is the time between two sweeps.
+	//
+	// Default: TTL / 2, at least 1 minute
+	Interval time.Duration
+}
+
+// Janitor periodically deletes export artifacts older than a TTL.
+//
+// Implementation Notes:
+//   - Files that disappear while a sweep runs (e.g. removed by a concurrent
+//     download handler) are ignored rather than reported
+//   - A missing Dir is treated as empty, so the janitor can start before the
+//     first export creates it
+//   - Start and Stop are idempotent; Stop waits for a running sweep and is a
+//     no-op for a janitor that was never started
+type Janitor struct {
+	config JanitorConfig
+	logger *zap.Logger
+	now    func() time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewJanitor creates a Janitor from config.
+// Returns an error when the directory or TTL is missing.
+func NewJanitor(config JanitorConfig, logger *zap.Logger) (*Janitor, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("janitor: directory is required")
+	}
+	if config.TTL <= 0 {
+		return nil, fmt.Errorf("janitor: TTL must be positive")
+	}
+	if config.Interval <= 0 {
+		config.Interval = max(config.TTL/2, time.Minute)
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Janitor{
+		config: config,
+		logger: logger,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start runs a sweep immediately and then every Interval in a background goroutine
+func (j *Janitor) Start() {
+	j.startOnce.Do(func() {
+		go j.run()
+	})
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Sweep(); err != nil {
+			j.logger.Warn("Export cleanup failed", zap.String("dir", j.config.Dir), zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the background goroutine and waits for it to exit
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+
+	// Never started: nothing to wait for, and Start becomes a no-op
+	j.startOnce.Do(func() {
+		close(j.done)
+	})
+	<-j.done
+}
+
+// Sweep deletes every regular file under Dir last modified more than TTL ago.
+// Returns the number of files removed.
+func (j *Janitor) Sweep() (int, error) {
+	cutoff := j.now().Add(-j.config.TTL)
+	removed := 0
+
+	err := filepath.WalkDir(j.config.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		removed++
+		j.logger.Info("Expired export removed",
+			zap.String("path", path),
+			zap.Time("modified_at", info.ModTime()),
+		)
+		return nil
+	})
+
+	return removed, err
+}
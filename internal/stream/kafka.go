@@ -0,0 +1,97 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaConfig configures the Kafka producer used by Publish.
+type KafkaConfig struct {
+	// Brokers is the list of bootstrap broker addresses (host:port)
+	Brokers []string
+
+	// Topic receives one message per published row
+	Topic string
+
+	// Acks is the required acknowledgement level: "none", "one" or "all".
+	//
+	// Default: "all"
+	Acks string
+
+	// BatchSize is the maximum number of messages buffered per partition
+	// before a produce request is sent.
+	//
+	// Default: 1000
+	BatchSize int
+
+	// BatchTimeout is how long an incomplete batch waits before being sent.
+	//
+	// Default: 100ms
+	BatchTimeout time.Duration
+}
+
+// KafkaProducer is a Producer backed by a synchronous, gzip-compressing Kafka
+// writer. Messages with the same key land on the same partition.
+type KafkaProducer struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaProducer creates a KafkaProducer from config.
+// Returns an error when brokers or topic are missing or acks is invalid.
+func NewKafkaProducer(config KafkaConfig) (*KafkaProducer, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("kafka: at least one broker is required")
+	}
+	if config.Topic == "" {
+		return nil, fmt.Errorf("kafka: topic is required")
+	}
+
+	acks := kafka.RequireAll
+	if config.Acks != "" {
+		if err := acks.UnmarshalText([]byte(config.Acks)); err != nil {
+			return nil, fmt.Errorf("kafka: %w", err)
+		}
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.BatchTimeout <= 0 {
+		config.BatchTimeout = 100 * time.Millisecond
+	}
+
+	return &KafkaProducer{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: acks,
+			Compression:  kafka.Gzip,
+			BatchSize:    config.BatchSize,
+			BatchTimeout: config.BatchTimeout,
+		},
+	}, nil
+}
+
+// Topic returns the topic messages are published to
+func (p *KafkaProducer) Topic() string {
+	return p.writer.Topic
+}
+
+// WriteMessages publishes messages and blocks until they are acknowledged
+func (p *KafkaProducer) WriteMessages(ctx context.Context, messages ...Message) error {
+	kafkaMessages := make([]kafka.Message, len(messages))
+	for i, message := range messages {
+		kafkaMessages[i] = kafka.Message{Key: message.Key, Value: message.Value}
+	}
+
+	return p.writer.WriteMessages(ctx, kafkaMessages...)
+}
+
+// Close flushes pending messages and closes broker connections
+func (p *KafkaProducer) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	json "github.com/json-iterator/go"
+)
+
+// Message is a single keyed record published to a message sink.
+// Value holds one transformed row encoded as a JSON document (one NDJSON line).
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// Producer publishes messages to a message sink such as a Kafka topic.
+//
+// Implementation Notes:
+//   - WriteMessages should return only once the messages are acknowledged
+//     (according to the producer's acks setting), so a nil error means flushed
+//   - Implementations MUST be safe for concurrent use
+type Producer interface {
+	WriteMessages(ctx context.Context, messages ...Message) error
+}
+
+// KeyFunc extracts the message key from a source item (e.g. the ticket id).
+// A nil key lets the producer pick the partition.
+type KeyFunc[T any] func(item T) []byte
+
+// Publish streams items from fetcher through transformer and publishes each
+// transformed item as one message instead of writing an HTTP body. It reuses
+// the same per-item JSON encoding as Stream().
+//
+// Type Parameters:
+//   - T: The type of data items being published
+//
+// Parameters:
+//   - ctx: Context for cancellation and timeout
+//   - fetcher: Function that provides data items
+//   - transformer: Function that transforms each item
+//   - producer: Sink receiving the encoded messages
+//   - key: Extracts the message key from the source item
+//   - batchSize: Number of messages sent per WriteMessages call
+//
+// Returns:
+//   - int64: Number of messages successfully published
+//   - error: First fetch, transform, encode or publish error
+//
+// Implementation Notes:
+//   - Messages are flushed every batchSize items and once more at the end
+//   - Stops at the first error; messages of the failed batch are not counted
+//   - Respects context cancellation
+func Publish[T any](
+	ctx context.Context,
+	fetcher DataFetcher[T],
+	transformer Transformer[T],
+	producer Producer,
+	key KeyFunc[T],
+	batchSize int,
+) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	// Stop the fetcher when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var published int64
+	batch := make([]Message, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := producer.WriteMessages(ctx, batch...); err != nil {
+			return fmt.Errorf("publish failed: %w", err)
+		}
+		published += int64(len(batch))
+		batch = make([]Message, 0, batchSize)
+		return nil
+	}
+
+	dataChan, errChan := fetcher(ctx)
+	for item := range dataChan {
+		transformed, err := transformer(item)
+		if err != nil {
+			return published, fmt.Errorf("transformation failed: %w", err)
+		}
+
+		value, err := json.Marshal(transformed)
+		if err != nil {
+			return published, fmt.Errorf("JSON marshal failed: %w", err)
+		}
+
+		batch = append(batch, Message{Key: key(item), Value: value})
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return published, err
+			}
+		}
+	}
+
+	// Sources send at most one error before closing their channels
+	if err := <-errChan; err != nil {
+		return published, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return published, err
+	}
+
+	if err := flush(); err != nil {
+		return published, err
+	}
+
+	return published, nil
+}
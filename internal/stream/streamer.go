@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"stream/common"
 	"stream/middleware"
 
 	json "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // streamer is the default implementation of the Streamer interface.
@@ -25,6 +28,10 @@ import (
 type streamer[T any] struct {
 	config     ChunkConfig
 	bufferPool BufferPool
+
+	// tracer records fetch/transform spans (nil disables tracing)
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
 }
 
 // NewStreamer creates a new Streamer with the given configuration.
@@ -70,7 +77,8 @@ func NewStreamer[T any](config ChunkConfig) Streamer[T] {
 // Flow:
 //  1. Start goroutine for processing
 //  2. Fetch data from fetcher
-//  3. Transform each item
+//  3. Transform each item (by TransformWorkers goroutines when > 1,
+//     reassembled in fetch order)
 //  4. Encode to JSON
 //  5. Buffer until chunk threshold
 //  6. Send chunk when threshold reached
@@ -79,6 +87,7 @@ func NewStreamer[T any](config ChunkConfig) Streamer[T] {
 // Error Handling:
 //   - Stops on first error from fetcher or transformer
 //   - Sends error via StreamChunk
+//   - Cancels the fetcher so its goroutine exits
 //   - Closes all channels
 //   - Cleans up resources
 //
@@ -96,6 +105,11 @@ func (s *streamer[T]) Stream(
 	go func() {
 		defer close(chunkChan)
 
+		// Stop the fetcher when streaming ends early (error or cancellation)
+		// so its goroutine never stays blocked on a send
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
 		// Get buffer from pool
 		jsonBuf := s.bufferPool.Get()
 		defer func() {
@@ -107,76 +121,137 @@ func (s *streamer[T]) Stream(
 		// Start JSON array
 		*jsonBuf = append(*jsonBuf, '[')
 
+		// Trace transformation (no-op when tracing is disabled)
+		var streamErr error
+		_, transformSpan := s.startPhase(ctx, "stream.transform")
+		defer func() { transformSpan.end(streamErr) }()
+
 		// Fetch data
-		dataChan, errChan := fetcher(ctx)
+		dataChan, errChan := traceFetcher(s, fetcher)(ctx)
+
+		// With TransformWorkers > 1 a worker pool transforms the rows and
+		// results arrive in fetch order; dataChan is then read by the pool only
+		var results <-chan chan transformResult
+		if s.config.TransformWorkers > 1 {
+			results = transformOrdered(ctx, dataChan, transformer, s.config.TransformWorkers)
+			dataChan = nil
+		}
 
 		firstItem := true
 
+		// fail reports err as the last chunk of the stream
+		fail := func(err error) {
+			streamErr = err
+			sendChunk(ctx, chunkChan, middleware.StreamChunk{
+				Error: common.NewStreamError(streamErr),
+			})
+		}
+
+		// appendRow encodes a transformed row into the chunk buffer and
+		// flushes it past the threshold; false means the stream has ended
+		appendRow := func(transformed interface{}) bool {
+			// Encode to JSON
+			jsonData, err := json.Marshal(transformed)
+			if err != nil {
+				fail(fmt.Errorf("JSON marshal error: %w", err))
+				return false
+			}
+			if err := CheckRowSize(len(jsonData), s.config.MaxRowBytes); err != nil {
+				fail(err)
+				return false
+			}
+
+			// Add comma separator if not first item
+			if !firstItem {
+				*jsonBuf = append(*jsonBuf, ',')
+			} else {
+				firstItem = false
+			}
+
+			// Append JSON data
+			*jsonBuf = append(*jsonBuf, jsonData...)
+
+			// Send chunk if threshold exceeded. Rows are only appended whole,
+			// so an oversized row grows the buffer and is flushed intact.
+			if len(*jsonBuf) > s.config.ChunkThreshold {
+				if !sendChunk(ctx, chunkChan, middleware.StreamChunk{JSONBuf: jsonBuf}) {
+					return false
+				}
+
+				// Get new buffer for next chunk
+				jsonBuf = s.bufferPool.Get()
+				*jsonBuf = (*jsonBuf)[:0]
+			}
+			return true
+		}
+
+		// finish closes the JSON array and sends the final chunk
+		finish := func() {
+			*jsonBuf = append(*jsonBuf, ']')
+			if sendChunk(ctx, chunkChan, middleware.StreamChunk{JSONBuf: jsonBuf}) {
+				jsonBuf = nil // Prevent double-put in defer
+			}
+		}
+
 		for {
 			select {
 			case <-ctx.Done():
 				// Context cancelled
 				return
 
-			case err := <-errChan:
+			case err, ok := <-errChan:
+				if !ok {
+					// Error channel closed without error, keep draining data
+					errChan = nil
+					continue
+				}
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("fetcher error: %w", err),
-					}
+					fail(fmt.Errorf("fetcher error: %w", err))
 					return
 				}
 
 			case item, ok := <-dataChan:
 				if !ok {
 					// Channel closed, all items processed
-					// Close JSON array
-					*jsonBuf = append(*jsonBuf, ']')
-
-					// Send final chunk
-					chunkChan <- middleware.StreamChunk{
-						JSONBuf: jsonBuf,
-					}
-					jsonBuf = nil // Prevent double-put in defer
+					finish()
 					return
 				}
 
 				// Transform item
+				started := transformSpan.begin()
 				transformed, err := transformer(item)
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("transformer error: %w", err),
-					}
+					fail(fmt.Errorf("transformer error: %w", err))
 					return
 				}
+				transformSpan.observe(1, started)
 
-				// Encode to JSON
-				jsonData, err := json.Marshal(transformed)
-				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("JSON marshal error: %w", err),
-					}
+				if !appendRow(transformed) {
 					return
 				}
 
-				// Add comma separator if not first item
-				if !firstItem {
-					*jsonBuf = append(*jsonBuf, ',')
-				} else {
-					firstItem = false
+			case slot, ok := <-results:
+				if !ok {
+					// Pool drained, all items processed
+					finish()
+					return
 				}
 
-				// Append JSON data
-				*jsonBuf = append(*jsonBuf, jsonData...)
-
-				// Send chunk if threshold exceeded
-				if len(*jsonBuf) > s.config.ChunkThreshold {
-					chunkChan <- middleware.StreamChunk{
-						JSONBuf: jsonBuf,
-					}
+				// Wait for the next row in order
+				var result transformResult
+				select {
+				case result = <-slot:
+				case <-ctx.Done():
+					return
+				}
+				if result.err != nil {
+					fail(fmt.Errorf("transformer error: %w", result.err))
+					return
+				}
+				transformSpan.record(1, result.elapsed)
 
-					// Get new buffer for next chunk
-					jsonBuf = s.bufferPool.Get()
-					*jsonBuf = (*jsonBuf)[:0]
+				if !appendRow(result.value) {
+					return
 				}
 			}
 		}
@@ -229,6 +304,11 @@ func (s *streamer[T]) StreamBatch(
 	go func() {
 		defer close(chunkChan)
 
+		// Stop the fetcher when streaming ends early (error or cancellation)
+		// so its goroutine never stays blocked on a send
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
 		// Get buffer from pool
 		jsonBuf := s.bufferPool.Get()
 		defer func() {
@@ -240,8 +320,13 @@ func (s *streamer[T]) StreamBatch(
 		// Start JSON array
 		*jsonBuf = append(*jsonBuf, '[')
 
+		// Trace transformation (no-op when tracing is disabled)
+		var streamErr error
+		_, transformSpan := s.startPhase(ctx, "stream.transform")
+		defer func() { transformSpan.end(streamErr) }()
+
 		// Fetch batches
-		batchChan, errChan := fetcher(ctx)
+		batchChan, errChan := traceBatchFetcher(s, fetcher)(ctx)
 
 		firstItem := true
 
@@ -251,11 +336,17 @@ func (s *streamer[T]) StreamBatch(
 				// Context cancelled
 				return
 
-			case err := <-errChan:
+			case err, ok := <-errChan:
+				if !ok {
+					// Error channel closed without error, keep draining data
+					errChan = nil
+					continue
+				}
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("batch fetcher error: %w", err),
-					}
+					streamErr = fmt.Errorf("batch fetcher error: %w", err)
+					sendChunk(ctx, chunkChan, middleware.StreamChunk{
+						Error: common.NewStreamError(streamErr),
+					})
 					return
 				}
 
@@ -266,29 +357,39 @@ func (s *streamer[T]) StreamBatch(
 					*jsonBuf = append(*jsonBuf, ']')
 
 					// Send final chunk
-					chunkChan <- middleware.StreamChunk{
-						JSONBuf: jsonBuf,
+					if sendChunk(ctx, chunkChan, middleware.StreamChunk{JSONBuf: jsonBuf}) {
+						jsonBuf = nil // Prevent double-put in defer
 					}
-					jsonBuf = nil // Prevent double-put in defer
 					return
 				}
 
 				// Transform batch
+				started := transformSpan.begin()
 				transformed, err := transformer(batch)
 				if err != nil {
-					chunkChan <- middleware.StreamChunk{
-						Error: fmt.Errorf("batch transformer error: %w", err),
-					}
+					streamErr = fmt.Errorf("batch transformer error: %w", err)
+					sendChunk(ctx, chunkChan, middleware.StreamChunk{
+						Error: common.NewStreamError(streamErr),
+					})
 					return
 				}
+				transformSpan.observe(len(transformed), started)
 
 				// Encode each transformed item
 				for _, item := range transformed {
 					jsonData, err := json.Marshal(item)
 					if err != nil {
-						chunkChan <- middleware.StreamChunk{
-							Error: fmt.Errorf("JSON marshal error: %w", err),
-						}
+						streamErr = fmt.Errorf("JSON marshal error: %w", err)
+						sendChunk(ctx, chunkChan, middleware.StreamChunk{
+							Error: common.NewStreamError(streamErr),
+						})
+						return
+					}
+					if err := CheckRowSize(len(jsonData), s.config.MaxRowBytes); err != nil {
+						streamErr = err
+						sendChunk(ctx, chunkChan, middleware.StreamChunk{
+							Error: common.NewStreamError(streamErr),
+						})
 						return
 					}
 
@@ -304,8 +405,8 @@ func (s *streamer[T]) StreamBatch(
 
 					// Send chunk if threshold exceeded
 					if len(*jsonBuf) > s.config.ChunkThreshold {
-						chunkChan <- middleware.StreamChunk{
-							JSONBuf: jsonBuf,
+						if !sendChunk(ctx, chunkChan, middleware.StreamChunk{JSONBuf: jsonBuf}) {
+							return
 						}
 
 						// Get new buffer for next chunk
@@ -325,6 +426,18 @@ func (s *streamer[T]) StreamBatch(
 	}
 }
 
+// sendChunk delivers a chunk to the consumer unless the context is done.
+// It returns false when the chunk was dropped, so the streaming goroutine
+// never blocks forever on a consumer that has stopped reading.
+func sendChunk(ctx context.Context, chunkChan chan<- middleware.StreamChunk, chunk middleware.StreamChunk) bool {
+	select {
+	case chunkChan <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 // GetConfig returns the current streaming configuration.
 //
 // Returns:
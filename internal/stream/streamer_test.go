@@ -1,16 +1,77 @@
 package stream
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"stream/common"
+	"stream/middleware"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	json "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// checkGoroutineLeaks fails the test if goroutines started during the test are
+// still running once it ends (e.g. a fetcher blocked on a channel send).
+// Tests using it must not run in parallel since the count is process-wide.
+func checkGoroutineLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+
+	t.Cleanup(func() {
+		// Give exiting goroutines a moment to finish
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if after := runtime.NumGoroutine(); after > before {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, true)
+			t.Errorf("Goroutine leak: %d before, %d after\n%s", before, after, buf[:n])
+		}
+	})
+}
+
+// infiniteFetcher sends increasing integers on an unbuffered channel until ctx is done
+func infiniteFetcher(ctx context.Context) (<-chan int, <-chan error) {
+	dataChan := make(chan int)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+
+		for i := 0; ; i++ {
+			select {
+			case dataChan <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return dataChan, errChan
+}
+
 // TestStreamer_Stream tests basic streaming functionality
 func TestStreamer_Stream(t *testing.T) {
 	ctx := context.Background()
@@ -19,6 +80,8 @@ func TestStreamer_Stream(t *testing.T) {
 	streamer := NewStreamer[int](config)
 
 	t.Run("streams items successfully", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		// Create fetcher that sends 10 items
 		fetcher := func(ctx context.Context) (<-chan int, <-chan error) {
 			dataChan := make(chan int, 10)
@@ -84,6 +147,8 @@ func TestStreamer_Stream(t *testing.T) {
 	})
 
 	t.Run("handles empty data", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		fetcher := func(ctx context.Context) (<-chan int, <-chan error) {
 			dataChan := make(chan int, 1)
 			errChan := make(chan error, 1)
@@ -109,6 +174,8 @@ func TestStreamer_Stream(t *testing.T) {
 	})
 
 	t.Run("handles fetcher error", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		fetcher := func(ctx context.Context) (<-chan int, <-chan error) {
 			dataChan := make(chan int, 1)
 			errChan := make(chan error, 1)
@@ -139,6 +206,8 @@ func TestStreamer_Stream(t *testing.T) {
 	})
 
 	t.Run("handles transformer error", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		fetcher := func(ctx context.Context) (<-chan int, <-chan error) {
 			dataChan := make(chan int, 1)
 			errChan := make(chan error, 1)
@@ -172,6 +241,8 @@ func TestStreamer_Stream(t *testing.T) {
 	})
 
 	t.Run("respects context cancellation", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		ctx, cancel := context.WithCancel(context.Background())
 
 		fetcher := func(ctx context.Context) (<-chan int, <-chan error) {
@@ -217,6 +288,49 @@ func TestStreamer_Stream(t *testing.T) {
 			t.Errorf("Expected early termination, got %d chunks", count)
 		}
 	})
+
+	t.Run("transformer error mid-stream stops the fetcher", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		transformer := func(item int) (interface{}, error) {
+			if item == 5 {
+				return nil, fmt.Errorf("transform error")
+			}
+			return item, nil
+		}
+
+		resp := streamer.Stream(ctx, infiniteFetcher, transformer)
+
+		gotError := false
+		for chunk := range resp.ChunkChan {
+			if chunk.Error != nil {
+				gotError = true
+			}
+		}
+
+		if !gotError {
+			t.Error("Expected to receive error from transformer")
+		}
+	})
+
+	t.Run("consumer abandoning the stream does not block the streamer", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		smallConfig := DefaultChunkConfig()
+		smallConfig.ChunkThreshold = 1
+		smallConfig.ChannelBuffer = 1
+		smallStreamer := NewStreamer[int](smallConfig)
+
+		resp := smallStreamer.Stream(ctx, infiniteFetcher, PassThroughTransformer[int]())
+
+		// Read a single chunk, then disconnect like a client going away.
+		// The channel is never read again; the leak check verifies the
+		// streamer and fetcher goroutines still exit.
+		<-resp.ChunkChan
+		cancel()
+	})
 }
 
 // TestStreamer_StreamBatch tests batch streaming functionality
@@ -227,6 +341,8 @@ func TestStreamer_StreamBatch(t *testing.T) {
 	streamer := NewStreamer[int](config)
 
 	t.Run("streams batches successfully", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
 		fetcher := func(ctx context.Context) (<-chan []int, <-chan error) {
 			batchChan := make(chan []int, 2)
 			errChan := make(chan error, 1)
@@ -279,6 +395,452 @@ func TestStreamer_StreamBatch(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("transformer error mid-stream stops the fetcher", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		fetcher := BatchFetcherFrom(infiniteFetcher, 10)
+		calls := 0
+		transformer := func(items []int) ([]interface{}, error) {
+			calls++
+			if calls == 2 {
+				return nil, fmt.Errorf("batch transform error")
+			}
+			result := make([]interface{}, len(items))
+			for i, item := range items {
+				result[i] = item
+			}
+			return result, nil
+		}
+
+		resp := streamer.StreamBatch(ctx, fetcher, transformer)
+
+		gotError := false
+		for chunk := range resp.ChunkChan {
+			if chunk.Error != nil {
+				gotError = true
+			}
+		}
+
+		if !gotError {
+			t.Error("Expected to receive error from batch transformer")
+		}
+	})
+
+	t.Run("context cancellation stops the fetcher", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		resp := streamer.StreamBatch(ctx, BatchFetcherFrom(infiniteFetcher, 10), PassThroughBatchTransformer[int]())
+
+		// Stop reading after the first chunk, like a disconnected client
+		<-resp.ChunkChan
+		cancel()
+	})
+}
+
+// countingPool is a BufferPool that counts buffers handed out and returned
+type countingPool struct {
+	BufferPool
+	gets atomic.Int64
+	puts atomic.Int64
+}
+
+func (p *countingPool) Get() *[]byte {
+	p.gets.Add(1)
+	return p.BufferPool.Get()
+}
+
+func (p *countingPool) Put(buf *[]byte) {
+	if buf != nil {
+		p.puts.Add(1)
+	}
+	p.BufferPool.Put(buf)
+}
+
+// TestStreamer_UnconsumedStream tests a client that goes away before reading
+// the first chunk: the producer fills the channel, then must exit on
+// cancellation and return every buffer it still owns to the pool
+func TestStreamer_UnconsumedStream(t *testing.T) {
+	config := DefaultChunkConfig()
+	config.ChunkThreshold = 1
+	config.ChannelBuffer = 2
+
+	run := func(t *testing.T, start func(s *streamer[int], ctx context.Context) middleware.StreamResponse) {
+		checkGoroutineLeaks(t)
+
+		pool := &countingPool{BufferPool: NewBufferPool(config.BufferSize)}
+		s := &streamer[int]{config: config, bufferPool: pool}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		resp := start(s, ctx)
+
+		// Wait until the producer is blocked on a full channel, without reading
+		deadline := time.Now().Add(time.Second)
+		for len(resp.ChunkChan) < cap(resp.ChunkChan) {
+			if time.Now().After(deadline) {
+				t.Fatal("producer never filled the chunk channel")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+
+		// The producer must close the channel promptly; chunks still buffered
+		// in it belong to the (absent) consumer
+		buffered := int64(0)
+		timeout := time.After(time.Second)
+		for done := false; !done; {
+			select {
+			case chunk, ok := <-resp.ChunkChan:
+				if !ok {
+					done = true
+				} else if chunk.JSONBuf != nil {
+					buffered++
+				}
+			case <-timeout:
+				t.Fatal("producer did not exit after cancellation")
+			}
+		}
+
+		if leaked := pool.gets.Load() - pool.puts.Load() - buffered; leaked != 0 {
+			t.Errorf("%d buffers not returned to the pool (gets=%d puts=%d buffered=%d)",
+				leaked, pool.gets.Load(), pool.puts.Load(), buffered)
+		}
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		run(t, func(s *streamer[int], ctx context.Context) middleware.StreamResponse {
+			return s.Stream(ctx, infiniteFetcher, PassThroughTransformer[int]())
+		})
+	})
+
+	t.Run("stream with transform workers", func(t *testing.T) {
+		run(t, func(s *streamer[int], ctx context.Context) middleware.StreamResponse {
+			s.config.TransformWorkers = 4
+			return s.Stream(ctx, infiniteFetcher, PassThroughTransformer[int]())
+		})
+	})
+
+	t.Run("stream batch", func(t *testing.T) {
+		run(t, func(s *streamer[int], ctx context.Context) middleware.StreamResponse {
+			return s.StreamBatch(ctx, BatchFetcherFrom(infiniteFetcher, 10), PassThroughBatchTransformer[int]())
+		})
+	})
+}
+
+// TestStreamer_OversizedRow tests rows whose JSON is larger than ChunkThreshold
+func TestStreamer_OversizedRow(t *testing.T) {
+	config := DefaultChunkConfig() // 32KB threshold, 50KB buffers
+
+	huge := strings.Repeat("x", 200*1024)
+	items := []string{"small", huge, "after"}
+	wrap := func(item string) (interface{}, error) {
+		return map[string]string{"description": item}, nil
+	}
+
+	// collect returns the chunks of a stream, failing on stream errors
+	collect := func(t *testing.T, resp middleware.StreamResponse) [][]byte {
+		t.Helper()
+		var chunks [][]byte
+		for chunk := range resp.ChunkChan {
+			if chunk.Error != nil {
+				t.Fatalf("Unexpected stream error: %v", chunk.Error)
+			}
+			chunks = append(chunks, append([]byte(nil), *chunk.JSONBuf...))
+		}
+		return chunks
+	}
+
+	check := func(t *testing.T, chunks [][]byte) {
+		t.Helper()
+
+		var body []byte
+		for _, chunk := range chunks {
+			body = append(body, chunk...)
+		}
+
+		var rows []map[string]string
+		if err := stdjson.Unmarshal(body, &rows); err != nil {
+			t.Fatalf("Output is not valid JSON: %v", err)
+		}
+		if len(rows) != len(items) {
+			t.Fatalf("Got %d rows, want %d", len(rows), len(items))
+		}
+		for i, row := range rows {
+			if row["description"] != items[i] {
+				t.Errorf("Row %d has %d bytes, want %d", i, len(row["description"]), len(items[i]))
+			}
+		}
+
+		// The oversized row is flushed as soon as it is appended, whole
+		if len(chunks) < 2 {
+			t.Errorf("Got %d chunks, want the oversized row flushed before the end", len(chunks))
+		}
+		for i, chunk := range chunks {
+			if i < len(chunks)-1 && !bytes.HasSuffix(chunk, []byte("}")) {
+				t.Errorf("Chunk %d ends mid-object: ...%q", i, chunk[max(0, len(chunk)-10):])
+			}
+		}
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		streamer := NewStreamer[string](config)
+		check(t, collect(t, streamer.Stream(context.Background(), SliceFetcher(items), wrap)))
+	})
+
+	t.Run("stream batch", func(t *testing.T) {
+		streamer := NewStreamer[string](config)
+		resp := streamer.StreamBatch(context.Background(), SliceBatchFetcher(items, 2), BatchTransformerAdapter(wrap))
+		check(t, collect(t, resp))
+	})
+
+	t.Run("row above MaxRowBytes stops the stream", func(t *testing.T) {
+		limited := config
+		limited.MaxRowBytes = 100 * 1024
+		streamer := NewStreamer[string](limited)
+
+		var streamErr error
+		for chunk := range streamer.Stream(context.Background(), SliceFetcher(items), wrap).ChunkChan {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+			}
+		}
+		if !errors.Is(streamErr, ErrRowTooLarge) {
+			t.Errorf("Error = %v, want ErrRowTooLarge", streamErr)
+		}
+		if !errors.Is(streamErr, common.ErrStream) {
+			t.Errorf("Error = %v, want a stream error", streamErr)
+		}
+	})
+
+	t.Run("negative MaxRowBytes means no limit", func(t *testing.T) {
+		unlimited := config
+		unlimited.MaxRowBytes = -1
+		streamer := NewStreamer[string](unlimited)
+		check(t, collect(t, streamer.Stream(context.Background(), SliceFetcher(items), wrap)))
+	})
+}
+
+// heavyTransform hashes item repeatedly to simulate a CPU-heavy operator such
+// as decrypt; odd items cost more so unordered workers would finish out of order
+func heavyTransform(item int) (interface{}, error) {
+	rounds := 200
+	if item%2 == 1 {
+		rounds = 600
+	}
+
+	sum := sha256.Sum256([]byte(strconv.Itoa(item)))
+	for i := 0; i < rounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return map[string]interface{}{"id": item, "hash": hex.EncodeToString(sum[:4])}, nil
+}
+
+// TestStreamer_TransformWorkers tests the parallel transform stage of Stream
+func TestStreamer_TransformWorkers(t *testing.T) {
+	items := make([]int, 500)
+	for i := range items {
+		items[i] = i
+	}
+
+	// stream returns the concatenated JSON and the stream error, if any
+	stream := func(config ChunkConfig, transformer Transformer[int]) ([]byte, error) {
+		resp := NewStreamer[int](config).Stream(context.Background(), SliceFetcher(items), transformer)
+
+		var data []byte
+		var streamErr error
+		for chunk := range resp.ChunkChan {
+			if chunk.Error != nil {
+				streamErr = chunk.Error
+				continue
+			}
+			data = append(data, *chunk.JSONBuf...)
+		}
+		return data, streamErr
+	}
+
+	t.Run("preserves fetch order", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		serialConfig := DefaultChunkConfig()
+		serialConfig.ChunkThreshold = 256
+		parallelConfig := serialConfig
+		parallelConfig.TransformWorkers = 4
+
+		want, err := stream(serialConfig, heavyTransform)
+		if err != nil {
+			t.Fatalf("serial stream error: %v", err)
+		}
+		got, err := stream(parallelConfig, heavyTransform)
+		if err != nil {
+			t.Fatalf("parallel stream error: %v", err)
+		}
+
+		type row struct {
+			ID   int    `json:"id"`
+			Hash string `json:"hash"`
+		}
+		var serialRows, rows []row
+		if err := json.Unmarshal(want, &serialRows); err != nil {
+			t.Fatalf("invalid serial JSON: %v", err)
+		}
+		if err := json.Unmarshal(got, &rows); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(rows) != len(items) {
+			t.Fatalf("expected %d rows, got %d", len(items), len(rows))
+		}
+		for i, r := range rows {
+			if r.ID != i {
+				t.Fatalf("row %d has id %d, want rows in fetch order", i, r.ID)
+			}
+			if r != serialRows[i] {
+				t.Fatalf("row %d = %+v, serial stream has %+v", i, r, serialRows[i])
+			}
+		}
+	})
+
+	t.Run("stops on transformer error", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+
+		config := DefaultChunkConfig()
+		config.TransformWorkers = 4
+
+		failing := func(item int) (interface{}, error) {
+			if item == 250 {
+				return nil, errors.New("boom")
+			}
+			return heavyTransform(item)
+		}
+
+		data, err := stream(config, failing)
+		if err == nil || !strings.Contains(err.Error(), "transformer error: boom") {
+			t.Fatalf("expected transformer error, got %v", err)
+		}
+		if len(data) != 0 {
+			t.Errorf("expected no data before the first chunk, got %d bytes", len(data))
+		}
+	})
+}
+
+func TestTracedStreamer(t *testing.T) {
+	checkGoroutineLeaks(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	// run streams inside a "request" span and drains the response
+	run := func(stream func(ctx context.Context) middleware.StreamResponse) {
+		ctx, request := tracer.Start(context.Background(), "request")
+		for range stream(ctx).ChunkChan {
+		}
+		request.End()
+	}
+
+	spansByName := func() map[string]sdktrace.ReadOnlySpan {
+		spans := make(map[string]sdktrace.ReadOnlySpan)
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+		return spans
+	}
+
+	attr := func(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+		for _, kv := range span.Attributes() {
+			if kv.Key == key {
+				return kv.Value
+			}
+		}
+		return attribute.Value{}
+	}
+
+	checkSpans := func(t *testing.T, wantRows int64) {
+		t.Helper()
+		spans := spansByName()
+
+		request, ok := spans["request"]
+		if !ok {
+			t.Fatal("request span not recorded")
+		}
+		for _, name := range []string{"stream.fetch", "stream.transform"} {
+			span, ok := spans[name]
+			if !ok {
+				t.Errorf("%s span not recorded", name)
+				continue
+			}
+			if span.Parent().SpanID() != request.SpanContext().SpanID() {
+				t.Errorf("%s parent = %v, want request span", name, span.Parent().SpanID())
+			}
+			if got := attr(span, AttrRowCount).AsInt64(); got != wantRows {
+				t.Errorf("%s row_count = %d, want %d", name, got, wantRows)
+			}
+			if got := attr(span, AttrTableName).AsString(); got != "tickets" {
+				t.Errorf("%s table = %q, want tickets", name, got)
+			}
+		}
+	}
+
+	items := []int{1, 2, 3}
+	double := func(item int) (interface{}, error) { return item * 2, nil }
+
+	t.Run("stream", func(t *testing.T) {
+		recorder.Reset()
+		streamer := NewTracedStreamer[int](DefaultChunkConfig(), tracer, AttrTableName.String("tickets"))
+
+		run(func(ctx context.Context) middleware.StreamResponse {
+			return streamer.Stream(ctx, SliceFetcher(items), double)
+		})
+		checkSpans(t, 3)
+	})
+
+	t.Run("stream batch", func(t *testing.T) {
+		recorder.Reset()
+		streamer := NewTracedStreamer[int](DefaultChunkConfig(), tracer, AttrTableName.String("tickets"))
+
+		run(func(ctx context.Context) middleware.StreamResponse {
+			return streamer.StreamBatch(ctx, SliceBatchFetcher(items, 2), BatchTransformerAdapter(double))
+		})
+		checkSpans(t, 3)
+	})
+
+	t.Run("fetch error is recorded", func(t *testing.T) {
+		recorder.Reset()
+		streamer := NewTracedStreamer[int](DefaultChunkConfig(), tracer)
+
+		failing := func(ctx context.Context) (<-chan int, <-chan error) {
+			dataChan := make(chan int)
+			errChan := make(chan error, 1)
+			errChan <- errors.New("connection reset")
+			close(errChan)
+			close(dataChan)
+			return dataChan, errChan
+		}
+
+		run(func(ctx context.Context) middleware.StreamResponse {
+			return streamer.Stream(ctx, failing, double)
+		})
+
+		fetch, ok := spansByName()["stream.fetch"]
+		if !ok {
+			t.Fatal("stream.fetch span not recorded")
+		}
+		if fetch.Status().Description != "connection reset" {
+			t.Errorf("stream.fetch status = %+v, want connection reset error", fetch.Status())
+		}
+	})
+
+	t.Run("nil tracer records nothing", func(t *testing.T) {
+		recorder.Reset()
+		streamer := NewTracedStreamer[int](DefaultChunkConfig(), nil)
+
+		for range streamer.Stream(context.Background(), SliceFetcher(items), double).ChunkChan {
+		}
+		if spans := recorder.Ended(); len(spans) != 0 {
+			t.Errorf("recorded %d spans, want 0", len(spans))
+		}
+	})
 }
 
 // TestBufferPool tests buffer pool functionality
@@ -455,6 +1017,419 @@ func TestSliceBatchFetcher(t *testing.T) {
 	}
 }
 
+func TestBatchFetcherFrom(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("groups items into batches", func(t *testing.T) {
+		items := []int{1, 2, 3, 4, 5, 6, 7}
+
+		fetcher := BatchFetcherFrom(SliceFetcher(items), 3)
+		batchChan, errChan := fetcher(ctx)
+
+		var batchSizes []int
+		var allItems []int
+		for batch := range batchChan {
+			batchSizes = append(batchSizes, len(batch))
+			allItems = append(allItems, batch...)
+		}
+
+		if err := <-errChan; err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		// Should have 3 batches: [1,2,3], [4,5,6], [7]
+		if len(batchSizes) != 3 || batchSizes[2] != 1 {
+			t.Errorf("Expected batch sizes [3 3 1], got %v", batchSizes)
+		}
+
+		for i, item := range allItems {
+			if item != items[i] {
+				t.Errorf("Item %d: expected %d, got %d", i, items[i], item)
+			}
+		}
+	})
+
+	t.Run("forwards source error", func(t *testing.T) {
+		source := func(ctx context.Context) (<-chan int, <-chan error) {
+			dataChan := make(chan int, 1)
+			errChan := make(chan error, 1)
+			dataChan <- 1
+			errChan <- errors.New("source failed")
+			close(dataChan)
+			close(errChan)
+			return dataChan, errChan
+		}
+
+		batchChan, errChan := BatchFetcherFrom[int](source, 10)(ctx)
+		for range batchChan {
+			t.Error("Expected partial batch to be dropped on error")
+		}
+
+		if err := <-errChan; err == nil || err.Error() != "source failed" {
+			t.Errorf("Expected source error, got %v", err)
+		}
+	})
+}
+
+func TestLimitFetcher(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		items   []int
+		limit   int
+		want    int
+		hasMore bool
+	}{
+		{"extra item beyond limit", []int{1, 2, 3}, 2, 2, true},
+		{"exactly at limit", []int{1, 2, 3}, 3, 3, false},
+		{"fewer items than limit", []int{1}, 3, 1, false},
+		{"no limit", []int{1, 2, 3}, 0, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher, hasMore := LimitFetcher(SliceFetcher(tt.items), tt.limit)
+			dataChan, errChan := fetcher(ctx)
+
+			var got []int
+			for item := range dataChan {
+				got = append(got, item)
+			}
+			if err := <-errChan; err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if len(got) != tt.want {
+				t.Errorf("Expected %d items, got %v", tt.want, got)
+			}
+			if hasMore() != tt.hasMore {
+				t.Errorf("Expected hasMore %v, got %v", tt.hasMore, hasMore())
+			}
+		})
+	}
+}
+
+// recordingProducer records every WriteMessages call
+type recordingProducer struct {
+	batches [][]Message
+	err     error
+}
+
+func (p *recordingProducer) WriteMessages(ctx context.Context, messages ...Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.batches = append(p.batches, messages)
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	key := func(item int) []byte { return []byte(fmt.Sprintf("k%d", item)) }
+	double := func(item int) (interface{}, error) { return map[string]int{"v": item * 2}, nil }
+
+	t.Run("publishes one message per item in batches", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+		producer := &recordingProducer{}
+
+		published, err := Publish(ctx, SliceFetcher([]int{1, 2, 3, 4, 5}), double, producer, key, 2)
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if published != 5 {
+			t.Errorf("Expected 5 published, got %d", published)
+		}
+
+		// Batches of 2, 2 and a final flush of 1
+		if len(producer.batches) != 3 || len(producer.batches[2]) != 1 {
+			t.Fatalf("Expected batch sizes [2 2 1], got %d batches", len(producer.batches))
+		}
+
+		first := producer.batches[0][0]
+		if string(first.Key) != "k1" || string(first.Value) != `{"v":2}` {
+			t.Errorf("Unexpected first message key=%s value=%s", first.Key, first.Value)
+		}
+	})
+
+	t.Run("producer error stops publishing", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+		producer := &recordingProducer{err: errors.New("broker down")}
+
+		published, err := Publish[int](ctx, infiniteFetcher, double, producer, key, 10)
+		if err == nil || !strings.Contains(err.Error(), "broker down") {
+			t.Errorf("Expected producer error, got %v", err)
+		}
+		if published != 0 {
+			t.Errorf("Expected 0 published, got %d", published)
+		}
+	})
+
+	t.Run("transformer error stops publishing", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+		failing := func(item int) (interface{}, error) {
+			if item == 3 {
+				return nil, errors.New("bad row")
+			}
+			return item, nil
+		}
+
+		published, err := Publish[int](ctx, infiniteFetcher, failing, &recordingProducer{}, key, 2)
+		if err == nil || !strings.Contains(err.Error(), "bad row") {
+			t.Errorf("Expected transformer error, got %v", err)
+		}
+		if published != 2 {
+			t.Errorf("Expected first batch of 2 published, got %d", published)
+		}
+	})
+}
+
+func TestNewKafkaProducer(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  KafkaConfig
+		wantErr bool
+	}{
+		{"valid config", KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "tickets", Acks: "one"}, false},
+		{"default acks", KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "tickets"}, false},
+		{"missing brokers", KafkaConfig{Topic: "tickets"}, true},
+		{"missing topic", KafkaConfig{Brokers: []string{"localhost:9092"}}, true},
+		{"invalid acks", KafkaConfig{Brokers: []string{"localhost:9092"}, Topic: "tickets", Acks: "some"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer, err := NewKafkaProducer(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKafkaProducer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if producer.Topic() != tt.config.Topic {
+					t.Errorf("Topic() = %q, want %q", producer.Topic(), tt.config.Topic)
+				}
+				producer.Close()
+			}
+		})
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	t.Run("removes files older than TTL", func(t *testing.T) {
+		dir := t.TempDir()
+		now := time.Now()
+
+		files := map[string]time.Duration{
+			"old.ndjson":         2 * time.Hour,
+			"nested/old.json":    3 * time.Hour,
+			"recent.ndjson":      10 * time.Minute,
+			"nested/recent.json": 0,
+		}
+		for name, age := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			modTime := now.Add(-age)
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		janitor, err := NewJanitor(JanitorConfig{Dir: dir, TTL: time.Hour}, nil)
+		if err != nil {
+			t.Fatalf("NewJanitor() error = %v", err)
+		}
+		janitor.now = func() time.Time { return now }
+
+		removed, err := janitor.Sweep()
+		if err != nil {
+			t.Fatalf("Sweep() error = %v", err)
+		}
+		if removed != 2 {
+			t.Errorf("Sweep() removed %d files, want 2", removed)
+		}
+
+		for name, age := range files {
+			_, err := os.Stat(filepath.Join(dir, name))
+			expired := age > time.Hour
+			if expired && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s should have been removed, stat error = %v", name, err)
+			}
+			if !expired && err != nil {
+				t.Errorf("%s should have been kept, stat error = %v", name, err)
+			}
+		}
+
+		// Directories are kept even when emptied
+		if _, err := os.Stat(filepath.Join(dir, "nested")); err != nil {
+			t.Errorf("nested directory should have been kept, stat error = %v", err)
+		}
+	})
+
+	t.Run("missing directory is not an error", func(t *testing.T) {
+		janitor, err := NewJanitor(JanitorConfig{Dir: filepath.Join(t.TempDir(), "missing"), TTL: time.Hour}, nil)
+		if err != nil {
+			t.Fatalf("NewJanitor() error = %v", err)
+		}
+
+		removed, err := janitor.Sweep()
+		if err != nil || removed != 0 {
+			t.Errorf("Sweep() = (%d, %v), want (0, nil)", removed, err)
+		}
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "old.ndjson")
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-2 * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		janitor, err := NewJanitor(JanitorConfig{Dir: dir, TTL: time.Hour, Interval: 10 * time.Millisecond}, nil)
+		if err != nil {
+			t.Fatalf("NewJanitor() error = %v", err)
+		}
+
+		janitor.Start()
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expired file was not removed by the background sweep")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		janitor.Stop()
+		janitor.Stop()
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		if _, err := NewJanitor(JanitorConfig{TTL: time.Hour}, nil); err == nil {
+			t.Error("expected error for missing directory")
+		}
+		if _, err := NewJanitor(JanitorConfig{Dir: t.TempDir()}, nil); err == nil {
+			t.Error("expected error for missing TTL")
+		}
+	})
+}
+
+func TestDownloadSigner(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "exports"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "exports", "tickets.ndjson"), []byte(`{"id":1}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewDownloadSigner(DownloadConfig{Dir: dir, Secret: []byte("secret"), Expiry: time.Hour})
+	if err != nil {
+		t.Fatalf("NewDownloadSigner() error = %v", err)
+	}
+	now := time.Now()
+	signer.now = func() time.Time { return now }
+
+	download := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		signer.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	url, err := signer.URL("https://exports.example.com/", "exports/tickets.ndjson")
+	if err != nil {
+		t.Fatalf("URL() error = %v", err)
+	}
+	token := strings.TrimPrefix(url, "https://exports.example.com/download/")
+
+	t.Run("valid token within expiry serves the artifact", func(t *testing.T) {
+		w := download("/download/" + token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+		if w.Body.String() != `{"id":1}`+"\n" {
+			t.Errorf("body = %q", w.Body.String())
+		}
+		if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="tickets.ndjson"` {
+			t.Errorf("Content-Disposition = %q", got)
+		}
+	})
+
+	t.Run("expired token is rejected", func(t *testing.T) {
+		signer.now = func() time.Time { return now.Add(time.Hour + time.Second) }
+		defer func() { signer.now = func() time.Time { return now } }()
+
+		if _, err := signer.Verify(token); !errors.Is(err, ErrTokenExpired) {
+			t.Errorf("Verify() error = %v, want ErrTokenExpired", err)
+		}
+		if w := download("/download/" + token); w.Code != http.StatusGone {
+			t.Errorf("status = %d, want 410", w.Code)
+		}
+	})
+
+	t.Run("tampered token is rejected", func(t *testing.T) {
+		payload, signature, _ := strings.Cut(token, ".")
+		other, _, err := signer.Sign("exports/other.ndjson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherPayload, _, _ := strings.Cut(other, ".")
+
+		for _, tampered := range []string{
+			otherPayload + "." + signature, // payload swapped
+			payload + "." + signature[1:],  // signature cut
+			payload,                        // signature missing
+			"",
+		} {
+			if _, err := signer.Verify(tampered); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify(%q) error = %v, want ErrInvalidToken", tampered, err)
+			}
+		}
+		if w := download("/download/" + otherPayload + "." + signature); w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+
+		// Signed with another secret
+		foreign, _ := NewDownloadSigner(DownloadConfig{Dir: dir, Secret: []byte("other")})
+		forged, _, _ := foreign.Sign("exports/tickets.ndjson")
+		if _, err := signer.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(forged) error = %v, want ErrInvalidToken", err)
+		}
+	})
+
+	t.Run("removed artifact is not found", func(t *testing.T) {
+		missing, _, _ := signer.Sign("exports/missing.ndjson")
+		if w := download("/download/" + missing); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("names outside the directory are not signed", func(t *testing.T) {
+		for _, name := range []string{"../secret.txt", "/etc/passwd", ""} {
+			if _, _, err := signer.Sign(name); err == nil {
+				t.Errorf("Sign(%q) should fail", name)
+			}
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		if _, err := NewDownloadSigner(DownloadConfig{Secret: []byte("secret")}); err == nil {
+			t.Error("expected error for missing directory")
+		}
+		if _, err := NewDownloadSigner(DownloadConfig{Dir: dir}); err == nil {
+			t.Error("expected error for missing secret")
+		}
+	})
+}
+
 func TestPassThroughTransformer(t *testing.T) {
 	transformer := PassThroughTransformer[string]()
 
@@ -676,6 +1651,99 @@ func TestSQLBatchFetcherWithColumns(t *testing.T) {
 	})
 }
 
+func TestSQLBatchFetcherWithStats(t *testing.T) {
+	// newRows returns n rows of a mock query and the database they hold a connection of
+	newRows := func(t *testing.T, n int) (*sql.DB, *sql.Rows) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		rows := sqlmock.NewRows([]string{"id"})
+		for i := 1; i <= n; i++ {
+			rows.AddRow(i)
+		}
+		mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+		sqlRows, err := db.Query("SELECT id FROM data")
+		if err != nil {
+			t.Fatalf("Failed to create rows: %v", err)
+		}
+		return db, sqlRows
+	}
+
+	t.Run("slow consumer: one batch prefetched and connection released on cancellation", func(t *testing.T) {
+		checkGoroutineLeaks(t)
+		db, rows := newRows(t, 100)
+
+		var scanned atomic.Int64
+		scanner := func(rows *sql.Rows, cols []string) (map[string]interface{}, error) {
+			scanned.Add(1)
+			return GenericRowScanner()(rows, cols)
+		}
+
+		stats := &FetchStats{}
+		ctx, cancel := context.WithCancel(context.Background())
+		batchChan, errChan := SQLBatchFetcherWithStats(rows, []string{"id"}, 5, scanner, stats)(ctx)
+
+		if batch := <-batchChan; len(batch) != 5 {
+			t.Fatalf("first batch has %d rows, want 5", len(batch))
+		}
+
+		// The consumer is busy with the first batch
+		time.Sleep(50 * time.Millisecond)
+		if got := scanned.Load(); got > 10 {
+			t.Errorf("scanned %d rows while the consumer held the first batch, want at most 2 batches (10)", got)
+		}
+		if db.Stats().InUse != 1 {
+			t.Errorf("connections in use = %d, want 1 while streaming", db.Stats().InUse)
+		}
+
+		cancel()
+		for range batchChan {
+		}
+		if err := <-errChan; err != nil {
+			t.Errorf("cancellation reported an error: %v", err)
+		}
+
+		if inUse := db.Stats().InUse; inUse != 0 {
+			t.Errorf("connections in use = %d after cancellation, want 0", inUse)
+		}
+		if !stats.ConsumerBound() {
+			t.Errorf("stats = scan %v, wait %v: want consumer-bound", stats.ScanDuration(), stats.WaitDuration())
+		}
+		if stats.Batches() != 1 {
+			t.Errorf("batches = %d, want 1", stats.Batches())
+		}
+	})
+
+	t.Run("slow database is not consumer-bound", func(t *testing.T) {
+		_, rows := newRows(t, 6)
+		scanner := func(rows *sql.Rows, cols []string) (map[string]interface{}, error) {
+			time.Sleep(5 * time.Millisecond)
+			return GenericRowScanner()(rows, cols)
+		}
+
+		stats := &FetchStats{}
+		batchChan, errChan := SQLBatchFetcherWithStats(rows, []string{"id"}, 4, scanner, stats)(context.Background())
+		count := 0
+		for batch := range batchChan {
+			count += len(batch)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if count != 6 || stats.Batches() != 2 {
+			t.Errorf("got %d rows in %d batches, want 6 in 2", count, stats.Batches())
+		}
+		if stats.ConsumerBound() {
+			t.Errorf("stats = scan %v, wait %v: want DB-bound", stats.ScanDuration(), stats.WaitDuration())
+		}
+	})
+}
+
 func TestGenericRowScanner(t *testing.T) {
 	t.Run("scans row to map correctly", func(t *testing.T) {
 		db, mock, err := sqlmock.New()
@@ -716,6 +1784,59 @@ func TestGenericRowScanner(t *testing.T) {
 }
 
 // BenchmarkStreamer benchmarks streaming performance
+func TestTypedRowScanner(t *testing.T) {
+	t.Run("DECIMAL survives round-trip without float error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock: %v", err)
+		}
+		defer db.Close()
+
+		rows := sqlmock.NewRowsWithColumnDefinition(
+			sqlmock.NewColumn("id").OfType("BIGINT", int64(0)),
+			sqlmock.NewColumn("amount").OfType("DECIMAL", []byte{}),
+			sqlmock.NewColumn("note").OfType("VARCHAR", []byte{}),
+		).AddRow(int64(1), []byte("1234.56"), []byte("paid"))
+
+		mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+		sqlRows, err := db.Query("SELECT id, amount, note FROM invoices")
+		if err != nil {
+			t.Fatalf("Failed to create rows: %v", err)
+		}
+		defer sqlRows.Close()
+
+		scanner := TypedRowScanner()
+
+		if !sqlRows.Next() {
+			t.Fatal("Expected at least one row")
+		}
+
+		result, err := scanner(sqlRows, []string{"id", "amount", "note"})
+		if err != nil {
+			t.Fatalf("Scanner failed: %v", err)
+		}
+
+		amount, ok := result["amount"].(stdjson.Number)
+		if !ok || amount.String() != "1234.56" {
+			t.Errorf("Expected amount=json.Number(1234.56), got %v (%T)", result["amount"], result["amount"])
+		}
+
+		// Non-DECIMAL columns are left as scanned
+		if _, ok := result["note"].([]byte); !ok {
+			t.Errorf("Expected note to stay []byte, got %T", result["note"])
+		}
+
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if !contains(string(encoded), `"amount":1234.56`) {
+			t.Errorf("Expected exact JSON number, got %s", encoded)
+		}
+	})
+}
+
 func BenchmarkStreamer_Stream(b *testing.B) {
 	ctx := context.Background()
 	config := DefaultChunkConfig()
@@ -751,6 +1872,33 @@ func BenchmarkStreamer_Stream(b *testing.B) {
 	}
 }
 
+// BenchmarkStreamer_Stream_TransformWorkers compares serial and parallel
+// transformation of CPU-heavy rows
+func BenchmarkStreamer_Stream_TransformWorkers(b *testing.B) {
+	items := make([]int, 1000)
+	for i := range items {
+		items[i] = i
+	}
+
+	for _, workers := range []int{0, 2, 4, runtime.NumCPU()} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			config := DefaultChunkConfig()
+			config.TransformWorkers = workers
+			streamer := NewStreamer[int](config)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				resp := streamer.Stream(context.Background(), SliceFetcher(items), heavyTransform)
+				for chunk := range resp.ChunkChan {
+					_ = chunk
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkSQLFetcherWithColumns benchmarks enhanced SQL fetcher
 func BenchmarkSQLFetcherWithColumns(b *testing.B) {
 	// Create mock DB
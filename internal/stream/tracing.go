@@ -0,0 +1,184 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attribute keys shared by the streamer and the services
+const (
+	AttrRowCount    = attribute.Key("stream.row_count")
+	AttrTableName   = attribute.Key("db.table")
+	AttrTransformMs = attribute.Key("stream.transform_ms")
+)
+
+// NewTracedStreamer creates a Streamer that records OpenTelemetry spans for
+// row fetching ("stream.fetch") and transformation ("stream.transform").
+// Both spans are children of the span in the ctx passed to Stream/StreamBatch
+// and carry attrs (e.g. the table name) plus the number of rows.
+//
+// Implementation Notes:
+//   - A nil tracer returns the plain NewStreamer, so disabled tracing costs nothing
+//   - Rows are not traced individually; stream.transform accumulates the
+//     time spent in the transformer for all rows in stream.transform_ms
+//   - stream.fetch ends when the fetcher closes its data channel
+func NewTracedStreamer[T any](config ChunkConfig, tracer trace.Tracer, attrs ...attribute.KeyValue) Streamer[T] {
+	s := NewStreamer[T](config).(*streamer[T])
+	if tracer != nil {
+		s.tracer = tracer
+		s.attrs = attrs
+	}
+	return s
+}
+
+// phaseSpan accumulates row counts and elapsed time for one streaming phase.
+// All methods are no-ops on a nil *phaseSpan (tracing disabled).
+type phaseSpan struct {
+	span    trace.Span
+	rows    int64
+	elapsed time.Duration
+}
+
+// startPhase starts a span named name, or returns nil when tracing is disabled
+func (s *streamer[T]) startPhase(ctx context.Context, name string) (context.Context, *phaseSpan) {
+	if s.tracer == nil {
+		return ctx, nil
+	}
+
+	ctx, span := s.tracer.Start(ctx, name, trace.WithAttributes(s.attrs...))
+	return ctx, &phaseSpan{span: span}
+}
+
+// begin returns the start time of a measured step (zero when disabled)
+func (p *phaseSpan) begin() time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe records rows processed in a step started at started
+func (p *phaseSpan) observe(rows int, started time.Time) {
+	if p == nil {
+		return
+	}
+	p.record(rows, time.Since(started))
+}
+
+// record adds rows processed in elapsed time, e.g. measured by a worker
+func (p *phaseSpan) record(rows int, elapsed time.Duration) {
+	if p == nil {
+		return
+	}
+	p.rows += int64(rows)
+	p.elapsed += elapsed
+}
+
+// end records the totals and the error (if any) and ends the span
+func (p *phaseSpan) end(err error) {
+	if p == nil {
+		return
+	}
+
+	p.span.SetAttributes(
+		AttrRowCount.Int64(p.rows),
+		AttrTransformMs.Int64(p.elapsed.Milliseconds()),
+	)
+	if err != nil {
+		p.span.RecordError(err)
+		p.span.SetStatus(codes.Error, err.Error())
+	}
+	p.span.End()
+}
+
+// traceFetcher wraps fetcher in a stream.fetch span that counts items and
+// ends when the source closes its data channel
+func traceFetcher[T any](s *streamer[T], fetcher DataFetcher[T]) DataFetcher[T] {
+	if s.tracer == nil {
+		return fetcher
+	}
+
+	return func(ctx context.Context) (<-chan T, <-chan error) {
+		ctx, span := s.tracer.Start(ctx, "stream.fetch", trace.WithAttributes(s.attrs...))
+		dataChan, errChan := fetcher(ctx)
+		return countItems(ctx, span, dataChan, errChan, func(T) int { return 1 })
+	}
+}
+
+// traceBatchFetcher is traceFetcher for batch fetchers; rows are counted
+// across batches
+func traceBatchFetcher[T any](s *streamer[T], fetcher BatchFetcher[T]) BatchFetcher[T] {
+	if s.tracer == nil {
+		return fetcher
+	}
+
+	return func(ctx context.Context) (<-chan []T, <-chan error) {
+		ctx, span := s.tracer.Start(ctx, "stream.fetch", trace.WithAttributes(s.attrs...))
+		batchChan, errChan := fetcher(ctx)
+		return countItems(ctx, span, batchChan, errChan, func(batch []T) int { return len(batch) })
+	}
+}
+
+// countItems forwards items and the error from a fetcher, ending span with
+// the number of rows once the data channel is closed
+func countItems[I any](ctx context.Context, span trace.Span, dataChan <-chan I, errChan <-chan error, rowsOf func(I) int) (<-chan I, <-chan error) {
+	out := make(chan I)
+	outErr := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(outErr)
+
+		var rows int64
+		defer func() {
+			span.SetAttributes(AttrRowCount.Int64(rows))
+			span.End()
+		}()
+
+		fail := func(err error) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			outErr <- err
+		}
+
+		for {
+			select {
+			case err, ok := <-errChan:
+				if !ok {
+					errChan = nil
+					continue
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+
+			case item, ok := <-dataChan:
+				if !ok {
+					// Like the streamer, a closed data channel ends the fetch;
+					// only an error that is already pending is still reported
+					select {
+					case err := <-errChan:
+						if err != nil {
+							fail(err)
+						}
+					default:
+					}
+					return
+				}
+				select {
+				case out <- item:
+					rows += int64(rowsOf(item))
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, outErr
+}
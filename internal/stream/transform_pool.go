@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"context"
+	"time"
+)
+
+// transformResult is the outcome of transforming one row in the worker pool
+type transformResult struct {
+	value   interface{}
+	err     error
+	elapsed time.Duration
+}
+
+// transformOrdered transforms the items of dataChan with workers goroutines
+// and returns one result slot per item in fetch order, so the consumer reads
+// results in order while later rows are still being transformed.
+//
+// Implementation Notes:
+//   - Slots are buffered, so workers never block on a slow consumer
+//   - At most 3*workers rows are in flight (queued slots plus running jobs)
+//   - The returned channel is closed when dataChan is closed or ctx is done;
+//     after cancellation queued rows are skipped without being transformed
+func transformOrdered[T any](ctx context.Context, dataChan <-chan T, transformer Transformer[T], workers int) <-chan chan transformResult {
+	type job struct {
+		item T
+		slot chan transformResult
+	}
+
+	jobs := make(chan job, workers)
+	slots := make(chan chan transformResult, 2*workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for j := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				started := time.Now()
+				value, err := transformer(j.item)
+				j.slot <- transformResult{value: value, err: err, elapsed: time.Since(started)}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(slots)
+		defer close(jobs)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case item, ok := <-dataChan:
+				if !ok {
+					return
+				}
+
+				// Queue the slot before the job so slots stay in fetch order
+				slot := make(chan transformResult, 1)
+				select {
+				case slots <- slot:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case jobs <- job{item: item, slot: slot}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return slots
+}
@@ -44,9 +44,28 @@ package stream
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"stream/middleware"
 )
 
+// ErrRowTooLarge is returned when a single encoded row exceeds ChunkConfig.MaxRowBytes
+var ErrRowTooLarge = errors.New("row exceeds maximum size")
+
+// DefaultMaxRowBytes is the row size limit the server applies when
+// MAX_ROW_BYTES is unset: far above any real row, but low enough that one
+// runaway row (e.g. a huge TEXT column) cannot grow a chunk buffer unbounded
+const DefaultMaxRowBytes = 16 << 20
+
+// CheckRowSize returns an error wrapping ErrRowTooLarge when an encoded row of
+// size bytes is above maxRowBytes (0 means no limit)
+func CheckRowSize(size, maxRowBytes int) error {
+	if maxRowBytes > 0 && size > maxRowBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrRowTooLarge, size, maxRowBytes)
+	}
+	return nil
+}
+
 // DataFetcher is a function that fetches data from a source and sends it to a channel.
 // It should close both channels when done or on error.
 // The data channel should send individual items of type T.
@@ -218,6 +237,27 @@ type ChunkConfig struct {
 	//   - Smaller: Lower memory, more blocking
 	//   - Larger: Higher memory, less blocking
 	ChannelBuffer int
+
+	// MaxRowBytes is the largest encoded JSON size allowed for a single row.
+	// A row above ChunkThreshold is never split: it is appended whole (the
+	// buffer grows as needed) and flushed right after. A row above
+	// MaxRowBytes stops the stream with ErrRowTooLarge instead.
+	//
+	// Default: 0 (no limit)
+	MaxRowBytes int
+
+	// TransformWorkers is the number of goroutines transforming rows in
+	// Stream(). Above 1, rows are transformed concurrently and reassembled in
+	// fetch order before encoding, so the transformer must be goroutine-safe.
+	// StreamBatch() is unaffected (see BatchTransformParallel).
+	//
+	// Default: 0 (serial, in the streaming goroutine)
+	// Recommended: runtime.NumCPU() for CPU-heavy transforms
+	//
+	// Tradeoffs:
+	//   - More workers: higher throughput for expensive transforms
+	//   - For cheap transforms the hand-off costs more than it saves
+	TransformWorkers int
 }
 
 // DefaultChunkConfig returns the default streaming configuration.
@@ -247,6 +287,12 @@ func (c *ChunkConfig) Validate() error {
 	if c.ChannelBuffer <= 0 {
 		c.ChannelBuffer = 4
 	}
+	if c.MaxRowBytes < 0 {
+		c.MaxRowBytes = 0
+	}
+	if c.TransformWorkers < 0 {
+		c.TransformWorkers = 0
+	}
 
 	// No validation errors for now
 	// Could add max limits if needed
@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"stream/application/admin"
 	"stream/application/health"
 	"stream/application/tickets"
+	"stream/application/ticketsV2/domain"
 	"stream/application/ticketsV2/handler"
 	"stream/application/ticketsV2/repository"
 	"stream/application/ticketsV2/service"
 	"stream/common"
+	"stream/internal/stream"
+	"strings"
 
 	"log"
 	"net/http"
@@ -17,6 +23,11 @@ import (
 	"stream/middleware"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
@@ -46,14 +57,40 @@ func main() {
 		log.Fatal("Failed to setup dummy database:", err)
 	}
 
-	// Setup real database (MySQL)
+	// Setup real database (MySQL). Without its configuration only the real
+	// database routes are disabled, so local development runs on SQLite alone.
 	realDB, err := setupRealDatabase()
-	if err != nil {
+	if errors.Is(err, errRealDatabaseNotConfigured) {
+		log.Printf("⚠️  %v, real database routes disabled", err)
+	} else if err != nil {
 		log.Fatal("Failed to setup real database:", err)
 	}
 
+	// Optional read replica for the real database's streaming queries
+	var realReplica *gorm.DB
+	if realDB != nil {
+		realReplica = setupRealReplica()
+	}
+
 	z := NewLogger()
-	r := SetupRouter(dummyDB, realDB)
+
+	// Optional OpenTelemetry tracing (nil tracer when disabled)
+	tracer, shutdownTracing := setupTracing()
+	defer shutdownTracing()
+
+	// Background health check of the real database, resetting a stale pool
+	var realMonitor *health.Monitor
+	if realDB != nil {
+		realHealthRepo := health.NewRepository(realDB)
+		realHealthRepo.SetMaxIdleConns(mysqlMaxIdleConns)
+		realMonitor = health.NewMonitor(realHealthRepo, getRealMonitorConfig(), z)
+		realMonitor.Start()
+	}
+
+	// Optional Kafka sink for /v2 .../stream/publish (nil when disabled)
+	kafkaProducer := setupKafkaProducer()
+
+	r := SetupRouter(dummyDB, realDB, realReplica, realMonitor, tracer, kafkaProducer, z)
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -91,6 +128,17 @@ func main() {
 		}
 	}()
 
+	// Optional cleanup of expired export artifacts
+	var janitor *stream.Janitor
+	if janitorConfig, ok := getJanitorConfig(); ok {
+		janitor, err = stream.NewJanitor(janitorConfig, z)
+		if err != nil {
+			log.Printf("⚠️  Invalid export cleanup configuration, cleanup disabled: %v", err)
+		} else {
+			janitor.Start()
+		}
+	}
+
 	go func() {
 		log.Println("🚀 Server starting on http://localhost:8080")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -102,6 +150,17 @@ func main() {
 	<-ctx.Done()
 	log.Println("🛑 Shutting down server...")
 	srv.Shutdown(context.Background())
+	if janitor != nil {
+		janitor.Stop()
+	}
+	if realMonitor != nil {
+		realMonitor.Stop()
+	}
+	if kafkaProducer != nil {
+		if err := kafkaProducer.Close(); err != nil {
+			log.Printf("⚠️  Failed to close Kafka producer: %v", err)
+		}
+	}
 }
 
 func NewLogger() *zap.Logger {
@@ -142,6 +201,10 @@ func setupDummyDatabase() (*gorm.DB, error) {
 	return db, nil
 }
 
+// errRealDatabaseNotConfigured is returned by setupRealDatabase when the
+// REAL_DB_* environment variables are missing
+var errRealDatabaseNotConfigured = errors.New("missing required real database environment variables")
+
 func setupRealDatabase() (*gorm.DB, error) {
 	log.Println("🗄️  Setting up real database (MySQL)...")
 
@@ -154,19 +217,52 @@ func setupRealDatabase() (*gorm.DB, error) {
 
 	// Validate required environment variables
 	if host == "" || port == "" || user == "" || pass == "" || dbname == "" {
-		return nil, fmt.Errorf("missing required real database environment variables")
+		return nil, errRealDatabaseNotConfigured
 	}
 
 	// Build MySQL DSN
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		user, pass, host, port, dbname)
 
-	// Open MySQL database connection
+	db, err := openMySQL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("real database: %w", err)
+	}
+
+	log.Println("✅ Real database connected successfully")
+
+	return db, nil
+}
+
+// setupRealReplica connects the optional read replica of the real database
+// from REAL_DB_REPLICA_DSN (MySQL DSN). Returns nil when unset or unreachable,
+// in which case every query runs on the primary.
+func setupRealReplica() *gorm.DB {
+	dsn := os.Getenv("REAL_DB_REPLICA_DSN")
+	if dsn == "" {
+		return nil
+	}
+
+	db, err := openMySQL(dsn)
+	if err != nil {
+		log.Printf("⚠️  Read replica unavailable, streaming from primary: %v", err)
+		return nil
+	}
+
+	log.Println("✅ Real database read replica connected successfully")
+	return db
+}
+
+// mysqlMaxIdleConns is the idle pool size of the MySQL connections
+const mysqlMaxIdleConns = 10
+
+// openMySQL opens a MySQL connection, pings it and configures the pool
+func openMySQL(dsn string) (*gorm.DB, error) {
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect real database: %w", err)
+		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 
 	// Test connection
@@ -176,19 +272,623 @@ func setupRealDatabase() (*gorm.DB, error) {
 	}
 
 	if err := sqlDB.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping real database: %w", err)
+		return nil, fmt.Errorf("failed to ping: %w", err)
 	}
 
 	// Configure connection pool
-	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxIdleConns(mysqlMaxIdleConns)
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	log.Println("✅ Real database connected successfully")
-
 	return db, nil
 }
 
+// getCountOnReplica reads REAL_DB_COUNT_ON_REPLICA ("true"/"false") to run
+// COUNT(*) queries on the read replica too (default: primary)
+func getCountOnReplica() bool {
+	value := os.Getenv("REAL_DB_COUNT_ON_REPLICA")
+	if value == "" {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid REAL_DB_COUNT_ON_REPLICA %q, counting on primary", value)
+		return false
+	}
+
+	return enabled
+}
+
+// getQueryTimeout reads the per-query timeout from DB_QUERY_TIMEOUT (e.g. "15s").
+// Falls back to tickets.DefaultQueryTimeout when unset or invalid.
+func getQueryTimeout() time.Duration {
+	value := os.Getenv("DB_QUERY_TIMEOUT")
+	if value == "" {
+		return tickets.DefaultQueryTimeout
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid DB_QUERY_TIMEOUT %q, using default %v", value, tickets.DefaultQueryTimeout)
+		return tickets.DefaultQueryTimeout
+	}
+
+	return timeout
+}
+
+// getMaxConnsPerRequest reads DB_MAX_CONNS_PER_REQUEST, the number of pooled
+// connections one v1 export may hold at once (0 disables the cap)
+func getMaxConnsPerRequest() int {
+	value := os.Getenv("DB_MAX_CONNS_PER_REQUEST")
+	if value == "" {
+		return tickets.DefaultMaxConnsPerRequest
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("⚠️  Invalid DB_MAX_CONNS_PER_REQUEST %q, using default %d", value, tickets.DefaultMaxConnsPerRequest)
+		return tickets.DefaultMaxConnsPerRequest
+	}
+
+	return n
+}
+
+// getCountRetries reads DB_COUNT_RETRIES, how many times a v1 COUNT(*)
+// failing with a transient error is retried (0 disables retries)
+func getCountRetries() int {
+	value := os.Getenv("DB_COUNT_RETRIES")
+	if value == "" {
+		return tickets.DefaultCountRetries
+	}
+
+	retries, err := strconv.Atoi(value)
+	if err != nil || retries < 0 {
+		log.Printf("⚠️  Invalid DB_COUNT_RETRIES %q, using default %d", value, tickets.DefaultCountRetries)
+		return tickets.DefaultCountRetries
+	}
+
+	return retries
+}
+
+// getMaxQueryRows reads MAX_QUERY_ROWS, the most rows a v1 SELECT on the real
+// (MySQL) database may be estimated (by EXPLAIN) to examine before it is
+// rejected. Unset, 0 or invalid values disable the check.
+func getMaxQueryRows() int64 {
+	value := os.Getenv("MAX_QUERY_ROWS")
+	if value == "" {
+		return 0
+	}
+
+	rows, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || rows < 0 {
+		log.Printf("⚠️  Invalid MAX_QUERY_ROWS %q, query cost unchecked", value)
+		return 0
+	}
+
+	return rows
+}
+
+// getRealMonitorConfig reads the real database health check settings:
+// REAL_DB_HEALTH_INTERVAL (duration), REAL_DB_HEALTH_FAILURES (consecutive
+// failed pings before the pool is reset) and REAL_DB_RESET_MAX_BACKOFF
+// (duration). Unset or invalid values keep the defaults.
+func getRealMonitorConfig() health.MonitorConfig {
+	var config health.MonitorConfig
+
+	if value := os.Getenv("REAL_DB_HEALTH_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			log.Printf("⚠️  Invalid REAL_DB_HEALTH_INTERVAL %q, using default", value)
+		} else {
+			config.Interval = interval
+		}
+	}
+
+	if value := os.Getenv("REAL_DB_HEALTH_FAILURES"); value != "" {
+		failures, err := strconv.Atoi(value)
+		if err != nil || failures < 1 {
+			log.Printf("⚠️  Invalid REAL_DB_HEALTH_FAILURES %q, using default", value)
+		} else {
+			config.FailureThreshold = failures
+		}
+	}
+
+	if value := os.Getenv("REAL_DB_RESET_MAX_BACKOFF"); value != "" {
+		backoff, err := time.ParseDuration(value)
+		if err != nil || backoff <= 0 {
+			log.Printf("⚠️  Invalid REAL_DB_RESET_MAX_BACKOFF %q, using default", value)
+		} else {
+			config.MaxBackoff = backoff
+		}
+	}
+
+	return config
+}
+
+// getMaxInListSize reads MAX_IN_LIST_SIZE, the longest WHERE IN list bound
+// in a single v1 query; longer lists are queried in chunks. 0 disables
+// chunking. Unset or invalid values keep the default.
+func getMaxInListSize() int {
+	value := os.Getenv("MAX_IN_LIST_SIZE")
+	if value == "" {
+		return tickets.DefaultMaxInListSize
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		log.Printf("⚠️  Invalid MAX_IN_LIST_SIZE %q, using default %d", value, tickets.DefaultMaxInListSize)
+		return tickets.DefaultMaxInListSize
+	}
+
+	return size
+}
+
+// getTransformWorkers reads TRANSFORM_WORKERS, the number of goroutines
+// transforming rows of the v2 (item-by-item) streams. Unset or invalid values
+// keep the serial default.
+func getTransformWorkers() int {
+	value := os.Getenv("TRANSFORM_WORKERS")
+	if value == "" {
+		return 0
+	}
+
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 0 {
+		log.Printf("⚠️  Invalid TRANSFORM_WORKERS %q, transforming serially", value)
+		return 0
+	}
+
+	return workers
+}
+
+// getMaxRowBytes reads MAX_ROW_BYTES, the largest encoded size of a single
+// row; a larger row fails its stream. Unset or invalid values keep
+// stream.DefaultMaxRowBytes; 0 disables the limit.
+func getMaxRowBytes() int {
+	value := os.Getenv("MAX_ROW_BYTES")
+	if value == "" {
+		return stream.DefaultMaxRowBytes
+	}
+
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		log.Printf("⚠️  Invalid MAX_ROW_BYTES %q, using %d", value, stream.DefaultMaxRowBytes)
+		return stream.DefaultMaxRowBytes
+	}
+
+	return size
+}
+
+// getLookupLimiter reads MAX_CONCURRENT_LOOKUPS, the most lookup operator
+// calls (translate, dbEnum) running at once across all streams. Unset, 0 or
+// invalid values keep lookups unlimited.
+func getLookupLimiter() *tickets.LookupLimiter {
+	value := os.Getenv("MAX_CONCURRENT_LOOKUPS")
+	if value == "" {
+		return nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Printf("⚠️  Invalid MAX_CONCURRENT_LOOKUPS %q, lookups unlimited", value)
+		return nil
+	}
+
+	return tickets.NewLookupLimiter(limit)
+}
+
+// getOperatorConfig reads tenant-specific operator values from the environment:
+// OPERATOR_TICKET_PREFIX, OPERATOR_ADDITIONAL_PREFIX, OPERATOR_DECRYPT_KEY,
+// OPERATOR_TIMEZONE (IANA name, e.g. "Asia/Jakarta"), OPERATOR_DICTIONARIES
+// (comma-separated JSON/YAML files for translate), OPERATOR_BUSINESS_HOURS /
+// OPERATOR_BUSINESS_DAYS (e.g. "09:00-17:00" and "mon-fri" for businessDuration),
+// OPERATOR_DATE_LAYOUTS ("|"-separated Go layouts tried by parseDateFlexible),
+// OPERATOR_INVALID_UTF8 ("replace" or "null" for fields with invalid UTF-8),
+// OPERATOR_NUMBER_LOCALE (e.g. "id-ID", the default locale of formatNumber),
+// OPERATOR_FORMAT_DATE_EPOCHS ("true" to format unix seconds in formatDate)
+// and OPERATOR_SENTIMENT_SCALES (JSON buckets of the sentimentMapping scales,
+// e.g. {"stars": [{"min": 1, "max": 2, "label": "Bad"}, ...]}).
+// Unset values keep the defaults; invalid ones are logged and skipped.
+func getOperatorConfig() tickets.OperatorConfig {
+	config, err := loadOperatorConfig()
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	return config
+}
+
+// loadOperatorConfig reads the operator config like getOperatorConfig and
+// also returns the errors of the values it skipped
+func loadOperatorConfig() (tickets.OperatorConfig, error) {
+	config := tickets.DefaultOperatorConfig()
+	var errs []error
+
+	if prefix := os.Getenv("OPERATOR_TICKET_PREFIX"); prefix != "" {
+		config.TicketPrefix = prefix
+	}
+	if prefix := os.Getenv("OPERATOR_ADDITIONAL_PREFIX"); prefix != "" {
+		config.AdditionalDataPrefix = prefix
+	}
+	config.DecryptKey = os.Getenv("OPERATOR_DECRYPT_KEY")
+
+	if tz := os.Getenv("OPERATOR_TIMEZONE"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_TIMEZONE %q, keeping dates as stored: %w", tz, err))
+		} else {
+			config.Location = location
+		}
+	}
+
+	if value := os.Getenv("OPERATOR_DICTIONARIES"); value != "" {
+		// "a.json, b.yaml" and a trailing comma name the same files
+		var paths []string
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		dictionaries, err := tickets.LoadDictionaries(paths...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_DICTIONARIES, translate disabled: %w", err))
+		} else {
+			config.Dictionaries = dictionaries
+		}
+	}
+
+	hours, days := os.Getenv("OPERATOR_BUSINESS_HOURS"), os.Getenv("OPERATOR_BUSINESS_DAYS")
+	if hours != "" || days != "" {
+		businessHours, err := tickets.ParseBusinessHours(hours, days)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_BUSINESS_HOURS/OPERATOR_BUSINESS_DAYS, keeping Mon-Fri 09:00-17:00: %w", err))
+		} else {
+			config.BusinessHours = businessHours
+		}
+	}
+
+	if layouts := os.Getenv("OPERATOR_DATE_LAYOUTS"); layouts != "" {
+		config.DateLayouts = nil
+		for _, layout := range strings.Split(layouts, "|") {
+			if layout = strings.TrimSpace(layout); layout != "" {
+				config.DateLayouts = append(config.DateLayouts, layout)
+			}
+		}
+	}
+
+	if name := os.Getenv("OPERATOR_INVALID_UTF8"); name != "" {
+		if policy, ok := tickets.ParseUTF8Policy(name); ok {
+			config.InvalidUTF8 = policy
+		} else {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_INVALID_UTF8 %q (use replace or null), keeping replace", name))
+		}
+	}
+
+	if name := os.Getenv("OPERATOR_NUMBER_LOCALE"); name != "" {
+		if locale, ok := tickets.ParseNumberLocale(name); ok {
+			config.NumberLocale = locale
+		} else {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_NUMBER_LOCALE %q (use e.g. en-US or id-ID), keeping en-US", name))
+		}
+	}
+
+	if value := os.Getenv("OPERATOR_FORMAT_DATE_EPOCHS"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			config.FormatDateEpochs = enabled
+		} else {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_FORMAT_DATE_EPOCHS %q (use true or false), keeping epochs as text", value))
+		}
+	}
+
+	if value := os.Getenv("OPERATOR_SENTIMENT_SCALES"); value != "" {
+		scales, err := tickets.ParseSentimentScales(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid OPERATOR_SENTIMENT_SCALES, keeping the built-in scales: %w", err))
+		} else {
+			config.SentimentScales = scales
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// getDeduplication reads EXPORT_DEDUP ("true"/"false") to enable sharing of
+// in-flight count queries between identical v1 export requests
+func getDeduplication() bool {
+	value := os.Getenv("EXPORT_DEDUP")
+	if value == "" {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid EXPORT_DEDUP %q, de-duplication disabled", value)
+		return false
+	}
+
+	return enabled
+}
+
+// getStreamSummaryLog reads STREAM_SUMMARY_LOG ("true"/"false") to log one
+// summary line per v1 stream when it ends
+func getStreamSummaryLog() bool {
+	value := os.Getenv("STREAM_SUMMARY_LOG")
+	if value == "" {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid STREAM_SUMMARY_LOG %q, stream summaries disabled", value)
+		return false
+	}
+
+	return enabled
+}
+
+// getStreamErrorMarker reads STREAM_ERROR_MARKER ("true"/"false") to end the
+// body of a v1 stream failing mid-way with an error marker
+func getStreamErrorMarker() bool {
+	value := os.Getenv("STREAM_ERROR_MARKER")
+	if value == "" {
+		return false
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid STREAM_ERROR_MARKER %q, error markers disabled", value)
+		return false
+	}
+
+	return enabled
+}
+
+// getDefaultOrderBy reads the ordering applied to v1 payloads without orderBy
+// from DEFAULT_ORDER_BY as "field,direction" (e.g. "id,desc"). "none" disables
+// it; unset keeps tickets.DefaultOrderBy.
+func getDefaultOrderBy() []string {
+	value := os.Getenv("DEFAULT_ORDER_BY")
+	switch {
+	case value == "":
+		return tickets.DefaultOrderBy()
+	case strings.EqualFold(value, "none"):
+		return nil
+	}
+
+	orderBy := strings.Split(value, ",")
+	for i := range orderBy {
+		orderBy[i] = strings.TrimSpace(orderBy[i])
+	}
+	return orderBy
+}
+
+// getOperatorMetrics returns the operator latency metrics when
+// OPERATOR_METRICS is "true" (served on GET /metrics), nil otherwise so the
+// operators are not timed
+func getOperatorMetrics() *tickets.OperatorMetrics {
+	value := os.Getenv("OPERATOR_METRICS")
+	if value == "" {
+		return nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid OPERATOR_METRICS %q, metrics disabled", value)
+		return nil
+	}
+	if !enabled {
+		return nil
+	}
+	return tickets.NewOperatorMetrics()
+}
+
+// getResultCache reads the replay cache of v1 exports: RESULT_CACHE_TTL
+// (e.g. "1m"; unset disables it) and RESULT_CACHE_MAX_BYTES, the memory
+// budget of each endpoint (default tickets.DefaultResultCacheMaxBytes)
+func getResultCache() (time.Duration, int64) {
+	value := os.Getenv("RESULT_CACHE_TTL")
+	if value == "" {
+		return 0, 0
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid RESULT_CACHE_TTL %q, result cache disabled", value)
+		return 0, 0
+	}
+
+	var maxBytes int64
+	if value := os.Getenv("RESULT_CACHE_MAX_BYTES"); value != "" {
+		maxBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			log.Printf("⚠️  Invalid RESULT_CACHE_MAX_BYTES %q, using default %d", value, tickets.DefaultResultCacheMaxBytes)
+			maxBytes = 0
+		}
+	}
+
+	return ttl, maxBytes
+}
+
+// getKeyCase reads the naming convention of the v1 output keys from
+// OUTPUT_KEY_CASE ("none", "snake" or "camel"); unset keeps the field names
+func getKeyCase() tickets.KeyCase {
+	value := os.Getenv("OUTPUT_KEY_CASE")
+	keyCase, ok := tickets.ParseKeyCase(value)
+	if !ok {
+		log.Printf("⚠️  Invalid OUTPUT_KEY_CASE %q, keeping field names", value)
+		return tickets.KeyCaseNone
+	}
+	return keyCase
+}
+
+// getQueryLogMode reads QUERY_DEBUG_LOG ("args" or "true" to log every v1
+// query with its args, "redacted" for their types only); unset logs none.
+// Args may hold personal data, so never log them in production.
+func getQueryLogMode() tickets.QueryLogMode {
+	value := os.Getenv("QUERY_DEBUG_LOG")
+	mode, ok := tickets.ParseQueryLogMode(value)
+	if !ok {
+		log.Printf("⚠️  Invalid QUERY_DEBUG_LOG %q, query logging disabled", value)
+		return tickets.QueryLogOff
+	}
+	return mode
+}
+
+// getReferenceTables reads the reference tables resolved by dbEnum from
+// REFERENCE_TABLES as comma-separated "name=table.key_column.label_column"
+// entries (e.g. "status=ticket_statuses.id.name") and their refresh interval
+// from REFERENCE_TABLES_TTL (default 5m). Invalid entries are skipped.
+func getReferenceTables() ([]tickets.ReferenceTable, time.Duration) {
+	var tables []tickets.ReferenceTable
+	for _, entry := range strings.Split(os.Getenv("REFERENCE_TABLES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+
+		name, source, _ := strings.Cut(entry, "=")
+		parts := strings.Split(source, ".")
+		if name == "" || len(parts) != 3 {
+			log.Printf("⚠️  Invalid REFERENCE_TABLES entry %q, expected name=table.key_column.label_column", entry)
+			continue
+		}
+		tables = append(tables, tickets.ReferenceTable{Name: name, Table: parts[0], KeyColumn: parts[1], LabelColumn: parts[2]})
+	}
+
+	ttl := 5 * time.Minute
+	if value := os.Getenv("REFERENCE_TABLES_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("⚠️  Invalid REFERENCE_TABLES_TTL %q, using default %v", value, ttl)
+		} else {
+			ttl = parsed
+		}
+	}
+
+	return tables, ttl
+}
+
+// setupKafkaProducer creates the producer of the export publishing sink, or
+// returns nil when Kafka is not configured or its configuration is invalid.
+// The caller closes it on shutdown, flushing pending messages.
+func setupKafkaProducer() *stream.KafkaProducer {
+	kafkaConfig, ok := getKafkaConfig()
+	if !ok {
+		return nil
+	}
+
+	kafkaProducer, err := stream.NewKafkaProducer(kafkaConfig)
+	if err != nil {
+		log.Printf("⚠️  Invalid Kafka configuration, publishing disabled: %v", err)
+		return nil
+	}
+	return kafkaProducer
+}
+
+// getKafkaConfig reads the export publishing sink from KAFKA_BROKERS
+// (comma-separated host:port list), KAFKA_TOPIC and KAFKA_ACKS (none/one/all).
+// Returns false when brokers or topic are unset, which disables publishing.
+func getKafkaConfig() (stream.KafkaConfig, bool) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	topic := os.Getenv("KAFKA_TOPIC")
+	if brokers == "" || topic == "" {
+		return stream.KafkaConfig{}, false
+	}
+
+	return stream.KafkaConfig{
+		Brokers: strings.Split(brokers, ","),
+		Topic:   topic,
+		Acks:    os.Getenv("KAFKA_ACKS"),
+	}, true
+}
+
+// getJanitorConfig reads the export cleanup settings from EXPORT_DIR,
+// EXPORT_TTL (e.g. "24h") and optionally EXPORT_CLEANUP_INTERVAL.
+// Returns false when EXPORT_DIR or EXPORT_TTL is unset, which disables cleanup.
+func getJanitorConfig() (stream.JanitorConfig, bool) {
+	dir := os.Getenv("EXPORT_DIR")
+	ttlValue := os.Getenv("EXPORT_TTL")
+	if dir == "" || ttlValue == "" {
+		return stream.JanitorConfig{}, false
+	}
+
+	ttl, err := time.ParseDuration(ttlValue)
+	if err != nil {
+		log.Printf("⚠️  Invalid EXPORT_TTL %q, export cleanup disabled", ttlValue)
+		return stream.JanitorConfig{}, false
+	}
+
+	config := stream.JanitorConfig{Dir: dir, TTL: ttl}
+	if value := os.Getenv("EXPORT_CLEANUP_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("⚠️  Invalid EXPORT_CLEANUP_INTERVAL %q, using default", value)
+		} else {
+			config.Interval = interval
+		}
+	}
+
+	return config, true
+}
+
+// getDownloadConfig reads the signed download link configuration:
+// EXPORT_DIR and DOWNLOAD_SECRET enable it, DOWNLOAD_URL_EXPIRY (a duration,
+// default 15m) sets how long a link stays valid
+func getDownloadConfig() (stream.DownloadConfig, bool) {
+	dir := os.Getenv("EXPORT_DIR")
+	secret := os.Getenv("DOWNLOAD_SECRET")
+	if dir == "" || secret == "" {
+		return stream.DownloadConfig{}, false
+	}
+
+	config := stream.DownloadConfig{Dir: dir, Secret: []byte(secret)}
+	if value := os.Getenv("DOWNLOAD_URL_EXPIRY"); value != "" {
+		expiry, err := time.ParseDuration(value)
+		if err != nil || expiry <= 0 {
+			log.Printf("⚠️  Invalid DOWNLOAD_URL_EXPIRY %q, using default", value)
+		} else {
+			config.Expiry = expiry
+		}
+	}
+
+	return config, true
+}
+
+// setupTracing enables OpenTelemetry tracing when OTEL_TRACING is "true".
+// Spans are written to stdout; W3C trace context headers are propagated.
+// Returns a nil tracer and a no-op shutdown when tracing is disabled.
+func setupTracing() (trace.Tracer, func()) {
+	value := os.Getenv("OTEL_TRACING")
+	if value == "" {
+		return nil, func() {}
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid OTEL_TRACING %q, tracing disabled", value)
+		return nil, func() {}
+	}
+	if !enabled {
+		return nil, func() {}
+	}
+
+	exporter, err := stdouttrace.New()
+	if err != nil {
+		log.Printf("⚠️  Failed to create trace exporter, tracing disabled: %v", err)
+		return nil, func() {}
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	shutdown := func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			log.Printf("⚠️  Failed to flush traces: %v", err)
+		}
+	}
+	return provider.Tracer("stream"), shutdown
+}
+
 func seedData(db *gorm.DB) error {
 	// Create tickets in batches for better performance
 	const batchSize = 1000
@@ -222,58 +922,285 @@ func seedData(db *gorm.DB) error {
 	return nil
 }
 
-func SetupRouter(dummyDB *gorm.DB, realDB *gorm.DB) *gin.Engine {
+// SetupRouter builds the router. realDB may be nil when the real database is
+// not configured: its routes then answer 503 Service Unavailable. When
+// realMonitor is set, /readyz follows its checks of the real database.
+// kafkaProducer publishes the /v2 exports of /stream/publish (nil disables
+// publishing); the caller owns and closes it. z logs the per-stream summaries
+// when STREAM_SUMMARY_LOG is enabled (nil: never).
+func SetupRouter(dummyDB *gorm.DB, realDB *gorm.DB, realReplica *gorm.DB, realMonitor *health.Monitor, tracer trace.Tracer, kafkaProducer *stream.KafkaProducer, z *zap.Logger) *gin.Engine {
 	gin.SetMode(gin.DebugMode)
 	r := gin.New()
 	r.Use(gin.Recovery())
 	r.Use(middleware.RequestInit())
+	if tracer != nil {
+		r.Use(middleware.Tracing(tracer))
+	}
 	r.Use(middleware.ResponseInit())
 
 	// Health endpoint (monitors both databases)
 	dummyHealthRepo := health.NewRepository(dummyDB)
 	realHealthRepo := health.NewRepository(realDB)
 	healthSvc := health.NewService(dummyHealthRepo, realHealthRepo)
+	if realMonitor != nil {
+		healthSvc.SetRealMonitor(realMonitor)
+	}
 	healthHandler := health.NewHandler(healthSvc)
 
+	// Per-query timeout for the tickets repositories (separate from the HTTP WriteTimeout)
+	queryTimeout := getQueryTimeout()
+	maxConnsPerRequest := getMaxConnsPerRequest()
+	countRetries := getCountRetries()
+
+	// Queries estimated to examine too many rows are rejected up front
+	maxQueryRows := getMaxQueryRows()
+
+	// Executed queries logged per request ID, for debugging only
+	queryLogMode := getQueryLogMode()
+
+	// Long IN lists are queried in chunks below the placeholder limits
+	maxInListSize := getMaxInListSize()
+
+	// Mid-stream failures marked in the body, besides the trailer
+	errorMarker := getStreamErrorMarker()
+
+	// Tenant-specific operator values (prefixes, labels, timezone, decrypt key)
+	operatorConfig := getOperatorConfig()
+
+	// Per-operator latency histograms, kept across config reloads
+	operatorMetrics := getOperatorMetrics()
+	operatorConfig.Metrics = operatorMetrics
+
+	// Lookup operators share one concurrency limit, kept across config reloads
+	lookupLimiter := getLookupLimiter()
+	operatorConfig.Lookups = lookupLimiter
+
+	// Share in-flight count queries between identical concurrent exports
+	deduplicate := getDeduplication()
+
+	// Naming convention of the output keys (snake_case for some clients)
+	keyCase := getKeyCase()
+
+	// One summary log line per v1 stream (rows, bytes, duration)
+	var streamLogger *zap.Logger
+	if getStreamSummaryLog() {
+		streamLogger = z
+	}
+
+	// Replay identical exports repeated by dashboards within a short TTL
+	resultCacheTTL, resultCacheMaxBytes := getResultCache()
+
+	// Real database exports stream from the read replica when configured
+	countOnReplica := getCountOnReplica()
+
+	// Ordering for payloads without orderBy, so OFFSET pagination is stable
+	defaultOrderBy := getDefaultOrderBy()
+
+	// Chunk tuning per endpoint: real tickets rows are much wider than the
+	// synthetic dummy rows, so they get larger chunks and batches
+	dummyChunkConfig := stream.DefaultChunkConfig()
+	realChunkConfig := stream.ChunkConfig{
+		ChunkThreshold: 128 * 1024,
+		BatchSize:      2000,
+		BufferSize:     160 * 1024,
+		ChannelBuffer:  4,
+	}
+	dummyChunkConfig.TransformWorkers = getTransformWorkers()
+	realChunkConfig.TransformWorkers = dummyChunkConfig.TransformWorkers
+	dummyChunkConfig.MaxRowBytes = getMaxRowBytes()
+	realChunkConfig.MaxRowBytes = dummyChunkConfig.MaxRowBytes
+
 	// Dummy database tickets streaming endpoint
 	dummyTicketsRepo := tickets.NewRepository(dummyDB)
+	dummyTicketsRepo.SetQueryTimeout(queryTimeout)
+	dummyTicketsRepo.SetMaxConnsPerRequest(maxConnsPerRequest)
+	dummyTicketsRepo.SetCountRetries(countRetries)
+	dummyTicketsRepo.SetQueryLog(z, queryLogMode)
 	dummyTicketsSvc := tickets.NewService(dummyTicketsRepo)
+	dummyTicketsSvc.SetOperatorConfig(operatorConfig)
+	dummyTicketsSvc.SetDeduplication(deduplicate)
+	dummyTicketsSvc.SetKeyCase(keyCase)
+	dummyTicketsSvc.SetLogger(streamLogger)
+	dummyTicketsSvc.SetResultCache(resultCacheTTL, resultCacheMaxBytes)
+	dummyTicketsSvc.SetChunkConfig(dummyChunkConfig)
+	dummyTicketsSvc.SetMaxInListSize(maxInListSize)
+	dummyTicketsSvc.SetErrorMarker(errorMarker)
+	dummyTicketsSvc.SetTracer(tracer)
+	if err := dummyTicketsSvc.SetDefaultOrderBy(defaultOrderBy); err != nil {
+		log.Printf("⚠️  Invalid DEFAULT_ORDER_BY, using default: %v", err)
+	}
 	dummyTicketsHandler := tickets.NewHandler(dummyTicketsSvc)
 
 	// Real database tickets streaming endpoint
-	realTicketsRepo := tickets.NewRepository(realDB)
-	realTicketsSvc := tickets.NewService(realTicketsRepo)
-	realTicketsHandler := tickets.NewHandler(realTicketsSvc)
+	var realTicketsSvc *tickets.Service
+	var realTicketsHandler *tickets.Handler
+	if realDB != nil {
+		realTicketsRepo := tickets.NewRepository(realDB)
+		realTicketsRepo.SetQueryTimeout(queryTimeout)
+		realTicketsRepo.SetMaxConnsPerRequest(maxConnsPerRequest)
+		realTicketsRepo.SetCountRetries(countRetries)
+		realTicketsRepo.SetMaxQueryRows(maxQueryRows)
+		realTicketsRepo.SetQueryLog(z, queryLogMode)
+		realTicketsRepo.SetReplica(realReplica, countOnReplica)
+		realTicketsSvc = tickets.NewService(realTicketsRepo)
+		realTicketsSvc.SetOperatorConfig(operatorConfig)
+		realTicketsSvc.SetDeduplication(deduplicate)
+		realTicketsSvc.SetKeyCase(keyCase)
+		realTicketsSvc.SetLogger(streamLogger)
+		realTicketsSvc.SetResultCache(resultCacheTTL, resultCacheMaxBytes)
+		// Status and priority labels maintained in real database tables
+		if tables, ttl := getReferenceTables(); len(tables) > 0 {
+			if err := realTicketsSvc.SetReferenceTables(tables, ttl); err != nil {
+				log.Printf("⚠️  Invalid REFERENCE_TABLES, dbEnum disabled: %v", err)
+			}
+		}
+		realTicketsSvc.SetChunkConfig(realChunkConfig)
+		realTicketsSvc.SetMaxInListSize(maxInListSize)
+		realTicketsSvc.SetErrorMarker(errorMarker)
+		realTicketsSvc.SetTracer(tracer)
+		if err := realTicketsSvc.SetDefaultOrderBy(defaultOrderBy); err != nil {
+			log.Printf("⚠️  Invalid DEFAULT_ORDER_BY, using default: %v", err)
+		}
+		realTicketsHandler = tickets.NewHandler(realTicketsSvc)
+	}
+
+	// V2 - Optional Kafka sink for /stream/publish
+	var producer stream.Producer
+	var topic string
+	if kafkaProducer != nil {
+		producer = kafkaProducer
+		topic = kafkaProducer.Topic()
+	}
 
 	// V2 - Dummy database tickets streaming endpoint
 	dummyTicketsV2Repo := repository.NewRepository(dummyDB)
-	dummyTicketsV2Svc := service.NewService(dummyTicketsV2Repo)
+	dummyTicketsV2Svc := service.NewServiceFromConfig(dummyTicketsV2Repo, service.Config{
+		Operators: operatorConfig,
+		Chunk:     dummyChunkConfig,
+		Producer:  producer,
+		Topic:     topic,
+		Tracer:    tracer,
+	})
 	dummyTicketsV2Handler := handler.NewHandler(dummyTicketsV2Svc)
 
 	// V2 - Real database tickets streaming endpoint
-	realTicketsV2Repo := repository.NewRepository(realDB)
-	realTicketsV2Svc := service.NewService(realTicketsV2Repo)
-	realTicketsV2Handler := handler.NewHandler(realTicketsV2Svc)
+	var realTicketsV2Svc domain.Service
+	var realTicketsV2Handler *handler.Handler
+	if realDB != nil {
+		realTicketsV2Repo := repository.NewRepositoryWithReplica(realDB, realReplica, countOnReplica)
+		realTicketsV2Svc = service.NewServiceFromConfig(realTicketsV2Repo, service.Config{
+			Operators: operatorConfig,
+			Chunk:     realChunkConfig,
+			Producer:  producer,
+			Topic:     topic,
+			Tracer:    tracer,
+		})
+		realTicketsV2Handler = handler.NewHandler(realTicketsV2Svc)
+	}
 
 	// Register routes
 	api := r.Group("")
 	healthHandler.RegisterRoutes(api)
+	if operatorMetrics != nil {
+		api.GET("/metrics", gin.WrapH(operatorMetrics))
+	}
+
+	// Signed, expiring download links for export artifacts
+	if downloadConfig, ok := getDownloadConfig(); ok {
+		signer, err := stream.NewDownloadSigner(downloadConfig)
+		if err != nil {
+			log.Printf("⚠️  Invalid download configuration, downloads disabled: %v", err)
+		} else {
+			api.GET("/download/:token", gin.WrapH(signer))
+		}
+	}
 
-	// Register dummy database routes under /v1/tickets
-	dummyGroup := api.Group("/v1/tickets")
-	dummyTicketsHandler.RegisterRoutesWithPrefix(dummyGroup)
+	// Admin endpoints are only exposed when ADMIN_TOKEN is set
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		reload := func() error {
+			// Keep the running config when any dictionary or value is invalid
+			config, err := loadOperatorConfig()
+			if err != nil {
+				return err
+			}
+			config.Metrics = operatorMetrics
+			config.Lookups = lookupLimiter
+
+			operators := repository.NewOperatorRegistry(config)
+			dummyTicketsSvc.SetOperatorConfig(config)
+			dummyTicketsV2Svc.SetOperators(operators)
+			if realDB != nil {
+				realTicketsSvc.SetOperatorConfig(config)
+				realTicketsV2Svc.SetOperators(operators)
+			}
+			log.Println("🔄 Operator config reloaded")
+			return nil
+		}
+		admin.NewHandler(adminToken, reload).RegisterRoutes(api)
+	}
+
+	// Register v1 routes under /v1/tickets; X-Data-Source selects the database
+	tickets.RegisterRoutesWithSelector(api.Group("/v1/tickets"), selectDataSource(dummyTicketsHandler, realTicketsHandler))
 
 	// Register real database routes under /v1/tickets-real
 	realGroup := api.Group("/v1/tickets-real")
-	realTicketsHandler.RegisterRoutesWithPrefix(realGroup)
+	if realDB != nil {
+		realTicketsHandler.RegisterRoutesWithPrefix(realGroup)
+	} else {
+		realGroup.Any("/*path", realDatabaseUnavailable)
+	}
 
-	// Register V2 dummy database routes under /v2/tickets
-	dummyV2Group := api.Group("/v2/tickets")
-	dummyTicketsV2Handler.RegisterRoutesWithPrefix(dummyV2Group)
+	// Register V2 routes under /v2/tickets; X-Data-Source selects the database
+	handler.RegisterRoutesWithSelector(api.Group("/v2/tickets"), selectDataSource(dummyTicketsV2Handler, realTicketsV2Handler))
 
 	// Register V2 real database routes under /v2/tickets-real
 	realV2Group := api.Group("/v2/tickets-real")
-	realTicketsV2Handler.RegisterRoutesWithPrefix(realV2Group)
+	if realDB != nil {
+		realTicketsV2Handler.RegisterRoutesWithPrefix(realV2Group)
+	} else {
+		realV2Group.Any("/*path", realDatabaseUnavailable)
+	}
 
 	return r
 }
+
+// realDatabaseUnavailable answers the real database routes when the real
+// database is not configured
+func realDatabaseUnavailable(c *gin.Context) {
+	send := c.MustGet("send").(func(middleware.Response))
+	send(middleware.Response{
+		Code:    http.StatusServiceUnavailable,
+		Message: "Real database is not configured",
+		Error:   errRealDatabaseNotConfigured,
+	})
+}
+
+// dataSourceHeader selects the database of the /v1/tickets and /v2/tickets
+// routes: "dummy" (the default when missing) or "real". The -real route
+// prefixes are kept for existing clients.
+const dataSourceHeader = "X-Data-Source"
+
+// selectDataSource returns a selector of the handler of the database named
+// by the X-Data-Source header. Requests for the real database are answered
+// with 503 when it is not configured (realHandler is nil), and any other value with 400.
+func selectDataSource[H any](dummyHandler, realHandler *H) func(c *gin.Context) *H {
+	return func(c *gin.Context) *H {
+		switch source := strings.ToLower(strings.TrimSpace(c.GetHeader(dataSourceHeader))); source {
+		case "", "dummy":
+			return dummyHandler
+		case "real":
+			if realHandler == nil {
+				realDatabaseUnavailable(c)
+			}
+			return realHandler
+		default:
+			send := c.MustGet("send").(func(middleware.Response))
+			send(middleware.Response{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("Invalid %s %q: must be dummy or real", dataSourceHeader, source),
+				Error:   common.NewValidationError(fmt.Errorf("unknown data source %q", source)),
+			})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"stream/common"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTicketsDB returns an in-memory database holding one open ticket numbered ticketNo
+func openTicketsDB(t *testing.T, ticketNo string) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1) // every connection to :memory: is a new database
+	if err := db.AutoMigrate(&common.Ticket{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	ticket := common.Ticket{ID: 1, TicketNo: ticketNo, Status: "open", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := db.Create(&ticket).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	return db
+}
+
+func TestSetupRouter_WithoutRealDatabase(t *testing.T) {
+	for _, name := range []string{"REAL_DB_HOST", "REAL_DB_PORT", "REAL_DB_USER", "REAL_DB_PASS", "REAL_DB_NAME"} {
+		t.Setenv(name, "")
+	}
+
+	realDB, err := setupRealDatabase()
+	if !errors.Is(err, errRealDatabaseNotConfigured) || realDB != nil {
+		t.Fatalf("setupRealDatabase() = %v, %v, want errRealDatabaseNotConfigured", realDB, err)
+	}
+
+	dummyDB := openTicketsDB(t, "TKT-000001")
+
+	r := SetupRouter(dummyDB, nil, nil, nil, nil, nil, nil)
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"tableName": "tickets", "formulas": [{"params": ["ticket_no"], "field": "ticketNo", "operator": "", "position": 1}]}`
+
+	t.Run("real routes return 503", func(t *testing.T) {
+		for _, path := range []string{"/v1/tickets-real/stream", "/v2/tickets-real/stream", "/v2/tickets-real/stream/batch"} {
+			if w := request(http.MethodPost, path, body); w.Code != http.StatusServiceUnavailable {
+				t.Errorf("POST %s: status = %d, want 503: %s", path, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("the real data source returns 503", func(t *testing.T) {
+		for _, path := range []string{"/v1/tickets/stream", "/v2/tickets/stream", "/v2/tickets/stream/batch"} {
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(dataSourceHeader, "real")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("POST %s (%s: real): status = %d, want 503: %s", path, dataSourceHeader, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("dummy routes work", func(t *testing.T) {
+		for _, path := range []string{"/v1/tickets/stream", "/v2/tickets/stream"} {
+			w := request(http.MethodPost, path, body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST %s: status = %d, want 200: %s", path, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), `"ticketNo":"TKT-000001"`) {
+				t.Errorf("POST %s: body = %s", path, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("health reports the real database as not configured", func(t *testing.T) {
+		w := request(http.MethodGet, "/health", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"real_database":"not_configured"`) {
+			t.Errorf("body = %s", w.Body.String())
+		}
+	})
+}
+
+func TestSetupRouter_DataSourceHeader(t *testing.T) {
+	r := SetupRouter(openTicketsDB(t, "TKT-DUMMY"), openTicketsDB(t, "TKT-REAL"), nil, nil, nil, nil, nil)
+	request := func(path, source string) *httptest.ResponseRecorder {
+		body := `{"tableName": "tickets", "formulas": [{"params": ["ticket_no"], "field": "ticketNo", "operator": "", "position": 1}]}`
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if source != "" {
+			req.Header.Set(dataSourceHeader, source)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"", "TKT-DUMMY"},
+		{"dummy", "TKT-DUMMY"},
+		{"real", "TKT-REAL"},
+		{" Real ", "TKT-REAL"},
+	}
+	for _, path := range []string{"/v1/tickets/stream", "/v2/tickets/stream"} {
+		for _, tt := range tests {
+			w := request(path, tt.source)
+			if w.Code != http.StatusOK {
+				t.Fatalf("POST %s (%s: %q): status = %d, want 200: %s", path, dataSourceHeader, tt.source, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), `"ticketNo":"`+tt.want+`"`) {
+				t.Errorf("POST %s (%s: %q): want %s, body = %s", path, dataSourceHeader, tt.source, tt.want, w.Body.String())
+			}
+		}
+
+		w := request(path, "staging")
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "must be dummy or real") {
+			t.Errorf("POST %s with an unknown source: status = %d, body = %s", path, w.Code, w.Body.String())
+		}
+	}
+
+	// The prefixed routes are unchanged
+	if w := request("/v1/tickets-real/stream", ""); !strings.Contains(w.Body.String(), `"ticketNo":"TKT-REAL"`) {
+		t.Errorf("POST /v1/tickets-real/stream: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLoadOperatorConfig_Dictionaries(t *testing.T) {
+	dir := t.TempDir()
+	country := filepath.Join(dir, "country.json")
+	status := filepath.Join(dir, "status.yaml")
+	if err := os.WriteFile(country, []byte(`{"ID": "Indonesia"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(status, []byte("1: Open\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spaces after the commas and empty entries are ignored
+	t.Setenv("OPERATOR_DICTIONARIES", country+", "+status+", ")
+	config, err := loadOperatorConfig()
+	if err != nil {
+		t.Fatalf("loadOperatorConfig() error = %v", err)
+	}
+	if got := config.Dictionaries["country"]["ID"]; got != "Indonesia" {
+		t.Errorf("country ID = %q, want Indonesia", got)
+	}
+	if got := config.Dictionaries["status"]["1"]; got != "Open" {
+		t.Errorf("status 1 = %q, want Open", got)
+	}
+}
+
+func TestSetupRouter_PublishUnknownFields(t *testing.T) {
+	r := SetupRouter(openTicketsDB(t, "TKT-DUMMY"), openTicketsDB(t, "TKT-REAL"), nil, nil, nil, nil, nil)
+	body := `{"tableName": "tickets", "formula": []}`
+
+	for _, path := range []string{"/v2/tickets/stream/publish", "/v2/tickets-real/stream/publish"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `unknown field \"formula\"`) {
+			t.Errorf("POST %s with an unknown field: status = %d, body = %s", path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"stream/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// strictJSON matches keys case-sensitively and rejects keys without a field,
+// so "formula" or "orderby" fail instead of being silently ignored
+var strictJSON = jsoniter.Config{
+	EscapeHTML:            true,
+	CaseSensitive:         true,
+	DisallowUnknownFields: true,
+}.Froze()
+
+// unknownFieldPattern extracts the key from jsoniter's unknown field error
+var unknownFieldPattern = regexp.MustCompile(`found unknown field: (.*?), error found in`)
+
+// UnknownFieldError reports a payload key that matches no field
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string { return fmt.Sprintf("unknown field %q", e.Field) }
+
+// BindStrictJSON decodes the request body into obj and validates its binding
+// tags like c.ShouldBindJSON, but rejects unknown and differently cased keys
+// (also in nested objects). Errors are validation errors; an unknown key
+// wraps an *UnknownFieldError.
+func BindStrictJSON(c *gin.Context, obj interface{}) error {
+	if c.Request == nil || c.Request.Body == nil {
+		return common.NewValidationError(errors.New("request body is empty"))
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return common.NewValidationError(err)
+	}
+
+	if err := strictJSON.Unmarshal(body, obj); err != nil {
+		if match := unknownFieldPattern.FindStringSubmatch(err.Error()); match != nil {
+			return common.NewValidationError(&UnknownFieldError{Field: match[1]})
+		}
+		return common.NewValidationError(err)
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return common.NewValidationError(err)
+	}
+	return nil
+}
+
+// InvalidPayloadMessage returns the response message for a BindStrictJSON
+// error, naming the offending key when the payload has an unknown field
+func InvalidPayloadMessage(err error) string {
+	var unknown *UnknownFieldError
+	if errors.As(err, &unknown) {
+		return fmt.Sprintf("Invalid JSON payload: unknown field %q", unknown.Field)
+	}
+	return "Invalid JSON payload"
+}
@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"stream/common"
 	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
 )
@@ -96,8 +100,51 @@ func RequestInit() gin.HandlerFunc {
 	}
 }
 
+// StreamErrorTrailer is the HTTP trailer carrying the error message of a
+// stream that failed after its first chunk was written
+const StreamErrorTrailer = "X-Stream-Error"
+
+// ErrFlushUnsupported is the error of a stream whose ResponseWriter cannot
+// flush: its chunks would be buffered instead of streamed
+var ErrFlushUnsupported = errors.New("response writer does not support flushing, cannot stream")
+
+// canFlush reports whether w can flush each chunk to the client. Wrappers
+// such as gin's writer implement http.Flusher by delegating to the writer
+// they wrap (and panic when it cannot), so the innermost writer is checked.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = wrapper.Unwrap()
+	}
+	_, ok := w.(http.Flusher)
+	return ok
+}
+
 // sendStream handles streaming responses with proper buffer management
 // Follows the same pattern as send() for consistency
+//
+// Error Handling:
+//   - Before the first chunk: a regular error response with a mapped status
+//   - After the first chunk: the status can no longer change, so the JSON
+//     array is left unclosed (the body fails to parse) and the error message
+//     is sent in the X-Stream-Error trailer. A complete body is always a
+//     valid array and never carries that trailer.
+//
+// Other formats (r.ContentType set) are written chunk by chunk as-is; a
+// failed stream ends early with the same trailer.
+//
+// With r.ErrorMarker set, a stream failing after the first chunk also ends
+// its body with the marker bytes, e.g. closing the array with an
+// {"_error":"..."} element, for clients that cannot read trailers.
+//
+// Every chunk is flushed once written, so memory stays bounded by one chunk
+// and the client receives rows as they are produced. A ResponseWriter that
+// cannot flush fails the stream with ErrFlushUnsupported before any write.
+// No Content-Length is ever sent; the net/http server frames the body itself
+// (chunked over HTTP/1.1, DATA frames over HTTP/2).
 func sendStream(c *gin.Context, shouldDebug bool) func(r StreamResponse) {
 	return func(r StreamResponse) {
 		if r.Code == 0 {
@@ -113,17 +160,58 @@ func sendStream(c *gin.Context, shouldDebug bool) func(r StreamResponse) {
 			return
 		}
 
-		c.Header("Content-Type", "application/json")
-		c.Header("X-Total-Count", fmt.Sprintf("%d", r.TotalCount))
+		if !canFlush(c.Writer) {
+			go drainChunks(r.ChunkChan)
+			send(c, shouldDebug)(Response{
+				Code:    http.StatusInternalServerError,
+				Message: "Stream failed",
+				Error:   ErrFlushUnsupported,
+			})
+			return
+		}
+
+		contentType, jsonArray := r.ContentType, r.ContentType == ""
+		if jsonArray {
+			contentType = "application/json"
+		}
+		c.Header("Content-Type", contentType)
+
+		// The body size is unknown up front: never announce one (e.g. set by
+		// an earlier middleware), so HTTP/1.1 uses chunked transfer encoding
+		// and HTTP/2 ends the body with the last DATA frame
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.Header().Del("Transfer-Encoding")
+
+		// hasMore is only known once the last row is streamed, so it goes in a
+		// trailer; so does a mid-stream error, once the status is already sent
+		trailers := StreamErrorTrailer
+		if r.HasMore != nil {
+			trailers = "X-Has-More, " + trailers
+		}
+		c.Header("Trailer", trailers)
 
 		writer := c.Writer
 		firstRecord := true
 
+		// Once this handler stops reading, the request context is cancelled on
+		// return, which stops the producer; chunks it already queued are drained
+		// so their pooled buffers are reused
+		stopped := false
+		defer func() {
+			if stopped {
+				go drainChunks(r.ChunkChan)
+			}
+		}()
+
 		for chunk := range r.ChunkChan {
 			select {
 			case <-c.Request.Context().Done():
 				requestID := c.GetString("requestId")
 				fmt.Printf("RequestID: %v, Context canceled: %v\n", requestID, c.Request.Context().Err())
+				if chunk.JSONBuf != nil {
+					jsonBufferPool.Put(chunk.JSONBuf)
+				}
+				stopped = true
 				return
 			default:
 			}
@@ -132,36 +220,68 @@ func sendStream(c *gin.Context, shouldDebug bool) func(r StreamResponse) {
 				requestID := c.GetString("requestId")
 				fmt.Printf("RequestID: %v, Stream error: %v\n", requestID, chunk.Error)
 				if firstRecord {
+					// Nothing written yet, so the error can still be reported with a proper status
 					send(c, shouldDebug)(Response{
-						Code:    r.Code,
+						Code:    common.HTTPStatus(chunk.Error),
 						Message: "Stream failed",
-						Error:   r.Error,
+						Error:   chunk.Error,
 					})
 					break
 				}
+				// The 200 status and part of the array are already sent: leave
+				// the array unclosed so parsing fails, or end it with the
+				// error marker, and report the error
+				writer.Header().Set(StreamErrorTrailer, chunk.Error.Error())
+				if r.ErrorMarker != nil {
+					if _, err := writer.Write(r.ErrorMarker(chunk.Error)); err == nil {
+						writer.Flush()
+					}
+				}
+				stopped = true
 				return
 			}
 
 			if chunk.JSONBuf != nil && len(*chunk.JSONBuf) > 0 {
-				if !firstRecord && len(*chunk.JSONBuf) > 0 && (*chunk.JSONBuf)[0] == ',' {
-					writer.Write(*chunk.JSONBuf)
+				var err error
+				// Chunks continue the array: add a separator unless the chunk
+				// already starts with one or only closes the array. Other
+				// formats are written as-is.
+				if !firstRecord && (!jsonArray || (*chunk.JSONBuf)[0] == ',' || (*chunk.JSONBuf)[0] == ']') {
+					_, err = writer.Write(*chunk.JSONBuf)
 				} else if !firstRecord {
-					writer.Write([]byte(`,`))
-					writer.Write(*chunk.JSONBuf)
+					if _, err = writer.Write([]byte(`,`)); err == nil {
+						_, err = writer.Write(*chunk.JSONBuf)
+					}
 				} else {
 					c.Status(r.Code)
-					writer.Write(*chunk.JSONBuf)
+					_, err = writer.Write(*chunk.JSONBuf)
 					firstRecord = false
 				}
 
 				jsonBufferPool.Put(chunk.JSONBuf)
 
-				if flusher, ok := writer.(http.Flusher); ok {
-					flusher.Flush()
+				if err != nil {
+					// Client went away: stop consuming so the producer is cancelled
+					if r.Logger != nil {
+						r.Logger.Warn("Stream write failed",
+							zap.String("requestId", c.GetString("requestId")),
+							zap.Error(err),
+						)
+					}
+					stopped = true
+					c.Abort()
+					return
 				}
+
+				// Push the chunk to the client now instead of buffering it
+				writer.Flush()
 			}
 		}
 
+		if r.HasMore != nil && !firstRecord {
+			writer.Header().Set("X-Has-More", strconv.FormatBool(r.HasMore()))
+		}
+
 		if shouldDebug {
 			startTime := getStartTime(c)
 			endTime := time.Now()
@@ -177,6 +297,16 @@ func sendStream(c *gin.Context, shouldDebug bool) func(r StreamResponse) {
 	}
 }
 
+// drainChunks discards the remaining chunks of an abandoned stream and
+// returns their buffers to the pool. It ends when the producer closes the channel.
+func drainChunks(chunkChan <-chan StreamChunk) {
+	for chunk := range chunkChan {
+		if chunk.JSONBuf != nil {
+			jsonBufferPool.Put(chunk.JSONBuf)
+		}
+	}
+}
+
 func ResponseInit() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		shouldDebug := gin.Mode() == gin.DebugMode
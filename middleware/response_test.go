@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// bufferingWriter is a ResponseWriter without http.Flusher, like a writer
+// that buffers the whole body
+type bufferingWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	code   int
+}
+
+func (w *bufferingWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *bufferingWriter) Write(data []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferingWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// flushingWriter records the body written before every Flush
+type flushingWriter struct {
+	bufferingWriter
+	flushed []string
+}
+
+func (w *flushingWriter) Flush() {
+	w.flushed = append(w.flushed, w.body.String())
+}
+
+// streamChunks returns a closed channel of pooled chunks holding parts
+func streamChunks(parts ...string) <-chan StreamChunk {
+	chunks := make(chan StreamChunk, len(parts))
+	for _, part := range parts {
+		buf := jsonBufferPool.Get().(*[]byte)
+		*buf = append((*buf)[:0], part...)
+		chunks <- StreamChunk{JSONBuf: buf}
+	}
+	close(chunks)
+	return chunks
+}
+
+// runStream sends a stream of parts through sendStream on w
+func runStream(w http.ResponseWriter, parts ...string) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/stream", nil)
+	sendStream(c, false)(StreamResponse{ChunkChan: streamChunks(parts...)})
+}
+
+func TestSendStream_FlushesEveryChunk(t *testing.T) {
+	w := &flushingWriter{}
+	runStream(w, `[{"id":1}`, `{"id":2}`, `]`)
+
+	want := []string{`[{"id":1}`, `[{"id":1},{"id":2}`, `[{"id":1},{"id":2}]`}
+	if len(w.flushed) != len(want) {
+		t.Fatalf("Flush called %d times, want once per chunk (%d): %q", len(w.flushed), len(want), w.flushed)
+	}
+	for i := range want {
+		if w.flushed[i] != want[i] {
+			t.Errorf("flush %d: body = %s, want %s", i+1, w.flushed[i], want[i])
+		}
+	}
+	if w.code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.code)
+	}
+}
+
+func TestSendStream_FlushUnsupported(t *testing.T) {
+	w := &bufferingWriter{}
+	runStream(w, `[{"id":1}`, `]`)
+
+	if w.code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.code)
+	}
+
+	var response ResponseAPI
+	if err := json.Unmarshal(w.body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid error body %q: %v", w.body.String(), err)
+	}
+	if response.Message != "Stream failed" {
+		t.Errorf("message = %q, want %q", response.Message, "Stream failed")
+	}
+	if bytes.Contains(w.body.Bytes(), []byte(`"id"`)) {
+		t.Errorf("rows were written to a writer that cannot flush: %s", w.body.String())
+	}
+}
+
+func TestCanFlush(t *testing.T) {
+	if !canFlush(httptest.NewRecorder()) {
+		t.Error("canFlush(httptest.ResponseRecorder) = false, want true")
+	}
+	if canFlush(&bufferingWriter{}) {
+		t.Error("canFlush(bufferingWriter) = true, want false")
+	}
+
+	// gin's writer always implements http.Flusher, so the writer it wraps decides
+	for _, tt := range []struct {
+		inner http.ResponseWriter
+		want  bool
+	}{
+		{&flushingWriter{}, true},
+		{&bufferingWriter{}, false},
+	} {
+		c, _ := gin.CreateTestContext(tt.inner)
+		if got := canFlush(c.Writer); got != tt.want {
+			t.Errorf("canFlush(gin writer over %T) = %v, want %v", tt.inner, got, tt.want)
+		}
+	}
+}
+
+func TestSendStream_IncrementalWithoutContentLength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The first chunk is produced right away, the rest only once the client
+	// has received it: a buffered body would never reach the client
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(RequestInit(), ResponseInit())
+	r.POST("/stream", func(c *gin.Context) {
+		c.Header("Content-Length", "12345") // e.g. set by another middleware
+		chunks := make(chan StreamChunk)
+		go func() {
+			defer close(chunks)
+			for i, part := range []string{`[{"id":1}`, `{"id":2}`, `]`} {
+				if i == 1 {
+					select {
+					case <-release:
+					case <-c.Request.Context().Done():
+						return
+					}
+				}
+				buf := jsonBufferPool.Get().(*[]byte)
+				*buf = append((*buf)[:0], part...)
+				chunks <- StreamChunk{JSONBuf: buf}
+			}
+		}()
+		c.MustGet("sendStream").(func(StreamResponse))(StreamResponse{ChunkChan: chunks})
+	})
+
+	tests := []struct {
+		name   string
+		server func() *httptest.Server
+		proto  int
+	}{
+		{"HTTP/1.1", func() *httptest.Server { return httptest.NewServer(r) }, 1},
+		{"HTTP/2", func() *httptest.Server {
+			server := httptest.NewUnstartedServer(r)
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			return server
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release = make(chan struct{})
+			server := tt.server()
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/stream", nil)
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request failed (body buffered until the end?): %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.ProtoMajor != tt.proto {
+				t.Errorf("protocol = %s, want HTTP/%d", resp.Proto, tt.proto)
+			}
+			if resp.ContentLength != -1 || resp.Header.Get("Content-Length") != "" {
+				t.Errorf("Content-Length = %d (%q), want none", resp.ContentLength, resp.Header.Get("Content-Length"))
+			}
+			if tt.proto == 1 && (len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked") {
+				t.Errorf("Transfer-Encoding = %v, want chunked", resp.TransferEncoding)
+			}
+
+			first := make([]byte, len(`[{"id":1}`))
+			if _, err := io.ReadFull(resp.Body, first); err != nil {
+				t.Fatalf("first chunk not received before the next was produced: %v", err)
+			}
+			close(release)
+
+			rest, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading the rest failed: %v", err)
+			}
+			if body := string(first) + string(rest); body != `[{"id":1},{"id":2}]` {
+				t.Errorf("body = %s", body)
+			}
+		})
+	}
+}
+
+// brokenWriter fails every write, like the connection of a client that went away
+type brokenWriter struct {
+	flushingWriter
+}
+
+func (w *brokenWriter) Write(data []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestSendStream_WriteFailureLogged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.WarnLevel)
+	c, _ := gin.CreateTestContext(&brokenWriter{})
+	c.Request = httptest.NewRequest(http.MethodPost, "/stream", nil)
+	c.Set("requestId", "req-1")
+
+	sendStream(c, false)(StreamResponse{ChunkChan: streamChunks(`[{"id":1}`, `]`), Logger: zap.New(core)})
+
+	entries := logs.FilterMessage("Stream write failed").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d write failures, want 1: %v", len(entries), logs.All())
+	}
+	fields := entries[0].ContextMap()
+	if fields["requestId"] != "req-1" || fields["error"] != io.ErrClosedPipe.Error() {
+		t.Errorf("log fields = %v", fields)
+	}
+}
@@ -3,6 +3,8 @@ package middleware
 import (
 	"sync"
 	"time"
+
+	"go.uber.org/zap"
 )
 
 type Response struct {
@@ -38,6 +40,25 @@ type StreamResponse struct {
 	ChunkChan  <-chan StreamChunk // Channel to receive data chunks
 	Error      error              // Error to return if streaming fails before starting
 	Code       int                // HTTP status code (default 200)
+	HasMore    func() bool        // When set, sent as X-Has-More trailer after ChunkChan is drained
+
+	// Fields are the output field names of every row, when known up front
+	// (sent as the X-Fields header, a JSON array, in preview mode)
+	Fields []string
+
+	// ContentType of a body that is not a JSON array (e.g. "text/csv"). When
+	// set, chunks are written as-is, without separators between them.
+	ContentType string
+
+	// ErrorMarker, when set, returns the bytes ending the body of a stream
+	// that fails after its first chunk (e.g. a final {"_error":"..."} line),
+	// so clients detect the failure from the body alone. The X-Stream-Error
+	// trailer is sent either way.
+	ErrorMarker func(err error) []byte
+
+	// Logger logs the failures sendStream sees itself, such as a write to a
+	// client that went away (nil: not logged)
+	Logger *zap.Logger
 }
 
 var jsonBufferPool = sync.Pool{